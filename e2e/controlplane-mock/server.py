@@ -3,7 +3,8 @@
 E2E Control Plane Mock - License authorization API for testing.
 
 This server mocks the TrustBridge Control Plane/EDC for E2E testing.
-It provides the authorization endpoint that the Sentinel calls during startup.
+It provides the authorization endpoint that the Sentinel calls during startup
+and logs the integrity reports it sends when a delivered asset fails a check.
 
 Usage:
     python server.py [--port PORT]
@@ -13,13 +14,20 @@ Environment Variables:
     E2E_DECRYPTION_KEY: 64-character hex decryption key
     E2E_BLOB_SERVER: Blob server base URL (default: http://blob-server:9000)
     E2E_EXPIRY_SECONDS: SAS URL expiry time in seconds (default: 3600)
+    E2E_KEY_ID: Provider key id reported with the key (default: 0, not reported)
+    E2E_KEY_VERSION: Version of E2E_KEY_ID (default: 0)
 """
 
 import os
 import json
+import base64
 import logging
 from datetime import datetime, timezone, timedelta
 from flask import Flask, request, Response
+from cryptography.hazmat.primitives import hashes
+from cryptography.hazmat.primitives.asymmetric.x25519 import X25519PrivateKey, X25519PublicKey
+from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+from cryptography.hazmat.primitives.kdf.hkdf import HKDF
 
 # Configure logging
 logging.basicConfig(
@@ -36,10 +44,42 @@ PORT = int(os.environ.get("CP_PORT", "8080"))
 DECRYPTION_KEY = os.environ.get("E2E_DECRYPTION_KEY", "")
 BLOB_SERVER = os.environ.get("E2E_BLOB_SERVER", "http://blob-server:9000")
 EXPIRY_SECONDS = int(os.environ.get("E2E_EXPIRY_SECONDS", "3600"))
+KEY_ID = int(os.environ.get("E2E_KEY_ID", "0"))
+KEY_VERSION = int(os.environ.get("E2E_KEY_VERSION", "0"))
 
 # Allowed contract ID for authorization
 ALLOWED_CONTRACT = "contract-allow"
 
+# Envelope scheme matching the sentinel's license.KeyWrapAlgorithm
+KEY_WRAP_ALG = "X25519-HKDF-SHA256-A256GCM"
+KEY_WRAP_INFO = b"trustbridge/key-wrap/v1"
+
+
+def wrap_key(public_key_b64: str, data_key: bytes, contract_id: str, asset_id: str) -> dict:
+    """Seal the data key to the sentinel's ephemeral X25519 public key."""
+    sentinel_pub = base64.b64decode(public_key_b64)
+    ephemeral = X25519PrivateKey.generate()
+    epk = ephemeral.public_key().public_bytes_raw()
+    shared = ephemeral.exchange(X25519PublicKey.from_public_bytes(sentinel_pub))
+
+    wrap_key_bytes = HKDF(
+        algorithm=hashes.SHA256(),
+        length=32,
+        salt=epk + sentinel_pub,
+        info=KEY_WRAP_INFO,
+    ).derive(shared)
+
+    nonce = os.urandom(12)
+    aad = KEY_WRAP_INFO + b"\x00" + contract_id.encode() + b"\x00" + asset_id.encode()
+    ciphertext = AESGCM(wrap_key_bytes).encrypt(nonce, data_key, aad)
+
+    return {
+        "alg": KEY_WRAP_ALG,
+        "epk": base64.b64encode(epk).decode(),
+        "nonce": base64.b64encode(nonce).decode(),
+        "ciphertext": base64.b64encode(ciphertext).decode(),
+    }
+
 
 @app.route("/api/v1/license/authorize", methods=["POST"])
 def authorize() -> Response:
@@ -52,7 +92,11 @@ def authorize() -> Response:
         "asset_id": "tb-asset-123",
         "hw_id": "<hardware-fingerprint>",
         "attestation": "<optional>",
-        "client_version": "sentinel/0.1.0"
+        "client_version": "sentinel/0.1.0",
+        "public_key": "<base64 X25519 public key>",
+        "key_wrap_alg": "X25519-HKDF-SHA256-A256GCM",
+        "key_id": 7,          // optional, from the asset manifest
+        "key_version": 3      // optional
     }
 
     Response (authorized):
@@ -60,10 +104,19 @@ def authorize() -> Response:
         "status": "authorized",
         "sas_url": "http://..../model.tbenc",
         "manifest_url": "http://..../model.manifest.json",
-        "decryption_key_hex": "<64 hex chars>",
+        "wrapped_key": {"alg": "...", "epk": "...", "nonce": "...", "ciphertext": "..."},
+        "key_id": 7,
+        "key_version": 3,
         "expires_at": "2026-01-08T12:00:00Z"
     }
 
+    The mock holds a single key. It is returned whatever key version is
+    requested, with key_id and key_version when E2E_KEY_ID is set, so a
+    request for another version is refused by the sentinel.
+
+    Requests without a public_key receive the legacy "decryption_key_hex" field
+    instead of "wrapped_key".
+
     Response (denied):
     {
         "status": "denied",
@@ -84,10 +137,12 @@ def authorize() -> Response:
         asset_id = data.get("asset_id", "")
         hw_id = data.get("hw_id", "")
         client_version = data.get("client_version", "unknown")
+        public_key = data.get("public_key", "")
+        requested_key = (data.get("key_id", 0), data.get("key_version", 0))
 
         logger.info(
             f"Authorization request: contract={contract_id}, asset={asset_id}, "
-            f"hw_id={hw_id[:16]}..., client={client_version}"
+            f"hw_id={hw_id[:16]}..., client={client_version}, key={requested_key}"
         )
 
         # Check if contract is allowed
@@ -122,9 +177,17 @@ def authorize() -> Response:
             "status": "authorized",
             "sas_url": f"{BLOB_SERVER}/artifacts/model.tbenc",
             "manifest_url": f"{BLOB_SERVER}/artifacts/model.manifest.json",
-            "decryption_key_hex": DECRYPTION_KEY,
             "expires_at": expires_at.strftime("%Y-%m-%dT%H:%M:%SZ"),
         }
+        if public_key:
+            response_data["wrapped_key"] = wrap_key(
+                public_key, bytes.fromhex(DECRYPTION_KEY), contract_id, asset_id
+            )
+        else:
+            response_data["decryption_key_hex"] = DECRYPTION_KEY
+        if KEY_ID:
+            response_data["key_id"] = KEY_ID
+            response_data["key_version"] = KEY_VERSION
 
         logger.info(
             f"Authorization granted: asset={asset_id}, expires={response_data['expires_at']}"
@@ -145,6 +208,48 @@ def authorize() -> Response:
         )
 
 
+@app.route("/api/v1/license/report", methods=["POST"])
+def report() -> Response:
+    """
+    Integrity report endpoint.
+
+    Request Body:
+    {
+        "contract_id": "contract-123",
+        "asset_id": "tb-asset-123",
+        "hw_id": "<hardware-fingerprint>",
+        "client_version": "sentinel/0.1.0",
+        "kind": "plaintext_hash_mismatch",
+        "expected": "<sha256_plaintext from the manifest>",
+        "actual": "<sha256 of the decrypted plaintext>",
+        "key_id": 7,          // optional
+        "key_version": 3,     // optional
+        "detail": "<error message>",
+        "detected_at": "2026-01-08T12:00:00Z"
+    }
+
+    The mock only logs the report and returns 202.
+    """
+    data = request.get_json(silent=True)
+    if not data:
+        return Response(
+            json.dumps({"status": "error", "reason": "invalid_request"}),
+            status=400,
+            mimetype="application/json",
+        )
+
+    logger.warning(
+        f"Integrity report: kind={data.get('kind')}, contract={data.get('contract_id')}, "
+        f"asset={data.get('asset_id')}, expected={data.get('expected')}, "
+        f"actual={data.get('actual')}, detail={data.get('detail')}"
+    )
+    return Response(
+        json.dumps({"status": "received"}),
+        status=202,
+        mimetype="application/json",
+    )
+
+
 @app.route("/health", methods=["GET"])
 def health() -> Response:
     """Health check endpoint."""
@@ -163,6 +268,7 @@ def index() -> Response:
             "version": "1.0.0",
             "endpoints": [
                 "POST /api/v1/license/authorize",
+                "POST /api/v1/license/report",
                 "GET /health",
             ],
             "config": {
@@ -171,8 +171,8 @@ class TestE2EHappyPath:
             parts = line.split()
             if len(parts) >= 9:
                 filename = parts[-1]
-                # Allow only .tbenc and .json files
-                assert filename.endswith((".tbenc", ".json", "manifest.json")), \
+                # Allow only .tbenc and .json files, and partial .tbenc downloads
+                assert filename.endswith((".tbenc", ".json", "manifest.json", ".tbenc.part")), \
                     f"Unexpected file in TB_TARGET_DIR: {filename}\n" \
                     f"Full listing:\n{stdout}"
 
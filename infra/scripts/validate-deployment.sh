@@ -198,7 +198,7 @@ if [ "$FILES_IN_TARGET" = "DIR_NOT_FOUND" ]; then
     log_info "Target directory not yet created (sentinel may still be initializing)"
 else
     # Check for plaintext files (anything without .tbenc extension)
-    PLAINTEXT_FILES=$(run_ssh "find $TB_TARGET_DIR -type f ! -name '*.tbenc' ! -name '*.manifest.json' ! -name '*.json' 2>/dev/null" || echo "")
+    PLAINTEXT_FILES=$(run_ssh "find $TB_TARGET_DIR -type f ! -name '*.tbenc' ! -name '*.tbenc.part' ! -name '*.manifest.json' ! -name '*.json' 2>/dev/null" || echo "")
     if [ -z "$PLAINTEXT_FILES" ]; then
         log_pass "No plaintext files found in $TB_TARGET_DIR"
     else
@@ -1,5 +1,7 @@
 #!/bin/bash
-# Test crypto interoperability between Python encryption and Go decryption
+# Test crypto interoperability between Python and Go in both directions:
+#   Python encryption -> Go decryption
+#   Go encryption (cmd/tbenc, v1 and v2) -> Python decryption
 
 set -e
 
@@ -80,6 +82,48 @@ else
     exit 1
 fi
 
+echo ""
+
+# Encrypt with Go (tbenc/v1 and tbenc/v2)
+echo "6. Encrypting with Go (cmd/tbenc)..."
+cd "$ROOT_DIR/src/sentinel"
+go build -o "$TEST_DIR/tbenc" ./cmd/tbenc
+for FORMAT in v1 v2; do
+    "$TEST_DIR/tbenc" encrypt -in "$PLAINTEXT_FILE" -out "$TEST_DIR/go-$FORMAT.tbenc" \
+        -key "$KEY_HEX" -format "$FORMAT" -chunk-bytes 1024 -asset-id interop-test | sed 's/^/   /'
+    "$TEST_DIR/tbenc" verify -in "$TEST_DIR/go-$FORMAT.tbenc" -key "$KEY_HEX" \
+        -manifest "$TEST_DIR/go-$FORMAT.manifest.json" | sed 's/^/   /'
+done
+echo ""
+
+# Decrypt with Python
+echo "7. Decrypting Go output with Python..."
+cd "$ROOT_DIR/src/cli"
+for FORMAT in v1 v2; do
+    python3 << EOF
+from pathlib import Path
+from trustbridge_cli.crypto_tbenc import decrypt_file
+
+key = bytes.fromhex("$KEY_HEX")
+written = decrypt_file(
+    Path("$TEST_DIR/go-$FORMAT.tbenc"),
+    Path("$TEST_DIR/go-$FORMAT.plain"),
+    key
+)
+print(f"   $FORMAT: decrypted {written} bytes")
+EOF
+
+    ACTUAL_HASH=$(shasum -a 256 "$TEST_DIR/go-$FORMAT.plain" | awk '{print $1}')
+    if [ "$EXPECTED_HASH" == "$ACTUAL_HASH" ]; then
+        echo "   ✓ Go encryption ($FORMAT) → Python decryption: WORKING"
+    else
+        echo "   ✗ FAILURE: Hash mismatch for $FORMAT!"
+        echo "   Expected: $EXPECTED_HASH"
+        echo "   Actual:   $ACTUAL_HASH"
+        exit 1
+    fi
+done
+
 echo ""
 echo "=== Crypto Interop Test PASSED ==="
 echo ""
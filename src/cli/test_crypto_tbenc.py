@@ -16,6 +16,7 @@ from trustbridge_cli.crypto_tbenc import (
     HEADER_SIZE,
     generate_key,
     encrypt_file,
+    decrypt_file,
     write_manifest,
     encrypt_and_generate_manifest,
 )
@@ -232,3 +233,109 @@ def test_known_test_vector():
         print(f"Ciphertext size: {output_path.stat().st_size}")
 
         assert plaintext_size == len(plaintext)
+
+
+def test_decrypt_round_trip():
+    """Test that decrypt_file recovers plaintext across multiple chunks."""
+    with tempfile.TemporaryDirectory() as tmpdir:
+        tmpdir = Path(tmpdir)
+
+        plaintext = bytes(range(256)) * 20  # 5120 bytes, 5 full chunks + partial
+        input_path = tmpdir / "plain.bin"
+        input_path.write_bytes(plaintext)
+
+        key = generate_key()
+        encrypted_path = tmpdir / "enc.tbenc"
+        encrypt_file(input_path, encrypted_path, key, chunk_bytes=1024)
+
+        output_path = tmpdir / "dec.bin"
+        written = decrypt_file(encrypted_path, output_path, key)
+
+        assert written == len(plaintext)
+        assert output_path.read_bytes() == plaintext
+
+
+def test_decrypt_wrong_key():
+    """Test that decryption with the wrong key fails authentication."""
+    with tempfile.TemporaryDirectory() as tmpdir:
+        tmpdir = Path(tmpdir)
+
+        input_path = tmpdir / "plain.txt"
+        input_path.write_bytes(b"SECRET" * 100)
+
+        encrypted_path = tmpdir / "enc.tbenc"
+        encrypt_file(input_path, encrypted_path, generate_key(), chunk_bytes=1024)
+
+        with pytest.raises(ValueError, match="chunk 0"):
+            decrypt_file(encrypted_path, tmpdir / "dec.bin", generate_key())
+
+
+def test_decrypt_tampered_chunk():
+    """Test that a modified ciphertext byte is detected."""
+    with tempfile.TemporaryDirectory() as tmpdir:
+        tmpdir = Path(tmpdir)
+
+        input_path = tmpdir / "plain.txt"
+        input_path.write_bytes(b"A" * 3000)
+
+        key = generate_key()
+        encrypted_path = tmpdir / "enc.tbenc"
+        encrypt_file(input_path, encrypted_path, key, chunk_bytes=1024)
+
+        data = bytearray(encrypted_path.read_bytes())
+        data[HEADER_SIZE + (4 + 1024 + 16) + 10] ^= 0x01  # inside chunk 1
+        encrypted_path.write_bytes(bytes(data))
+
+        with pytest.raises(ValueError, match="chunk 1"):
+            decrypt_file(encrypted_path, tmpdir / "dec.bin", key)
+
+
+def test_decrypt_go_v2_vector():
+    """Test decryption of a tbenc/v2 vector produced by the Go encryptor."""
+    # Produced by crypto.EncryptToWriter with nonce prefix 01020304 (testing only!)
+    vector = bytes.fromhex(
+        "5442454e433030310002010000040001020304000000000000001b0000000000"
+        "0000001bc1251786d7795ce6600fe511411200b7574f1c3689bd915dd2146e50"
+        "61a475f6d48f08b0237f5fe3393e7e"
+    )
+    key = bytes.fromhex("0123456789abcdef" * 4)
+
+    with tempfile.TemporaryDirectory() as tmpdir:
+        tmpdir = Path(tmpdir)
+
+        encrypted_path = tmpdir / "vector.tbenc"
+        encrypted_path.write_bytes(vector)
+
+        output_path = tmpdir / "dec.txt"
+        decrypt_file(encrypted_path, output_path, key)
+        assert output_path.read_bytes() == b"TrustBridge-Test-Vector-123"
+
+        # Dropping the final record must be detected as truncation
+        encrypted_path.write_bytes(vector[:HEADER_SIZE])
+        with pytest.raises(ValueError, match="Truncated"):
+            decrypt_file(encrypted_path, output_path, key)
+
+        # Appending data after the final record must be rejected
+        encrypted_path.write_bytes(vector + b"\x00")
+        with pytest.raises(ValueError, match="Trailing"):
+            decrypt_file(encrypted_path, output_path, key)
+
+
+def test_decrypt_v1_zero_pt_len_vector():
+    """Test that an authentic tbenc/v1 record with pt_len 0 is rejected."""
+    # Header, pt_len 0 and a valid tag for the empty chunk; the Go reader
+    # rejects the same vector (TestDecrypt_V1ZeroPtLenVector)
+    vector = bytes.fromhex(
+        "5442454e43303031000101000004000102030400000000000000000000000000"
+        "00000000c3d875132e772cfe85c231e9099b48b4"
+    )
+    key = bytes.fromhex("0123456789abcdef" * 4)
+
+    with tempfile.TemporaryDirectory() as tmpdir:
+        tmpdir = Path(tmpdir)
+
+        encrypted_path = tmpdir / "vector.tbenc"
+        encrypted_path.write_bytes(vector)
+
+        with pytest.raises(ValueError, match="Invalid pt_len 0"):
+            decrypt_file(encrypted_path, tmpdir / "dec.bin", key)
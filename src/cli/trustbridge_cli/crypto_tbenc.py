@@ -28,12 +28,14 @@ import struct
 from pathlib import Path
 from typing import BinaryIO, Dict, Tuple
 
+from cryptography.exceptions import InvalidTag
 from cryptography.hazmat.primitives.ciphers.aead import AESGCM
 
 
 # Constants
 MAGIC = b"TBENC001"
 VERSION = 1
+VERSION_V2 = 2
 ALGO_AES_GCM_CHUNKED = 1
 HEADER_SIZE = 32
 NONCE_PREFIX_SIZE = 4
@@ -192,6 +194,109 @@ def encrypt_file(
     return ciphertext_sha256, plaintext_size
 
 
+def decrypt_file(
+    input_path: Path,
+    output_path: Path,
+    key: bytes
+) -> int:
+    """
+    Decrypt a tbenc/v1 or tbenc/v2 file.
+
+    tbenc/v2 files store the plaintext length in the first 8 reserved header
+    bytes and extend the AAD with plaintext_bytes (uint64) and a final-chunk
+    flag (uint8), so truncation and trailing data are detected.
+
+    Args:
+        input_path: Path to encrypted input file
+        output_path: Path to write decrypted plaintext
+        key: 32-byte AES-256 key
+
+    Returns:
+        Number of plaintext bytes written
+
+    Raises:
+        ValueError: If the file is malformed, truncated or fails authentication
+    """
+    if len(key) != 32:
+        raise ValueError("Key must be 32 bytes for AES-256")
+
+    aesgcm = AESGCM(key)
+    written = 0
+
+    with open(input_path, "rb") as fin, open(output_path, "wb") as fout:
+        header = fin.read(HEADER_SIZE)
+        if len(header) != HEADER_SIZE:
+            raise ValueError("File too short for tbenc header")
+
+        magic = header[0:8]
+        version, algo, chunk_bytes = struct.unpack_from(">HBI", header, 8)
+        nonce_prefix = header[15:19]
+
+        if magic != MAGIC:
+            raise ValueError(f"Invalid magic: {magic!r}")
+        if version not in (VERSION, VERSION_V2):
+            raise ValueError(f"Unsupported version: {version}")
+        if algo != ALGO_AES_GCM_CHUNKED:
+            raise ValueError(f"Unsupported algorithm: {algo}")
+        if chunk_bytes == 0 or chunk_bytes > 64 * 1024 * 1024:
+            raise ValueError(f"Invalid chunk_bytes: {chunk_bytes}")
+
+        plaintext_bytes = 0
+        chunk_count = None
+        if version == VERSION_V2:
+            (plaintext_bytes,) = struct.unpack_from(">Q", header, 19)
+            # Empty v2 plaintext is encoded as a single empty final record
+            chunk_count = max(1, -(-plaintext_bytes // chunk_bytes))
+
+        chunk_index = 0
+        while True:
+            record_header = fin.read(4)
+            if chunk_count is not None and chunk_index == chunk_count:
+                if record_header:
+                    raise ValueError("Trailing data after final chunk")
+                break
+            if not record_header:
+                if chunk_count is not None:
+                    raise ValueError(f"Truncated stream at chunk {chunk_index}")
+                break
+            if len(record_header) != 4:
+                raise ValueError(f"Truncated record header at chunk {chunk_index}")
+
+            (pt_len,) = struct.unpack(">I", record_header)
+            # Only an empty v2 plaintext has an empty record; v1 has none
+            if pt_len > chunk_bytes or (version == VERSION and pt_len == 0):
+                raise ValueError(f"Invalid pt_len {pt_len} at chunk {chunk_index}")
+
+            ciphertext_with_tag = fin.read(pt_len + TAG_SIZE)
+            if len(ciphertext_with_tag) != pt_len + TAG_SIZE:
+                raise ValueError(f"Truncated ciphertext at chunk {chunk_index}")
+
+            aad = _build_aad(
+                magic,
+                version,
+                algo,
+                chunk_bytes,
+                nonce_prefix,
+                chunk_index,
+                pt_len
+            )
+            if version == VERSION_V2:
+                final = 1 if chunk_index == chunk_count - 1 else 0
+                aad += struct.pack(">QB", plaintext_bytes, final)
+
+            nonce = _derive_nonce(nonce_prefix, chunk_index)
+            try:
+                plaintext_chunk = aesgcm.decrypt(nonce, ciphertext_with_tag, aad)
+            except InvalidTag:
+                raise ValueError(f"Decryption failed at chunk {chunk_index}") from None
+
+            fout.write(plaintext_chunk)
+            written += len(plaintext_chunk)
+            chunk_index += 1
+
+    return written
+
+
 def write_manifest(
     manifest_path: Path,
     asset_id: str,
@@ -11,13 +11,18 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -25,9 +30,11 @@ import (
 	"trustbridge/sentinel/internal/billing"
 	"trustbridge/sentinel/internal/config"
 	"trustbridge/sentinel/internal/crypto"
+	"trustbridge/sentinel/internal/handoff"
 	"trustbridge/sentinel/internal/health"
 	"trustbridge/sentinel/internal/license"
 	"trustbridge/sentinel/internal/proxy"
+	"trustbridge/sentinel/internal/rangeserver"
 	"trustbridge/sentinel/internal/state"
 )
 
@@ -100,8 +107,14 @@ func run(logger *slog.Logger) error {
 	stateMachine.SetAssetID(cfg.AssetID)
 	logger.Info("Configuration loaded", "config", cfg.String())
 
+	// FIFO delivery sessions, reported in /status
+	fifoSessions := crypto.NewSessionTracker()
+
 	// Start health server immediately (returns 503 until Ready)
-	healthServer := health.NewServer(stateMachine, health.WithAddr(cfg.HealthAddr))
+	healthServer := health.NewServer(stateMachine,
+		health.WithAddr(cfg.HealthAddr),
+		health.WithSessionProvider(sessionStatuses(fifoSessions)),
+	)
 	if err := healthServer.Start(); err != nil {
 		logger.Warn("Failed to start health server", "error", err.Error())
 	} else {
@@ -119,55 +132,95 @@ func run(logger *slog.Logger) error {
 	}
 	logger.Info("Phase: Authorize - Calling Control Plane")
 
-	authResp, err := authorize(ctx, cfg, logger)
+	authResp, manifest, err := authorize(ctx, cfg, logger)
 	if err != nil {
 		stateMachine.Suspend(fmt.Sprintf("authorization failed: %v", err))
 		return fmt.Errorf("authorize failed: %w", err)
 	}
 	logger.Info("Authorization successful",
 		"expires_at", authResp.ExpiresAt.Format(time.RFC3339),
+		"key", authResp.KeyRef().String(),
 	)
 
+	// run owns the key until decryption starts; decryptFiles then destroys it
+	// as soon as every file has been decrypted.
+	decryptionKey := authResp.DecryptionKey
+	keyHandedOff := false
+	defer func() {
+		if !keyHandedOff {
+			decryptionKey.Destroy()
+		}
+	}()
+
 	// PHASE: Hydrate - Download and verify assets
 	if err := stateMachine.Transition(state.StateHydrate); err != nil {
 		return fmt.Errorf("failed to transition to Hydrate: %w", err)
 	}
-	logger.Info("Phase: Hydrate - Downloading assets")
+	logger.Info("Phase: Hydrate - Downloading assets", "mode", cfg.HydrateMode)
 
-	manifest, encryptedPath, err := hydrate(ctx, cfg, authResp, logger)
+	var files []*modelFile
+	if cfg.HydrateMode == config.HydrateModeStream {
+		files, err = hydrateStream(ctx, cfg, authResp, manifest, logger)
+	} else {
+		files, err = hydrate(ctx, cfg, authResp, manifest, logger)
+	}
 	if err != nil {
 		stateMachine.Suspend(fmt.Sprintf("hydration failed: %v", err))
 		return fmt.Errorf("hydrate failed: %w", err)
 	}
+	defer closeStreams(files)
 	logger.Info("Hydration complete",
-		"encrypted_path", encryptedPath,
-		"plaintext_bytes", manifest.PlaintextBytes,
+		"files", len(files),
+		"plaintext_bytes", manifest.TotalPlaintextBytes(),
 	)
 
-	// PHASE: Decrypt - Create FIFO and start decryption
+	// PHASE: Decrypt - Create FIFOs and start decryption
 	if err := stateMachine.Transition(state.StateDecrypt); err != nil {
 		return fmt.Errorf("failed to transition to Decrypt: %w", err)
 	}
 	logger.Info("Phase: Decrypt - Starting decryption to FIFO")
 
-	decryptionKey, err := hex.DecodeString(authResp.DecryptionKeyHex)
+	modelDir := planLayout(cfg, manifest, files)
+
+	// Start async decryption to FIFOs, decrypt to memfds and hand them off,
+	// or serve ranges decrypted on demand
+	keyHandedOff = true
+	var decryptResultCh <-chan crypto.StreamResult
+	switch cfg.DeliveryMode {
+	case config.DeliveryModeMemfd:
+		var stopHandoff func()
+		stopHandoff, decryptResultCh, err = handOff(ctx, cfg, files, decryptionKey, logger)
+		if err == nil {
+			defer stopHandoff()
+		}
+	case config.DeliveryModeHTTP:
+		var stopRanges func()
+		stopRanges, decryptResultCh, err = serveRanges(cfg, files, decryptionKey, logger)
+		if err == nil {
+			defer stopRanges()
+		}
+	default:
+		decryptResultCh, err = decryptFiles(ctx, cfg, files, decryptionKey, fifoSessions, logger)
+	}
 	if err != nil {
-		stateMachine.Suspend(fmt.Sprintf("invalid decryption key: %v", err))
-		return fmt.Errorf("invalid decryption key: %w", err)
-	}
-
-	// Start async decryption to FIFO
-	decryptResultCh := crypto.DecryptToFIFO(
-		ctx,
-		encryptedPath,
-		cfg.PipePath,
-		decryptionKey,
-		crypto.WithLogger(logger),
-		crypto.WithTotalBytes(manifest.PlaintextBytes),
-	)
+		reportIntegrityFailure(cfg, authResp, err, logger)
+		stateMachine.Suspend(deliveryFailureReason(err))
+		return fmt.Errorf("decryption failed: %w", err)
+	}
 
 	// Write ready signal for runtime
-	if err := crypto.WriteReadySignal(cfg.ReadySignal); err != nil {
+	readyFiles := make([]crypto.ReadyFile, len(files))
+	for i, f := range files {
+		readyFiles[i] = f.ready
+	}
+	readySignal := crypto.ReadySignal{ModelDir: modelDir, Files: readyFiles}
+	if cfg.DeliveryMode == config.DeliveryModeHTTP {
+		readySignal.TokenFile = rangeTokenPath(cfg)
+		if socket, ok := strings.CutPrefix(cfg.RangeAddr, rangeserver.UnixPrefix); ok {
+			readySignal.HTTPSocket = socket
+		}
+	}
+	if err := crypto.WriteReadySignalData(cfg.ReadySignal, readySignal); err != nil {
 		stateMachine.Suspend(fmt.Sprintf("failed to write ready signal: %v", err))
 		return fmt.Errorf("failed to write ready signal: %w", err)
 	}
@@ -177,10 +230,31 @@ func run(logger *slog.Logger) error {
 	if err := stateMachine.Transition(state.StateReady); err != nil {
 		return fmt.Errorf("failed to transition to Ready: %w", err)
 	}
-	logger.Info("Phase: Ready - Sentinel is ready",
-		"health_endpoint", fmt.Sprintf("http://%s/health", cfg.HealthAddr),
-		"fifo_path", cfg.PipePath,
-	)
+	if cfg.DeliveryMode == config.DeliveryModeMemfd {
+		logger.Info("Phase: Ready - Sentinel is ready",
+			"health_endpoint", fmt.Sprintf("http://%s/health", cfg.HealthAddr),
+			"handoff_socket", cfg.HandoffSocket,
+			"files", len(files),
+		)
+	} else if cfg.DeliveryMode == config.DeliveryModeHTTP {
+		logger.Info("Phase: Ready - Sentinel is ready",
+			"health_endpoint", fmt.Sprintf("http://%s/health", cfg.HealthAddr),
+			"range_addr", cfg.RangeAddr,
+			"token_file", rangeTokenPath(cfg),
+			"files", len(files),
+		)
+	} else if modelDir != "" {
+		logger.Info("Phase: Ready - Sentinel is ready",
+			"health_endpoint", fmt.Sprintf("http://%s/health", cfg.HealthAddr),
+			"model_dir", modelDir,
+			"files", len(files),
+		)
+	} else {
+		logger.Info("Phase: Ready - Sentinel is ready",
+			"health_endpoint", fmt.Sprintf("http://%s/health", cfg.HealthAddr),
+			"fifo_path", cfg.PipePath,
+		)
+	}
 
 	// Create billing components if enabled
 	var billingCounter *billing.Counter
@@ -292,7 +366,9 @@ func run(logger *slog.Logger) error {
 		// Wait a bit for decryption to finish gracefully
 		select {
 		case result := <-decryptResultCh:
-			if result.Err != nil {
+			if errors.Is(result.Err, context.Canceled) {
+				logger.Info("FIFO serving stopped", "bytes_written", result.BytesWritten)
+			} else if result.Err != nil {
 				logger.Error("Decryption failed during shutdown", "error", result.Err.Error())
 			} else {
 				logger.Info("Decryption completed", "bytes_written", result.BytesWritten)
@@ -304,7 +380,10 @@ func run(logger *slog.Logger) error {
 
 	case result := <-decryptResultCh:
 		if result.Err != nil {
-			stateMachine.Suspend(fmt.Sprintf("decryption failed: %v", result.Err))
+			// The runtime may already hold the mismatching plaintext; suspending
+			// stops the proxy from serving it
+			reportIntegrityFailure(cfg, authResp, result.Err, logger)
+			stateMachine.Suspend(deliveryFailureReason(result.Err))
 			return fmt.Errorf("decryption failed: %w", result.Err)
 		}
 		logger.Info("Decryption completed successfully",
@@ -318,6 +397,19 @@ func run(logger *slog.Logger) error {
 	}
 }
 
+// deliveryFailureReason returns the suspend reason for a failed decryption,
+// naming the runtime as the cause when it stopped reading or never connected.
+func deliveryFailureReason(err error) string {
+	switch {
+	case errors.Is(err, crypto.ErrReaderStalled):
+		return fmt.Sprintf("runtime stopped reading model weights: %v", err)
+	case errors.Is(err, crypto.ErrNoReader):
+		return fmt.Sprintf("runtime never opened the model FIFO: %v", err)
+	default:
+		return fmt.Sprintf("decryption failed: %v", err)
+	}
+}
+
 // loadConfig loads and validates configuration from environment variables.
 func loadConfig(logger *slog.Logger) (*config.Config, error) {
 	cfg, err := config.Load()
@@ -338,13 +430,15 @@ func loadConfig(logger *slog.Logger) (*config.Config, error) {
 	return cfg, nil
 }
 
-// authorize calls the Control Plane to request authorization.
-func authorize(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*license.AuthResponse, error) {
+// authorize calls the Control Plane to request authorization and downloads
+// the manifest. If the manifest names a key version other than the one the
+// Control Plane returned, authorization is requested again for that version.
+func authorize(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*license.AuthResponse, *asset.Manifest, error) {
 	// Generate hardware fingerprint
 	logger.Info("Generating hardware fingerprint")
 	fingerprint, err := license.GenerateHardwareFingerprintWithSource()
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate hardware fingerprint: %w", err)
+		return nil, nil, fmt.Errorf("failed to generate hardware fingerprint: %w", err)
 	}
 	logger.Info("Hardware fingerprint generated",
 		"source", string(fingerprint.Source),
@@ -352,10 +446,10 @@ func authorize(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*l
 	)
 
 	// Create license client
-	client := license.NewLicenseClient(
-		cfg.EDCEndpoint,
-		license.WithClientVersion(fmt.Sprintf("sentinel/%s", Version)),
-	)
+	if cfg.AllowPlainKey {
+		logger.Warn("Legacy plain key delivery enabled; the decryption key may be sent unwrapped")
+	}
+	client := newLicenseClient(cfg)
 
 	// Authorize
 	logger.Info("Calling Control Plane for authorization",
@@ -366,62 +460,904 @@ func authorize(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*l
 
 	resp, err := client.Authorize(ctx, cfg.ContractID, cfg.AssetID, fingerprint.ID)
 	if err != nil {
-		return nil, fmt.Errorf("authorization request failed: %w", err)
+		return nil, nil, fmt.Errorf("authorization request failed: %w", err)
+	}
+
+	manifest, err := downloadManifest(ctx, resp, logger)
+	if err != nil {
+		resp.DecryptionKey.Destroy()
+		return nil, nil, err
 	}
 
-	return resp, nil
+	// A Control Plane that does not report the key version cannot be asked
+	// for another; the headers are then checked against nothing
+	want := manifest.KeyRef()
+	if want.IsZero() || resp.KeyRef().IsZero() || resp.KeyRef() == want {
+		return resp, manifest, nil
+	}
+
+	logger.Info("Requesting the key version named by the manifest",
+		"manifest_key", want.String(),
+		"returned_key", resp.KeyRef().String(),
+	)
+	resp.DecryptionKey.Destroy()
+	resp, err = client.AuthorizeForKey(ctx, cfg.ContractID, cfg.AssetID, fingerprint.ID, want)
+	if err != nil {
+		return nil, nil, fmt.Errorf("authorization request for %s failed: %w", want, err)
+	}
+
+	return resp, manifest, nil
+}
+
+// newLicenseClient creates the Control Plane client from configuration.
+func newLicenseClient(cfg *config.Config) *license.LicenseClient {
+	return license.NewLicenseClient(
+		cfg.EDCEndpoint,
+		license.WithClientVersion(fmt.Sprintf("sentinel/%s", Version)),
+		license.WithPlainKeyAllowed(cfg.AllowPlainKey),
+	)
 }
 
-// hydrate downloads the manifest and encrypted asset, then verifies integrity.
-func hydrate(ctx context.Context, cfg *config.Config, authResp *license.AuthResponse, logger *slog.Logger) (*asset.Manifest, string, error) {
-	// Download manifest
+// reportIntegrityFailure reports a plaintext hash mismatch to the Control
+// Plane. Other errors are not reported. Reporting is best effort: a failure
+// is logged and the sentinel suspends either way.
+func reportIntegrityFailure(cfg *config.Config, authResp *license.AuthResponse, err error, logger *slog.Logger) {
+	var hashErr *crypto.PlaintextHashError
+	if !errors.As(err, &hashErr) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	report := &license.IntegrityReport{
+		ContractID: cfg.ContractID,
+		AssetID:    cfg.AssetID,
+		Kind:       license.ReportPlaintextHashMismatch,
+		Expected:   hashErr.Expected,
+		Actual:     hashErr.Actual,
+		KeyID:      authResp.KeyID,
+		KeyVersion: authResp.KeyVersion,
+		Detail:     err.Error(),
+	}
+	if fingerprint, fpErr := license.GenerateHardwareFingerprintWithSource(); fpErr == nil {
+		report.HardwareID = fingerprint.ID
+	}
+
+	if reportErr := newLicenseClient(cfg).ReportIntegrityFailure(ctx, report); reportErr != nil {
+		logger.Error("Failed to report integrity failure", "error", reportErr.Error())
+		return
+	}
+	logger.Info("Integrity failure reported to Control Plane", "kind", report.Kind)
+}
+
+// downloadManifest downloads and validates the asset manifest.
+func downloadManifest(ctx context.Context, authResp *license.AuthResponse, logger *slog.Logger) (*asset.Manifest, error) {
 	logger.Info("Downloading manifest", "url_prefix", truncateURL(authResp.ManifestUrl))
 	manifest, err := asset.DownloadManifest(ctx, authResp.ManifestUrl)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to download manifest: %w", err)
+		return nil, fmt.Errorf("failed to download manifest: %w", err)
 	}
 	logger.Info("Manifest downloaded and validated",
 		"asset_id", manifest.AssetID,
-		"plaintext_bytes", manifest.PlaintextBytes,
-		"chunk_bytes", manifest.ChunkBytes,
+		"files", len(manifest.Entries()),
+		"plaintext_bytes", manifest.TotalPlaintextBytes(),
 	)
+	return manifest, nil
+}
 
-	// Prepare download path
-	encryptedPath := filepath.Join(cfg.TargetDir, manifest.WeightsFilename)
-	logger.Info("Downloading encrypted asset",
-		"url_prefix", truncateURL(authResp.SASUrl),
-		"target", encryptedPath,
-	)
+// newDownloader creates the asset downloader from configuration. Expired
+// SAS URLs are replaced through refresh. All of its downloads share one
+// bandwidth limit.
+func newDownloader(cfg *config.Config, refresh asset.URLRefreshFunc, logger *slog.Logger) *asset.Downloader {
+	// Validated by config.Load
+	schedule, _ := asset.ParseBandwidthSchedule(cfg.DownloadBandwidthSchedule)
+	var limiter *asset.BandwidthLimiter
+	if cfg.DownloadMaxBytesPerSec > 0 || len(schedule) > 0 {
+		limiter = asset.NewBandwidthLimiter(int64(cfg.DownloadMaxBytesPerSec), asset.WithBandwidthSchedule(schedule))
+	}
 
-	// Download encrypted asset with concurrency
-	downloader := asset.NewDownloader(
+	return asset.NewDownloader(
 		asset.WithConcurrency(cfg.DownloadConcurrency),
+		asset.WithAdaptiveConcurrency(cfg.DownloadMaxConcurrency),
 		asset.WithChunkBytes(cfg.DownloadChunkBytes),
+		asset.WithResume(cfg.DownloadResume),
+		asset.WithURLRefresh(refresh, cfg.DownloadMaxURLRefreshes),
+		asset.WithBandwidthLimiter(limiter),
 		asset.WithProgressCallback(func(downloaded, total int64) {
 			// Progress is logged by the downloader
 		}),
+		asset.WithStatsCallback(func(s asset.DownloadStats) {
+			logger.Debug("Download stats",
+				"downloaded_bytes", s.Downloaded,
+				"total_bytes", s.Total,
+				"concurrency", s.Concurrency,
+				"bytes_per_sec", int64(s.Throughput),
+				"worker_bytes_per_sec", s.Workers,
+			)
+		}),
 	)
+}
 
-	expectedSize := manifest.CiphertextSize()
-	result, err := downloader.DownloadFileConcurrent(ctx, authResp.SASUrl, encryptedPath, expectedSize)
+// modelFile is one encrypted file of the asset and where its plaintext goes.
+type modelFile struct {
+	entry         asset.ManifestFile
+	url           string
+	encryptedPath string           // Downloaded ciphertext (disk mode)
+	stream        *assetStream     // Ciphertext being downloaded (stream mode)
+	ready         crypto.ReadyFile // Output listed in the ready signal
+	key           crypto.KeyRef    // Key the header must name, if any
+	cached        bool             // Chunks authenticated under the key by an earlier run
+}
+
+// modelFiles lists the manifest entries with their download URLs. Entries of
+// a multi-file manifest are resolved next to the asset SAS URL.
+func modelFiles(manifest *asset.Manifest, authResp *license.AuthResponse) ([]*modelFile, error) {
+	entries := manifest.Entries()
+	files := make([]*modelFile, len(entries))
+	for i, entry := range entries {
+		// One authorization returns one key
+		if manifest.FileKeyRef(entry) != manifest.KeyRef() {
+			return nil, fmt.Errorf("%w: %s is under key %s, the manifest under %s (key rotation incomplete)",
+				crypto.ErrWrongKeyVersion, entry.Name, manifest.FileKeyRef(entry), manifest.KeyRef())
+		}
+
+		url := authResp.SASUrl
+		if manifest.IsMultiFile() {
+			var err error
+			if url, err = asset.ResolveFileURL(authResp.SASUrl, entry.Filename); err != nil {
+				return nil, fmt.Errorf("failed to resolve URL for %s: %w", entry.Name, err)
+			}
+		}
+		files[i] = &modelFile{entry: entry, url: url, key: authResp.KeyRef()}
+	}
+	return files, nil
+}
+
+// sasRefresher re-authorizes with the Control Plane when a download URL
+// expires. Expiries of several files share one authorization: a file whose
+// URL was already replaced gets the new URL without another request.
+type sasRefresher struct {
+	cfg      *config.Config
+	manifest *asset.Manifest
+	key      crypto.KeyRef // Key version of the original authorization
+	logger   *slog.Logger
+
+	mu   sync.Mutex
+	urls map[string]string // Current URL by blob path
+}
+
+// newSASRefresher returns a refresher for the URLs of files.
+func newSASRefresher(cfg *config.Config, authResp *license.AuthResponse, manifest *asset.Manifest, files []*modelFile, logger *slog.Logger) *sasRefresher {
+	r := &sasRefresher{
+		cfg:      cfg,
+		manifest: manifest,
+		key:      authResp.KeyRef(),
+		logger:   logger,
+		urls:     make(map[string]string, len(files)),
+	}
+	for _, f := range files {
+		r.urls[blobPath(f.url)] = f.url
+	}
+	return r
+}
+
+// Refresh implements asset.URLRefreshFunc.
+func (r *sasRefresher) Refresh(ctx context.Context, expired string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := blobPath(expired)
+	current, ok := r.urls[path]
+	if !ok {
+		return "", fmt.Errorf("no file of the asset at %s", truncateURL(expired))
+	}
+	if current != expired {
+		return current, nil
+	}
+
+	r.logger.Info("SAS URL expired, requesting new authorization", "url_prefix", truncateURL(expired))
+	fingerprint, err := license.GenerateHardwareFingerprintWithSource()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate hardware fingerprint: %w", err)
+	}
+	client := newLicenseClient(r.cfg)
+	var resp *license.AuthResponse
+	if r.key.IsZero() {
+		resp, err = client.Authorize(ctx, r.cfg.ContractID, r.cfg.AssetID, fingerprint.ID)
+	} else {
+		resp, err = client.AuthorizeForKey(ctx, r.cfg.ContractID, r.cfg.AssetID, fingerprint.ID, r.key)
+	}
+	if err != nil {
+		return "", fmt.Errorf("re-authorization failed: %w", err)
+	}
+	// Only the URL is needed; the key in use stays the original one
+	resp.DecryptionKey.Destroy()
+
+	files, err := modelFiles(r.manifest, resp)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		r.urls[blobPath(f.url)] = f.url
+	}
+	r.logger.Info("Download URLs refreshed", "url_prefix", truncateURL(resp.SASUrl))
+	return r.urls[path], nil
+}
+
+// blobPath returns rawURL without its query string, which holds the SAS.
+func blobPath(rawURL string) string {
+	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to download encrypted asset: %w", err)
+		return rawURL
+	}
+	u.RawQuery = ""
+	return u.String()
+}
+
+// hydrate downloads the encrypted files of the manifest that are not already
+// in the asset cache, then verifies integrity and that every header names the
+// authorized key.
+func hydrate(ctx context.Context, cfg *config.Config, authResp *license.AuthResponse, manifest *asset.Manifest, logger *slog.Logger) ([]*modelFile, error) {
+	files, err := modelFiles(manifest, authResp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Files verified by an earlier run are reused as they are
+	cache := asset.NewCache(cfg.TargetDir, asset.WithCacheMaxBytes(int64(cfg.CacheMaxBytes)))
+	fingerprint := authResp.DecryptionKey.Fingerprint()
+	var targets []asset.FileTarget
+	var keep []string
+	var incoming int64
+	for _, f := range files {
+		path, err := cache.Path(cfg.AssetID, f.entry.SHA256Ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate %s in the asset cache: %w", f.entry.Name, err)
+		}
+		f.encryptedPath = path
+		keep = append(keep, path)
+
+		size := f.entry.CiphertextSize(manifest.Format)
+		if cache.Lookup(path, size) {
+			f.cached = cache.ChunksVerified(path, fingerprint)
+			logger.Info("Using verified encrypted file from a previous run", "file", f.entry.Name, "path", path, "chunks_verified", f.cached)
+			continue
+		}
+		targets = append(targets, asset.FileTarget{
+			URL:       f.url,
+			Path:      path,
+			Size:      size,
+			SHA256:    f.entry.SHA256Ciphertext,
+			Integrity: f.entry.Integrity,
+		})
+		incoming += size
+	}
+
+	if len(targets) > 0 {
+		if _, err := cache.Evict(incoming, keep...); err != nil {
+			logger.Warn("Failed to evict old assets from the cache", "error", err)
+		}
+
+		logger.Info("Downloading encrypted asset",
+			"url_prefix", truncateURL(authResp.SASUrl),
+			"target_dir", cfg.TargetDir,
+			"files", len(targets),
+			"cached_files", len(files)-len(targets),
+		)
+
+		// Download and verify all missing files concurrently
+		start := time.Now()
+		refresher := newSASRefresher(cfg, authResp, manifest, files, logger)
+		if _, err := newDownloader(cfg, refresher.Refresh, logger).DownloadFiles(ctx, targets); err != nil {
+			return nil, fmt.Errorf("failed to download encrypted asset: %w", err)
+		}
+		for _, t := range targets {
+			if err := cache.Record(t.Path, t.SHA256); err != nil {
+				logger.Warn("Failed to record verified file in the asset cache", "path", t.Path, "error", err)
+			}
+		}
+		logger.Info("Download complete, asset integrity verified",
+			"files", len(targets),
+			"duration", time.Since(start).String(),
+		)
 	}
-	logger.Info("Download complete",
-		"bytes_downloaded", result.BytesWritten,
-		"duration", result.Duration.String(),
+
+	if err := checkKeys(files); err != nil {
+		return nil, err
+	}
+
+	// Files whose chunks authenticated under this key before are not read
+	// again; a cached file is authenticated anew whenever the key changed
+	var unverified []*modelFile
+	for _, f := range files {
+		if !f.cached {
+			unverified = append(unverified, f)
+		}
+	}
+	if cfg.VerifyChunks && len(unverified) > 0 {
+		if err := verifyChunks(manifest, unverified, authResp.DecryptionKey, logger); err != nil {
+			return nil, err
+		}
+		for _, f := range unverified {
+			if err := cache.RecordChunksVerified(f.encryptedPath, fingerprint); err != nil {
+				logger.Warn("Failed to record authenticated chunks in the asset cache", "path", f.encryptedPath, "error", err)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// checkKeys reads the header of every downloaded file and fails with
+// crypto.ErrWrongKeyVersion if one names a key other than the authorized one.
+func checkKeys(files []*modelFile) error {
+	for _, f := range files {
+		ef, err := os.Open(f.encryptedPath)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", f.entry.Filename, err)
+		}
+		header, err := crypto.ParseHeader(ef)
+		ef.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.entry.Filename, err)
+		}
+		if err := header.CheckKey(f.key); err != nil {
+			return fmt.Errorf("%s: %w", f.entry.Filename, err)
+		}
+	}
+	return nil
+}
+
+// verifyChunks authenticates every chunk of the downloaded files against the
+// key and manifest, so a wrong key or corrupt chunk fails hydration before
+// the runtime sees a partial model. Cached files that an earlier run
+// authenticated under the same key are not passed in.
+func verifyChunks(manifest *asset.Manifest, files []*modelFile, key *crypto.Key, logger *slog.Logger) error {
+	start := time.Now()
+	for _, f := range files {
+		ef, err := os.Open(f.encryptedPath)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", f.entry.Filename, err)
+		}
+
+		result, err := crypto.VerifyAll(bufio.NewReaderSize(ef, 1024*1024), key.Bytes(), crypto.WithExpected(crypto.Expected{
+			ChunkBytes:      f.entry.ChunkBytes,
+			PlaintextBytes:  f.entry.PlaintextBytes,
+			CiphertextBytes: f.entry.CiphertextSize(manifest.Format),
+		}))
+		ef.Close()
+		if err != nil {
+			if result != nil {
+				for _, failure := range result.Failures {
+					logger.Error("Chunk failed authentication",
+						"file", f.entry.Filename,
+						"chunk", failure.Index,
+						"offset", failure.Offset,
+					)
+				}
+			}
+			return fmt.Errorf("chunk verification failed for %s: %w", f.entry.Filename, err)
+		}
+	}
+
+	logger.Info("All chunks authenticated",
+		"files", len(files),
+		"duration", time.Since(start).String(),
 	)
+	return nil
+}
 
-	// Verify hash
-	logger.Info("Verifying asset integrity")
-	if err := asset.VerifyFileHash(encryptedPath, manifest.SHA256Ciphertext); err != nil {
-		// Clean up the downloaded file
-		os.Remove(encryptedPath)
-		return nil, "", fmt.Errorf("integrity verification failed: %w", err)
+// assetStream is an encrypted asset being downloaded in order, hashed as it is read.
+type assetStream struct {
+	*asset.HashingReader
+	body io.ReadCloser
+}
+
+// Close cancels the download.
+func (s *assetStream) Close() error {
+	return s.body.Close()
+}
+
+// hydrateStream opens every encrypted file of the manifest as an ordered
+// stream. Nothing is written to disk; ciphertext hashes are computed
+// while the files are decrypted and checked by checkStream, and files with an
+// integrity index are checked block by block as they arrive. Each open stream
+// reads ahead up to Concurrency*ChunkBytes.
+func hydrateStream(ctx context.Context, cfg *config.Config, authResp *license.AuthResponse, manifest *asset.Manifest, logger *slog.Logger) ([]*modelFile, error) {
+	files, err := modelFiles(manifest, authResp)
+	if err != nil {
+		return nil, err
 	}
-	logger.Info("Asset integrity verified")
 
-	return manifest, encryptedPath, nil
+	logger.Info("Streaming encrypted asset",
+		"url_prefix", truncateURL(authResp.SASUrl),
+		"files", len(files),
+	)
+
+	downloader := newDownloader(cfg, newSASRefresher(cfg, authResp, manifest, files, logger).Refresh, logger)
+	for _, f := range files {
+		var body io.ReadCloser
+		var err error
+		if f.entry.Integrity != nil {
+			body, err = downloader.OpenStreamVerified(ctx, f.url, f.entry.CiphertextSize(manifest.Format), f.entry.Integrity)
+		} else {
+			body, err = downloader.OpenStream(ctx, f.url, f.entry.CiphertextSize(manifest.Format))
+		}
+		if err != nil {
+			closeStreams(files)
+			return nil, fmt.Errorf("failed to open encrypted asset stream for %s: %w", f.entry.Name, err)
+		}
+		f.stream = &assetStream{
+			HashingReader: asset.NewHashingReader(body),
+			body:          body,
+		}
+	}
+
+	return files, nil
+}
+
+// closeStreams cancels any open downloads.
+func closeStreams(files []*modelFile) {
+	for _, f := range files {
+		if f.stream != nil {
+			f.stream.Close()
+		}
+	}
+}
+
+// planLayout decides where each file is materialised and returns the model
+// directory. A single-file asset keeps the FIFO at TB_PIPE_PATH and has no
+// model directory. A multi-file asset becomes a directory under TB_MODEL_DIR:
+// entries up to TB_INMEMORY_MAX_BYTES are regular tmpfs files, since runtimes
+// may read configs and tokenizers more than once, and the rest are FIFOs.
+// In memfd delivery mode every file is listed with the handoff socket instead,
+// and in http delivery mode with its URL on the range server.
+func planLayout(cfg *config.Config, manifest *asset.Manifest, files []*modelFile) string {
+	if cfg.DeliveryMode == config.DeliveryModeMemfd {
+		for _, f := range files {
+			f.ready = crypto.ReadyFile{
+				Name:           f.entry.Name,
+				Path:           cfg.HandoffSocket,
+				Type:           crypto.ReadyFileMemfd,
+				PlaintextBytes: f.entry.PlaintextBytes,
+			}
+		}
+		return ""
+	}
+
+	if cfg.DeliveryMode == config.DeliveryModeHTTP {
+		for _, f := range files {
+			f.ready = crypto.ReadyFile{
+				Name:           f.entry.Name,
+				Path:           rangeserver.FileURL(cfg.RangeAddr, f.entry.Name),
+				Type:           crypto.ReadyFileHTTP,
+				PlaintextBytes: f.entry.PlaintextBytes,
+			}
+		}
+		return ""
+	}
+
+	if !manifest.IsMultiFile() {
+		f := files[0]
+		f.ready = crypto.ReadyFile{
+			Name:           f.entry.Name,
+			Path:           cfg.PipePath,
+			Type:           crypto.ReadyFileFIFO,
+			PlaintextBytes: f.entry.PlaintextBytes,
+		}
+		return ""
+	}
+
+	for _, f := range files {
+		fileType := crypto.ReadyFileFIFO
+		if f.entry.PlaintextBytes <= int64(cfg.InMemoryMaxBytes) {
+			fileType = crypto.ReadyFileRegular
+		}
+		f.ready = crypto.ReadyFile{
+			Name:           f.entry.Name,
+			Path:           filepath.Join(cfg.ModelDir, filepath.FromSlash(f.entry.Name)),
+			Type:           fileType,
+			PlaintextBytes: f.entry.PlaintextBytes,
+		}
+	}
+	return cfg.ModelDir
+}
+
+// decryptFiles materialises every file for the runtime.
+//
+// Regular files are decrypted before returning so they are complete when the
+// ready signal is written. FIFOs are created up front and fed asynchronously,
+// in parallel, since the runtime may open them in any order. In disk mode each
+// FIFO is served again to every new reader, up to cfg.FIFOMaxSessions, and the
+// sessions are recorded in tracker. The returned channel receives the first
+// failure, or the total once every FIFO has finished serving.
+//
+// decryptFiles takes ownership of key: it is destroyed once every decryption
+// it started has finished, whether or not an error is returned. While FIFOs
+// are still being served the key therefore stays in guarded memory.
+func decryptFiles(ctx context.Context, cfg *config.Config, files []*modelFile, key *crypto.Key, tracker *crypto.SessionTracker, logger *slog.Logger) (<-chan crypto.StreamResult, error) {
+	var pending []fileResult
+	destroyKey := func() {
+		key.Destroy()
+		logger.Info("Decryption key destroyed")
+	}
+
+	// On failure the FIFOs already started keep the key until they finish
+	fail := func(err error) (<-chan crypto.StreamResult, error) {
+		mergeResults(pending, destroyKey)
+		return nil, err
+	}
+
+	if cfg.HydrateMode == config.HydrateModeStream && cfg.FIFOMaxSessions != 1 {
+		logger.Info("Stream mode delivers each FIFO once; TB_FIFO_MAX_SESSIONS applies to disk mode only")
+	}
+
+	for _, f := range files {
+		opts := []crypto.StreamOption{
+			crypto.WithLogger(logger.With("file", f.entry.Name)),
+			crypto.WithTotalBytes(f.entry.PlaintextBytes),
+			crypto.WithWorkers(cfg.DecryptWorkers),
+			crypto.WithExpectedKey(f.key),
+			crypto.WithPlaintextSHA256(f.entry.SHA256Plaintext),
+		}
+
+		if f.ready.Type == crypto.ReadyFileRegular {
+			if err := decryptToFile(ctx, f, key.Bytes(), opts); err != nil {
+				return fail(fmt.Errorf("%s: %w", f.entry.Name, err))
+			}
+			continue
+		}
+
+		if err := crypto.CreateFIFO(f.ready.Path); err != nil {
+			return fail(fmt.Errorf("%s: %w", f.entry.Name, err))
+		}
+		opts = append(opts,
+			crypto.WithOpenTimeout(cfg.FIFOOpenTimeout),
+			crypto.WithStallTimeout(cfg.FIFOStallTimeout),
+		)
+
+		var ch <-chan crypto.StreamResult
+		if f.stream != nil {
+			ch = verifyStream(
+				crypto.DecryptStreamToFIFO(ctx, f.stream, f.ready.Path, key.Bytes(), opts...),
+				f.stream,
+				f.entry.SHA256Ciphertext,
+				logger,
+			)
+		} else {
+			// Serve every new reader so a restarted runtime can reload
+			opts = append(opts,
+				crypto.WithMaxSessions(cfg.FIFOMaxSessions),
+				crypto.WithSessionTracker(tracker),
+			)
+			ch = crypto.ServeFIFO(ctx, f.encryptedPath, f.ready.Path, key.Bytes(), opts...)
+		}
+		pending = append(pending, fileResult{name: f.entry.Name, ch: ch})
+	}
+
+	return mergeResults(pending, destroyKey), nil
+}
+
+// decryptToFile decrypts one file into a regular tmpfs file.
+func decryptToFile(ctx context.Context, f *modelFile, key []byte, opts []crypto.StreamOption) error {
+	if f.stream != nil {
+		if _, err := crypto.DecryptToFile(ctx, f.stream, f.ready.Path, key, opts...); err != nil {
+			return err
+		}
+		if err := checkStream(f.stream, f.entry.SHA256Ciphertext); err != nil {
+			os.Remove(f.ready.Path)
+			return err
+		}
+		return nil
+	}
+
+	in, err := os.Open(f.encryptedPath)
+	if err != nil {
+		return fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	_, err = crypto.DecryptToFile(ctx, in, f.ready.Path, key, opts...)
+	return err
+}
+
+// fileResult is the pending decryption result of one file.
+type fileResult struct {
+	name string
+	ch   <-chan crypto.StreamResult
+}
+
+// handOff decrypts every file into a sealed memfd and starts the handoff
+// server that passes read-only descriptors to permitted runtime processes.
+//
+// handOff takes ownership of key and destroys it as soon as every file is
+// decrypted, before the socket accepts connections. The returned channel
+// receives the total plaintext bytes; stop shuts the server down and releases
+// the memfds.
+func handOff(ctx context.Context, cfg *config.Config, files []*modelFile, key *crypto.Key, logger *slog.Logger) (stop func(), results <-chan crypto.StreamResult, err error) {
+	var handoffFiles []handoff.File
+	closeFiles := func() {
+		for _, f := range handoffFiles {
+			f.File.Close()
+		}
+	}
+
+	var total int64
+	for _, f := range files {
+		opts := []crypto.StreamOption{
+			crypto.WithLogger(logger.With("file", f.entry.Name)),
+			crypto.WithTotalBytes(f.entry.PlaintextBytes),
+			crypto.WithWorkers(cfg.DecryptWorkers),
+			crypto.WithExpectedKey(f.key),
+			crypto.WithPlaintextSHA256(f.entry.SHA256Plaintext),
+		}
+
+		mf, n, err := decryptToMemfd(ctx, f, key.Bytes(), opts)
+		if err != nil {
+			key.Destroy()
+			closeFiles()
+			return nil, nil, fmt.Errorf("%s: %w", f.entry.Name, err)
+		}
+		total += n
+		handoffFiles = append(handoffFiles, handoff.File{
+			Name:           f.entry.Name,
+			PlaintextBytes: n,
+			File:           mf,
+		})
+	}
+
+	key.Destroy()
+	logger.Info("Decryption key destroyed")
+
+	server := handoff.NewServer(cfg.HandoffSocket, handoffFiles,
+		handoff.WithLogger(logger),
+		handoff.WithPolicy(handoff.PeerPolicy{
+			UIDs: cfg.HandoffAllowedUIDs,
+			PIDs: cfg.HandoffAllowedPIDs,
+		}),
+	)
+	if err := server.Start(); err != nil {
+		closeFiles()
+		return nil, nil, err
+	}
+	logger.Info("Handoff server started", "socket", cfg.HandoffSocket, "files", len(handoffFiles))
+
+	done := make(chan crypto.StreamResult, 1)
+	done <- crypto.StreamResult{BytesWritten: total}
+	close(done)
+
+	stop = func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		server.Stop(shutdownCtx)
+		closeFiles()
+	}
+	return stop, done, nil
+}
+
+// decryptToMemfd decrypts one file into a sealed memfd, checking the
+// ciphertext hash of streamed files once the download completes.
+func decryptToMemfd(ctx context.Context, f *modelFile, key []byte, opts []crypto.StreamOption) (*os.File, int64, error) {
+	if f.stream != nil {
+		mf, n, err := crypto.DecryptToMemfd(ctx, f.stream, f.entry.Name, key, opts...)
+		if err != nil {
+			return nil, n, err
+		}
+		if err := checkStream(f.stream, f.entry.SHA256Ciphertext); err != nil {
+			mf.Close()
+			return nil, n, err
+		}
+		return mf, n, nil
+	}
+
+	in, err := os.Open(f.encryptedPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer in.Close()
+
+	return crypto.DecryptToMemfd(ctx, in, f.entry.Name, key, opts...)
+}
+
+// serveRanges starts the local range server over the downloaded ciphertext.
+// Each request decrypts only the chunks covering the requested range.
+//
+// serveRanges takes ownership of key and destroys it once a reader has been
+// set up for every file; the readers keep only the expanded cipher state.
+// A fresh bearer token is written to rangeTokenPath for the runtime. The
+// returned channel receives the total plaintext bytes; stop shuts the server
+// down, wipes cached plaintext and removes the token file.
+func serveRanges(cfg *config.Config, files []*modelFile, key *crypto.Key, logger *slog.Logger) (stop func(), results <-chan crypto.StreamResult, err error) {
+	var opened []*os.File
+	var readers []*crypto.DecryptingReader
+	release := func() {
+		for _, r := range readers {
+			r.Close()
+		}
+		for _, f := range opened {
+			f.Close()
+		}
+	}
+
+	var rangeFiles []rangeserver.File
+	var total int64
+	for _, f := range files {
+		in, err := os.Open(f.encryptedPath)
+		if err != nil {
+			key.Destroy()
+			release()
+			return nil, nil, fmt.Errorf("%s: failed to open encrypted file: %w", f.entry.Name, err)
+		}
+		opened = append(opened, in)
+
+		reader, err := crypto.NewDecryptingReaderAt(in, key.Bytes())
+		if err != nil {
+			key.Destroy()
+			release()
+			return nil, nil, fmt.Errorf("%s: %w", f.entry.Name, err)
+		}
+		readers = append(readers, reader)
+
+		total += reader.Size()
+		rangeFiles = append(rangeFiles, rangeserver.File{
+			Name:   f.entry.Name,
+			Reader: reader,
+			Size:   reader.Size(),
+		})
+	}
+
+	key.Destroy()
+	logger.Info("Decryption key destroyed")
+
+	for _, f := range files {
+		if f.entry.SHA256Plaintext != "" {
+			logger.Warn("Range delivery decrypts on demand; sha256_plaintext is not checked")
+			break
+		}
+	}
+
+	token, err := rangeserver.NewToken()
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	tokenPath := rangeTokenPath(cfg)
+	if err := crypto.WriteTokenFile(tokenPath, token); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	server := rangeserver.NewServer(rangeFiles, token,
+		rangeserver.WithAddr(cfg.RangeAddr),
+		rangeserver.WithAuditLogger(proxy.NewSlogAuditLogger(logger)),
+		rangeserver.WithAuditIdentity(cfg.ContractID, cfg.AssetID),
+		rangeserver.WithLogger(logger),
+	)
+	if err := server.Start(); err != nil {
+		os.Remove(tokenPath)
+		release()
+		return nil, nil, err
+	}
+	logger.Info("Range server started", "addr", server.Addr(), "files", len(rangeFiles))
+
+	done := make(chan crypto.StreamResult, 1)
+	done <- crypto.StreamResult{BytesWritten: total}
+	close(done)
+
+	stop = func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer shutdownCancel()
+		server.Stop(shutdownCtx)
+		os.Remove(tokenPath)
+		release()
+	}
+	return stop, done, nil
+}
+
+// rangeTokenPath returns where the range server bearer token is written,
+// next to the ready signal.
+func rangeTokenPath(cfg *config.Config) string {
+	return filepath.Join(filepath.Dir(cfg.ReadySignal), "range.token")
+}
+
+// sessionStatuses adapts a FIFO session tracker to the health server.
+func sessionStatuses(tracker *crypto.SessionTracker) func() []health.SessionStatus {
+	return func() []health.SessionStatus {
+		sessions := tracker.Sessions()
+		statuses := make([]health.SessionStatus, len(sessions))
+		for i, s := range sessions {
+			statuses[i] = health.SessionStatus{
+				File:         s.Path,
+				Session:      s.Number,
+				State:        s.State,
+				BytesWritten: s.BytesWritten,
+				Completed:    s.Completed,
+				Aborted:      s.Aborted,
+			}
+			if !s.StartedAt.IsZero() {
+				statuses[i].StartedAt = s.StartedAt.Format(time.RFC3339)
+			}
+		}
+		return statuses
+	}
+}
+
+// mergeResults combines per-file results: the first failure is reported as
+// soon as it happens, otherwise the total bytes once all files succeed.
+// onDone runs once every file has finished, including after a failure.
+func mergeResults(pending []fileResult, onDone func()) <-chan crypto.StreamResult {
+	out := make(chan crypto.StreamResult, 1)
+	collected := make(chan crypto.StreamResult, len(pending))
+
+	for _, p := range pending {
+		go func(p fileResult) {
+			result := <-p.ch
+			if result.Err != nil {
+				result.Err = fmt.Errorf("%s: %w", p.name, result.Err)
+			}
+			collected <- result
+		}(p)
+	}
+
+	go func() {
+		defer close(out)
+
+		var total int64
+		var failed bool
+		for range pending {
+			result := <-collected
+			total += result.BytesWritten
+			if result.Err != nil && !failed {
+				failed = true
+				out <- crypto.StreamResult{BytesWritten: total, Err: result.Err}
+			}
+		}
+		onDone()
+		if !failed {
+			out <- crypto.StreamResult{BytesWritten: total}
+		}
+	}()
+
+	return out
+}
+
+// checkStream hashes whatever the decrypter did not consume and compares the
+// ciphertext hash with the manifest.
+//
+// Every chunk is authenticated before it is written, so a mismatch here
+// means the asset differs from the manifest as a whole (e.g. trailing data or
+// a substituted file).
+func checkStream(stream *assetStream, expectedSHA256 string) error {
+	if _, err := io.Copy(io.Discard, stream); err != nil {
+		return fmt.Errorf("failed to read encrypted asset stream: %w", err)
+	}
+	if err := stream.Verify(expectedSHA256); err != nil {
+		return fmt.Errorf("integrity verification failed: %w", err)
+	}
+	return nil
+}
+
+// verifyStream runs checkStream once streamed decryption succeeds and reports
+// a mismatch as a decryption failure so the sentinel suspends.
+func verifyStream(results <-chan crypto.StreamResult, stream *assetStream, expectedSHA256 string, logger *slog.Logger) <-chan crypto.StreamResult {
+	out := make(chan crypto.StreamResult, 1)
+
+	go func() {
+		defer close(out)
+
+		result := <-results
+		if result.Err == nil {
+			if err := checkStream(stream, expectedSHA256); err != nil {
+				result.Err = err
+			} else {
+				logger.Info("Asset integrity verified")
+			}
+		}
+		out <- result
+	}()
+
+	return out
 }
 
 // truncateURL returns a truncated URL for logging (hides SAS tokens).
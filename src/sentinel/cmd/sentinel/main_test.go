@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"trustbridge/sentinel/internal/asset"
+	"trustbridge/sentinel/internal/config"
+	"trustbridge/sentinel/internal/crypto"
+	"trustbridge/sentinel/internal/license"
+)
+
+func TestHydrate_CachedFileWithDifferentKey(t *testing.T) {
+	newKey := func(b byte) *crypto.Key {
+		key, err := crypto.NewKey(bytes.Repeat([]byte{b}, crypto.KeySize))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(key.Destroy)
+		return key
+	}
+	cachedKey, rotatedKey := newKey(0x11), newKey(0x22)
+
+	// The cache holds a file whose chunks authenticated under cachedKey
+	var ciphertext bytes.Buffer
+	plaintext := bytes.Repeat([]byte("weights "), 4096)
+	result, err := crypto.EncryptToWriter(bytes.NewReader(plaintext), &ciphertext, cachedKey.Bytes(), 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &asset.Manifest{
+		Format:           asset.FormatTbencV1,
+		ChunkBytes:       4096,
+		PlaintextBytes:   int64(len(plaintext)),
+		SHA256Ciphertext: result.SHA256Ciphertext,
+		AssetID:          "tb-asset-123",
+		WeightsFilename:  "model.tbenc",
+	}
+
+	cfg := &config.Config{AssetID: manifest.AssetID, TargetDir: t.TempDir(), VerifyChunks: true}
+	cache := asset.NewCache(cfg.TargetDir)
+	path, err := cache.Path(cfg.AssetID, result.SHA256Ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, ciphertext.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Record(path, result.SHA256Ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.RecordChunksVerified(path, cachedKey.Fingerprint()); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	authorize := func(key *crypto.Key) *license.AuthResponse {
+		return &license.AuthResponse{
+			Status:        "authorized",
+			SASUrl:        "https://account.blob.core.windows.net/assets/model.tbenc?sig=abc",
+			DecryptionKey: key,
+		}
+	}
+
+	// A rotated key is caught before the runtime sees the model
+	_, err = hydrate(context.Background(), cfg, authorize(rotatedKey), manifest, logger)
+	if !errors.Is(err, crypto.ErrChunkAuthFailed) {
+		t.Fatalf("hydrate() with a different key error = %v, want ErrChunkAuthFailed", err)
+	}
+	if cache.ChunksVerified(path, rotatedKey.Fingerprint()) {
+		t.Error("failed authentication recorded as verified")
+	}
+
+	// The key the chunks authenticated under reuses the file as it is
+	files, err := hydrate(context.Background(), cfg, authorize(cachedKey), manifest, logger)
+	if err != nil {
+		t.Fatalf("hydrate() with the cached key error = %v", err)
+	}
+	if len(files) != 1 || !files[0].cached || files[0].encryptedPath != path {
+		t.Errorf("hydrate() did not reuse the authenticated cached file")
+	}
+}
@@ -0,0 +1,879 @@
+// tbenc is a CLI tool for building and checking tbenc encrypted assets.
+//
+// Usage:
+//
+//	tbenc encrypt -in <plaintext> -out <file.tbenc> [-key <hex> | -key-file <path>] [-format v1|v2] [-algo NAME] [-compress zstd] [-key-id N [-key-version N]] [-chunk-bytes N] [-integrity-block N] [-asset-id ID] [-manifest <path>]
+//	tbenc decrypt -in <file.tbenc> -out <plaintext|-> (-key <hex> | -key-file <path>) [-workers N]
+//	tbenc inspect -in <file.tbenc>
+//	tbenc verify  -in <file.tbenc> (-key <hex> | -key-file <path>) [-manifest <path>] [-workers N]
+//	tbenc rekey   -in <old.tbenc> -out <new.tbenc> (-old-key <hex> | -old-key-file <path>) [-new-key <hex> | -new-key-file <path>] [-key-id N [-key-version N]] [-algo NAME] [-manifest <path>] [-out-manifest <path>] [-file NAME]
+//	tbenc proof   -manifest <path> -block N [-file NAME]
+//
+// If encrypt or rekey is run without a (new) key, a random key is generated
+// and printed. The key is the only way to decrypt the output and must be
+// stored securely.
+//
+// rekey rotates the key of an encrypted file without exposing more than one
+// chunk of plaintext, and writes the updated manifest. A multi-file asset is
+// re-keyed one entry at a time with -file, passing each run's -out-manifest
+// as the next run's -manifest. Re-keyed entries record the new key reference
+// themselves; the manifest's moves to it once every entry has been re-keyed.
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"trustbridge/sentinel/internal/asset"
+	"trustbridge/sentinel/internal/crypto"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "encrypt":
+		err = runEncrypt(os.Args[2:])
+	case "decrypt":
+		err = runDecrypt(os.Args[2:])
+	case "inspect":
+		err = runInspect(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	case "rekey":
+		err = runRekey(os.Args[2:])
+	case "proof":
+		err = runProof(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: tbenc <command> [flags]
+
+Commands:
+  encrypt   Encrypt a file and write its manifest
+  decrypt   Decrypt a tbenc file
+  inspect   Print header and chunk statistics (no key required)
+  verify    Authenticate every chunk and check the manifest
+  rekey     Re-encrypt a tbenc file under a new key and update its manifest
+  proof     Print the Merkle inclusion proof of one integrity block
+
+Run "tbenc <command> -h" for command flags.
+`)
+}
+
+// keyFlags holds the shared key selection flags.
+type keyFlags struct {
+	name string // Flag name, "key" if empty; the file flag is name + "-file"
+	hex  string
+	file string
+}
+
+func (k *keyFlags) register(fs *flag.FlagSet) {
+	k.registerAs(fs, "key", "decryption key")
+}
+
+// registerAs registers -name and -name-file for the key described by usage.
+func (k *keyFlags) registerAs(fs *flag.FlagSet, name, usage string) {
+	k.name = name
+	fs.StringVar(&k.hex, name, "", usage+" as 64 hex characters")
+	fs.StringVar(&k.file, name+"-file", "", "file containing the "+usage+" as 64 hex characters")
+}
+
+// load returns the key, or nil if no key flag was set.
+func (k *keyFlags) load() ([]byte, error) {
+	name := k.name
+	if name == "" {
+		name = "key"
+	}
+
+	keyHex := k.hex
+	if k.file != "" {
+		if keyHex != "" {
+			return nil, fmt.Errorf("-%s and -%s-file are mutually exclusive", name, name)
+		}
+		data, err := os.ReadFile(k.file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		keyHex = strings.TrimSpace(string(data))
+	}
+	if keyHex == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key hex: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes (64 hex chars), got %d bytes", len(key))
+	}
+	return key, nil
+}
+
+// require returns the key or an error if none was given.
+func (k *keyFlags) require() ([]byte, error) {
+	key, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		name := k.name
+		if name == "" {
+			name = "key"
+		}
+		return nil, fmt.Errorf("a key is required (-%s or -%s-file)", name, name)
+	}
+	return key, nil
+}
+
+// parseKeyRef checks the -key-id and -key-version flags.
+func parseKeyRef(id, version uint) (crypto.KeyRef, error) {
+	if id > 0xffff || version > 0xffff {
+		return crypto.KeyRef{}, errors.New("-key-id and -key-version must be at most 65535")
+	}
+	if version != 0 && id == 0 {
+		return crypto.KeyRef{}, errors.New("-key-version requires -key-id")
+	}
+	return crypto.KeyRef{ID: uint16(id), Version: uint16(version)}, nil
+}
+
+// generateKey returns a random 32-byte key.
+func generateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return key, nil
+}
+
+// loadManifest reads and validates a manifest file.
+func loadManifest(path string) (*asset.Manifest, error) {
+	mf, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	defer mf.Close()
+
+	manifest, err := asset.ParseManifest(mf)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// entryIndex returns the index in manifest.Files of the entry named name,
+// or -1 for the file of a single-file manifest, where name must be empty.
+func entryIndex(manifest *asset.Manifest, name string) (int, error) {
+	if !manifest.IsMultiFile() && name == "" {
+		return -1, nil
+	}
+	for i, e := range manifest.Files {
+		if e.Name == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no manifest entry %q (use -file with a multi-file manifest)", name)
+}
+
+// parseFormat maps a -format value to a tbenc version and manifest format.
+func parseFormat(format string) (uint16, string, error) {
+	switch format {
+	case "v1", asset.FormatTbencV1:
+		return crypto.Version, asset.FormatTbencV1, nil
+	case "v2", asset.FormatTbencV2:
+		return crypto.VersionV2, asset.FormatTbencV2, nil
+	default:
+		return 0, "", fmt.Errorf("unsupported format %q (want v1 or v2)", format)
+	}
+}
+
+// defaultManifestPath mirrors the Python CLI: model.tbenc -> model.manifest.json.
+func defaultManifestPath(outPath string) string {
+	dir, name := filepath.Split(outPath)
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return filepath.Join(dir, name+".manifest.json")
+}
+
+func runEncrypt(args []string) error {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	in := fs.String("in", "", "plaintext input file (required)")
+	out := fs.String("out", "", "encrypted output file (required)")
+	format := fs.String("format", "v1", "output format: v1 or v2")
+	algoName := fs.String("algo", asset.AlgoAESGCMChunked, fmt.Sprintf("chunk algorithm: one of %s", strings.Join(crypto.AlgorithmNames(), ", ")))
+	compress := fs.String("compress", "", fmt.Sprintf("chunk compression with -format v2: %s (default none)", asset.CompressionZstd))
+	keyID := fs.Uint("key-id", 0, "provider key id recorded in the header and manifest with -format v2 (0 omits it)")
+	keyVersion := fs.Uint("key-version", 0, "version of -key-id recorded in the header and manifest")
+	chunkBytes := fs.Uint("chunk-bytes", crypto.DefaultChunkBytes, "plaintext bytes per chunk")
+	integrityBlock := fs.Int64("integrity-block", 0, fmt.Sprintf("ciphertext bytes per integrity index block, e.g. %d (0 omits the index)", asset.DefaultIntegrityBlockBytes))
+	assetID := fs.String("asset-id", "", "asset identifier for the manifest (defaults to the input filename)")
+	manifestPath := fs.String("manifest", "", "manifest output path (defaults to <out>.manifest.json)")
+	var kf keyFlags
+	kf.register(fs)
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		return errors.New("-in and -out are required")
+	}
+	if *chunkBytes > crypto.MaxChunkBytes {
+		return fmt.Errorf("chunk_bytes must be at most %d", crypto.MaxChunkBytes)
+	}
+	if *integrityBlock < 0 {
+		return errors.New("-integrity-block must not be negative")
+	}
+	keyRef, err := parseKeyRef(*keyID, *keyVersion)
+	if err != nil {
+		return err
+	}
+
+	version, manifestFormat, err := parseFormat(*format)
+	if err != nil {
+		return err
+	}
+	if *compress != "" && version != crypto.VersionV2 {
+		return errors.New("-compress requires -format v2")
+	}
+	if !keyRef.IsZero() && version != crypto.VersionV2 {
+		return errors.New("-key-id requires -format v2")
+	}
+
+	algo, err := crypto.LookupAlgorithmName(*algoName)
+	if err != nil {
+		return err
+	}
+
+	encryptOpts := []crypto.EncryptOption{
+		crypto.WithFormatVersion(version),
+		crypto.WithAlgorithm(algo.ID),
+		crypto.WithKeyRef(keyRef),
+	}
+	switch *compress {
+	case "":
+	case asset.CompressionZstd:
+		encryptOpts = append(encryptOpts, crypto.WithZstd())
+	default:
+		return fmt.Errorf("unsupported compression %q (want %s)", *compress, asset.CompressionZstd)
+	}
+
+	key, err := kf.load()
+	if err != nil {
+		return err
+	}
+	generated := key == nil
+	if generated {
+		if key, err = generateKey(); err != nil {
+			return err
+		}
+	}
+	defer crypto.SecureZeroBytes(key)
+
+	fin, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer fin.Close()
+
+	info, err := fin.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat input: %w", err)
+	}
+
+	fout, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+
+	result, err := crypto.EncryptToWriter(fin, fout, key, uint32(*chunkBytes),
+		append(encryptOpts, crypto.WithPlaintextSize(info.Size()))...,
+	)
+	if closeErr := fout.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close output: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	if *assetID == "" {
+		*assetID = filepath.Base(*in)
+	}
+	if *manifestPath == "" {
+		*manifestPath = defaultManifestPath(*out)
+	}
+
+	manifest := &asset.Manifest{
+		Format:           manifestFormat,
+		Algo:             algo.Name,
+		ChunkBytes:       int64(*chunkBytes),
+		PlaintextBytes:   result.PlaintextBytes,
+		SHA256Ciphertext: result.SHA256Ciphertext,
+		SHA256Plaintext:  result.SHA256Plaintext,
+		AssetID:          *assetID,
+		KeyID:            keyRef.ID,
+		KeyVersion:       keyRef.Version,
+		WeightsFilename:  filepath.Base(*out),
+	}
+	if *compress != "" {
+		manifest.Compression = *compress
+		manifest.CiphertextBytes = result.CiphertextBytes
+	}
+
+	if *integrityBlock > 0 {
+		if manifest.Integrity, err = asset.BuildIntegrityIndex(*out, *integrityBlock); err != nil {
+			return fmt.Errorf("failed to build integrity index: %w", err)
+		}
+	}
+
+	mf, err := os.Create(*manifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	if err := manifest.WriteJSON(mf); err != nil {
+		mf.Close()
+		return err
+	}
+	if err := mf.Close(); err != nil {
+		return fmt.Errorf("failed to close manifest: %w", err)
+	}
+
+	fmt.Printf("Encryption successful!\n")
+	fmt.Printf("Format: %s\n", manifestFormat)
+	fmt.Printf("Plaintext size: %d bytes\n", result.PlaintextBytes)
+	fmt.Printf("Ciphertext size: %d bytes\n", result.CiphertextBytes)
+	if manifest.Compression != "" && result.PlaintextBytes > 0 {
+		fmt.Printf("Compression: %s (%.1f%% of plaintext)\n", manifest.Compression, 100*float64(result.CiphertextBytes)/float64(result.PlaintextBytes))
+	}
+	fmt.Printf("Ciphertext SHA256: %s\n", result.SHA256Ciphertext)
+	fmt.Printf("Plaintext SHA256: %s\n", result.SHA256Plaintext)
+	if !keyRef.IsZero() {
+		fmt.Printf("Key: %s\n", keyRef)
+	}
+	if manifest.Integrity != nil {
+		fmt.Printf("Integrity root: %s (%d blocks)\n", manifest.Integrity.Root, len(manifest.Integrity.Leaves))
+	}
+	fmt.Printf("Encrypted file: %s\n", *out)
+	fmt.Printf("Manifest: %s\n", *manifestPath)
+	if generated {
+		fmt.Printf("\nDecryption key (save this, it is shown only once):\n%s\n", hex.EncodeToString(key))
+	}
+
+	return nil
+}
+
+func runDecrypt(args []string) error {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	in := fs.String("in", "", "encrypted input file (required)")
+	out := fs.String("out", "", "plaintext output file, or - for stdout (required)")
+	workers := fs.Int("workers", 1, "number of parallel decryption workers")
+	var kf keyFlags
+	kf.register(fs)
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		return errors.New("-in and -out are required")
+	}
+
+	key, err := kf.require()
+	if err != nil {
+		return err
+	}
+	defer crypto.SecureZeroBytes(key)
+
+	fin, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer fin.Close()
+
+	if *out == "-" {
+		_, err := crypto.DecryptToWriterParallel(fin, os.Stdout, key, *workers)
+		return err
+	}
+
+	fout, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+
+	n, err := crypto.DecryptToWriterParallel(fin, fout, key, *workers)
+	if closeErr := fout.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close output: %w", closeErr)
+	}
+	if err != nil {
+		// Never leave partially decrypted plaintext behind
+		os.Remove(*out)
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Decrypted %d bytes to %s\n", n, *out)
+	return nil
+}
+
+func runInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	in := fs.String("in", "", "encrypted input file (required)")
+	fs.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer f.Close()
+
+	header, err := crypto.ParseHeader(f)
+	if err != nil {
+		return fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	fmt.Printf("Magic: %s\n", string(header.Magic[:]))
+	fmt.Printf("Version: %d\n", header.Version)
+	if algo, err := crypto.LookupAlgorithm(header.Algo); err == nil {
+		fmt.Printf("Algorithm: %d (%s)\n", header.Algo, algo.Name)
+	}
+	fmt.Printf("Chunk bytes: %d\n", header.ChunkBytes)
+	fmt.Printf("Nonce prefix: %s\n", hex.EncodeToString(header.NoncePrefix[:]))
+	if header.Compressed() {
+		fmt.Printf("Compression: %s\n", crypto.CompressionZstd)
+	}
+	if !header.Key().IsZero() {
+		fmt.Printf("Key: %s\n", header.Key())
+	}
+	if header.Version == crypto.VersionV2 {
+		fmt.Printf("Declared plaintext bytes: %d\n", header.PlaintextBytes)
+		fmt.Printf("Declared chunks: %d\n", header.ChunkCount())
+	}
+
+	// Walk the record framing without decrypting
+	var chunks, fullChunks, compressedChunks, plaintextBytes uint64
+	minChunk, maxChunk := uint64(0), uint64(0)
+	ciphertextBytes := uint64(crypto.HeaderSize)
+	lenBuf := make([]byte, header.RecordHeaderSize())
+
+	for {
+		if _, err := io.ReadFull(f, lenBuf); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("truncated record header at chunk %d: %w", chunks, err)
+		}
+		ptLen := uint64(binary.BigEndian.Uint32(lenBuf))
+		if ptLen > uint64(header.ChunkBytes) {
+			return fmt.Errorf("invalid pt_len %d at chunk %d (max %d)", ptLen, chunks, header.ChunkBytes)
+		}
+		storedLen := ptLen
+		if header.Compressed() {
+			storedLen = uint64(binary.BigEndian.Uint32(lenBuf[crypto.RecordHeaderSize:]))
+			if storedLen > ptLen {
+				return fmt.Errorf("invalid stored_len %d at chunk %d (pt_len %d)", storedLen, chunks, ptLen)
+			}
+			if storedLen < ptLen {
+				compressedChunks++
+			}
+		}
+
+		skipped, err := io.CopyN(io.Discard, f, int64(storedLen)+crypto.TagSize)
+		if err != nil {
+			return fmt.Errorf("truncated record at chunk %d (%d of %d bytes): %w", chunks, skipped, storedLen+crypto.TagSize, err)
+		}
+
+		if chunks == 0 || ptLen < minChunk {
+			minChunk = ptLen
+		}
+		if ptLen > maxChunk {
+			maxChunk = ptLen
+		}
+		if ptLen == uint64(header.ChunkBytes) {
+			fullChunks++
+		}
+		chunks++
+		plaintextBytes += ptLen
+		ciphertextBytes += uint64(header.RecordHeaderSize()) + storedLen + crypto.TagSize
+	}
+
+	fmt.Printf("Chunks: %d (%d full)\n", chunks, fullChunks)
+	if header.Compressed() {
+		fmt.Printf("Compressed chunks: %d (others stored raw)\n", compressedChunks)
+	}
+	if chunks > 0 {
+		fmt.Printf("Chunk plaintext min/max: %d/%d bytes\n", minChunk, maxChunk)
+	}
+	fmt.Printf("Plaintext bytes: %d\n", plaintextBytes)
+	fmt.Printf("Ciphertext bytes: %d\n", ciphertextBytes)
+
+	if header.Version == crypto.VersionV2 {
+		if chunks != header.ChunkCount() || plaintextBytes != header.PlaintextBytes {
+			return fmt.Errorf("framing does not match header: %d chunks / %d bytes, header declares %d / %d",
+				chunks, plaintextBytes, header.ChunkCount(), header.PlaintextBytes)
+		}
+	}
+
+	return nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	in := fs.String("in", "", "encrypted input file (required)")
+	manifestPath := fs.String("manifest", "", "manifest to check the file against")
+	workers := fs.Int("workers", 1, "number of parallel decryption workers")
+	var kf keyFlags
+	kf.register(fs)
+	fs.Parse(args)
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+
+	key, err := kf.require()
+	if err != nil {
+		return err
+	}
+	defer crypto.SecureZeroBytes(key)
+
+	var manifest *asset.Manifest
+	if *manifestPath != "" {
+		if manifest, err = loadManifest(*manifestPath); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer f.Close()
+
+	header, err := crypto.ParseHeader(io.NewSectionReader(f, 0, crypto.HeaderSize))
+	if err != nil {
+		return fmt.Errorf("failed to parse header: %w", err)
+	}
+	if manifest != nil {
+		_, wantFormat, _ := parseFormat(fmt.Sprintf("v%d", header.Version))
+		if manifest.Format != wantFormat {
+			return fmt.Errorf("format mismatch: manifest %s, file %s", manifest.Format, wantFormat)
+		}
+		if manifest.ChunkBytes != int64(header.ChunkBytes) {
+			return fmt.Errorf("chunk_bytes mismatch: manifest %d, file %d", manifest.ChunkBytes, header.ChunkBytes)
+		}
+		if (manifest.Compression != "") != header.Compressed() {
+			return fmt.Errorf("compression mismatch: manifest %q, file compressed %t", manifest.Compression, header.Compressed())
+		}
+		if manifest.KeyRef() != header.Key() {
+			return fmt.Errorf("%w: manifest names %s, file %s", crypto.ErrWrongKeyVersion, manifest.KeyRef(), header.Key())
+		}
+	}
+
+	// Hash the ciphertext and plaintext while authenticating every chunk
+	hasher := sha256.New()
+	ptHasher := sha256.New()
+	n, err := crypto.DecryptToWriterParallel(io.TeeReader(f, hasher), ptHasher, key, *workers)
+	if err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	// Drain anything the decrypter did not consume so the hash covers the whole file
+	if _, err := io.Copy(hasher, f); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+	ptSum := hex.EncodeToString(ptHasher.Sum(nil))
+
+	fmt.Printf("All chunks authenticated\n")
+	fmt.Printf("Plaintext size: %d bytes\n", n)
+	fmt.Printf("Ciphertext SHA256: %s\n", sum)
+	fmt.Printf("Plaintext SHA256: %s\n", ptSum)
+
+	if manifest != nil {
+		if !strings.EqualFold(sum, manifest.SHA256Ciphertext) {
+			return fmt.Errorf("ciphertext SHA256 mismatch: manifest %s, file %s", manifest.SHA256Ciphertext, sum)
+		}
+		if n != manifest.PlaintextBytes {
+			return fmt.Errorf("plaintext size mismatch: manifest %d, file %d", manifest.PlaintextBytes, n)
+		}
+		if manifest.SHA256Plaintext != "" && !strings.EqualFold(ptSum, manifest.SHA256Plaintext) {
+			return fmt.Errorf("%w: manifest %s, file %s", crypto.ErrPlaintextHashMismatch, manifest.SHA256Plaintext, ptSum)
+		}
+		if manifest.Integrity != nil {
+			if err := asset.VerifyFileIntegrity(*in, manifest.Integrity); err != nil {
+				return err
+			}
+			fmt.Printf("Integrity index matches (%d blocks)\n", len(manifest.Integrity.Leaves))
+		}
+		fmt.Printf("Manifest matches\n")
+	}
+
+	return nil
+}
+
+func runRekey(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	in := fs.String("in", "", "encrypted input file (required)")
+	out := fs.String("out", "", "re-keyed output file (required)")
+	algoName := fs.String("algo", "", "chunk algorithm of the output (defaults to the input's)")
+	keyID := fs.Uint("key-id", 0, "provider key id of the new key, recorded in the header and manifest of a tbenc/v2 file (0 omits it)")
+	keyVersion := fs.Uint("key-version", 0, "version of -key-id")
+	manifestPath := fs.String("manifest", "", "manifest of the input (defaults to <in>.manifest.json)")
+	outManifestPath := fs.String("out-manifest", "", "manifest output path (defaults to <out>.manifest.json)")
+	file := fs.String("file", "", "entry name in a multi-file manifest")
+	var oldKF, newKF keyFlags
+	oldKF.registerAs(fs, "old-key", "current key")
+	newKF.registerAs(fs, "new-key", "new key")
+	fs.Parse(args)
+
+	if *in == "" || *out == "" {
+		return errors.New("-in and -out are required")
+	}
+	if *in == *out {
+		return errors.New("-out must differ from -in")
+	}
+
+	keyRef, err := parseKeyRef(*keyID, *keyVersion)
+	if err != nil {
+		return err
+	}
+
+	if *manifestPath == "" {
+		*manifestPath = defaultManifestPath(*in)
+	}
+	if *outManifestPath == "" {
+		*outManifestPath = defaultManifestPath(*out)
+	}
+	manifest, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	index, err := entryIndex(manifest, *file)
+	if err != nil {
+		return err
+	}
+	entry := manifest.Entries()[max(index, 0)]
+	if !keyRef.IsZero() && manifest.Format != asset.FormatTbencV2 {
+		return fmt.Errorf("-key-id requires a %s file, the manifest is %s", asset.FormatTbencV2, manifest.Format)
+	}
+	if index >= 0 && keyRef.IsZero() && !manifest.KeyRef().IsZero() {
+		return errors.New("-key-id is required to re-key one entry of a manifest that names its key")
+	}
+
+	var rekeyOpts []crypto.EncryptOption
+	rekeyOpts = append(rekeyOpts, crypto.WithKeyRef(keyRef))
+	if *algoName != "" && *algoName != manifest.Algo {
+		if manifest.IsMultiFile() {
+			return errors.New("-algo cannot change one entry of a multi-file manifest")
+		}
+		algo, err := crypto.LookupAlgorithmName(*algoName)
+		if err != nil {
+			return err
+		}
+		rekeyOpts = append(rekeyOpts, crypto.WithAlgorithm(algo.ID))
+		manifest.Algo = algo.Name
+	}
+
+	oldKey, err := oldKF.require()
+	if err != nil {
+		return err
+	}
+	defer crypto.SecureZeroBytes(oldKey)
+
+	newKey, err := newKF.load()
+	if err != nil {
+		return err
+	}
+	generated := newKey == nil
+	if generated {
+		if newKey, err = generateKey(); err != nil {
+			return err
+		}
+	}
+	defer crypto.SecureZeroBytes(newKey)
+
+	fin, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open input: %w", err)
+	}
+	defer fin.Close()
+
+	fout, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+
+	// Hash the input so a file that does not match the manifest is caught
+	hasher := sha256.New()
+	result, err := crypto.Rekey(io.TeeReader(fin, hasher), fout, oldKey, newKey, rekeyOpts...)
+	if err == nil {
+		oldSum := hex.EncodeToString(hasher.Sum(nil))
+		switch {
+		case !strings.EqualFold(oldSum, entry.SHA256Ciphertext):
+			err = fmt.Errorf("input ciphertext SHA256 mismatch: manifest %s, file %s", entry.SHA256Ciphertext, oldSum)
+		case result.PlaintextBytes != entry.PlaintextBytes:
+			err = fmt.Errorf("plaintext size mismatch: manifest %d, file %d", entry.PlaintextBytes, result.PlaintextBytes)
+		}
+	}
+	if closeErr := fout.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close output: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+
+	// Sizes are unchanged; the hash, integrity index and key are not
+	entry.SHA256Ciphertext = result.SHA256Ciphertext
+	entry.Filename = path.Join(path.Dir(entry.Filename), filepath.Base(*out))
+	if entry.Integrity != nil {
+		if entry.Integrity, err = asset.BuildIntegrityIndex(*out, entry.Integrity.BlockBytes); err != nil {
+			os.Remove(*out)
+			return fmt.Errorf("failed to build integrity index: %w", err)
+		}
+	}
+	rekeyed := 1
+	if index < 0 {
+		manifest.SHA256Ciphertext = entry.SHA256Ciphertext
+		manifest.WeightsFilename = entry.Filename
+		manifest.Integrity = entry.Integrity
+		manifest.KeyID = keyRef.ID
+		manifest.KeyVersion = keyRef.Version
+	} else {
+		entry.KeyID = keyRef.ID
+		entry.KeyVersion = keyRef.Version
+		manifest.Files[index] = entry
+		rekeyed = promoteFileKeys(manifest, keyRef)
+	}
+
+	mf, err := os.Create(*outManifestPath)
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	if err := manifest.WriteJSON(mf); err != nil {
+		mf.Close()
+		return err
+	}
+	if err := mf.Close(); err != nil {
+		return fmt.Errorf("failed to close manifest: %w", err)
+	}
+
+	fmt.Printf("Re-key successful!\n")
+	if index >= 0 {
+		fmt.Printf("Entry: %s\n", entry.Name)
+	}
+	fmt.Printf("Plaintext size: %d bytes\n", result.PlaintextBytes)
+	fmt.Printf("Ciphertext SHA256: %s\n", result.SHA256Ciphertext)
+	// Re-keying leaves the plaintext, and so the manifest's hash of it, as is
+	if entry.SHA256Plaintext != "" {
+		fmt.Printf("Plaintext SHA256: %s\n", entry.SHA256Plaintext)
+	}
+	if !keyRef.IsZero() {
+		fmt.Printf("Key: %s\n", keyRef)
+	}
+	if rekeyed < len(manifest.Entries()) {
+		fmt.Printf("Manifest key: %s until every file is re-keyed (%d of %d done)\n", manifest.KeyRef(), rekeyed, len(manifest.Entries()))
+	}
+	if entry.Integrity != nil {
+		fmt.Printf("Integrity root: %s (%d blocks)\n", entry.Integrity.Root, len(entry.Integrity.Leaves))
+	}
+	fmt.Printf("Encrypted file: %s\n", *out)
+	fmt.Printf("Manifest: %s\n", *outManifestPath)
+	if generated {
+		fmt.Printf("\nNew decryption key (save this, it is shown only once):\n%s\n", hex.EncodeToString(newKey))
+	}
+
+	return nil
+}
+
+// promoteFileKeys moves the key reference of a multi-file manifest to key
+// once every entry is under it, and returns how many entries are.
+func promoteFileKeys(manifest *asset.Manifest, key crypto.KeyRef) int {
+	rekeyed := 0
+	for _, f := range manifest.Files {
+		if manifest.FileKeyRef(f) == key {
+			rekeyed++
+		}
+	}
+	if rekeyed < len(manifest.Files) {
+		return rekeyed
+	}
+
+	manifest.KeyID = key.ID
+	manifest.KeyVersion = key.Version
+	for i := range manifest.Files {
+		manifest.Files[i].KeyID = 0
+		manifest.Files[i].KeyVersion = 0
+	}
+	return rekeyed
+}
+
+func runProof(args []string) error {
+	fs := flag.NewFlagSet("proof", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "", "manifest with an integrity index (required)")
+	block := fs.Int("block", -1, "integrity block index (required)")
+	file := fs.String("file", "", "entry name in a multi-file manifest")
+	fs.Parse(args)
+
+	if *manifestPath == "" || *block < 0 {
+		return errors.New("-manifest and -block are required")
+	}
+
+	manifest, err := loadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+
+	i, err := entryIndex(manifest, *file)
+	if err != nil {
+		return err
+	}
+	entry := &manifest.Entries()[max(i, 0)]
+	if entry.Integrity == nil {
+		return fmt.Errorf("%s has no integrity index", entry.Name)
+	}
+
+	proof, err := entry.Integrity.Proof(*block)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("File: %s\n", entry.Name)
+	fmt.Printf("Block: %d of %d\n", *block, len(entry.Integrity.Leaves))
+	fmt.Printf("Leaf: %s\n", entry.Integrity.Leaves[*block])
+	fmt.Printf("Root: %s\n", entry.Integrity.Root)
+	fmt.Printf("Proof:\n")
+	for _, h := range proof {
+		fmt.Printf("  %s\n", h)
+	}
+
+	return nil
+}
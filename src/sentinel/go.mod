@@ -1,3 +1,9 @@
 module trustbridge/sentinel
 
 go 1.24.4
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/crypto v0.41.0
+	golang.org/x/sys v0.35.0
+)
@@ -0,0 +1,192 @@
+package asset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLimiterWait bounds a single wait of the bandwidth limiter, so a schedule
+// that raises the limit takes effect within this time.
+const maxLimiterWait = time.Second
+
+// ErrInvalidBandwidthSchedule indicates a bandwidth schedule that cannot be
+// parsed.
+var ErrInvalidBandwidthSchedule = errors.New("invalid bandwidth schedule")
+
+// BandwidthRule limits download bandwidth during a window of the day.
+type BandwidthRule struct {
+	Start       time.Duration // Offset from midnight, local time
+	End         time.Duration // Exclusive; at or before Start the window wraps past midnight
+	BytesPerSec int64         // Limit within the window, 0 for unlimited
+}
+
+// contains reports whether the time of day of t falls within the window.
+func (r BandwidthRule) contains(t time.Time) bool {
+	h, m, s := t.Clock()
+	tod := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if r.Start < r.End {
+		return tod >= r.Start && tod < r.End
+	}
+	return tod >= r.Start || tod < r.End
+}
+
+// ParseBandwidthSchedule parses comma-separated rules of the form
+// "HH:MM-HH:MM=bytesPerSec", for example "08:00-18:00=10485760,18:00-08:00=0"
+// for 10MB/s during business hours and no limit at night. An empty string
+// yields no rules.
+func ParseBandwidthSchedule(s string) ([]BandwidthRule, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var rules []BandwidthRule
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		window, limit, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: want HH:MM-HH:MM=bytesPerSec", ErrInvalidBandwidthSchedule, field)
+		}
+		from, to, ok := strings.Cut(window, "-")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q: want HH:MM-HH:MM=bytesPerSec", ErrInvalidBandwidthSchedule, field)
+		}
+
+		var rule BandwidthRule
+		var err error
+		if rule.Start, err = parseTimeOfDay(from); err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidBandwidthSchedule, field, err)
+		}
+		if rule.End, err = parseTimeOfDay(to); err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidBandwidthSchedule, field, err)
+		}
+		if rule.BytesPerSec, err = strconv.ParseInt(strings.TrimSpace(limit), 10, 64); err != nil || rule.BytesPerSec < 0 {
+			return nil, fmt.Errorf("%w: %q: limit must be a non-negative number of bytes per second", ErrInvalidBandwidthSchedule, field)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseTimeOfDay parses "HH:MM" as an offset from midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// BandwidthLimiter is a token bucket limiting the bytes per second read by
+// every request of the downloaders sharing it.
+//
+// The limit is the one of the first schedule rule whose window contains the
+// current time, or the default limit outside all windows. It is looked up on
+// every wait, so a download running across a window boundary changes speed
+// as it crosses it. A nil limiter does not limit.
+type BandwidthLimiter struct {
+	defaultRate int64
+	rules       []BandwidthRule
+	now         func() time.Time
+
+	mu     sync.Mutex
+	rate   int64   // Limit in force, 0 for unlimited, -1 before first use
+	tokens float64 // Bytes that may be read now; negative while in debt
+	last   time.Time
+}
+
+// BandwidthOption is a functional option for configuring a BandwidthLimiter.
+type BandwidthOption func(*BandwidthLimiter)
+
+// WithBandwidthSchedule sets the time-of-day rules that override the
+// default limit.
+func WithBandwidthSchedule(rules []BandwidthRule) BandwidthOption {
+	return func(l *BandwidthLimiter) {
+		l.rules = rules
+	}
+}
+
+// NewBandwidthLimiter creates a limiter allowing bytesPerSec by default.
+// Zero means unlimited outside the schedule.
+func NewBandwidthLimiter(bytesPerSec int64, opts ...BandwidthOption) *BandwidthLimiter {
+	l := &BandwidthLimiter{
+		defaultRate: max(0, bytesPerSec),
+		now:         time.Now,
+		rate:        -1,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// LimitAt returns the bytes per second allowed at t, 0 for unlimited.
+func (l *BandwidthLimiter) LimitAt(t time.Time) int64 {
+	for _, r := range l.rules {
+		if r.contains(t) {
+			return r.BytesPerSec
+		}
+	}
+	return l.defaultRate
+}
+
+// WaitN accounts for n bytes read and waits until the limit allows them.
+// Reads may take the bucket into debt by one buffer, which later reads pay
+// off, so callers can wait after reading.
+func (l *BandwidthLimiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+
+	for {
+		l.mu.Lock()
+		now := l.now()
+		rate := l.LimitAt(now)
+		if rate != l.rate {
+			l.setRate(rate, now)
+		}
+		if rate == 0 {
+			l.mu.Unlock()
+			return nil
+		}
+
+		// Refill, holding at most one second's worth
+		l.tokens = min(float64(rate), l.tokens+now.Sub(l.last).Seconds()*float64(rate))
+		l.last = now
+		if l.tokens >= 0 {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := min(maxLimiterWait, time.Duration(-l.tokens/float64(rate)*float64(time.Second)))
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// setRate puts a new limit in force. l.mu must be held.
+func (l *BandwidthLimiter) setRate(rate int64, now time.Time) {
+	if rate == 0 {
+		log.Printf("Download bandwidth unlimited")
+	} else {
+		log.Printf("Download bandwidth limited to %d bytes/s", rate)
+	}
+	if l.rate <= 0 {
+		// Start a limited period with an empty bucket
+		l.tokens = 0
+	}
+	l.rate = rate
+	l.last = now
+}
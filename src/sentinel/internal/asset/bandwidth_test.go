@@ -0,0 +1,172 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseBandwidthSchedule(t *testing.T) {
+	rules, err := ParseBandwidthSchedule("08:00-18:30=1048576, 22:00-06:00=0")
+	if err != nil {
+		t.Fatalf("ParseBandwidthSchedule() error = %v", err)
+	}
+	want := []BandwidthRule{
+		{Start: 8 * time.Hour, End: 18*time.Hour + 30*time.Minute, BytesPerSec: 1048576},
+		{Start: 22 * time.Hour, End: 6 * time.Hour, BytesPerSec: 0},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(want))
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	if rules, err := ParseBandwidthSchedule(""); err != nil || rules != nil {
+		t.Errorf("empty schedule = %v, %v, want no rules", rules, err)
+	}
+
+	for _, bad := range []string{
+		"08:00-18:00",
+		"08:00=100",
+		"8am-6pm=100",
+		"08:00-24:00=100",
+		"08:00-18:00=-1",
+		"08:00-18:00=fast",
+		"08:00-18:00=100,",
+	} {
+		if _, err := ParseBandwidthSchedule(bad); !errors.Is(err, ErrInvalidBandwidthSchedule) {
+			t.Errorf("ParseBandwidthSchedule(%q) error = %v, want ErrInvalidBandwidthSchedule", bad, err)
+		}
+	}
+}
+
+func TestBandwidthLimiter_LimitAt(t *testing.T) {
+	rules, _ := ParseBandwidthSchedule("09:00-17:00=1000,22:00-06:00=0,00:00-00:00=5000")
+	l := NewBandwidthLimiter(2000, WithBandwidthSchedule(rules[:2]))
+	day := func(hour, minute int) time.Time {
+		return time.Date(2026, 3, 2, hour, minute, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want int64
+	}{
+		{day(8, 59), 2000},
+		{day(9, 0), 1000},
+		{day(16, 59), 1000},
+		{day(17, 0), 2000},
+		{day(23, 0), 0},
+		{day(3, 0), 0},
+		{day(6, 0), 2000},
+	}
+	for _, tt := range tests {
+		if got := l.LimitAt(tt.at); got != tt.want {
+			t.Errorf("LimitAt(%s) = %d, want %d", tt.at.Format("15:04"), got, tt.want)
+		}
+	}
+
+	// A window starting and ending at the same time covers the whole day
+	allDay := NewBandwidthLimiter(0, WithBandwidthSchedule(rules[2:]))
+	if got := allDay.LimitAt(day(12, 0)); got != 5000 {
+		t.Errorf("LimitAt() = %d, want the all-day rule's 5000", got)
+	}
+}
+
+func TestBandwidthLimiter_WaitN(t *testing.T) {
+	l := NewBandwidthLimiter(200 * 1024)
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		if err := l.WaitN(context.Background(), 4096); err != nil {
+			t.Fatalf("WaitN() error = %v", err)
+		}
+	}
+	// 100KB at 200KB/s, the first buffer taken on credit
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("100KB took %v at 200KB/s, want about 500ms", elapsed)
+	}
+
+	var unlimited *BandwidthLimiter
+	if err := unlimited.WaitN(context.Background(), 1<<30); err != nil {
+		t.Errorf("nil limiter WaitN() error = %v", err)
+	}
+}
+
+func TestBandwidthLimiter_WaitNCancelled(t *testing.T) {
+	l := NewBandwidthLimiter(1024)
+	l.WaitN(context.Background(), 64*1024) // Deep in debt
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.WaitN(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitN() error = %v, want the context's", err)
+	}
+}
+
+func TestBandwidthLimiter_ScheduleChangesLive(t *testing.T) {
+	rules, _ := ParseBandwidthSchedule("12:00-13:00=0")
+	l := NewBandwidthLimiter(1024, WithBandwidthSchedule(rules))
+
+	var mu sync.Mutex
+	clock := time.Date(2026, 3, 2, 11, 59, 59, 0, time.Local)
+	l.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return clock
+	}
+
+	// A minute of debt at 1KB/s
+	l.WaitN(context.Background(), 60*1024)
+	done := make(chan error, 1)
+	go func() { done <- l.WaitN(context.Background(), 1024) }()
+	select {
+	case err := <-done:
+		t.Fatalf("WaitN() returned %v while in debt", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The unlimited window opens while the download waits
+	mu.Lock()
+	clock = clock.Add(time.Second)
+	mu.Unlock()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WaitN() error = %v", err)
+		}
+	case <-time.After(2 * maxLimiterWait):
+		t.Fatal("WaitN() still waiting after the limit was lifted")
+	}
+}
+
+func TestDownloadFileConcurrent_BandwidthLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("limited "), 32*1024) // 256KB
+	server := newTestRangeServer(data)
+	defer server.Close()
+
+	d := NewDownloader(
+		WithConcurrency(8),
+		WithChunkBytes(16*1024),
+		WithBandwidthLimiter(NewBandwidthLimiter(512*1024)),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	start := time.Now()
+	if _, err := d.DownloadFileConcurrent(context.Background(), server.URL, outputPath, int64(len(data))); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	// The limit is shared: eight workers together get 512KB/s, not each
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("256KB downloaded in %v at 512KB/s, want about 500ms", elapsed)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data) {
+		t.Error("content mismatch")
+	}
+}
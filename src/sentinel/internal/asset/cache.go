@@ -0,0 +1,320 @@
+package asset
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// cacheFileSuffix is the extension of cached ciphertext files.
+	cacheFileSuffix = ".tbenc"
+
+	// verifiedSuffix names the sidecar recording that a cached file was
+	// verified.
+	verifiedSuffix = ".verified.json"
+)
+
+// ErrInvalidCacheKey indicates an asset ID or hash that cannot name a cache
+// entry.
+var ErrInvalidCacheKey = errors.New("invalid cache key")
+
+// Cache keeps verified ciphertext files across restarts, keyed by asset ID
+// and SHA256, so an unchanged asset is neither downloaded nor hashed again.
+//
+// Files live at <dir>/<asset ID>/<sha256>.tbenc. Once a file has been
+// verified, Record writes a sidecar holding its size and modification time;
+// Lookup trusts a file only while both still match. RecordChunksVerified
+// notes in the sidecar which key every chunk authenticated under, so the
+// chunk pass can be skipped while that key stays the same. Partial downloads
+// of an entry (.part files and journals) live next to it and count towards
+// its size. With a size limit, Evict removes the least recently used
+// entries.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex // Serializes sidecar updates and eviction
+}
+
+// CacheOption is a functional option for configuring a Cache.
+type CacheOption func(*Cache)
+
+// WithCacheMaxBytes bounds the total size of the cache. Zero (the default)
+// means unbounded.
+func WithCacheMaxBytes(n int64) CacheOption {
+	return func(c *Cache) {
+		c.maxBytes = n
+	}
+}
+
+// NewCache creates a cache rooted at dir.
+func NewCache(dir string, opts ...CacheOption) *Cache {
+	c := &Cache{dir: dir}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// cacheRecord is the content of a verified sidecar.
+type cacheRecord struct {
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"` // Of the file when it was verified
+	VerifiedAt time.Time `json:"verified_at"`
+	LastUsed   time.Time `json:"last_used"`
+	ChunksKey  string    `json:"chunks_key,omitempty"` // Fingerprint of the key every chunk authenticated under
+}
+
+// Path returns where the file of assetID with the given SHA256 is cached.
+func (c *Cache) Path(assetID, sha256 string) (string, error) {
+	if assetID == "" || assetID == "." || assetID == ".." || strings.ContainsAny(assetID, `/\`) {
+		return "", fmt.Errorf("%w: asset ID %q", ErrInvalidCacheKey, assetID)
+	}
+	if err := validateSHA256("sha256", sha256); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidCacheKey, err)
+	}
+	return filepath.Join(c.dir, assetID, strings.ToLower(sha256)+cacheFileSuffix), nil
+}
+
+// Lookup reports whether path holds a verified file of the given size. A hit
+// marks the entry as used; a file changed since it was verified loses its
+// sidecar and must be downloaded again.
+func (c *Cache) Lookup(path string, size int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rec, err := readCacheRecord(path + verifiedSuffix)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != rec.Size || !info.ModTime().Equal(rec.ModTime) || (size > 0 && rec.Size != size) {
+		log.Printf("Cached file %s changed since it was verified, discarding", path)
+		os.Remove(path + verifiedSuffix)
+		return false
+	}
+
+	rec.LastUsed = time.Now().UTC()
+	if err := writeCacheRecord(path+verifiedSuffix, rec); err != nil {
+		log.Printf("Failed to update cache record for %s: %v", path, err)
+	}
+	return true
+}
+
+// Record marks the file at path as verified against sha256. It must be
+// called only after the file's content has been checked.
+func (c *Cache) Record(path, sha256 string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat cached file: %w", err)
+	}
+	now := time.Now().UTC()
+	return writeCacheRecord(path+verifiedSuffix, &cacheRecord{
+		SHA256:     strings.ToLower(sha256),
+		Size:       info.Size(),
+		ModTime:    info.ModTime(),
+		VerifiedAt: now,
+		LastUsed:   now,
+	})
+}
+
+// RecordChunksVerified notes that every chunk of the file at path, already
+// recorded as verified, authenticated under the key with the given
+// fingerprint (see crypto.Key.Fingerprint).
+func (c *Cache) RecordChunksVerified(path, keyFingerprint string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rec, err := readCacheRecord(path + verifiedSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read cache record: %w", err)
+	}
+	rec.ChunksKey = keyFingerprint
+	return writeCacheRecord(path+verifiedSuffix, rec)
+}
+
+// ChunksVerified reports whether every chunk of the cached file at path has
+// been authenticated under the key with the given fingerprint. Call it after
+// a Lookup hit.
+func (c *Cache) ChunksVerified(path, keyFingerprint string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rec, err := readCacheRecord(path + verifiedSuffix)
+	return err == nil && keyFingerprint != "" && rec.ChunksKey == keyFingerprint
+}
+
+// cacheEntry is a cached file with its sidecar and partial downloads.
+type cacheEntry struct {
+	path     string // Cached file, which may not exist yet
+	size     int64  // Bytes of the cached file or its .part file
+	lastUsed time.Time
+}
+
+// Evict removes least recently used entries until incoming more bytes fit
+// within the size limit. Entries whose path is in keep are never removed.
+// It returns the paths of the evicted entries.
+func (c *Cache) Evict(incoming int64, keep ...string) ([]string, error) {
+	if c.maxBytes <= 0 {
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, p := range keep {
+		kept[filepath.Clean(p)] = true
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+
+	var evicted []string
+	for _, e := range entries {
+		if total+incoming <= c.maxBytes {
+			break
+		}
+		if kept[e.path] {
+			continue
+		}
+		for _, suffix := range []string{"", verifiedSuffix, partSuffix, journalSuffix} {
+			if err := os.Remove(e.path + suffix); err != nil && !os.IsNotExist(err) {
+				return evicted, fmt.Errorf("failed to evict %s: %w", e.path, err)
+			}
+		}
+		log.Printf("Evicted %s from the asset cache (%d bytes, last used %s)", e.path, e.size, e.lastUsed.Format(time.RFC3339))
+		total -= e.size
+		evicted = append(evicted, e.path)
+	}
+
+	if total+incoming > c.maxBytes {
+		log.Printf("Asset cache holds %d bytes and needs %d more, over its %d byte limit", total, incoming, c.maxBytes)
+	}
+	return evicted, nil
+}
+
+// entries lists the cache entries. An entry is last used when its sidecar
+// says so, or when its newest file was modified if it was never verified.
+func (c *Cache) entries() ([]*cacheEntry, error) {
+	assets, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	byPath := make(map[string]*cacheEntry)
+	var entries []*cacheEntry
+	for _, dir := range assets {
+		if !dir.IsDir() {
+			continue
+		}
+		assetDir := filepath.Join(c.dir, dir.Name())
+		files, err := os.ReadDir(assetDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cache directory: %w", err)
+		}
+		for _, file := range files {
+			name, ok := cacheEntryName(file.Name())
+			if !ok || !file.Type().IsRegular() {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				continue // Removed meanwhile
+			}
+
+			path := filepath.Join(assetDir, name)
+			e := byPath[path]
+			if e == nil {
+				e = &cacheEntry{path: path}
+				byPath[path] = e
+				entries = append(entries, e)
+			}
+			// Sidecars and journals are too small to count
+			if n := file.Name(); strings.HasSuffix(n, cacheFileSuffix) || strings.HasSuffix(n, partSuffix) {
+				e.size += info.Size()
+			}
+			if info.ModTime().After(e.lastUsed) {
+				e.lastUsed = info.ModTime()
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if rec, err := readCacheRecord(e.path + verifiedSuffix); err == nil {
+			e.lastUsed = rec.LastUsed
+		}
+	}
+	return entries, nil
+}
+
+// cacheEntryName returns the cached file name a file of an entry belongs to,
+// or false if name is not part of the cache.
+func cacheEntryName(name string) (string, bool) {
+	for _, suffix := range []string{verifiedSuffix, journalSuffix, partSuffix} {
+		if strings.HasSuffix(name, suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	hash, ok := strings.CutSuffix(name, cacheFileSuffix)
+	if !ok || validateSHA256("sha256", hash) != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// readCacheRecord loads a verified sidecar.
+func readCacheRecord(path string) (*cacheRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec cacheRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse cache record: %w", err)
+	}
+	return &rec, nil
+}
+
+// writeCacheRecord atomically replaces a verified sidecar.
+func writeCacheRecord(path string, rec *cacheRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache record: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write cache record: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write cache record: %w", err)
+	}
+	return nil
+}
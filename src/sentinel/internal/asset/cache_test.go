@@ -0,0 +1,208 @@
+package asset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cacheFile writes data as the cached file of sha and records it verified,
+// last used at lastUsed.
+func cacheFile(t *testing.T, c *Cache, assetID, sha string, data []byte, lastUsed time.Time) string {
+	t.Helper()
+	path, err := c.Path(assetID, sha)
+	if err != nil {
+		t.Fatalf("Path() error = %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Record(path, sha); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	rec, err := readCacheRecord(path + verifiedSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.LastUsed = lastUsed
+	if err := writeCacheRecord(path+verifiedSuffix, rec); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCache_Path(t *testing.T) {
+	c := NewCache("/cache")
+	sha := strings.Repeat("AB", 32)
+
+	path, err := c.Path("asset-1", sha)
+	if err != nil {
+		t.Fatalf("Path() error = %v", err)
+	}
+	if want := filepath.Join("/cache", "asset-1", strings.Repeat("ab", 32)+".tbenc"); path != want {
+		t.Errorf("Path() = %q, want %q", path, want)
+	}
+
+	for _, tt := range []struct{ assetID, sha string }{
+		{"", sha},
+		{"..", sha},
+		{"a/b", sha},
+		{"asset-1", "abc"},
+		{"asset-1", strings.Repeat("zz", 32)},
+	} {
+		if _, err := c.Path(tt.assetID, tt.sha); !errors.Is(err, ErrInvalidCacheKey) {
+			t.Errorf("Path(%q, %q) error = %v, want ErrInvalidCacheKey", tt.assetID, tt.sha, err)
+		}
+	}
+}
+
+func TestCache_Lookup(t *testing.T) {
+	c := NewCache(t.TempDir())
+	data := []byte("encrypted weights")
+	sha := computeSHA256(data)
+
+	path, _ := c.Path("asset-1", sha)
+	if c.Lookup(path, int64(len(data))) {
+		t.Fatal("Lookup() hit before the file exists")
+	}
+
+	path = cacheFile(t, c, "asset-1", sha, data, time.Now().Add(-time.Hour))
+	if !c.Lookup(path, int64(len(data))) {
+		t.Fatal("Lookup() missed a verified file")
+	}
+	if rec, _ := readCacheRecord(path + verifiedSuffix); time.Since(rec.LastUsed) > time.Minute {
+		t.Errorf("LastUsed = %v, want it updated by the hit", rec.LastUsed)
+	}
+	if c.Lookup(path, int64(len(data))+1) {
+		t.Error("Lookup() hit for a different manifest size")
+	}
+
+	// A file written after verification is not trusted
+	path = cacheFile(t, c, "asset-1", sha, data, time.Now())
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if c.Lookup(path, int64(len(data))) {
+		t.Error("Lookup() hit for a file modified after verification")
+	}
+	if _, err := os.Stat(path + verifiedSuffix); !os.IsNotExist(err) {
+		t.Error("stale cache record not removed")
+	}
+}
+
+func TestCache_ChunksVerified(t *testing.T) {
+	c := NewCache(t.TempDir())
+	sha := strings.Repeat("ab", 32)
+	path := cacheFile(t, c, "asset-1", sha, []byte("ciphertext"), time.Now())
+	key, otherKey := strings.Repeat("11", 32), strings.Repeat("22", 32)
+
+	if c.ChunksVerified(path, key) {
+		t.Error("ChunksVerified() = true before any chunk pass")
+	}
+	if err := c.RecordChunksVerified(path, key); err != nil {
+		t.Fatalf("RecordChunksVerified() error = %v", err)
+	}
+	if !c.Lookup(path, 10) || !c.ChunksVerified(path, key) {
+		t.Error("chunk verification not remembered across lookups")
+	}
+
+	// Chunks that authenticated under another key prove nothing about this one
+	if c.ChunksVerified(path, otherKey) {
+		t.Error("ChunksVerified() = true for a different key")
+	}
+	if c.ChunksVerified(path, "") {
+		t.Error("ChunksVerified() = true without a key")
+	}
+
+	// A file downloaded again must be authenticated again
+	if err := c.Record(path, sha); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if c.ChunksVerified(path, key) {
+		t.Error("ChunksVerified() = true after the file was recorded anew")
+	}
+
+	if err := c.RecordChunksVerified(filepath.Join(t.TempDir(), "missing.tbenc"), key); err == nil {
+		t.Error("RecordChunksVerified() succeeded without a cache record")
+	}
+}
+
+func TestCache_Evict(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, WithCacheMaxBytes(300))
+	now := time.Now()
+
+	block := func(b byte) []byte { return []byte(strings.Repeat(string(b), 100)) }
+	oldest := cacheFile(t, c, "asset-1", computeSHA256(block('a')), block('a'), now.Add(-3*time.Hour))
+	current := cacheFile(t, c, "asset-1", computeSHA256(block('b')), block('b'), now.Add(-2*time.Hour))
+	recent := cacheFile(t, c, "asset-2", computeSHA256(block('c')), block('c'), now.Add(-time.Hour))
+
+	// Unrelated files in the directory are left alone
+	if err := os.WriteFile(filepath.Join(dir, "asset-1", "notes.txt"), block('x'), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 more bytes need 100 freed: the oldest entry goes
+	evicted, err := c.Evict(100, current)
+	if err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != oldest {
+		t.Errorf("evicted %v, want [%s]", evicted, oldest)
+	}
+	for _, suffix := range []string{"", verifiedSuffix} {
+		if _, err := os.Stat(oldest + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s%s not removed", oldest, suffix)
+		}
+	}
+
+	// The kept entry survives even when it is the least recently used
+	evicted, err = c.Evict(300, current)
+	if err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != recent {
+		t.Errorf("evicted %v, want [%s]", evicted, recent)
+	}
+	if _, err := os.Stat(current); err != nil {
+		t.Errorf("kept entry removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "asset-1", "notes.txt")); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestCache_EvictPartialDownload(t *testing.T) {
+	dir := t.TempDir()
+	c := NewCache(dir, WithCacheMaxBytes(50))
+
+	path, _ := c.Path("asset-1", computeSHA256([]byte("unfinished")))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{partSuffix, journalSuffix} {
+		if err := os.WriteFile(path+suffix, make([]byte, 100), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evicted, err := c.Evict(0)
+	if err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if len(evicted) != 1 || evicted[0] != path {
+		t.Errorf("evicted %v, want [%s]", evicted, path)
+	}
+	for _, suffix := range []string{partSuffix, journalSuffix} {
+		if _, err := os.Stat(path + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", suffix)
+		}
+	}
+}
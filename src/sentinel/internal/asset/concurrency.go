@@ -0,0 +1,330 @@
+package asset
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultSampleInterval is how often the concurrency controller measures
+	// throughput and how often stats are reported.
+	defaultSampleInterval = time.Second
+
+	// throughputGain is the improvement over the previous sample that earns
+	// one more concurrent request.
+	throughputGain = 1.05
+
+	// latencySpikeFactor marks a request whose time to first byte exceeds
+	// the running average by this factor as a congestion signal.
+	latencySpikeFactor = 4
+
+	// minLatencySpike ignores spikes below this, where scheduling noise
+	// dominates.
+	minLatencySpike = 250 * time.Millisecond
+)
+
+// DownloadStats is a snapshot of a concurrent download, reported through
+// StatsCallback once per sample interval.
+type DownloadStats struct {
+	Downloaded  int64     // Bytes downloaded so far, including resumed ranges
+	Total       int64     // Size of the file
+	Concurrency int       // Range requests currently allowed in flight
+	Throughput  float64   // Bytes per second over the last interval
+	Workers     []float64 // Bytes per second of each worker while busy
+}
+
+// StatsFunc receives download statistics.
+type StatsFunc func(DownloadStats)
+
+// concurrencyController limits the range requests in flight. With adaptive
+// bounds it follows AIMD: one more request whenever a sample interval's
+// throughput beat the previous one, half as many on throttling (429/503) or
+// a latency spike. A Retry-After pauses all requests until it has passed.
+//
+// A Downloader has one controller, shared by all of its downloads and
+// streams, so the limit applies to their requests together.
+type concurrencyController struct {
+	min, max int
+
+	mu          sync.Mutex
+	limit       int
+	active      int
+	pausedUntil time.Time
+	wake        chan struct{} // Closed and replaced on every change
+	users       int           // Downloads and streams sampling
+	stopSample  chan struct{} // Closed when the last user is done
+
+	bytes          atomic.Int64 // Since the last sample
+	lastThroughput float64
+	decreased      bool          // Within the current interval
+	latency        time.Duration // Running average time to first byte
+}
+
+// newConcurrencyController starts at initial requests in flight, adapting
+// between min and max. With min == max the limit is fixed.
+func newConcurrencyController(initial, min, max int) *concurrencyController {
+	return &concurrencyController{
+		min:   min,
+		max:   max,
+		limit: initial,
+		wake:  make(chan struct{}),
+	}
+}
+
+// concurrencyController returns the controller shared by the downloads and
+// streams of d.
+func (d *Downloader) concurrencyController() *concurrencyController {
+	if d.config.MaxAdaptiveConcurrency > 0 {
+		return newConcurrencyController(d.config.Concurrency, MinConcurrency, max(d.config.MaxAdaptiveConcurrency, d.config.Concurrency))
+	}
+	return newConcurrencyController(d.config.Concurrency, d.config.Concurrency, d.config.Concurrency)
+}
+
+// acquire waits until another request may start.
+func (c *concurrencyController) acquire(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		pause := time.Until(c.pausedUntil)
+		if pause <= 0 && c.active < c.limit {
+			c.active++
+			c.mu.Unlock()
+			return nil
+		}
+		wake := c.wake
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if pause > 0 {
+			timer = time.NewTimer(pause)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// release ends a request started by acquire.
+func (c *concurrencyController) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	c.notify()
+}
+
+// notify wakes every waiting acquire. c.mu must be held.
+func (c *concurrencyController) notify() {
+	close(c.wake)
+	c.wake = make(chan struct{})
+}
+
+// Limit returns the number of requests currently allowed in flight.
+func (c *concurrencyController) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// addBytes counts downloaded bytes towards the current sample.
+func (c *concurrencyController) addBytes(n int64) {
+	c.bytes.Add(n)
+}
+
+// throttled handles a 429 or 503: the limit is halved and, if the server
+// asked for it, all requests wait for retryAfter.
+func (c *concurrencyController) throttled(retryAfter time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if retryAfter > 0 {
+		if until := time.Now().Add(retryAfter); until.After(c.pausedUntil) {
+			c.pausedUntil = until
+		}
+	}
+	c.decrease()
+}
+
+// observeLatency records the time to first byte of a request. A spike well
+// above the running average halves the limit.
+func (c *concurrencyController) observeLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.latency > 0 && d > minLatencySpike && d > latencySpikeFactor*c.latency {
+		c.decrease()
+	}
+	if c.latency == 0 {
+		c.latency = d
+	} else {
+		c.latency = (7*c.latency + d) / 8
+	}
+}
+
+// decrease halves the limit, at most once per sample interval so a burst of
+// failures from one event counts once. c.mu must be held.
+func (c *concurrencyController) decrease() {
+	if c.decreased || c.limit <= c.min {
+		return
+	}
+	c.limit = max(c.min, c.limit/2)
+	c.decreased = true
+	c.notify()
+}
+
+// startSampling samples throughput every interval until every caller has
+// called the returned stop function. The limit adapts only while at least
+// one download or stream is running.
+func (c *concurrencyController) startSampling(interval time.Duration) (stop func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.users == 0 {
+		// Throughput from an earlier, finished download is not comparable
+		c.bytes.Store(0)
+		c.lastThroughput = 0
+		c.stopSample = make(chan struct{})
+		go c.sampleEvery(interval, c.stopSample)
+	}
+	c.users++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.users--
+			if c.users == 0 {
+				close(c.stopSample)
+			}
+		})
+	}
+}
+
+// sampleEvery calls sample every interval until done is closed.
+func (c *concurrencyController) sampleEvery(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			c.sample(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// sample closes a sample interval of length elapsed and returns its
+// throughput, raising the limit by one if it improved on the last interval.
+func (c *concurrencyController) sample(elapsed time.Duration) float64 {
+	throughput := float64(c.bytes.Swap(0)) / elapsed.Seconds()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.decreased:
+		c.decreased = false
+	case throughput > c.lastThroughput*throughputGain && c.limit < c.max:
+		c.limit++
+		c.notify()
+	}
+	c.lastThroughput = throughput
+	return throughput
+}
+
+// workerStats accumulates what one worker downloaded and how long it was
+// busy doing so.
+type workerStats struct {
+	bytes atomic.Int64
+	busy  atomic.Int64 // Nanoseconds
+}
+
+// add records a finished range.
+func (w *workerStats) add(n int64, d time.Duration) {
+	w.bytes.Add(n)
+	w.busy.Add(int64(d))
+}
+
+// throughput returns bytes per second while busy.
+func (w *workerStats) throughput() float64 {
+	busy := time.Duration(w.busy.Load())
+	if busy <= 0 {
+		return 0
+	}
+	return float64(w.bytes.Load()) / busy.Seconds()
+}
+
+// isThrottled reports whether err is a 429 or 503 response.
+func isThrottled(err error) bool {
+	var assetErr *AssetError
+	if !errors.As(err, &assetErr) {
+		return false
+	}
+	return assetErr.StatusCode == http.StatusTooManyRequests || assetErr.StatusCode == http.StatusServiceUnavailable
+}
+
+// retryAfter returns the delay requested by a throttled response, if any.
+func retryAfter(err error) time.Duration {
+	var assetErr *AssetError
+	if errors.As(err, &assetErr) {
+		return assetErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date. It returns 0 if the header is absent or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(t))
+	}
+	return 0
+}
+
+// workerThroughputs returns the throughput of each worker.
+func workerThroughputs(workers []workerStats) []float64 {
+	out := make([]float64, len(workers))
+	for i := range workers {
+		out[i] = workers[i].throughput()
+	}
+	return out
+}
+
+// meanThroughput returns the average throughput of the workers that have
+// completed a range.
+func meanThroughput(workers []workerStats) float64 {
+	var sum float64
+	var n int
+	for i := range workers {
+		if t := workers[i].throughput(); t > 0 {
+			sum += t
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
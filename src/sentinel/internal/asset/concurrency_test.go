@@ -0,0 +1,395 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyController_AIMD(t *testing.T) {
+	c := newConcurrencyController(4, 1, 6)
+
+	// Improving throughput earns one more request per interval
+	c.addBytes(1000)
+	c.sample(time.Second)
+	c.addBytes(2000)
+	c.sample(time.Second)
+	if got := c.Limit(); got != 6 {
+		t.Fatalf("limit = %d after two improving intervals, want 6", got)
+	}
+
+	// Never above max
+	c.addBytes(4000)
+	c.sample(time.Second)
+	if got := c.Limit(); got != 6 {
+		t.Errorf("limit = %d, want it held at max 6", got)
+	}
+
+	// Flat throughput holds the limit
+	c = newConcurrencyController(4, 1, 8)
+	c.addBytes(1000)
+	c.sample(time.Second)
+	c.addBytes(1000)
+	c.sample(time.Second)
+	if got := c.Limit(); got != 5 {
+		t.Errorf("limit = %d after a flat interval, want 5", got)
+	}
+
+	// A burst of throttled requests halves the limit once per interval
+	c.throttled(0)
+	c.throttled(0)
+	c.throttled(0)
+	if got := c.Limit(); got != 2 {
+		t.Errorf("limit = %d after one throttling event, want 2", got)
+	}
+	// No increase in the interval of a decrease
+	c.addBytes(1 << 20)
+	c.sample(time.Second)
+	if got := c.Limit(); got != 2 {
+		t.Errorf("limit = %d after the throttled interval, want 2", got)
+	}
+
+	// Never below min
+	c.throttled(0)
+	c.sample(time.Second)
+	c.throttled(0)
+	if got := c.Limit(); got != 1 {
+		t.Errorf("limit = %d, want min 1", got)
+	}
+}
+
+func TestConcurrencyController_Fixed(t *testing.T) {
+	c := newConcurrencyController(3, 3, 3)
+	c.addBytes(1 << 20)
+	c.sample(time.Second)
+	c.throttled(0)
+	if got := c.Limit(); got != 3 {
+		t.Errorf("limit = %d, want the fixed 3", got)
+	}
+}
+
+func TestConcurrencyController_LatencySpike(t *testing.T) {
+	c := newConcurrencyController(8, 1, 8)
+	for i := 0; i < 10; i++ {
+		c.observeLatency(100 * time.Millisecond)
+	}
+	if got := c.Limit(); got != 8 {
+		t.Fatalf("limit = %d under steady latency, want 8", got)
+	}
+	c.observeLatency(time.Second)
+	if got := c.Limit(); got != 4 {
+		t.Errorf("limit = %d after a latency spike, want 4", got)
+	}
+}
+
+func TestConcurrencyController_Acquire(t *testing.T) {
+	c := newConcurrencyController(2, 1, 2)
+	ctx := context.Background()
+	c.acquire(ctx)
+	c.acquire(ctx)
+
+	acquired := make(chan struct{})
+	go func() {
+		c.acquire(ctx)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquire() succeeded beyond the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	c.release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("acquire() not woken by release()")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.acquire(cancelled); err == nil {
+		t.Error("acquire() succeeded on a cancelled context")
+	}
+}
+
+func TestConcurrencyController_RetryAfterPauses(t *testing.T) {
+	c := newConcurrencyController(4, 1, 4)
+	c.throttled(100 * time.Millisecond)
+
+	start := time.Now()
+	if err := c.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("acquire() returned after %v, want the Retry-After of 100ms", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-1", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat), 8 * time.Second, 10 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v-%v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestDownloadFileConcurrent_BoundedWorkers(t *testing.T) {
+	data := bytes.Repeat([]byte("worker pool "), 40000) // ~470KB, 470 ranges
+	var inFlight, maxInFlight, maxGoroutines int64
+	baseline := int64(runtime.NumGoroutine())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			m := atomic.LoadInt64(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt64(&maxInFlight, m, n) {
+				break
+			}
+		}
+		if g := int64(runtime.NumGoroutine()); g > atomic.LoadInt64(&maxGoroutines) {
+			atomic.StoreInt64(&maxGoroutines, g)
+		}
+
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[start : end+1])
+	}))
+	defer server.Close()
+
+	d := NewDownloader(WithConcurrency(3), WithChunkBytes(MinChunkBytes))
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+	if _, err := d.DownloadFileConcurrent(context.Background(), server.URL, outputPath, int64(len(data))); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data) {
+		t.Error("content mismatch")
+	}
+	if maxInFlight > 3 {
+		t.Errorf("%d range requests in flight, want at most 3", maxInFlight)
+	}
+	// Workers, server handlers and connections; not one goroutine per range
+	if extra := maxGoroutines - baseline; extra > 50 {
+		t.Errorf("%d goroutines during a download of %d ranges", extra, len(data)/MinChunkBytes)
+	}
+}
+
+func TestDownloader_SharedConcurrencyLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("shared limit "), 10000) // ~130KB, 130 ranges
+	var inFlight, maxInFlight int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		n := atomic.AddInt64(&inFlight, 1)
+		defer atomic.AddInt64(&inFlight, -1)
+		for {
+			m := atomic.LoadInt64(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt64(&maxInFlight, m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[start : end+1])
+	}))
+	defer server.Close()
+
+	// Two files and two streams at once, all within one limit of 2
+	d := NewDownloader(WithConcurrency(2), WithChunkBytes(MinChunkBytes))
+	ctx := context.Background()
+	dir := t.TempDir()
+	var targets []FileTarget
+	for i := range 2 {
+		targets = append(targets, FileTarget{
+			URL:    fmt.Sprintf("%s/file-%d.tbenc", server.URL, i),
+			Path:   filepath.Join(dir, fmt.Sprintf("file-%d.tbenc", i)),
+			Size:   int64(len(data)),
+			SHA256: computeSHA256(data),
+		})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		_, err := d.DownloadFiles(ctx, targets)
+		errs <- err
+	}()
+	for i := range 2 {
+		go func(i int) {
+			defer wg.Done()
+			stream, err := d.OpenStream(ctx, fmt.Sprintf("%s/stream-%d.tbenc", server.URL, i), int64(len(data)))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer stream.Close()
+			got, err := io.ReadAll(stream)
+			if err == nil && !bytes.Equal(got, data) {
+				err = fmt.Errorf("stream %d content mismatch", i)
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if maxInFlight > 2 {
+		t.Errorf("%d range requests in flight, want at most 2 across all downloads", maxInFlight)
+	}
+}
+
+func TestDownloadFileConcurrent_AdaptiveIncrease(t *testing.T) {
+	data := make([]byte, 400*MinChunkBytes)
+	server := newTestRangeServer(data)
+	server.delayMs = 5 // Latency-bound, so more requests mean more throughput
+	defer server.Close()
+
+	var mu sync.Mutex
+	var peak int
+	d := NewDownloader(
+		WithConcurrency(1),
+		WithAdaptiveConcurrency(8),
+		WithChunkBytes(MinChunkBytes),
+		WithStatsCallback(func(s DownloadStats) {
+			mu.Lock()
+			defer mu.Unlock()
+			peak = max(peak, s.Concurrency)
+			if len(s.Workers) != 8 {
+				t.Errorf("stats report %d workers, want the pool of 8", len(s.Workers))
+			}
+		}),
+	)
+	d.sampleInterval = 20 * time.Millisecond
+
+	if _, err := d.DownloadFileConcurrent(context.Background(), server.URL, filepath.Join(t.TempDir(), "model.tbenc"), int64(len(data))); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if peak < 3 {
+		t.Errorf("concurrency peaked at %d, want it raised while throughput improved", peak)
+	}
+}
+
+func TestDownloadFileConcurrent_ThrottlingBacksOff(t *testing.T) {
+	data := bytes.Repeat([]byte{0x7e}, 64*MinChunkBytes)
+	var throttledAt atomic.Int64
+	var throttled, early int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		if r.Method == "HEAD" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		// The account is throttled once, for a second
+		if atomic.CompareAndSwapInt32(&throttled, 0, 1) {
+			throttledAt.Store(time.Now().UnixNano())
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "ServerBusy", http.StatusServiceUnavailable)
+			return
+		}
+		if at := throttledAt.Load(); at != 0 && time.Since(time.Unix(0, at)) < 900*time.Millisecond {
+			atomic.AddInt32(&early, 1)
+		}
+		time.Sleep(2 * time.Millisecond)
+
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data[start : end+1])
+	}))
+	defer server.Close()
+
+	var stats []DownloadStats
+	var mu sync.Mutex
+	d := NewDownloader(
+		WithConcurrency(8),
+		WithAdaptiveConcurrency(8),
+		WithChunkBytes(MinChunkBytes),
+		WithRetryConfig(3, time.Millisecond, time.Millisecond),
+		WithStatsCallback(func(s DownloadStats) {
+			mu.Lock()
+			defer mu.Unlock()
+			stats = append(stats, s)
+		}),
+	)
+	d.sampleInterval = 50 * time.Millisecond
+
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+	if _, err := d.DownloadFileConcurrent(context.Background(), server.URL, outputPath, int64(len(data))); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data) {
+		t.Error("content mismatch")
+	}
+
+	// Only requests already sent by the other workers may arrive before the
+	// Retry-After has passed
+	if early > 7 {
+		t.Errorf("%d requests arrived during the Retry-After, want at most the 7 in flight", early)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(stats) == 0 {
+		t.Fatal("no stats reported")
+	}
+	var lowest = 8
+	for _, s := range stats {
+		lowest = min(lowest, s.Concurrency)
+	}
+	if lowest > 4 {
+		t.Errorf("concurrency stayed at %d or more after a 503, want it halved", lowest)
+	}
+	if last := stats[len(stats)-1]; last.Total != int64(len(data)) {
+		t.Errorf("stats total = %d, want %d", last.Total, len(data))
+	}
+}
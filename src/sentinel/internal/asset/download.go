@@ -2,6 +2,7 @@ package asset
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -25,6 +26,10 @@ type DownloadResult struct {
 type Downloader struct {
 	httpClient *http.Client
 	config     *DownloadConfig
+	limiter    *BandwidthLimiter // Shared by every request, may be nil
+
+	ctl            *concurrencyController // Shared by every range request
+	sampleInterval time.Duration          // Of the concurrency controller
 }
 
 // NewDownloader creates a new Downloader with the given options.
@@ -33,7 +38,8 @@ func NewDownloader(opts ...DownloaderOption) *Downloader {
 		httpClient: &http.Client{
 			Timeout: DefaultRequestTimeout,
 		},
-		config: DefaultDownloadConfig(),
+		config:         DefaultDownloadConfig(),
+		sampleInterval: defaultSampleInterval,
 	}
 
 	for _, opt := range opts {
@@ -42,6 +48,7 @@ func NewDownloader(opts ...DownloaderOption) *Downloader {
 
 	// Validate and clamp configuration values
 	d.config.Validate()
+	d.ctl = d.concurrencyController()
 
 	return d
 }
@@ -50,7 +57,13 @@ func NewDownloader(opts ...DownloaderOption) *Downloader {
 // This is the basic implementation suitable for smaller files or when
 // the server doesn't support HTTP Range requests.
 func (d *Downloader) DownloadFile(ctx context.Context, url, outputPath string) (*DownloadResult, error) {
+	return d.downloadFile(ctx, d.newBlobURL(url), outputPath)
+}
+
+// downloadFile implements DownloadFile for the current URL of src.
+func (d *Downloader) downloadFile(ctx context.Context, src *blobURL, outputPath string) (*DownloadResult, error) {
 	start := time.Now()
+	url := src.String()
 
 	// Ensure output directory exists
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
@@ -64,87 +77,126 @@ func (d *Downloader) DownloadFile(ctx context.Context, url, outputPath string) (
 	}
 	defer f.Close()
 
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	body, totalSize, err := d.getCurrent(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Copy with progress tracking
+	written, err := d.copyWithProgress(ctx, f, body, totalSize, url)
+	if err != nil {
+		os.Remove(outputPath) // Clean up partial download
+		return nil, err
+	}
+
+	return &DownloadResult{
+		Path:         outputPath,
+		BytesWritten: written,
+		Duration:     time.Since(start),
+	}, nil
+}
+
+// getCurrent opens the whole blob at the current URL of src, renewing the
+// URL when its SAS has expired. It returns the body and its size, -1 if
+// unknown.
+func (d *Downloader) getCurrent(ctx context.Context, src *blobURL) (io.ReadCloser, int64, error) {
+	for {
+		url, gen, err := src.get(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		body, size, err := d.open(ctx, url)
+		if !IsSASExpired(err) {
+			return body, size, err
+		}
+		if err := src.renew(ctx, gen, err); err != nil {
+			return nil, 0, err
+		}
+	}
+}
+
+// open opens the whole blob at url through its source, retrying transient
+// failures. The caller must close the body.
+func (d *Downloader) open(ctx context.Context, url string) (io.ReadCloser, int64, error) {
+	source, err := d.source(url)
 	if err != nil {
-		return nil, NewDownloadError(url, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+		return nil, 0, err
 	}
 
 	// Execute request with retry
-	var resp *http.Response
 	var lastErr error
 	for attempt := 0; attempt <= d.config.MaxRetries; attempt++ {
 		if attempt > 0 {
-			delay := d.calculateBackoff(attempt)
+			delay := max(d.calculateBackoff(attempt), retryAfter(lastErr))
 			select {
 			case <-ctx.Done():
-				return nil, NewNetworkError("download", url, ctx.Err())
+				return nil, 0, NewNetworkError("download", url, ctx.Err())
 			case <-time.After(delay):
 			}
 		}
 
-		resp, err = d.httpClient.Do(req)
-		if err != nil {
-			lastErr = NewNetworkError("download", url, err)
-			continue
-		}
-
-		// Check for retryable status codes
-		if isRetryableStatusCode(resp.StatusCode) {
-			resp.Body.Close()
-			lastErr = NewDownloadError(url, resp.StatusCode, fmt.Errorf("%w: status %d", ErrDownloadFailed, resp.StatusCode))
-			continue
-		}
-
-		// Check for SAS expiry
-		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized {
-			resp.Body.Close()
-			return nil, NewDownloadError(url, resp.StatusCode, ErrSASExpired)
+		body, size, err := source.Open(ctx)
+		if err == nil {
+			return body, size, nil
 		}
-
-		// Check for success
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			return nil, NewDownloadError(url, resp.StatusCode, fmt.Errorf("%w: unexpected status %d", ErrDownloadFailed, resp.StatusCode))
+		if IsSASExpired(err) || !IsRetryable(err) {
+			return nil, 0, err
 		}
-
-		break
-	}
-
-	if resp == nil {
-		return nil, fmt.Errorf("%w: %v", ErrMaxRetriesExceeded, lastErr)
-	}
-	defer resp.Body.Close()
-
-	// Get total size for progress reporting
-	totalSize := resp.ContentLength
-
-	// Copy with progress tracking
-	written, err := d.copyWithProgress(ctx, f, resp.Body, totalSize, url)
-	if err != nil {
-		os.Remove(outputPath) // Clean up partial download
-		return nil, err
+		lastErr = err
 	}
 
-	return &DownloadResult{
-		Path:         outputPath,
-		BytesWritten: written,
-		Duration:     time.Since(start),
-	}, nil
+	return nil, 0, fmt.Errorf("%w: %v", ErrMaxRetriesExceeded, lastErr)
 }
 
 // DownloadFileConcurrent performs a concurrent download using HTTP Range requests.
 // Falls back to single-threaded download if the server doesn't support ranges.
 // The totalSize parameter should be provided from the manifest for best results.
 func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath string, totalSize int64) (*DownloadResult, error) {
+	return d.downloadConcurrent(ctx, url, outputPath, totalSize, nil, "")
+}
+
+// DownloadFileVerified downloads a file like DownloadFileConcurrent and checks
+// it against an integrity index while it downloads.
+//
+// Ranges are aligned to index blocks and every block is verified as it lands;
+// a corrupt block fails only its range, which is retried on its own. When the
+// file has to be fetched with a single GET, its blocks are verified in
+// parallel afterwards and any bad ones are re-fetched with range requests.
+// A file returned without error needs no further whole-file hash.
+func (d *Downloader) DownloadFileVerified(ctx context.Context, url, outputPath string, totalSize int64, ix *IntegrityIndex) (*DownloadResult, error) {
+	if ix == nil {
+		return nil, errors.New("integrity index is required")
+	}
+	return d.downloadConcurrent(ctx, url, outputPath, totalSize, ix, "")
+}
+
+// downloadConcurrent implements DownloadFileConcurrent, verifying blocks
+// against ix when it is not nil.
+//
+// Ranges are queued and fetched by a fixed pool of workers, one per request
+// the concurrency controller may ever allow; the controller decides how many
+// of them have a request in flight (see WithAdaptiveConcurrency).
+//
+// With Resume enabled and an ETag from the server, ranges are written to
+// outputPath+".part" and recorded in a journal as they complete. An
+// interrupted download keeps both, and the next call for the same blob
+// (same expectedSHA256, size and ETag, under any URL) fetches only the
+// missing ranges before renaming the .part file into place.
+//
+// With a URLRefresh function, an expired SAS URL is replaced mid-download:
+// see WithURLRefresh.
+func (d *Downloader) downloadConcurrent(ctx context.Context, url, outputPath string, totalSize int64, ix *IntegrityIndex, expectedSHA256 string) (*DownloadResult, error) {
 	start := time.Now()
+	src := d.newBlobURL(url)
 
 	// Check if server supports range requests and get size
-	supportsRange, serverSize, err := d.checkRangeSupport(ctx, url)
+	probe, err := d.probe(ctx, src)
+	supportsRange, serverSize := probe.Ranges, probe.Size
 	if err != nil {
 		// If we can't check, fall back to single-threaded
 		log.Printf("Range check failed, falling back to single-threaded download: %v", err)
-		return d.DownloadFile(ctx, url, outputPath)
+		return d.downloadSingle(ctx, src, outputPath, false, ix)
 	}
 
 	// Use server-reported size if totalSize is 0 or doesn't match
@@ -155,12 +207,19 @@ func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath
 	// If server doesn't support ranges, fall back to single-threaded
 	if !supportsRange || totalSize <= 0 {
 		log.Printf("Server doesn't support range requests or size unknown, falling back to single-threaded")
-		return d.DownloadFile(ctx, url, outputPath)
+		return d.downloadSingle(ctx, src, outputPath, false, ix)
 	}
 
 	// For small files, use single-threaded download
 	if totalSize < int64(d.config.ChunkBytes) {
-		return d.DownloadFile(ctx, url, outputPath)
+		return d.downloadSingle(ctx, src, outputPath, true, ix)
+	}
+
+	var verifier *blockVerifier
+	if ix != nil {
+		if verifier, err = newBlockVerifier(ix, totalSize); err != nil {
+			return nil, NewVerifyError(outputPath, err)
+		}
 	}
 
 	// Ensure output directory exists
@@ -168,39 +227,84 @@ func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath
 		return nil, NewDownloadError(url, 0, fmt.Errorf("%w: %v", ErrFileCreation, err))
 	}
 
-	// Create and pre-allocate output file
-	f, err := os.Create(outputPath)
-	if err != nil {
-		return nil, NewDownloadError(url, 0, fmt.Errorf("%w: %v", ErrFileCreation, err))
-	}
+	// Calculate ranges, whole blocks each when verifying
+	rangeBytes := int64(d.config.ChunkBytes)
+	if verifier != nil {
+		rangeBytes = verifier.rangeBytes(rangeBytes)
+	}
+	ranges := splitRanges(totalSize, rangeBytes)
+
+	// Resumable downloads go to a .part file described by a journal
+	var journal *downloadJournal
+	writePath := outputPath
+	if d.config.Resume && probe.ETag != "" {
+		journal = openJournal(outputPath, downloadJournal{
+			SHA256:     expectedSHA256,
+			Size:       totalSize,
+			ETag:       probe.ETag,
+			RangeBytes: rangeBytes,
+		}, len(ranges))
+		writePath = journal.partPath
+	}
+
+	var f *os.File
+	var resumedBytes int64
+	if journal != nil && journal.resumed {
+		resumedBytes = journal.doneBytes(ranges)
+		log.Printf("Resuming download of %s: %d/%d bytes already complete", outputPath, resumedBytes, totalSize)
+		if f, err = os.OpenFile(writePath, os.O_WRONLY, 0); err != nil {
+			return nil, NewDownloadError(url, 0, fmt.Errorf("%w: %v", ErrFileCreation, err))
+		}
+	} else {
+		// Create and pre-allocate output file
+		f, err = os.Create(writePath)
+		if err != nil {
+			return nil, NewDownloadError(url, 0, fmt.Errorf("%w: %v", ErrFileCreation, err))
+		}
 
-	// Pre-allocate the file to avoid fragmentation
-	if err := f.Truncate(totalSize); err != nil {
-		f.Close()
-		os.Remove(outputPath)
-		return nil, NewDownloadError(url, 0, fmt.Errorf("failed to pre-allocate file: %w", err))
+		// Pre-allocate the file to avoid fragmentation
+		if err := f.Truncate(totalSize); err != nil {
+			f.Close()
+			os.Remove(writePath)
+			return nil, NewDownloadError(url, 0, fmt.Errorf("failed to pre-allocate file: %w", err))
+		}
 	}
 
-	// Calculate ranges
-	ranges := d.calculateRanges(totalSize)
-
 	// Create channels for coordination
 	type rangeResult struct {
+		index   int
 		start   int64
 		end     int64
 		written int64
 		err     error
 	}
 
-	resultCh := make(chan rangeResult, len(ranges))
-	progressCh := make(chan int64, len(ranges)*10)
+	var pending []int
+	for i := range ranges {
+		if journal == nil || !journal.isDone(i) {
+			pending = append(pending, i)
+		}
+	}
+
+	// A fixed pool of workers pulls ranges from the queue; the controller
+	// decides how many requests of all downloads may be in flight
+	ctl := d.ctl
+	workers := make([]workerStats, min(ctl.max, len(pending)))
+	queue := make(chan int, len(pending))
+	for _, i := range pending {
+		queue <- i
+	}
+	close(queue)
+
+	resultCh := make(chan rangeResult, len(pending))
+	progressCh := make(chan int64, len(workers)*64)
 
 	// Context for cancellation on first error
 	downloadCtx, cancelDownload := context.WithCancel(ctx)
 	defer cancelDownload()
 
 	// Progress tracking goroutine
-	var totalDownloaded int64
+	totalDownloaded := resumedBytes
 	var progressWg sync.WaitGroup
 	progressWg.Add(1)
 	go func() {
@@ -221,38 +325,72 @@ func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath
 				// Log progress at 10% intervals
 				percent := int(float64(downloaded) / float64(totalSize) * 100)
 				if percent/10 > lastLoggedPercent/10 {
-					log.Printf("Download progress: %d%% (%d/%d bytes)", percent, downloaded, totalSize)
+					log.Printf("Download progress: %d%% (%d/%d bytes, %d concurrent, %.1f MB/s per worker)",
+						percent, downloaded, totalSize, ctl.Limit(), meanThroughput(workers)/(1024*1024))
 					lastLoggedPercent = percent
 				}
 			}
 		}
 	}()
 
+	// Adapt concurrency to the throughput of all downloads, and report the
+	// stats of this one
+	stopController := ctl.startSampling(d.sampleInterval)
+	defer stopController()
+	stopSampling := make(chan struct{})
+	progressWg.Add(1)
+	go func() {
+		defer progressWg.Done()
+		ticker := time.NewTicker(d.sampleInterval)
+		defer ticker.Stop()
+		last, lastDownloaded := time.Now(), atomic.LoadInt64(&totalDownloaded)
+		for {
+			select {
+			case <-stopSampling:
+				return
+			case now := <-ticker.C:
+				downloaded := atomic.LoadInt64(&totalDownloaded)
+				throughput := float64(downloaded-lastDownloaded) / now.Sub(last).Seconds()
+				last, lastDownloaded = now, downloaded
+				if d.config.StatsCallback != nil {
+					d.config.StatsCallback(DownloadStats{
+						Downloaded:  downloaded,
+						Total:       totalSize,
+						Concurrency: ctl.Limit(),
+						Throughput:  throughput,
+						Workers:     workerThroughputs(workers),
+					})
+				}
+			}
+		}
+	}()
+
 	// Launch download workers
 	var wg sync.WaitGroup
-	semaphore := make(chan struct{}, d.config.Concurrency)
-
-	for _, r := range ranges {
+	for w := range workers {
 		wg.Add(1)
-		go func(rangeStart, rangeEnd int64) {
+		go func(stats *workerStats) {
 			defer wg.Done()
+			for i := range queue {
+				r := ranges[i]
+				began := time.Now()
+				written, err := d.downloadRange(downloadCtx, f, src, probe.ETag, r.start, r.end, progressCh, verifier, ctl)
+				stats.add(written, time.Since(began))
+				resultCh <- rangeResult{
+					index:   i,
+					start:   r.start,
+					end:     r.end,
+					written: written,
+					err:     err,
+				}
 
-			semaphore <- struct{}{}        // Acquire semaphore
-			defer func() { <-semaphore }() // Release semaphore
-
-			written, err := d.downloadRange(downloadCtx, f, url, rangeStart, rangeEnd, progressCh)
-			resultCh <- rangeResult{
-				start:   rangeStart,
-				end:     rangeEnd,
-				written: written,
-				err:     err,
-			}
-
-			// Cancel on error
-			if err != nil {
-				cancelDownload()
+				// Cancel on error
+				if err != nil {
+					cancelDownload()
+					return
+				}
 			}
-		}(r.start, r.end)
+		}(&workers[w])
 	}
 
 	// Wait for all workers and close result channel
@@ -264,17 +402,34 @@ func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath
 
 	// Collect results
 	var firstErr error
-	var totalWritten int64
+	totalWritten := resumedBytes
 	for result := range resultCh {
 		if result.err != nil && firstErr == nil {
 			firstErr = result.err
 		}
 		totalWritten += result.written
+		if result.err == nil && journal != nil {
+			journal.markDone(result.index)
+			journal.saveIfDue(f)
+		}
 	}
 
-	// Wait for progress goroutine
+	// Wait for progress and sampling goroutines
+	close(stopSampling)
 	progressWg.Wait()
 
+	// Keep an interrupted download for the next attempt, unless the blob
+	// it was fetched from has changed
+	keep := firstErr != nil && journal != nil && !errors.Is(firstErr, ErrSourceChanged)
+	if keep {
+		if err := journal.save(f); err != nil {
+			log.Printf("Failed to save download journal %s: %v", journal.path, err)
+			keep = false
+		} else {
+			log.Printf("Kept partial download of %s for resume (%d/%d bytes)", outputPath, journal.doneBytes(ranges), totalSize)
+		}
+	}
+
 	// Close file
 	if err := f.Close(); err != nil && firstErr == nil {
 		firstErr = fmt.Errorf("failed to close file: %w", err)
@@ -282,16 +437,32 @@ func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath
 
 	// Clean up on error
 	if firstErr != nil {
-		os.Remove(outputPath)
+		if !keep {
+			os.Remove(writePath)
+			if journal != nil {
+				journal.remove()
+			}
+		}
 		return nil, firstErr
 	}
 
 	// Verify final size
 	if totalWritten != totalSize {
-		os.Remove(outputPath)
+		os.Remove(writePath)
+		if journal != nil {
+			journal.remove()
+		}
 		return nil, NewVerifyError(outputPath, fmt.Errorf("%w: expected %d bytes, got %d", ErrFileSizeMismatch, totalSize, totalWritten))
 	}
 
+	if journal != nil {
+		if err := os.Rename(writePath, outputPath); err != nil {
+			journal.remove()
+			return nil, NewDownloadError(url, 0, fmt.Errorf("%w: %v", ErrFileCreation, err))
+		}
+		journal.remove()
+	}
+
 	return &DownloadResult{
 		Path:         outputPath,
 		BytesWritten: totalWritten,
@@ -299,6 +470,136 @@ func (d *Downloader) DownloadFileConcurrent(ctx context.Context, url, outputPath
 	}, nil
 }
 
+// downloadSingle performs a single-threaded download. With an integrity
+// index the file is verified afterwards and, if the server supports range
+// requests, mismatching blocks are re-fetched.
+func (d *Downloader) downloadSingle(ctx context.Context, src *blobURL, outputPath string, supportsRange bool, ix *IntegrityIndex) (*DownloadResult, error) {
+	result, err := d.downloadFile(ctx, src, outputPath)
+	if err != nil || ix == nil {
+		return result, err
+	}
+
+	if err := d.repairBlocks(ctx, src, outputPath, supportsRange, ix); err != nil {
+		os.Remove(outputPath)
+		return nil, err
+	}
+	return result, nil
+}
+
+// repairBlocks verifies a downloaded file against ix and re-fetches the
+// blocks that do not match.
+func (d *Downloader) repairBlocks(ctx context.Context, src *blobURL, outputPath string, supportsRange bool, ix *IntegrityIndex) error {
+	err := VerifyFileIntegrity(outputPath, ix)
+	var mismatch *BlockMismatchError
+	if err == nil || !errors.As(err, &mismatch) || !supportsRange {
+		return err
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return NewVerifyError(outputPath, err)
+	}
+	verifier, err := newBlockVerifier(ix, info.Size())
+	if err != nil {
+		return NewVerifyError(outputPath, err)
+	}
+
+	f, err := os.OpenFile(outputPath, os.O_WRONLY, 0)
+	if err != nil {
+		return NewDownloadError(src.String(), 0, fmt.Errorf("%w: %v", ErrFileCreation, err))
+	}
+	defer f.Close()
+
+	log.Printf("Re-fetching %d corrupt block(s) of %s", len(mismatch.Blocks), outputPath)
+	for _, block := range mismatch.Blocks {
+		start, end := ix.blockRange(block, info.Size())
+		if _, err := d.downloadRange(ctx, f, src, "", start, end, nil, verifier, nil); err != nil {
+			return err
+		}
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	return nil
+}
+
+// FileTarget describes one file of a multi-file download.
+type FileTarget struct {
+	URL       string          // Source URL
+	Path      string          // Output file path
+	Size      int64           // Expected size in bytes (0 if unknown)
+	SHA256    string          // Expected SHA256 (hex) of the downloaded file
+	Integrity *IntegrityIndex // Optional per-block integrity index
+}
+
+// DownloadFiles downloads and verifies several files concurrently.
+//
+// Up to Concurrency files are in flight at once, each fetched with
+// DownloadFileConcurrent and checked against its SHA256 once complete.
+// Targets with an integrity index are fetched with DownloadFileVerified
+// instead and need no whole-file hash.
+// The first failure cancels the remaining downloads; files that failed
+// verification are removed. With Resume enabled, the journal of an
+// interrupted file is keyed by its SHA256, so only a later call for the same
+// manifest entry resumes it. Results are returned in the order of targets.
+func (d *Downloader) DownloadFiles(ctx context.Context, targets []FileTarget) ([]*DownloadResult, error) {
+	downloadCtx, cancelDownload := context.WithCancel(ctx)
+	defer cancelDownload()
+
+	results := make([]*DownloadResult, len(targets))
+
+	// The first failure is reported, not the cancellations it causes
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancelDownload()
+		})
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, d.config.Concurrency)
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target FileTarget) {
+			defer wg.Done()
+
+			select {
+			case semaphore <- struct{}{}: // Acquire semaphore
+			case <-downloadCtx.Done():
+				fail(NewNetworkError("download", target.URL, downloadCtx.Err()))
+				return
+			}
+			defer func() { <-semaphore }() // Release semaphore
+
+			result, err := d.downloadConcurrent(downloadCtx, target.URL, target.Path, target.Size, target.Integrity, target.SHA256)
+			if err == nil && target.Integrity == nil {
+				if err = VerifyFileHash(target.Path, target.SHA256); err != nil {
+					os.Remove(target.Path)
+				}
+			}
+			if err != nil {
+				fail(fmt.Errorf("%s: %w", filepath.Base(target.Path), err))
+				return
+			}
+
+			log.Printf("Downloaded and verified %s (%d bytes)", target.Path, result.BytesWritten)
+			results[i] = result
+		}(i, target)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return results, nil
+}
+
 // rangeSpec represents a byte range to download.
 type rangeSpec struct {
 	start int64
@@ -307,7 +608,11 @@ type rangeSpec struct {
 
 // calculateRanges divides the total size into ranges for concurrent download.
 func (d *Downloader) calculateRanges(totalSize int64) []rangeSpec {
-	chunkSize := int64(d.config.ChunkBytes)
+	return splitRanges(totalSize, int64(d.config.ChunkBytes))
+}
+
+// splitRanges divides the total size into ranges of chunkSize bytes.
+func splitRanges(totalSize, chunkSize int64) []rangeSpec {
 	var ranges []rangeSpec
 
 	for start := int64(0); start < totalSize; start += chunkSize {
@@ -324,119 +629,132 @@ func (d *Downloader) calculateRanges(totalSize int64) []rangeSpec {
 // checkRangeSupport checks if the server supports HTTP Range requests.
 // Returns: supportsRange, totalSize, error
 func (d *Downloader) checkRangeSupport(ctx context.Context, url string) (bool, int64, error) {
-	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
-	if err != nil {
-		return false, 0, err
-	}
-
-	resp, err := d.httpClient.Do(req)
-	if err != nil {
-		return false, 0, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		// Try a range request instead to check support
-		return d.checkRangeSupportWithGet(ctx, url)
-	}
-
-	acceptRanges := resp.Header.Get("Accept-Ranges")
-	supportsRange := acceptRanges == "bytes"
-
-	return supportsRange, resp.ContentLength, nil
+	info, err := d.stat(ctx, url)
+	return info.Ranges, info.Size, err
 }
 
-// checkRangeSupportWithGet tries a small range request to check support.
-func (d *Downloader) checkRangeSupportWithGet(ctx context.Context, url string) (bool, int64, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return false, 0, err
+// probe runs stat against the current URL of src, renewing the URL when its
+// SAS has expired.
+func (d *Downloader) probe(ctx context.Context, src *blobURL) (SourceInfo, error) {
+	for {
+		url, gen, err := src.get(ctx)
+		if err != nil {
+			return SourceInfo{}, err
+		}
+		info, err := d.stat(ctx, url)
+		if !IsSASExpired(err) {
+			return info, err
+		}
+		if err := src.renew(ctx, gen, err); err != nil {
+			return SourceInfo{}, err
+		}
 	}
-	req.Header.Set("Range", "bytes=0-0")
+}
 
-	resp, err := d.httpClient.Do(req)
+// stat asks the source of url for range support, size and ETag.
+func (d *Downloader) stat(ctx context.Context, url string) (SourceInfo, error) {
+	source, err := d.source(url)
 	if err != nil {
-		return false, 0, err
-	}
-	defer resp.Body.Close()
-
-	// 206 Partial Content means range requests are supported
-	if resp.StatusCode == http.StatusPartialContent {
-		// Try to parse Content-Range header for total size
-		// Format: "bytes 0-0/12345"
-		contentRange := resp.Header.Get("Content-Range")
-		var start, end, total int64
-		if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err == nil {
-			return true, total, nil
-		}
-		return true, 0, nil
+		return SourceInfo{}, err
 	}
-
-	return false, resp.ContentLength, nil
+	return source.Stat(ctx)
 }
 
-// downloadRange downloads a specific byte range and writes it to the file.
-func (d *Downloader) downloadRange(ctx context.Context, f *os.File, url string, start, end int64, progressCh chan<- int64) (int64, error) {
+// downloadRange downloads a specific byte range and writes it to f at the
+// range's absolute offset. If verifier is not nil the range must cover whole
+// blocks, and a block that fails verification fails the attempt. A non-empty
+// etag is sent as If-Match, so a replaced blob fails with ErrSourceChanged.
+//
+// A failed attempt is continued after the last byte written, or after the
+// last verified block when verifying. An expired SAS renews src (see blobURL) and
+// does not count as a retry. A throttled attempt waits at least as long as
+// the server's Retry-After.
+//
+// With a controller, every request waits for its turn and reports its
+// latency, throughput and throttling to it.
+func (d *Downloader) downloadRange(ctx context.Context, f io.WriterAt, src *blobURL, etag string, start, end int64, progressCh chan<- int64, verifier *blockVerifier, ctl *concurrencyController) (int64, error) {
 	var lastErr error
+	var done int64 // Bytes of the range already written
 
 	for attempt := 0; attempt <= d.config.MaxRetries; attempt++ {
 		if attempt > 0 {
-			delay := d.calculateBackoff(attempt)
+			delay := max(d.calculateBackoff(attempt), retryAfter(lastErr))
 			select {
 			case <-ctx.Done():
-				return 0, NewNetworkError("range", url, ctx.Err())
+				return done, NewNetworkError("range", src.String(), ctx.Err())
 			case <-time.After(delay):
 			}
 		}
 
-		written, err := d.doRangeRequest(ctx, f, url, start, end, progressCh)
+		url, gen, err := src.get(ctx)
+		if err != nil {
+			return done, err
+		}
+
+		if ctl != nil {
+			if err := ctl.acquire(ctx); err != nil {
+				return done, NewNetworkError("range", url, err)
+			}
+		}
+		written, err := d.doRangeRequest(ctx, f, url, etag, start+done, end, progressCh, verifier, ctl)
+		if ctl != nil {
+			ctl.release()
+		}
 		if err == nil {
-			return written, nil
+			return done + written, nil
 		}
 
+		done += written
 		lastErr = err
 
-		// Check for SAS expiry - don't retry locally
+		// Wait for a new URL, then continue without using up a retry
 		if IsSASExpired(err) {
-			return 0, err
+			if err := src.renew(ctx, gen, err); err != nil {
+				return done, err
+			}
+			attempt--
+			continue
 		}
 
 		// Check if context is cancelled
 		if ctx.Err() != nil {
-			return 0, NewNetworkError("range", url, ctx.Err())
+			return done, NewNetworkError("range", url, ctx.Err())
+		}
+
+		// Slow every worker down, not just this one
+		if ctl != nil && isThrottled(err) {
+			ctl.throttled(retryAfter(err))
 		}
 
 		// Check if error is retryable
 		if !IsRetryable(err) {
-			return 0, err
+			return done, err
 		}
 	}
 
-	return 0, fmt.Errorf("%w: %v", ErrMaxRetriesExceeded, lastErr)
+	// Keep a persistent integrity failure visible to IsHashMismatch
+	if errors.Is(lastErr, ErrHashMismatch) {
+		return done, fmt.Errorf("%w: %w", ErrMaxRetriesExceeded, lastErr)
+	}
+	return done, fmt.Errorf("%w: %v", ErrMaxRetriesExceeded, lastErr)
 }
 
-// doRangeRequest performs a single range request.
-func (d *Downloader) doRangeRequest(ctx context.Context, f *os.File, url string, start, end int64, progressCh chan<- int64) (int64, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+// doRangeRequest performs a single range request through the source of url.
+func (d *Downloader) doRangeRequest(ctx context.Context, f io.WriterAt, url, etag string, start, end int64, progressCh chan<- int64, verifier *blockVerifier, ctl *concurrencyController) (int64, error) {
+	source, err := d.source(url)
 	if err != nil {
-		return 0, NewRangeError(url, 0, start, end, err)
+		return 0, err
 	}
 
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
-
-	resp, err := d.httpClient.Do(req)
+	sent := time.Now()
+	body, err := source.ReadRange(ctx, start, end, etag)
 	if err != nil {
-		return 0, NewNetworkError("range", url, err)
-	}
-	defer resp.Body.Close()
-
-	// Check status
-	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized {
-		return 0, NewRangeError(url, resp.StatusCode, start, end, ErrSASExpired)
+		return 0, err
 	}
+	defer body.Close()
 
-	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-		return 0, NewRangeError(url, resp.StatusCode, start, end, fmt.Errorf("unexpected status: %d", resp.StatusCode))
+	if ctl != nil {
+		ctl.observeLatency(time.Since(sent))
 	}
 
 	// Read and write at the correct offset
@@ -444,30 +762,64 @@ func (d *Downloader) doRangeRequest(ctx context.Context, f *os.File, url string,
 	buf := make([]byte, 32*1024) // 32KB buffer
 	var totalWritten int64
 
+	var blocks *rangeHasher
+	if verifier != nil {
+		blocks = verifier.newRangeHasher(start)
+	}
+
+	// A failed attempt resumes after the bytes it completed; when verifying,
+	// those are only the blocks that matched their hashes
+	completed := func() int64 {
+		if blocks != nil {
+			return blocks.verified
+		}
+		return totalWritten
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
-			return totalWritten, NewNetworkError("range", url, ctx.Err())
+			return completed(), NewNetworkError("range", url, ctx.Err())
 		default:
 		}
 
-		n, readErr := resp.Body.Read(buf)
+		n, readErr := body.Read(buf)
 		if n > 0 {
 			writeOffset := start + totalWritten
 			nw, writeErr := f.WriteAt(buf[:n], writeOffset)
 			if writeErr != nil {
-				return totalWritten, NewRangeError(url, 0, start, end, fmt.Errorf("write error: %w", writeErr))
+				return completed(), NewRangeError(url, 0, start, end, fmt.Errorf("write error: %w", writeErr))
 			}
 			if nw != n {
-				return totalWritten, NewRangeError(url, 0, start, end, fmt.Errorf("short write: %d/%d", nw, n))
+				return completed(), NewRangeError(url, 0, start, end, fmt.Errorf("short write: %d/%d", nw, n))
 			}
 			totalWritten += int64(nw)
+			if ctl != nil {
+				ctl.addBytes(int64(nw))
+			}
+			if err := d.limiter.WaitN(ctx, nw); err != nil {
+				return completed(), NewNetworkError("range", url, err)
+			}
+
+			// Verify every block as soon as its last byte lands. A failed
+			// attempt is retried from its last verified block, so only
+			// verified blocks count as progress.
+			progress := int64(nw)
+			if blocks != nil {
+				verified, err := blocks.write(buf[:n])
+				if err != nil {
+					return completed(), NewRangeError(url, 0, start, end, err)
+				}
+				progress = verified
+			}
 
 			// Report progress
-			select {
-			case progressCh <- int64(nw):
-			default:
-				// Don't block on progress
+			if progress > 0 {
+				select {
+				case progressCh <- progress:
+				default:
+					// Don't block on progress
+				}
 			}
 		}
 
@@ -475,13 +827,13 @@ func (d *Downloader) doRangeRequest(ctx context.Context, f *os.File, url string,
 			break
 		}
 		if readErr != nil {
-			return totalWritten, NewNetworkError("range", url, readErr)
+			return completed(), NewNetworkError("range", url, readErr)
 		}
 	}
 
 	// Verify we got the expected number of bytes
 	if totalWritten != expectedBytes {
-		return totalWritten, NewRangeError(url, 0, start, end, fmt.Errorf("incomplete read: got %d, expected %d", totalWritten, expectedBytes))
+		return completed(), NewRangeError(url, 0, start, end, fmt.Errorf("incomplete read: got %d, expected %d", totalWritten, expectedBytes))
 	}
 
 	return totalWritten, nil
@@ -510,6 +862,9 @@ func (d *Downloader) copyWithProgress(ctx context.Context, dst io.Writer, src io
 				return written, NewDownloadError(url, 0, fmt.Errorf("short write: %d/%d", nw, n))
 			}
 			written += int64(nw)
+			if err := d.limiter.WaitN(ctx, nw); err != nil {
+				return written, NewNetworkError("download", url, err)
+			}
 
 			// Report progress
 			if d.config.ProgressCallback != nil {
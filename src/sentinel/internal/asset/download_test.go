@@ -23,10 +23,13 @@ type testRangeServer struct {
 	*httptest.Server
 	data          []byte
 	requestCount  int64
-	failAfter     int64 // If > 0, fail after this many bytes (simulates partial failure)
-	failWithCode  int   // Status code to return on failure
-	delayMs       int   // Delay per request in milliseconds
-	rangeDisabled bool  // If true, don't support range requests
+	failAfter     int64         // If > 0, fail after this many bytes (simulates partial failure)
+	failWithCode  int           // Status code to return on failure
+	delayMs       int           // Delay per request in milliseconds
+	rangeDisabled bool          // If true, don't support range requests
+	failDelay     time.Duration // Delay before a simulated failure
+	etag          string        // ETag to send and require in If-Match, if set
+	rangeCount    int64         // Range requests served
 }
 
 func newTestRangeServer(data []byte) *testRangeServer {
@@ -43,6 +46,10 @@ func (ts *testRangeServer) handleRequest(w http.ResponseWriter, r *http.Request)
 		time.Sleep(time.Duration(ts.delayMs) * time.Millisecond)
 	}
 
+	if ts.etag != "" {
+		w.Header().Set("ETag", ts.etag)
+	}
+
 	// Handle HEAD request
 	if r.Method == "HEAD" {
 		if ts.rangeDisabled {
@@ -71,8 +78,14 @@ func (ts *testRangeServer) handleRequest(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		if match := r.Header.Get("If-Match"); match != "" && match != ts.etag {
+			http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+
 		// Check for simulated failure
 		if ts.failAfter > 0 && start >= ts.failAfter {
+			time.Sleep(ts.failDelay)
 			code := ts.failWithCode
 			if code == 0 {
 				code = http.StatusInternalServerError
@@ -82,6 +95,7 @@ func (ts *testRangeServer) handleRequest(w http.ResponseWriter, r *http.Request)
 		}
 
 		// Serve partial content
+		atomic.AddInt64(&ts.rangeCount, 1)
 		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(ts.data)))
 		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
 		w.WriteHeader(http.StatusPartialContent)
@@ -735,6 +749,80 @@ func TestIntegration_DownloadAndVerify(t *testing.T) {
 	}
 }
 
+func TestDownloadFiles_Success(t *testing.T) {
+	tmpDir := t.TempDir()
+	d := NewDownloader(WithConcurrency(2), WithChunkBytes(64*1024))
+
+	var targets []FileTarget
+	var contents [][]byte
+	for i, size := range []int{300 * 1024, 1000, 0} {
+		data := bytes.Repeat([]byte{byte('a' + i)}, size)
+		server := newTestRangeServer(data)
+		defer server.Close()
+
+		contents = append(contents, data)
+		targets = append(targets, FileTarget{
+			URL:    server.URL + fmt.Sprintf("/file-%d.tbenc", i),
+			Path:   filepath.Join(tmpDir, fmt.Sprintf("file-%d.tbenc", i)),
+			Size:   int64(size),
+			SHA256: computeSHA256(data),
+		})
+	}
+
+	results, err := d.DownloadFiles(context.Background(), targets)
+	if err != nil {
+		t.Fatalf("DownloadFiles failed: %v", err)
+	}
+	if len(results) != len(targets) {
+		t.Fatalf("got %d results, want %d", len(results), len(targets))
+	}
+
+	for i, target := range targets {
+		if results[i].Path != target.Path {
+			t.Errorf("result %d path = %q, want %q", i, results[i].Path, target.Path)
+		}
+		got, err := os.ReadFile(target.Path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", target.Path, err)
+		}
+		if !bytes.Equal(got, contents[i]) {
+			t.Errorf("file %d content mismatch", i)
+		}
+	}
+}
+
+func TestDownloadFiles_HashMismatch(t *testing.T) {
+	tmpDir := t.TempDir()
+	d := NewDownloader(WithConcurrency(2))
+
+	good := []byte("good file")
+	bad := []byte("tampered file")
+	goodServer := newTestRangeServer(good)
+	defer goodServer.Close()
+	badServer := newTestRangeServer(bad)
+	defer badServer.Close()
+
+	badPath := filepath.Join(tmpDir, "bad.tbenc")
+	targets := []FileTarget{
+		{URL: goodServer.URL + "/good.tbenc", Path: filepath.Join(tmpDir, "good.tbenc"), Size: int64(len(good)), SHA256: computeSHA256(good)},
+		{URL: badServer.URL + "/bad.tbenc", Path: badPath, Size: int64(len(bad)), SHA256: computeSHA256([]byte("expected content"))},
+	}
+
+	_, err := d.DownloadFiles(context.Background(), targets)
+	if err == nil {
+		t.Fatal("expected error for hash mismatch")
+	}
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected ErrHashMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "bad.tbenc") {
+		t.Errorf("error should name the failing file, got %v", err)
+	}
+	if _, statErr := os.Stat(badPath); !os.IsNotExist(statErr) {
+		t.Error("file failing verification should be removed")
+	}
+}
+
 func TestDownloadFileConcurrent_WithProgressCallback(t *testing.T) {
 	size := 1024 * 1024 // 1MB
 	testData := make([]byte, size)
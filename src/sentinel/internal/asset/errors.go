@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // Sentinel error values for asset operations.
@@ -44,6 +45,10 @@ var (
 
 	// ErrFileCreation indicates the output file could not be created.
 	ErrFileCreation = errors.New("failed to create output file")
+
+	// ErrSourceChanged indicates the blob changed (its ETag no longer
+	// matches) while it was being downloaded.
+	ErrSourceChanged = errors.New("source changed during download")
 )
 
 // AssetError represents an asset operation error with additional context.
@@ -53,6 +58,9 @@ type AssetError struct {
 	StatusCode int    // HTTP status code (if applicable)
 	Retryable  bool   // Whether this error can be retried
 	Err        error  // Underlying error
+
+	// RetryAfter is the delay a throttled (429/503) response asked for
+	RetryAfter time.Duration
 }
 
 // Error implements the error interface.
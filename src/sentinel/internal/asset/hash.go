@@ -129,6 +129,34 @@ func (hr *HashingReader) Sum() string {
 	return hex.EncodeToString(hr.hash.Sum(nil))
 }
 
+// Verify compares the computed hash to the expected hash.
+// Should only be called after all data has been read.
+//
+// Returns nil if the hashes match, or an error wrapping ErrHashMismatch if they don't.
+func (hr *HashingReader) Verify(expectedSHA256 string) error {
+	return compareHash(hr.Sum(), expectedSHA256)
+}
+
+// compareHash compares a computed lowercase hex hash to an expected hash.
+func compareHash(actualSHA256, expectedSHA256 string) error {
+	// Normalize expected hash to lowercase
+	expectedSHA256 = strings.ToLower(expectedSHA256)
+
+	// Validate expected hash format
+	if len(expectedSHA256) != 64 {
+		return fmt.Errorf("invalid expected hash: must be 64 hex characters, got %d", len(expectedSHA256))
+	}
+	if _, err := hex.DecodeString(expectedSHA256); err != nil {
+		return fmt.Errorf("invalid expected hash: not valid hex: %w", err)
+	}
+
+	if actualSHA256 != expectedSHA256 {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, expectedSHA256, actualSHA256)
+	}
+
+	return nil
+}
+
 // HashingWriter wraps an io.Writer and computes a running SHA256 hash as data is written.
 // Use this when you need to compute a hash while also writing data.
 type HashingWriter struct {
@@ -268,6 +268,28 @@ func TestHashingReader(t *testing.T) {
 	}
 }
 
+func TestHashingReader_Verify(t *testing.T) {
+	testData := []byte("Data to verify while reading")
+
+	hr := NewHashingReader(bytes.NewReader(testData))
+	if _, err := io.Copy(io.Discard, hr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := hr.Verify(strings.ToUpper(computeExpectedHash(testData))); err != nil {
+		t.Errorf("expected match, got %v", err)
+	}
+
+	err := hr.Verify(computeExpectedHash([]byte("other data")))
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected ErrHashMismatch, got %v", err)
+	}
+
+	if err := hr.Verify("abc"); err == nil || errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected invalid hash format error, got %v", err)
+	}
+}
+
 func TestHashingReader_PartialReads(t *testing.T) {
 	testData := []byte("Data to hash in chunks")
 	originalReader := bytes.NewReader(testData)
@@ -0,0 +1,142 @@
+package asset
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// httpSource reads a blob over plain HTTP(S): Azure Blob Storage through a
+// SAS URL, or an EDC data plane. 401 and 403 mean the SAS expired, 412 that
+// the blob changed, and 429 and 5xx are transient.
+type httpSource struct {
+	client *http.Client
+	url    string
+}
+
+// Stat sends a HEAD request to learn range support, size and ETag, and
+// falls back to a one-byte range request if HEAD is not allowed.
+func (s *httpSource) Stat(ctx context.Context) (SourceInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", s.url, nil)
+	if err != nil {
+		return SourceInfo{}, NewDownloadError(s.url, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return SourceInfo{}, NewNetworkError("download", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Try a range request instead to check support
+		return s.statWithGet(ctx)
+	}
+
+	return SourceInfo{
+		Size:   resp.ContentLength,
+		Ranges: resp.Header.Get("Accept-Ranges") == "bytes",
+		ETag:   resp.Header.Get("ETag"),
+	}, nil
+}
+
+// statWithGet tries a small range request to check support.
+func (s *httpSource) statWithGet(ctx context.Context) (SourceInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return SourceInfo{}, NewDownloadError(s.url, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return SourceInfo{}, NewNetworkError("download", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized {
+		return SourceInfo{}, NewDownloadError(s.url, resp.StatusCode, ErrSASExpired)
+	}
+
+	// 206 Partial Content means range requests are supported
+	if resp.StatusCode == http.StatusPartialContent {
+		return SourceInfo{
+			Size:   parseContentRangeSize(resp.Header.Get("Content-Range")),
+			Ranges: true,
+			ETag:   resp.Header.Get("ETag"),
+		}, nil
+	}
+
+	return SourceInfo{Size: resp.ContentLength}, nil
+}
+
+// ReadRange sends a range request, with If-Match when etag is set.
+func (s *httpSource) ReadRange(ctx context.Context, start, end int64, etag string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, NewRangeError(s.url, 0, start, end, err)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, NewNetworkError("range", s.url, err)
+	}
+
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s.statusError("range", resp, start, end)
+	}
+	return resp.Body, nil
+}
+
+// Open sends a GET request for the whole blob.
+func (s *httpSource) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, 0, NewDownloadError(s.url, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, NewNetworkError("download", s.url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, s.statusError("download", resp, 0, 0)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// statusError classifies an unsuccessful response.
+func (s *httpSource) statusError(op string, resp *http.Response, start, end int64) *AssetError {
+	status := resp.StatusCode
+	var err *AssetError
+	switch {
+	case status == http.StatusForbidden || status == http.StatusUnauthorized:
+		err = sourceError(op, s.url, status, start, end, true, ErrSASExpired)
+	case status == http.StatusPreconditionFailed:
+		err = sourceError(op, s.url, status, start, end, false, ErrSourceChanged)
+	default:
+		err = sourceError(op, s.url, status, start, end, isRetryableStatusCode(status),
+			fmt.Errorf("%w: status %d: %s", ErrDownloadFailed, status, errorBody(resp)))
+	}
+	err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	return err
+}
+
+// parseContentRangeSize returns the total size from a Content-Range header
+// such as "bytes 0-0/12345", or 0 if it has none.
+func parseContentRangeSize(contentRange string) int64 {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return 0
+	}
+	return total
+}
@@ -0,0 +1,163 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// partSuffix names the file a resumable download is written to until it
+	// is complete.
+	partSuffix = ".part"
+
+	// journalSuffix names the journal recording which ranges of the .part
+	// file are complete.
+	journalSuffix = ".part.json"
+
+	// journalVersion is the format version of download journals.
+	journalVersion = 1
+
+	// journalSaveInterval bounds how often the journal is rewritten while
+	// ranges complete. At most this much progress is lost to a crash.
+	journalSaveInterval = time.Second
+)
+
+// downloadJournal records the progress of a resumable download.
+//
+// A journal is tied to the blob it describes, not to the URL it was fetched
+// from: a new SAS URL for the same blob resumes it, while a different
+// manifest hash, size, ETag or range layout discards it. Ranges are marked
+// done only after the .part file has been synced, and with an integrity index
+// only after every block of the range verified.
+type downloadJournal struct {
+	Version    int    `json:"version"`
+	SHA256     string `json:"sha256,omitempty"` // Expected hash from the manifest
+	Size       int64  `json:"size"`
+	ETag       string `json:"etag"`        // Source ETag the ranges were fetched under
+	RangeBytes int64  `json:"range_bytes"` // Size of every range but the last
+	Done       []byte `json:"done"`        // Bitmap of completed ranges
+
+	path     string // Journal file
+	partPath string // Data file
+	resumed  bool   // Loaded from a previous run
+	saved    time.Time
+}
+
+// openJournal returns the journal for outputPath if a previous run left one
+// matching want and its .part file, or a fresh journal otherwise. Stale files
+// of a different blob are removed.
+func openJournal(outputPath string, want downloadJournal, ranges int) *downloadJournal {
+	j := want
+	j.Version = journalVersion
+	j.path = outputPath + journalSuffix
+	j.partPath = outputPath + partSuffix
+	j.Done = make([]byte, (ranges+7)/8)
+
+	prev, err := readJournal(j.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		log.Printf("Ignoring unreadable download journal %s: %v", j.path, err)
+	case !prev.matches(&j):
+		log.Printf("Discarding download journal %s: source changed", j.path)
+	default:
+		info, statErr := os.Stat(j.partPath)
+		if statErr == nil && info.Size() == j.Size {
+			j.Done = prev.Done
+			j.resumed = true
+			return &j
+		}
+		log.Printf("Discarding download journal %s: %s missing or truncated", j.path, filepath.Base(j.partPath))
+	}
+
+	j.remove()
+	return &j
+}
+
+// readJournal loads a journal from path.
+func readJournal(path string) (*downloadJournal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var j downloadJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("failed to parse journal: %w", err)
+	}
+	return &j, nil
+}
+
+// matches reports whether j describes the same blob and range layout as want.
+func (j *downloadJournal) matches(want *downloadJournal) bool {
+	return j.Version == want.Version &&
+		j.SHA256 == want.SHA256 &&
+		j.Size == want.Size &&
+		j.ETag == want.ETag &&
+		j.RangeBytes == want.RangeBytes &&
+		len(j.Done) == len(want.Done)
+}
+
+// isDone reports whether range i is complete.
+func (j *downloadJournal) isDone(i int) bool {
+	return j.Done[i/8]&(1<<(i%8)) != 0
+}
+
+// markDone records range i as complete.
+func (j *downloadJournal) markDone(i int) {
+	j.Done[i/8] |= 1 << (i % 8)
+}
+
+// doneBytes returns the number of bytes in completed ranges.
+func (j *downloadJournal) doneBytes(ranges []rangeSpec) int64 {
+	var n int64
+	for i, r := range ranges {
+		if j.isDone(i) {
+			n += r.end - r.start + 1
+		}
+	}
+	return n
+}
+
+// saveIfDue saves the journal if journalSaveInterval has passed since the
+// last save. Failures are logged: they cost resumability, not the download.
+func (j *downloadJournal) saveIfDue(f *os.File) {
+	if time.Since(j.saved) < journalSaveInterval {
+		return
+	}
+	if err := j.save(f); err != nil {
+		log.Printf("Failed to save download journal %s: %v", j.path, err)
+	}
+}
+
+// save syncs f, the .part file, and then atomically replaces the journal,
+// so that no range is recorded before its data is durable.
+func (j *downloadJournal) save(f *os.File) error {
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %w", j.partPath, err)
+	}
+
+	data, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	j.saved = time.Now()
+	return nil
+}
+
+// remove deletes the journal and its .part file.
+func (j *downloadJournal) remove() {
+	os.Remove(j.path)
+	os.Remove(j.partPath)
+}
@@ -0,0 +1,214 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const journalTestRange = 64 * 1024
+
+// interruptedDownload runs a resumable download whose last range fails, and
+// returns the journal it leaves behind.
+func interruptedDownload(t *testing.T, server *testRangeServer, outputPath string) *downloadJournal {
+	t.Helper()
+	size := int64(len(server.data))
+	server.failAfter = (size - 1) / journalTestRange * journalTestRange
+	server.failDelay = 200 * time.Millisecond // Let the other ranges finish first
+	defer func() { server.failAfter = 0 }()
+
+	d := NewDownloader(
+		WithConcurrency(4),
+		WithChunkBytes(journalTestRange),
+		WithRetryConfig(0, time.Millisecond, time.Millisecond),
+		WithResume(true),
+	)
+	_, err := d.downloadConcurrent(context.Background(), server.URL+"/model.tbenc?sig=old", outputPath, size, nil, computeSHA256(server.data))
+	if err == nil {
+		t.Fatal("expected the interrupted download to fail")
+	}
+
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Error("output file exists after an interrupted download")
+	}
+	journal, err := readJournal(outputPath + journalSuffix)
+	if err != nil {
+		t.Fatalf("journal not kept: %v", err)
+	}
+	if _, err := os.Stat(outputPath + partSuffix); err != nil {
+		t.Fatalf(".part file not kept: %v", err)
+	}
+	return journal
+}
+
+func countDone(j *downloadJournal, ranges int) int {
+	var n int
+	for i := 0; i < ranges; i++ {
+		if j.isDone(i) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDownloadFiles_Resume(t *testing.T) {
+	data := make([]byte, 10*journalTestRange+123)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	server := newTestRangeServer(data)
+	server.etag = `"0x8DC1"`
+	defer server.Close()
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	journal := interruptedDownload(t, server, outputPath)
+	ranges := len(splitRanges(int64(len(data)), journalTestRange))
+	done := countDone(journal, ranges)
+	if done == 0 || done == ranges {
+		t.Fatalf("journal has %d/%d ranges done, want a partial download", done, ranges)
+	}
+
+	// A new authorization yields a new URL for the same blob
+	atomic.StoreInt64(&server.rangeCount, 0)
+	d := NewDownloader(WithConcurrency(4), WithChunkBytes(journalTestRange), WithResume(true))
+	_, err := d.DownloadFiles(context.Background(), []FileTarget{{
+		URL:    server.URL + "/model.tbenc?sig=new",
+		Path:   outputPath,
+		Size:   int64(len(data)),
+		SHA256: computeSHA256(data),
+	}})
+	if err != nil {
+		t.Fatalf("resumed download failed: %v", err)
+	}
+
+	if got := atomic.LoadInt64(&server.rangeCount); got != int64(ranges-done) {
+		t.Errorf("resumed download fetched %d ranges, want %d", got, ranges-done)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("resumed file content mismatch")
+	}
+	for _, suffix := range []string{partSuffix, journalSuffix} {
+		if _, err := os.Stat(outputPath + suffix); !os.IsNotExist(err) {
+			t.Errorf("%s left behind after a complete download", suffix)
+		}
+	}
+}
+
+func TestDownloadFileConcurrent_ResumeDiscardsChangedBlob(t *testing.T) {
+	data := bytes.Repeat([]byte("v1"), 5*journalTestRange)
+	server := newTestRangeServer(data)
+	server.etag = `"v1"`
+	defer server.Close()
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	interruptedDownload(t, server, outputPath)
+
+	// The blob was replaced in place: same manifest entry and size, new ETag
+	server.data = bytes.Repeat([]byte("v2"), 5*journalTestRange)
+	server.etag = `"v2"`
+	atomic.StoreInt64(&server.rangeCount, 0)
+
+	d := NewDownloader(WithConcurrency(4), WithChunkBytes(journalTestRange), WithResume(true))
+	if _, err := d.downloadConcurrent(context.Background(), server.URL+"/model.tbenc", outputPath, int64(len(data)), nil, computeSHA256(data)); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	ranges := len(splitRanges(int64(len(data)), journalTestRange))
+	if got := atomic.LoadInt64(&server.rangeCount); got != int64(ranges) {
+		t.Errorf("fetched %d ranges, want all %d", got, ranges)
+	}
+	got, _ := os.ReadFile(outputPath)
+	if !bytes.Equal(got, server.data) {
+		t.Error("file mixes ranges of two blob versions")
+	}
+}
+
+func TestDownloadRange_SourceChanged(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, 4*journalTestRange)
+	server := newTestRangeServer(data)
+	server.etag = `"v1"`
+	defer server.Close()
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	// The range request still names the ETag the journal was started under
+	d := NewDownloader(WithRetryConfig(0, time.Millisecond, time.Millisecond))
+	f, err := os.Create(outputPath + partSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = d.downloadRange(context.Background(), f, d.newBlobURL(server.URL+"/model.tbenc"), `"v0"`, 0, journalTestRange-1, nil, nil, nil)
+	if !errors.Is(err, ErrSourceChanged) {
+		t.Errorf("expected ErrSourceChanged, got %v", err)
+	}
+}
+
+func TestOpenJournal_Validation(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+	want := downloadJournal{SHA256: "aa", Size: 3 * journalTestRange, ETag: `"e"`, RangeBytes: journalTestRange}
+
+	j := openJournal(outputPath, want, 3)
+	if j.resumed {
+		t.Fatal("fresh journal reported as resumed")
+	}
+	if err := os.WriteFile(j.partPath, make([]byte, want.Size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(j.partPath, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	j.markDone(1)
+	if err := j.save(f); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	if j := openJournal(outputPath, want, 3); !j.resumed || !j.isDone(1) || j.isDone(0) {
+		t.Errorf("matching journal not resumed: %+v", j)
+	}
+
+	prev, err := readJournal(outputPath + journalSuffix)
+	if err != nil {
+		t.Fatalf("failed to read journal: %v", err)
+	}
+	tests := []struct {
+		name   string
+		change func(*downloadJournal)
+		match  bool
+	}{
+		{"same blob", func(j *downloadJournal) {}, true},
+		{"manifest hash", func(j *downloadJournal) { j.SHA256 = "bb" }, false},
+		{"size", func(j *downloadJournal) { j.Size++ }, false},
+		{"etag", func(j *downloadJournal) { j.ETag = `"f"` }, false},
+		{"range size", func(j *downloadJournal) { j.RangeBytes = 2 * journalTestRange }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := want
+			other.Version = journalVersion
+			other.Done = make([]byte, 1)
+			tt.change(&other)
+			if got := prev.matches(&other); got != tt.match {
+				t.Errorf("matches() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+
+	// A different blob discards the journal and its data
+	if j := openJournal(outputPath, downloadJournal{SHA256: "bb", Size: want.Size, ETag: `"e"`, RangeBytes: journalTestRange}, 3); j.resumed {
+		t.Error("journal of another blob resumed")
+	}
+	if _, err := os.Stat(outputPath + partSuffix); !os.IsNotExist(err) {
+		t.Error("stale .part file not removed")
+	}
+}
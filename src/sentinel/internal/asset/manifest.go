@@ -1,26 +1,74 @@
 package asset
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
 	"time"
+
+	"trustbridge/sentinel/internal/crypto"
 )
 
-// Manifest represents the parsed JSON manifest for a tbenc/v1 encrypted asset.
+// Manifest represents the parsed JSON manifest for a tbenc encrypted asset.
 // The manifest contains metadata about the encrypted file and is used for
 // integrity verification after download.
+//
+// A single-file manifest describes one encrypted file with the top-level
+// fields. A multi-file manifest (sharded weights, tokenizer, config, ...)
+// lists each encrypted file in Files instead; the top-level chunk_bytes,
+// plaintext_bytes, ciphertext_bytes, sha256_ciphertext, integrity and
+// weights_filename are then ignored.
+//
+// With compression set, chunks are compressed before encryption and the
+// encrypted size no longer follows from plaintext_bytes, so every file must
+// record it in ciphertext_bytes.
+//
+// sha256_plaintext, top-level or per file, is the optional SHA256 of the
+// decrypted file. It is checked as plaintext streams out, catching a wrong
+// model encrypted under the right key.
+//
+// key_id and key_version name the provider key every file is encrypted
+// under, matching the tbenc headers. They are sent when authorizing so the
+// Control Plane returns that version of the key. While a multi-file asset is
+// being re-keyed one file at a time, the re-keyed entries name the new key
+// themselves and the manifest keeps the old one.
 type Manifest struct {
-	Format           string `json:"format"`             // Must be "tbenc/v1"
-	Algo             string `json:"algo"`               // Must be "aes-256-gcm-chunked"
-	ChunkBytes       int64  `json:"chunk_bytes"`        // Size of encryption chunks
-	PlaintextBytes   int64  `json:"plaintext_bytes"`    // Total size of original plaintext
-	SHA256Ciphertext string `json:"sha256_ciphertext"`  // SHA256 hash of encrypted file (64 hex chars)
-	AssetID          string `json:"asset_id"`           // Asset identifier
-	WeightsFilename  string `json:"weights_filename"`   // Filename of the encrypted weights file
+	Format           string          `json:"format"`                     // "tbenc/v1" or "tbenc/v2"
+	Algo             string          `json:"algo"`                       // Registered chunk algorithm, e.g. "aes-256-gcm-chunked"
+	Compression      string          `json:"compression,omitempty"`      // Chunk compression: "zstd", or empty for none
+	ChunkBytes       int64           `json:"chunk_bytes"`                // Size of encryption chunks
+	PlaintextBytes   int64           `json:"plaintext_bytes"`            // Total size of original plaintext
+	CiphertextBytes  int64           `json:"ciphertext_bytes,omitempty"` // Size of the encrypted file (required with compression)
+	SHA256Ciphertext string          `json:"sha256_ciphertext"`          // SHA256 hash of encrypted file (64 hex chars)
+	SHA256Plaintext  string          `json:"sha256_plaintext,omitempty"` // Optional SHA256 hash of the decrypted file
+	AssetID          string          `json:"asset_id"`                   // Asset identifier
+	KeyID            uint16          `json:"key_id,omitempty"`           // Provider key, 0 if not named
+	KeyVersion       uint16          `json:"key_version,omitempty"`      // Rotation of key_id
+	WeightsFilename  string          `json:"weights_filename"`           // Filename of the encrypted weights file
+	Integrity        *IntegrityIndex `json:"integrity,omitempty"`        // Optional per-block integrity index of the encrypted file
+	Files            []ManifestFile  `json:"files,omitempty"`            // Entries of a multi-file asset
+}
+
+// ManifestFile describes one encrypted file of a multi-file asset.
+type ManifestFile struct {
+	Name             string          `json:"name"`                       // Relative path in the model directory (e.g. "config.json")
+	Filename         string          `json:"filename"`                   // Filename of the encrypted file, next to the primary asset
+	ChunkBytes       int64           `json:"chunk_bytes"`                // Size of encryption chunks
+	PlaintextBytes   int64           `json:"plaintext_bytes"`            // Size of original plaintext
+	CiphertextBytes  int64           `json:"ciphertext_bytes,omitempty"` // Size of the encrypted file (required with compression)
+	SHA256Ciphertext string          `json:"sha256_ciphertext"`          // SHA256 hash of encrypted file (64 hex chars)
+	SHA256Plaintext  string          `json:"sha256_plaintext,omitempty"` // Optional SHA256 hash of the decrypted file
+	KeyID            uint16          `json:"key_id,omitempty"`           // Key of a file re-keyed ahead of the others, 0 for the manifest's
+	KeyVersion       uint16          `json:"key_version,omitempty"`      // Rotation of key_id
+	Integrity        *IntegrityIndex `json:"integrity,omitempty"`        // Optional per-block integrity index of the encrypted file
 }
 
 // ManifestValidationError represents a specific validation failure.
@@ -34,15 +82,20 @@ func (e *ManifestValidationError) Error() string {
 }
 
 const (
-	// Expected values for manifest validation
-	expectedFormat = "tbenc/v1"
-	expectedAlgo   = "aes-256-gcm-chunked"
+	// Supported values for manifest validation. Algorithms are validated
+	// against the crypto algorithm registry.
+	FormatTbencV1               = "tbenc/v1"
+	FormatTbencV2               = "tbenc/v2"
+	CompressionZstd             = crypto.CompressionZstd
+	AlgoAESGCMChunked           = crypto.AlgoNameAESGCMChunked
+	AlgoChaCha20Poly1305Chunked = crypto.AlgoNameChaCha20Poly1305Chunked
 
 	// Default timeout for manifest download
 	defaultManifestTimeout = 30 * time.Second
 
-	// Maximum manifest size (1MB should be plenty)
-	maxManifestSize = 1 * 1024 * 1024
+	// Maximum manifest size. Integrity indexes add 67 bytes per block, so a
+	// 100GB asset with 8MB blocks needs under 1MB of leaves.
+	maxManifestSize = 4 * 1024 * 1024
 )
 
 // Validate checks that all required fields are present and valid.
@@ -53,10 +106,10 @@ func (m *Manifest) Validate() error {
 	if m.Format == "" {
 		return &ManifestValidationError{Field: "format", Message: "required but not set"}
 	}
-	if m.Format != expectedFormat {
+	if m.Format != FormatTbencV1 && m.Format != FormatTbencV2 {
 		return &ManifestValidationError{
 			Field:   "format",
-			Message: fmt.Sprintf("must be %q, got %q", expectedFormat, m.Format),
+			Message: fmt.Sprintf("must be %q or %q, got %q", FormatTbencV1, FormatTbencV2, m.Format),
 		}
 	}
 
@@ -64,58 +117,266 @@ func (m *Manifest) Validate() error {
 	if m.Algo == "" {
 		return &ManifestValidationError{Field: "algo", Message: "required but not set"}
 	}
-	if m.Algo != expectedAlgo {
+	if _, err := crypto.LookupAlgorithmName(m.Algo); err != nil {
 		return &ManifestValidationError{
 			Field:   "algo",
-			Message: fmt.Sprintf("must be %q, got %q", expectedAlgo, m.Algo),
+			Message: fmt.Sprintf("must be one of %q, got %q", crypto.AlgorithmNames(), m.Algo),
 		}
 	}
 
-	// Check chunk_bytes
-	if m.ChunkBytes <= 0 {
+	if m.Compression != "" && m.Compression != CompressionZstd {
 		return &ManifestValidationError{
-			Field:   "chunk_bytes",
-			Message: fmt.Sprintf("must be positive, got %d", m.ChunkBytes),
+			Field:   "compression",
+			Message: fmt.Sprintf("must be %q or empty, got %q", CompressionZstd, m.Compression),
+		}
+	}
+	if m.Compression != "" && m.Format != FormatTbencV2 {
+		return &ManifestValidationError{
+			Field:   "compression",
+			Message: fmt.Sprintf("requires format %q, got %q", FormatTbencV2, m.Format),
+		}
+	}
+
+	// Check asset_id
+	if m.AssetID == "" {
+		return &ManifestValidationError{Field: "asset_id", Message: "required but not set"}
+	}
+
+	if m.KeyVersion != 0 && m.KeyID == 0 {
+		return &ManifestValidationError{Field: "key_version", Message: "requires key_id"}
+	}
+
+	if len(m.Files) > 0 {
+		return m.validateFiles()
+	}
+
+	if err := validateSizes("", m.ChunkBytes, m.PlaintextBytes); err != nil {
+		return err
+	}
+	if err := m.validateCiphertextBytes("", m.Entries()[0]); err != nil {
+		return err
+	}
+	if err := validateSHA256("sha256_ciphertext", m.SHA256Ciphertext); err != nil {
+		return err
+	}
+	if m.SHA256Plaintext != "" {
+		if err := validateSHA256("sha256_plaintext", m.SHA256Plaintext); err != nil {
+			return err
+		}
+	}
+	if m.Integrity != nil {
+		if err := m.Integrity.validate("integrity.", m.CiphertextSize()); err != nil {
+			return err
+		}
+	}
+
+	// Check weights_filename
+	if m.WeightsFilename == "" {
+		return &ManifestValidationError{Field: "weights_filename", Message: "required but not set"}
+	}
+
+	return nil
+}
+
+// validateFiles checks every entry of a multi-file manifest.
+// Names and filenames must be unique, clean relative paths so they cannot
+// escape the model or download directory they are written into.
+func (m *Manifest) validateFiles() error {
+	names := make(map[string]bool, len(m.Files))
+	filenames := make(map[string]bool, len(m.Files))
+
+	for i, f := range m.Files {
+		prefix := fmt.Sprintf("files[%d].", i)
+
+		if f.Name == "" {
+			return &ManifestValidationError{Field: prefix + "name", Message: "required but not set"}
+		}
+		if !isCleanRelPath(f.Name) {
+			return &ManifestValidationError{
+				Field:   prefix + "name",
+				Message: fmt.Sprintf("must be a clean relative path, got %q", f.Name),
+			}
+		}
+		if names[f.Name] {
+			return &ManifestValidationError{
+				Field:   prefix + "name",
+				Message: fmt.Sprintf("duplicate name %q", f.Name),
+			}
+		}
+		names[f.Name] = true
+
+		if f.Filename == "" {
+			return &ManifestValidationError{Field: prefix + "filename", Message: "required but not set"}
+		}
+		if f.KeyVersion != 0 && f.KeyID == 0 {
+			return &ManifestValidationError{Field: prefix + "key_version", Message: "requires key_id"}
+		}
+		if !isCleanRelPath(f.Filename) {
+			return &ManifestValidationError{
+				Field:   prefix + "filename",
+				Message: fmt.Sprintf("must be a clean relative path, got %q", f.Filename),
+			}
+		}
+		if filenames[f.Filename] {
+			return &ManifestValidationError{
+				Field:   prefix + "filename",
+				Message: fmt.Sprintf("duplicate filename %q", f.Filename),
+			}
+		}
+		filenames[f.Filename] = true
+
+		if err := validateSizes(prefix, f.ChunkBytes, f.PlaintextBytes); err != nil {
+			return err
+		}
+		if err := m.validateCiphertextBytes(prefix, f); err != nil {
+			return err
+		}
+		if err := validateSHA256(prefix+"sha256_ciphertext", f.SHA256Ciphertext); err != nil {
+			return err
+		}
+		if f.SHA256Plaintext != "" {
+			if err := validateSHA256(prefix+"sha256_plaintext", f.SHA256Plaintext); err != nil {
+				return err
+			}
+		}
+		if f.Integrity != nil {
+			if err := f.Integrity.validate(prefix+"integrity.", f.CiphertextSize(m.Format)); err != nil {
+				return err
+			}
 		}
 	}
 
-	// Check plaintext_bytes
-	if m.PlaintextBytes < 0 {
+	return nil
+}
+
+// isCleanRelPath reports whether p is a slash-separated relative path that
+// stays within its base directory.
+func isCleanRelPath(p string) bool {
+	return p != "." && !path.IsAbs(p) && path.Clean(p) == p && p != ".." && !strings.HasPrefix(p, "../")
+}
+
+// validateSizes checks chunk_bytes and plaintext_bytes.
+func validateSizes(prefix string, chunkBytes, plaintextBytes int64) error {
+	if chunkBytes <= 0 {
 		return &ManifestValidationError{
-			Field:   "plaintext_bytes",
-			Message: fmt.Sprintf("must be non-negative, got %d", m.PlaintextBytes),
+			Field:   prefix + "chunk_bytes",
+			Message: fmt.Sprintf("must be positive, got %d", chunkBytes),
 		}
 	}
+	if plaintextBytes < 0 {
+		return &ManifestValidationError{
+			Field:   prefix + "plaintext_bytes",
+			Message: fmt.Sprintf("must be non-negative, got %d", plaintextBytes),
+		}
+	}
+	return nil
+}
+
+// validateCiphertextBytes checks an entry's ciphertext_bytes. It is required
+// for compressed assets; otherwise it is optional but must match the size
+// implied by plaintext_bytes.
+func (m *Manifest) validateCiphertextBytes(prefix string, f ManifestFile) error {
+	field := prefix + "ciphertext_bytes"
+	if m.Compression != "" {
+		if f.CiphertextBytes < crypto.HeaderSize {
+			return &ManifestValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("must be at least %d with compression, got %d", crypto.HeaderSize, f.CiphertextBytes),
+			}
+		}
+		return nil
+	}
 
-	// Check sha256_ciphertext
-	if m.SHA256Ciphertext == "" {
-		return &ManifestValidationError{Field: "sha256_ciphertext", Message: "required but not set"}
+	if f.CiphertextBytes != 0 {
+		if want := ciphertextSize(m.Format, f.ChunkBytes, f.PlaintextBytes); f.CiphertextBytes != want {
+			return &ManifestValidationError{
+				Field:   field,
+				Message: fmt.Sprintf("must be %d for %d plaintext bytes, got %d", want, f.PlaintextBytes, f.CiphertextBytes),
+			}
+		}
 	}
-	if len(m.SHA256Ciphertext) != 64 {
+	return nil
+}
+
+// validateSHA256 checks that a hash is 64 hex characters.
+func validateSHA256(field, value string) error {
+	if value == "" {
+		return &ManifestValidationError{Field: field, Message: "required but not set"}
+	}
+	if len(value) != 64 {
 		return &ManifestValidationError{
-			Field:   "sha256_ciphertext",
-			Message: fmt.Sprintf("must be 64 hex characters, got %d", len(m.SHA256Ciphertext)),
+			Field:   field,
+			Message: fmt.Sprintf("must be 64 hex characters, got %d", len(value)),
 		}
 	}
 	// Validate it's actually hex
-	if _, err := hex.DecodeString(m.SHA256Ciphertext); err != nil {
+	if _, err := hex.DecodeString(value); err != nil {
 		return &ManifestValidationError{
-			Field:   "sha256_ciphertext",
+			Field:   field,
 			Message: fmt.Sprintf("must be valid hex: %v", err),
 		}
 	}
+	return nil
+}
 
-	// Check asset_id
-	if m.AssetID == "" {
-		return &ManifestValidationError{Field: "asset_id", Message: "required but not set"}
+// IsMultiFile reports whether the manifest lists its files in Files.
+func (m *Manifest) IsMultiFile() bool {
+	return len(m.Files) > 0
+}
+
+// Entries returns the encrypted files described by the manifest.
+// A single-file manifest yields one entry built from the top-level fields,
+// named after WeightsFilename.
+func (m *Manifest) Entries() []ManifestFile {
+	if m.IsMultiFile() {
+		return m.Files
 	}
+	return []ManifestFile{{
+		Name:             m.WeightsFilename,
+		Filename:         m.WeightsFilename,
+		ChunkBytes:       m.ChunkBytes,
+		PlaintextBytes:   m.PlaintextBytes,
+		CiphertextBytes:  m.CiphertextBytes,
+		SHA256Ciphertext: m.SHA256Ciphertext,
+		SHA256Plaintext:  m.SHA256Plaintext,
+		Integrity:        m.Integrity,
+	}}
+}
 
-	// Check weights_filename
-	if m.WeightsFilename == "" {
-		return &ManifestValidationError{Field: "weights_filename", Message: "required but not set"}
+// KeyRef returns the provider key reference recorded in the manifest.
+func (m *Manifest) KeyRef() crypto.KeyRef {
+	return crypto.KeyRef{ID: m.KeyID, Version: m.KeyVersion}
+}
+
+// FileKeyRef returns the key reference of one entry: its own if it was
+// re-keyed ahead of the others, otherwise the manifest's.
+func (m *Manifest) FileKeyRef(f ManifestFile) crypto.KeyRef {
+	if f.KeyID != 0 {
+		return crypto.KeyRef{ID: f.KeyID, Version: f.KeyVersion}
+	}
+	return m.KeyRef()
+}
+
+// TotalPlaintextBytes returns the plaintext size summed over all entries.
+func (m *Manifest) TotalPlaintextBytes() int64 {
+	var total int64
+	for _, f := range m.Entries() {
+		total += f.PlaintextBytes
 	}
+	return total
+}
 
-	return nil
+// ResolveFileURL returns the URL of a sibling file of the primary asset.
+// The last path segment of assetURL is replaced with filename and the query
+// string (e.g. a container-scoped SAS token) is kept.
+func ResolveFileURL(assetURL, filename string) (string, error) {
+	u, err := url.Parse(assetURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	u.Path = path.Join(path.Dir(u.Path), filename)
+	u.RawPath = ""
+	return u.String(), nil
 }
 
 // ParseManifest parses manifest JSON from a reader.
@@ -146,35 +407,21 @@ func DownloadManifest(ctx context.Context, url string) (*Manifest, error) {
 
 // DownloadManifestWithClient fetches and parses the manifest using a custom HTTP client.
 // This allows for custom timeouts, transport configurations, or testing with mock clients.
+// The manifest is read from any Source; the client is used by HTTP-based ones.
 func DownloadManifestWithClient(ctx context.Context, client *http.Client, manifestURL string) (*Manifest, error) {
-	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", manifestURL, nil)
+	source, err := NewSource(manifestURL, client)
 	if err != nil {
-		return nil, NewManifestError(manifestURL, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+		return nil, NewManifestError(manifestURL, 0, err)
 	}
 
-	req.Header.Set("Accept", "application/json")
-
-	// Execute request
-	resp, err := client.Do(req)
+	body, _, err := source.Open(ctx)
 	if err != nil {
-		return nil, NewNetworkError("manifest", manifestURL, err)
-	}
-	defer resp.Body.Close()
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		// Read error body for debugging (limited size)
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
-		return nil, NewManifestError(
-			manifestURL,
-			resp.StatusCode,
-			fmt.Errorf("%w: status %d: %s", ErrManifestDownloadFailed, resp.StatusCode, string(body)),
-		)
+		return nil, manifestSourceError(manifestURL, err)
 	}
+	defer body.Close()
 
 	// Parse manifest
-	manifest, err := ParseManifest(resp.Body)
+	manifest, err := ParseManifest(body)
 	if err != nil {
 		return nil, NewManifestError(manifestURL, 0, fmt.Errorf("%w: %v", ErrManifestInvalid, err))
 	}
@@ -187,13 +434,56 @@ func DownloadManifestWithClient(ctx context.Context, client *http.Client, manife
 	return manifest, nil
 }
 
+// manifestSourceError reports a failure to open the manifest. An error
+// response is retryable only if its status is transient: a manifest URL that
+// was denied is not renewed.
+func manifestSourceError(manifestURL string, err error) error {
+	var assetErr *AssetError
+	if !errors.As(err, &assetErr) {
+		return NewManifestError(manifestURL, 0, fmt.Errorf("%w: %v", ErrManifestDownloadFailed, err))
+	}
+	if assetErr.StatusCode != 0 {
+		return NewManifestError(manifestURL, assetErr.StatusCode, fmt.Errorf("%w: %v", ErrManifestDownloadFailed, assetErr.Err))
+	}
+	return &AssetError{
+		Op:        "manifest",
+		URL:       assetErr.URL,
+		Retryable: assetErr.Retryable,
+		Err:       fmt.Errorf("%w: %w", ErrManifestDownloadFailed, assetErr.Err),
+	}
+}
+
 // CiphertextSize calculates the expected size of the encrypted file based on manifest data.
+// For multi-file manifests use ManifestFile.CiphertextSize.
 // This is useful for pre-allocating download buffers or validating downloaded file size.
 // The calculation accounts for:
 //   - 32-byte header
 //   - For each chunk: 4-byte length prefix + plaintext_len + 16-byte GCM tag
+//
+// An empty tbenc/v2 file still carries one empty final record.
+// If ciphertext_bytes is set, as it must be for compressed assets, it is
+// returned instead.
 func (m *Manifest) CiphertextSize() int64 {
-	if m.PlaintextBytes == 0 {
+	if m.CiphertextBytes > 0 {
+		return m.CiphertextBytes
+	}
+	return ciphertextSize(m.Format, m.ChunkBytes, m.PlaintextBytes)
+}
+
+// CiphertextSize calculates the expected size of the entry's encrypted file.
+// The format is shared by all entries of a manifest.
+func (f *ManifestFile) CiphertextSize(format string) int64 {
+	if f.CiphertextBytes > 0 {
+		return f.CiphertextBytes
+	}
+	return ciphertextSize(format, f.ChunkBytes, f.PlaintextBytes)
+}
+
+func ciphertextSize(format string, chunkBytes, plaintextBytes int64) int64 {
+	if plaintextBytes == 0 {
+		if format == FormatTbencV2 {
+			return 32 + 4 + 16 // Header plus empty final record
+		}
 		return 32 // Just header for empty file
 	}
 
@@ -201,14 +491,14 @@ func (m *Manifest) CiphertextSize() int64 {
 	size := int64(32)
 
 	// Calculate number of full chunks and remainder
-	fullChunks := m.PlaintextBytes / m.ChunkBytes
-	remainder := m.PlaintextBytes % m.ChunkBytes
+	fullChunks := plaintextBytes / chunkBytes
+	remainder := plaintextBytes % chunkBytes
 
 	// Each chunk record: 4-byte pt_len + pt_len + 16-byte tag
 	chunkOverhead := int64(4 + 16) // Length prefix + GCM tag
 
 	// Full chunks
-	size += fullChunks * (m.ChunkBytes + chunkOverhead)
+	size += fullChunks * (chunkBytes + chunkOverhead)
 
 	// Remainder chunk (if any)
 	if remainder > 0 {
@@ -217,3 +507,22 @@ func (m *Manifest) CiphertextSize() int64 {
 
 	return size
 }
+
+// WriteJSON writes the manifest as indented JSON.
+// The output matches the Python CLI's write_manifest (two-space indent,
+// fields in struct order, no trailing newline) so manifests produced by
+// either tool are byte-identical.
+func (m *Manifest) WriteJSON(w io.Writer) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(m); err != nil {
+		return fmt.Errorf("failed to encode manifest JSON: %w", err)
+	}
+
+	if _, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
@@ -1,14 +1,18 @@
 package asset
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"trustbridge/sentinel/internal/crypto"
 )
 
 // validManifestJSON returns a valid manifest JSON string for testing.
@@ -45,6 +49,142 @@ func TestManifest_Validate_Success(t *testing.T) {
 	}
 }
 
+func TestManifest_Validate_ChaCha20Poly1305(t *testing.T) {
+	m := validManifest()
+	m.Algo = AlgoChaCha20Poly1305Chunked
+	if err := m.Validate(); err != nil {
+		t.Errorf("expected chacha20-poly1305 manifest to pass validation, got error: %v", err)
+	}
+}
+
+func TestManifest_Validate_Compression(t *testing.T) {
+	m := validManifest()
+	m.Format = FormatTbencV2
+	m.Compression = CompressionZstd
+	m.CiphertextBytes = 30000000
+	if err := m.Validate(); err != nil {
+		t.Fatalf("expected compressed manifest to pass validation, got error: %v", err)
+	}
+	if got := m.CiphertextSize(); got != 30000000 {
+		t.Errorf("CiphertextSize() = %d, want ciphertext_bytes 30000000", got)
+	}
+	if got := m.Entries()[0].CiphertextSize(m.Format); got != 30000000 {
+		t.Errorf("entry CiphertextSize() = %d, want 30000000", got)
+	}
+
+	tests := []struct {
+		name      string
+		modify    func(*Manifest)
+		wantField string
+	}{
+		{"unknown compression", func(m *Manifest) { m.Compression = "gzip" }, "compression"},
+		{"tbenc/v1 format", func(m *Manifest) { m.Format = FormatTbencV1 }, "compression"},
+		{"missing ciphertext_bytes", func(m *Manifest) { m.CiphertextBytes = 0 }, "ciphertext_bytes"},
+		{"multi-file missing ciphertext_bytes", func(m *Manifest) {
+			m.Files = []ManifestFile{{
+				Name:             "model.safetensors",
+				Filename:         "model.safetensors.tbenc",
+				ChunkBytes:       1024,
+				PlaintextBytes:   4096,
+				SHA256Ciphertext: m.SHA256Ciphertext,
+			}}
+		}, "files[0].ciphertext_bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := validManifest()
+			m.Format = FormatTbencV2
+			m.Compression = CompressionZstd
+			m.CiphertextBytes = 30000000
+			tt.modify(m)
+
+			var verr *ManifestValidationError
+			if err := m.Validate(); !errors.As(err, &verr) || verr.Field != tt.wantField {
+				t.Errorf("Validate() = %v, want error on %s", err, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestManifest_Validate_CiphertextBytesUncompressed(t *testing.T) {
+	m := validManifest()
+	m.CiphertextBytes = m.CiphertextSize()
+	if err := m.Validate(); err != nil {
+		t.Errorf("expected matching ciphertext_bytes to pass validation, got error: %v", err)
+	}
+
+	m.CiphertextBytes++
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "ciphertext_bytes") {
+		t.Errorf("Validate() = %v, want ciphertext_bytes mismatch", err)
+	}
+}
+
+func TestManifest_KeyRef(t *testing.T) {
+	m, err := ParseManifest(strings.NewReader(`{
+		"format": "tbenc/v1",
+		"algo": "aes-256-gcm-chunked",
+		"chunk_bytes": 4194304,
+		"plaintext_bytes": 1000,
+		"sha256_ciphertext": "0000000000000000000000000000000000000000000000000000000000000000",
+		"asset_id": "asset-1",
+		"key_id": 7,
+		"key_version": 3,
+		"weights_filename": "model.tbenc"
+	}`))
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if m.KeyRef() != (crypto.KeyRef{ID: 7, Version: 3}) {
+		t.Errorf("KeyRef() = %+v, want key 7 version 3", m.KeyRef())
+	}
+
+	m.KeyID = 0
+	var verr *ManifestValidationError
+	if err := m.Validate(); !errors.As(err, &verr) || verr.Field != "key_version" {
+		t.Errorf("Validate() = %v, want error on key_version", err)
+	}
+}
+
+func TestManifest_FileKeyRef(t *testing.T) {
+	m := validMultiFileManifest()
+	m.KeyID, m.KeyVersion = 7, 3
+	m.Files[1].KeyID, m.Files[1].KeyVersion = 7, 4
+	if err := m.Validate(); err != nil {
+		t.Fatalf("manifest mid-rotation rejected: %v", err)
+	}
+
+	if got := m.FileKeyRef(m.Files[0]); got != (crypto.KeyRef{ID: 7, Version: 3}) {
+		t.Errorf("FileKeyRef(files[0]) = %+v, want the manifest's key", got)
+	}
+	if got := m.FileKeyRef(m.Files[1]); got != (crypto.KeyRef{ID: 7, Version: 4}) {
+		t.Errorf("FileKeyRef(files[1]) = %+v, want its own key", got)
+	}
+
+	m.Files[1].KeyID = 0
+	var verr *ManifestValidationError
+	if err := m.Validate(); !errors.As(err, &verr) || verr.Field != "files[1].key_version" {
+		t.Errorf("Validate() = %v, want error on files[1].key_version", err)
+	}
+}
+
+func TestManifest_SHA256Plaintext(t *testing.T) {
+	m := validManifest()
+	m.SHA256Plaintext = strings.Repeat("ab", 32)
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if got := m.Entries()[0].SHA256Plaintext; got != m.SHA256Plaintext {
+		t.Errorf("entry SHA256Plaintext = %q, want %q", got, m.SHA256Plaintext)
+	}
+
+	m.SHA256Plaintext = "abc"
+	var verr *ManifestValidationError
+	if err := m.Validate(); !errors.As(err, &verr) || verr.Field != "sha256_plaintext" {
+		t.Errorf("Validate() = %v, want error on sha256_plaintext", err)
+	}
+}
+
 func TestManifest_Validate_MissingFields(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -105,7 +245,7 @@ func TestManifest_Validate_InvalidValues(t *testing.T) {
 	}{
 		{
 			name:      "wrong format",
-			modify:    func(m *Manifest) { m.Format = "tbenc/v2" },
+			modify:    func(m *Manifest) { m.Format = "tbenc/v3" },
 			wantField: "format",
 		},
 		{
@@ -159,6 +299,14 @@ func TestManifest_Validate_InvalidValues(t *testing.T) {
 	}
 }
 
+func TestManifest_Validate_FormatV2(t *testing.T) {
+	m := validManifest()
+	m.Format = FormatTbencV2
+	if err := m.Validate(); err != nil {
+		t.Errorf("tbenc/v2 manifest should be valid, got error: %v", err)
+	}
+}
+
 func TestManifest_Validate_ZeroPlaintextAllowed(t *testing.T) {
 	m := validManifest()
 	m.PlaintextBytes = 0
@@ -168,6 +316,232 @@ func TestManifest_Validate_ZeroPlaintextAllowed(t *testing.T) {
 	}
 }
 
+// validMultiFileManifest returns a valid multi-file Manifest for testing.
+func validMultiFileManifest() *Manifest {
+	return &Manifest{
+		Format:  "tbenc/v2",
+		Algo:    "aes-256-gcm-chunked",
+		AssetID: "tb-asset-123",
+		Files: []ManifestFile{
+			{
+				Name:             "model-00001-of-00002.safetensors",
+				Filename:         "model-00001-of-00002.safetensors.tbenc",
+				ChunkBytes:       4194304,
+				PlaintextBytes:   10000000,
+				SHA256Ciphertext: "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
+			},
+			{
+				Name:             "model-00002-of-00002.safetensors",
+				Filename:         "model-00002-of-00002.safetensors.tbenc",
+				ChunkBytes:       4194304,
+				PlaintextBytes:   5000000,
+				SHA256Ciphertext: "b1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
+			},
+			{
+				Name:             "tokenizer/tokenizer.json",
+				Filename:         "tokenizer.json.tbenc",
+				ChunkBytes:       1024,
+				PlaintextBytes:   2000,
+				SHA256Ciphertext: "c1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
+			},
+		},
+	}
+}
+
+func TestManifest_Validate_MultiFile(t *testing.T) {
+	m := validMultiFileManifest()
+	if err := m.Validate(); err != nil {
+		t.Fatalf("expected valid multi-file manifest, got error: %v", err)
+	}
+
+	if !m.IsMultiFile() {
+		t.Error("IsMultiFile() = false, want true")
+	}
+	if got := len(m.Entries()); got != 3 {
+		t.Errorf("len(Entries()) = %d, want 3", got)
+	}
+	if got := m.TotalPlaintextBytes(); got != 15002000 {
+		t.Errorf("TotalPlaintextBytes() = %d, want 15002000", got)
+	}
+}
+
+func TestManifest_Validate_MultiFileInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(*Manifest)
+		wantField string
+	}{
+		{
+			name:      "missing name",
+			modify:    func(m *Manifest) { m.Files[1].Name = "" },
+			wantField: "files[1].name",
+		},
+		{
+			name:      "absolute name",
+			modify:    func(m *Manifest) { m.Files[0].Name = "/etc/passwd" },
+			wantField: "files[0].name",
+		},
+		{
+			name:      "escaping name",
+			modify:    func(m *Manifest) { m.Files[0].Name = "../model.safetensors" },
+			wantField: "files[0].name",
+		},
+		{
+			name:      "unclean name",
+			modify:    func(m *Manifest) { m.Files[2].Name = "tokenizer//tokenizer.json" },
+			wantField: "files[2].name",
+		},
+		{
+			name:      "duplicate name",
+			modify:    func(m *Manifest) { m.Files[1].Name = m.Files[0].Name },
+			wantField: "files[1].name",
+		},
+		{
+			name:      "missing filename",
+			modify:    func(m *Manifest) { m.Files[0].Filename = "" },
+			wantField: "files[0].filename",
+		},
+		{
+			name:      "escaping filename",
+			modify:    func(m *Manifest) { m.Files[0].Filename = "../../etc/cron.d/x" },
+			wantField: "files[0].filename",
+		},
+		{
+			name:      "duplicate filename",
+			modify:    func(m *Manifest) { m.Files[2].Filename = m.Files[0].Filename },
+			wantField: "files[2].filename",
+		},
+		{
+			name:      "zero chunk_bytes",
+			modify:    func(m *Manifest) { m.Files[1].ChunkBytes = 0 },
+			wantField: "files[1].chunk_bytes",
+		},
+		{
+			name:      "negative plaintext_bytes",
+			modify:    func(m *Manifest) { m.Files[1].PlaintextBytes = -1 },
+			wantField: "files[1].plaintext_bytes",
+		},
+		{
+			name:      "invalid sha256",
+			modify:    func(m *Manifest) { m.Files[2].SHA256Ciphertext = "abc" },
+			wantField: "files[2].sha256_ciphertext",
+		},
+		{
+			name:      "missing asset_id",
+			modify:    func(m *Manifest) { m.AssetID = "" },
+			wantField: "asset_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := validMultiFileManifest()
+			tt.modify(m)
+			err := m.Validate()
+			if err == nil {
+				t.Fatal("expected validation error, got nil")
+			}
+			var validErr *ManifestValidationError
+			if !errors.As(err, &validErr) {
+				t.Fatalf("expected ManifestValidationError, got %T", err)
+			}
+			if validErr.Field != tt.wantField {
+				t.Errorf("expected field %q, got %q", tt.wantField, validErr.Field)
+			}
+		})
+	}
+}
+
+func TestManifest_Entries_SingleFile(t *testing.T) {
+	m := validManifest()
+
+	entries := m.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("len(Entries()) = %d, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Name != m.WeightsFilename || e.Filename != m.WeightsFilename {
+		t.Errorf("entry name/filename = %q/%q, want %q", e.Name, e.Filename, m.WeightsFilename)
+	}
+	if e.SHA256Ciphertext != m.SHA256Ciphertext || e.PlaintextBytes != m.PlaintextBytes || e.ChunkBytes != m.ChunkBytes {
+		t.Errorf("entry %+v does not match manifest %+v", e, m)
+	}
+	if e.CiphertextSize(m.Format) != m.CiphertextSize() {
+		t.Errorf("entry CiphertextSize = %d, want %d", e.CiphertextSize(m.Format), m.CiphertextSize())
+	}
+	if m.IsMultiFile() {
+		t.Error("IsMultiFile() = true, want false")
+	}
+}
+
+func TestParseManifest_MultiFile(t *testing.T) {
+	jsonData := `{
+		"format": "tbenc/v1",
+		"algo": "aes-256-gcm-chunked",
+		"asset_id": "tb-asset-123",
+		"files": [
+			{
+				"name": "config.json",
+				"filename": "config.json.tbenc",
+				"chunk_bytes": 1024,
+				"plaintext_bytes": 700,
+				"sha256_ciphertext": "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2"
+			}
+		]
+	}`
+
+	m, err := ParseManifest(strings.NewReader(jsonData))
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Name != "config.json" || m.Files[0].PlaintextBytes != 700 {
+		t.Errorf("unexpected files: %+v", m.Files)
+	}
+}
+
+func TestResolveFileURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		assetURL string
+		filename string
+		want     string
+	}{
+		{
+			name:     "keeps sas query",
+			assetURL: "https://acct.blob.core.windows.net/models/llama/model.tbenc?sv=2024&sig=abc%2Bdef",
+			filename: "config.json.tbenc",
+			want:     "https://acct.blob.core.windows.net/models/llama/config.json.tbenc?sv=2024&sig=abc%2Bdef",
+		},
+		{
+			name:     "no query",
+			assetURL: "http://localhost:9000/assets/model.tbenc",
+			filename: "shard-1.tbenc",
+			want:     "http://localhost:9000/assets/shard-1.tbenc",
+		},
+		{
+			name:     "nested filename",
+			assetURL: "https://example.com/a/model.tbenc?x=1",
+			filename: "tokenizer/tokenizer.json.tbenc",
+			want:     "https://example.com/a/tokenizer/tokenizer.json.tbenc?x=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveFileURL(tt.assetURL, tt.filename)
+			if err != nil {
+				t.Fatalf("ResolveFileURL failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveFileURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParseManifest_Success(t *testing.T) {
 	r := strings.NewReader(validManifestJSON())
 	m, err := ParseManifest(r)
@@ -401,6 +775,7 @@ func TestDownloadManifestWithClient_CustomClient(t *testing.T) {
 func TestManifest_CiphertextSize(t *testing.T) {
 	tests := []struct {
 		name           string
+		format         string
 		chunkBytes     int64
 		plaintextBytes int64
 		wantSize       int64
@@ -411,6 +786,22 @@ func TestManifest_CiphertextSize(t *testing.T) {
 			plaintextBytes: 0,
 			wantSize:       32, // Just header
 		},
+		{
+			name:           "empty file v2",
+			format:         FormatTbencV2,
+			chunkBytes:     4194304,
+			plaintextBytes: 0,
+			// Header (32) + empty final chunk (4 + 0 + 16)
+			wantSize: 32 + 20,
+		},
+		{
+			name:           "multiple chunks v2",
+			format:         FormatTbencV2,
+			chunkBytes:     1024,
+			plaintextBytes: 1025,
+			// Same framing as v1 when plaintext is non-empty
+			wantSize: 32 + 1044 + 21,
+		},
 		{
 			name:           "one byte",
 			chunkBytes:     4194304,
@@ -444,6 +835,7 @@ func TestManifest_CiphertextSize(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			m := &Manifest{
+				Format:         tt.format,
 				ChunkBytes:     tt.chunkBytes,
 				PlaintextBytes: tt.plaintextBytes,
 			}
@@ -537,3 +929,34 @@ func TestManifest_JSONRoundTrip(t *testing.T) {
 		t.Errorf("weights_filename mismatch: got %q, want %q", decoded.WeightsFilename, original.WeightsFilename)
 	}
 }
+
+func TestManifest_WriteJSON(t *testing.T) {
+	m := validManifest()
+
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+
+	// Must match Python's json.dump(manifest, f, indent=2)
+	expected := `{
+  "format": "tbenc/v1",
+  "algo": "aes-256-gcm-chunked",
+  "chunk_bytes": 4194304,
+  "plaintext_bytes": 53821440,
+  "sha256_ciphertext": "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
+  "asset_id": "tb-asset-123",
+  "weights_filename": "model.tbenc"
+}`
+	if buf.String() != expected {
+		t.Errorf("WriteJSON output mismatch:\ngot:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+
+	parsed, err := ParseManifest(&buf)
+	if err != nil {
+		t.Fatalf("ParseManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, m) {
+		t.Errorf("round trip mismatch: got %+v, want %+v", parsed, m)
+	}
+}
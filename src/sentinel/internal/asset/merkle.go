@@ -0,0 +1,466 @@
+package asset
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"trustbridge/sentinel/internal/crypto"
+)
+
+// IntegrityAlgoSHA256Merkle is the only supported integrity index algorithm.
+//
+// The ciphertext file is split into BlockBytes-sized blocks (the last one may
+// be shorter). Leaf i is SHA256(0x00 || block i) and interior nodes are
+// SHA256(0x01 || left || right), with the tree shape of RFC 6962: a node over
+// n > 1 leaves splits them at the largest power of two smaller than n.
+const IntegrityAlgoSHA256Merkle = "sha256-merkle"
+
+// DefaultIntegrityBlockBytes is the recommended block size for an index.
+// It matches DefaultChunkBytes so each download range covers whole blocks.
+const DefaultIntegrityBlockBytes = DefaultChunkBytes
+
+// MaxIntegrityBlockBytes is the largest block size accepted in an index.
+// Hashing and verification buffer a whole block per worker.
+const MaxIntegrityBlockBytes = crypto.MaxChunkBytes
+
+// Domain separation prefixes for leaf and interior node hashes.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// IntegrityIndex is the optional per-block integrity index of an encrypted file.
+//
+// Leaves lets the downloader verify every block as it lands and re-fetch only
+// the blocks that fail. Root commits to all leaves, so a single leaf can be
+// proven part of the file with an inclusion proof (see Proof).
+type IntegrityIndex struct {
+	Algo       string   `json:"algo"`        // Must be IntegrityAlgoSHA256Merkle
+	BlockBytes int64    `json:"block_bytes"` // Ciphertext bytes per leaf
+	Root       string   `json:"root"`        // Merkle root (64 hex chars)
+	Leaves     []string `json:"leaves"`      // Leaf hashes in file order (64 hex chars each)
+}
+
+// BlockMismatchError reports the blocks of a file that failed verification.
+// It wraps ErrHashMismatch.
+type BlockMismatchError struct {
+	Blocks []int // Indices of the mismatching blocks, ascending
+}
+
+func (e *BlockMismatchError) Error() string {
+	return fmt.Sprintf("%v: %d block(s) do not match the integrity index, first at block %d", ErrHashMismatch, len(e.Blocks), e.Blocks[0])
+}
+
+// Unwrap returns ErrHashMismatch for errors.Is compatibility.
+func (e *BlockMismatchError) Unwrap() error {
+	return ErrHashMismatch
+}
+
+// validate checks the index against the size of the file it describes.
+func (ix *IntegrityIndex) validate(prefix string, ciphertextSize int64) error {
+	if ix.Algo != IntegrityAlgoSHA256Merkle {
+		return &ManifestValidationError{
+			Field:   prefix + "algo",
+			Message: fmt.Sprintf("must be %q, got %q", IntegrityAlgoSHA256Merkle, ix.Algo),
+		}
+	}
+	if ix.BlockBytes <= 0 {
+		return &ManifestValidationError{
+			Field:   prefix + "block_bytes",
+			Message: fmt.Sprintf("must be positive, got %d", ix.BlockBytes),
+		}
+	}
+	if ix.BlockBytes > MaxIntegrityBlockBytes {
+		return &ManifestValidationError{
+			Field:   prefix + "block_bytes",
+			Message: fmt.Sprintf("must be at most %d, got %d", MaxIntegrityBlockBytes, ix.BlockBytes),
+		}
+	}
+
+	want := blockCount(ciphertextSize, ix.BlockBytes)
+	if int64(len(ix.Leaves)) != want {
+		return &ManifestValidationError{
+			Field:   prefix + "leaves",
+			Message: fmt.Sprintf("must have %d entries for %d ciphertext bytes, got %d", want, ciphertextSize, len(ix.Leaves)),
+		}
+	}
+
+	leaves, err := ix.leafHashes()
+	if err != nil {
+		return &ManifestValidationError{Field: prefix + "leaves", Message: err.Error()}
+	}
+	if err := validateSHA256(prefix+"root", ix.Root); err != nil {
+		return err
+	}
+	root, _ := hex.DecodeString(ix.Root)
+	if !bytes.Equal(MerkleRoot(leaves), root) {
+		return &ManifestValidationError{Field: prefix + "root", Message: "does not match leaves"}
+	}
+
+	return nil
+}
+
+// leafHashes decodes the hex leaf hashes.
+func (ix *IntegrityIndex) leafHashes() ([][]byte, error) {
+	leaves := make([][]byte, len(ix.Leaves))
+	for i, s := range ix.Leaves {
+		leaf, err := hex.DecodeString(s)
+		if err != nil || len(leaf) != sha256.Size {
+			return nil, fmt.Errorf("entry %d must be 64 hex characters", i)
+		}
+		leaves[i] = leaf
+	}
+	return leaves, nil
+}
+
+// blockRange returns the byte range [start, end] of block i in a file of size bytes.
+func (ix *IntegrityIndex) blockRange(i int, size int64) (int64, int64) {
+	start := int64(i) * ix.BlockBytes
+	end := min(start+ix.BlockBytes, size) - 1
+	return start, end
+}
+
+// Proof returns the inclusion proof of block i: the sibling hashes from the
+// leaf up to the root, hex-encoded. Verify it with VerifyProof.
+func (ix *IntegrityIndex) Proof(i int) ([]string, error) {
+	leaves, err := ix.leafHashes()
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(leaves) {
+		return nil, fmt.Errorf("block %d out of range [0, %d)", i, len(leaves))
+	}
+
+	path := merklePath(leaves, i)
+	proof := make([]string, len(path))
+	for j, h := range path {
+		proof[j] = hex.EncodeToString(h)
+	}
+	return proof, nil
+}
+
+// VerifyProof checks that block i with leaf hash leaf belongs to a tree of
+// count leaves with the given root, using an inclusion proof from Proof.
+func VerifyProof(root, leaf string, i, count int, proof []string) error {
+	rootHash, err := hex.DecodeString(root)
+	if err != nil {
+		return fmt.Errorf("invalid root: %w", err)
+	}
+	hash, err := hex.DecodeString(leaf)
+	if err != nil {
+		return fmt.Errorf("invalid leaf: %w", err)
+	}
+	path := make([][]byte, len(proof))
+	for j, s := range proof {
+		if path[j], err = hex.DecodeString(s); err != nil {
+			return fmt.Errorf("invalid proof entry %d: %w", j, err)
+		}
+	}
+	if i < 0 || i >= count {
+		return fmt.Errorf("block %d out of range [0, %d)", i, count)
+	}
+
+	computed, rest := rootFromPath(hash, i, count, path)
+	if len(rest) != 0 || !bytes.Equal(computed, rootHash) {
+		return fmt.Errorf("%w: inclusion proof for block %d does not match root", ErrHashMismatch, i)
+	}
+	return nil
+}
+
+// blockVerifier checks downloaded ranges of one file against its index.
+// It is safe for concurrent use by several range downloads.
+type blockVerifier struct {
+	ix     *IntegrityIndex
+	leaves [][]byte
+	size   int64
+}
+
+// newBlockVerifier decodes ix for a file of size bytes.
+func newBlockVerifier(ix *IntegrityIndex, size int64) (*blockVerifier, error) {
+	if ix.BlockBytes <= 0 || ix.BlockBytes > MaxIntegrityBlockBytes {
+		return nil, fmt.Errorf("integrity block size must be between 1 and %d, got %d", MaxIntegrityBlockBytes, ix.BlockBytes)
+	}
+	if got := blockCount(size, ix.BlockBytes); got != int64(len(ix.Leaves)) {
+		return nil, fmt.Errorf("%w: %d bytes is %d blocks, index has %d", ErrFileSizeMismatch, size, got, len(ix.Leaves))
+	}
+	leaves, err := ix.leafHashes()
+	if err != nil {
+		return nil, err
+	}
+	return &blockVerifier{ix: ix, leaves: leaves, size: size}, nil
+}
+
+// rangeBytes returns the download range size: chunkBytes rounded down to
+// whole blocks, and at least one block.
+func (v *blockVerifier) rangeBytes(chunkBytes int64) int64 {
+	return max(chunkBytes/v.ix.BlockBytes, 1) * v.ix.BlockBytes
+}
+
+// newRangeHasher starts verifying a range at offset start, which must be the
+// start of a block.
+func (v *blockVerifier) newRangeHasher(start int64) *rangeHasher {
+	rh := &rangeHasher{v: v, block: int(start / v.ix.BlockBytes), h: sha256.New()}
+	rh.reset()
+	return rh
+}
+
+// rangeHasher hashes the bytes of one range attempt in order, checking each
+// block as it completes.
+type rangeHasher struct {
+	v         *blockVerifier
+	block     int   // block being hashed
+	remaining int64 // bytes of the block not yet seen
+	verified  int64 // bytes of the range in verified blocks
+	h         hash.Hash
+}
+
+// reset starts hashing the current block.
+func (rh *rangeHasher) reset() {
+	start, end := rh.v.ix.blockRange(rh.block, rh.v.size)
+	rh.remaining = end - start + 1
+	rh.h.Reset()
+	rh.h.Write([]byte{merkleLeafPrefix})
+}
+
+// write feeds the next bytes of the range and returns how many bytes of
+// blocks it completed and verified. It returns an error wrapping
+// ErrHashMismatch as soon as a completed block does not match its leaf.
+func (rh *rangeHasher) write(p []byte) (int64, error) {
+	var verified int64
+	for len(p) > 0 {
+		if rh.block >= len(rh.v.leaves) {
+			return verified, fmt.Errorf("%w: data past the last block", ErrFileSizeMismatch)
+		}
+
+		n := min(int64(len(p)), rh.remaining)
+		rh.h.Write(p[:n])
+		p = p[n:]
+		rh.remaining -= n
+
+		if rh.remaining == 0 {
+			if !bytes.Equal(rh.h.Sum(nil), rh.v.leaves[rh.block]) {
+				return verified, fmt.Errorf("%w: block %d", ErrHashMismatch, rh.block)
+			}
+			start, end := rh.v.ix.blockRange(rh.block, rh.v.size)
+			verified += end - start + 1
+			rh.verified += end - start + 1
+			rh.block++
+			if rh.block < len(rh.v.leaves) {
+				rh.reset()
+			}
+		}
+	}
+	return verified, nil
+}
+
+// HashLeaf returns the leaf hash of one ciphertext block.
+func HashLeaf(block []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleLeafPrefix})
+	h.Write(block)
+	return h.Sum(nil)
+}
+
+// hashNode returns the hash of an interior node.
+func hashNode(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{merkleNodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// MerkleRoot returns the root over leaf hashes. The root of an empty tree is
+// SHA256 of the empty string.
+func MerkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return hashNode(MerkleRoot(leaves[:k]), MerkleRoot(leaves[k:]))
+}
+
+// merklePath returns the sibling hashes of leaf i, leaf level first.
+func merklePath(leaves [][]byte, i int) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if i < k {
+		return append(merklePath(leaves[:k], i), MerkleRoot(leaves[k:]))
+	}
+	return append(merklePath(leaves[k:], i-k), MerkleRoot(leaves[:k]))
+}
+
+// rootFromPath recomputes the root of a count-leaf tree from leaf i and its
+// path, returning the unused tail of path.
+func rootFromPath(leaf []byte, i, count int, path [][]byte) ([]byte, [][]byte) {
+	if count <= 1 {
+		return leaf, path
+	}
+	k := splitPoint(count)
+	var sub []byte
+	if i < k {
+		sub, path = rootFromPath(leaf, i, k, path)
+	} else {
+		sub, path = rootFromPath(leaf, i-k, count-k, path)
+	}
+	if len(path) == 0 {
+		return nil, nil
+	}
+	sibling := path[0]
+	if i < k {
+		return hashNode(sub, sibling), path[1:]
+	}
+	return hashNode(sibling, sub), path[1:]
+}
+
+// splitPoint returns the largest power of two smaller than n (n > 1).
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// blockCount returns the number of blocks covering size bytes.
+func blockCount(size, blockBytes int64) int64 {
+	return (size + blockBytes - 1) / blockBytes
+}
+
+// BuildIntegrityIndex hashes a file into an integrity index with blocks of
+// blockBytes. Blocks are hashed in parallel across all CPUs.
+func BuildIntegrityIndex(filePath string, blockBytes int64) (*IntegrityIndex, error) {
+	if blockBytes <= 0 || blockBytes > MaxIntegrityBlockBytes {
+		return nil, fmt.Errorf("block size must be between 1 and %d, got %d", MaxIntegrityBlockBytes, blockBytes)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	ix := &IntegrityIndex{
+		Algo:       IntegrityAlgoSHA256Merkle,
+		BlockBytes: blockBytes,
+		Leaves:     make([]string, blockCount(info.Size(), blockBytes)),
+	}
+
+	leaves := make([][]byte, len(ix.Leaves))
+	err = hashBlocks(f, info.Size(), ix, func(i int, leaf []byte) {
+		leaves[i] = leaf
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, leaf := range leaves {
+		ix.Leaves[i] = hex.EncodeToString(leaf)
+	}
+	ix.Root = hex.EncodeToString(MerkleRoot(leaves))
+	return ix, nil
+}
+
+// VerifyFileIntegrity checks every block of a file against the index, in
+// parallel across all CPUs. A file whose blocks do not all match returns a
+// *BlockMismatchError listing them, so callers can re-fetch just those blocks.
+func VerifyFileIntegrity(filePath string, ix *IntegrityIndex) error {
+	if ix.BlockBytes <= 0 || ix.BlockBytes > MaxIntegrityBlockBytes {
+		return NewVerifyError(filePath, fmt.Errorf("integrity block size must be between 1 and %d, got %d", MaxIntegrityBlockBytes, ix.BlockBytes))
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return NewVerifyError(filePath, fmt.Errorf("failed to open file: %w", err))
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return NewVerifyError(filePath, fmt.Errorf("failed to stat file: %w", err))
+	}
+	if got := blockCount(info.Size(), ix.BlockBytes); got != int64(len(ix.Leaves)) {
+		return NewVerifyError(filePath, fmt.Errorf("%w: %d bytes is %d blocks, index has %d", ErrFileSizeMismatch, info.Size(), got, len(ix.Leaves)))
+	}
+
+	want, err := ix.leafHashes()
+	if err != nil {
+		return NewVerifyError(filePath, err)
+	}
+
+	bad := make([]bool, len(want))
+	err = hashBlocks(f, info.Size(), ix, func(i int, leaf []byte) {
+		bad[i] = !bytes.Equal(leaf, want[i])
+	})
+	if err != nil {
+		return NewVerifyError(filePath, err)
+	}
+
+	var mismatch BlockMismatchError
+	for i, b := range bad {
+		if b {
+			mismatch.Blocks = append(mismatch.Blocks, i)
+		}
+	}
+	if len(mismatch.Blocks) > 0 {
+		return NewVerifyError(filePath, &mismatch)
+	}
+	return nil
+}
+
+// hashBlocks reads every block of r with one worker per CPU and calls done
+// with each block index and leaf hash. done may be called concurrently for
+// different blocks.
+func hashBlocks(r io.ReaderAt, size int64, ix *IntegrityIndex, done func(i int, leaf []byte)) error {
+	count := int(blockCount(size, ix.BlockBytes))
+	workers := min(runtime.NumCPU(), count)
+
+	blocks := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, ix.BlockBytes)
+			for i := range blocks {
+				start, end := ix.blockRange(i, size)
+				block := buf[:end-start+1]
+				if _, err := r.ReadAt(block, start); err != nil && !(errors.Is(err, io.EOF) && start+int64(len(block)) == size) {
+					errOnce.Do(func() { firstErr = fmt.Errorf("failed to read block %d: %w", i, err) })
+					continue
+				}
+				done(i, HashLeaf(block))
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		blocks <- i
+	}
+	close(blocks)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,409 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// buildTestIndex writes data to a temp file and builds its integrity index.
+func buildTestIndex(t *testing.T, data []byte, blockBytes int64) *IntegrityIndex {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "index-src.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	ix, err := BuildIntegrityIndex(path, blockBytes)
+	if err != nil {
+		t.Fatalf("BuildIntegrityIndex failed: %v", err)
+	}
+	return ix
+}
+
+// testData returns size bytes of non-repeating content.
+func testData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i*7 + i/251)
+	}
+	return data
+}
+
+// corruptingServer serves data with Range support and flips a byte in the
+// first responses that cover a corrupted offset.
+type corruptingServer struct {
+	*httptest.Server
+	data []byte
+
+	mu       sync.Mutex
+	corrupt  map[int64]int // offset -> remaining corrupted responses
+	requests map[string]int
+}
+
+func newCorruptingServer(data []byte) *corruptingServer {
+	cs := &corruptingServer{data: data, corrupt: map[int64]int{}, requests: map[string]int{}}
+	cs.Server = httptest.NewServer(http.HandlerFunc(cs.handle))
+	return cs
+}
+
+func (cs *corruptingServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(cs.data)))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	start, end := int64(0), int64(len(cs.data)-1)
+	status := http.StatusOK
+	if rh := r.Header.Get("Range"); rh != "" {
+		fmt.Sscanf(rh, "bytes=%d-%d", &start, &end)
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(cs.data)))
+	}
+
+	body := append([]byte(nil), cs.data[start:end+1]...)
+
+	cs.mu.Lock()
+	cs.requests[r.Header.Get("Range")]++
+	for off, n := range cs.corrupt {
+		if n > 0 && off >= start && off <= end {
+			body[off-start] ^= 0xFF
+			cs.corrupt[off] = n - 1
+		}
+	}
+	cs.mu.Unlock()
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+func (cs *corruptingServer) requestCount(rangeHeader string) int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.requests[rangeHeader]
+}
+
+func TestMerkleRoot_Shape(t *testing.T) {
+	l := [][]byte{HashLeaf([]byte("a")), HashLeaf([]byte("b")), HashLeaf([]byte("c"))}
+
+	// Three leaves split 2+1
+	want := hashNode(hashNode(l[0], l[1]), l[2])
+	if got := MerkleRoot(l); !bytes.Equal(got, want) {
+		t.Errorf("root = %x, want %x", got, want)
+	}
+
+	if got := MerkleRoot(l[:1]); !bytes.Equal(got, l[0]) {
+		t.Error("root of one leaf should be the leaf")
+	}
+
+	// Leaf and node hashes are domain separated
+	if bytes.Equal(HashLeaf(append(append([]byte{}, l[0]...), l[1]...)), hashNode(l[0], l[1])) {
+		t.Error("leaf hash collides with node hash")
+	}
+}
+
+func TestIntegrityIndex_ProofRoundTrip(t *testing.T) {
+	for count := 1; count <= 9; count++ {
+		ix := buildTestIndex(t, testData(count*16), 16)
+		if len(ix.Leaves) != count {
+			t.Fatalf("got %d leaves, want %d", len(ix.Leaves), count)
+		}
+
+		for i := 0; i < count; i++ {
+			proof, err := ix.Proof(i)
+			if err != nil {
+				t.Fatalf("Proof(%d) failed: %v", i, err)
+			}
+			if err := VerifyProof(ix.Root, ix.Leaves[i], i, count, proof); err != nil {
+				t.Errorf("count %d block %d: %v", count, i, err)
+			}
+
+			// A proof does not verify another leaf
+			other := ix.Leaves[(i+1)%count]
+			if count > 1 {
+				if err := VerifyProof(ix.Root, other, i, count, proof); !errors.Is(err, ErrHashMismatch) {
+					t.Errorf("count %d block %d: wrong leaf accepted: %v", count, i, err)
+				}
+			}
+		}
+	}
+}
+
+func TestIntegrityIndex_ProofOutOfRange(t *testing.T) {
+	ix := buildTestIndex(t, testData(64), 16)
+	if _, err := ix.Proof(4); err == nil {
+		t.Error("expected error for block past the end")
+	}
+	if _, err := ix.Proof(-1); err == nil {
+		t.Error("expected error for negative block")
+	}
+}
+
+func TestVerifyFileIntegrity(t *testing.T) {
+	data := testData(10*1024 + 100)
+	ix := buildTestIndex(t, data, 1024)
+	if len(ix.Leaves) != 11 {
+		t.Fatalf("got %d leaves, want 11", len(ix.Leaves))
+	}
+
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFileIntegrity(path, ix); err != nil {
+		t.Fatalf("VerifyFileIntegrity failed on intact file: %v", err)
+	}
+
+	// Corrupt blocks 2 and 10 (the short last block)
+	data[2*1024+5] ^= 0xFF
+	data[len(data)-1] ^= 0xFF
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := VerifyFileIntegrity(path, ix)
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+	var mismatch *BlockMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected BlockMismatchError, got %T", err)
+	}
+	if fmt.Sprint(mismatch.Blocks) != "[2 10]" {
+		t.Errorf("mismatching blocks = %v, want [2 10]", mismatch.Blocks)
+	}
+}
+
+func TestManifest_Validate_Integrity(t *testing.T) {
+	data := testData(100)
+	base := func() *Manifest {
+		return &Manifest{
+			Format:           FormatTbencV1,
+			Algo:             AlgoAESGCMChunked,
+			ChunkBytes:       1024,
+			PlaintextBytes:   int64(len(data)) - 32 - 20,
+			SHA256Ciphertext: computeSHA256(data),
+			AssetID:          "asset",
+			WeightsFilename:  "model.tbenc",
+			Integrity:        buildTestIndex(t, data, 16),
+		}
+	}
+
+	if m := base(); m.CiphertextSize() != int64(len(data)) {
+		t.Fatalf("test manifest describes %d bytes, want %d", m.CiphertextSize(), len(data))
+	}
+	if err := base().Validate(); err != nil {
+		t.Fatalf("valid manifest rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m *Manifest)
+		field  string
+	}{
+		{"wrong algo", func(m *Manifest) { m.Integrity.Algo = "md5" }, "integrity.algo"},
+		{"zero block size", func(m *Manifest) { m.Integrity.BlockBytes = 0 }, "integrity.block_bytes"},
+		{"huge block size", func(m *Manifest) { m.Integrity.BlockBytes = 1 << 40 }, "integrity.block_bytes"},
+		{"missing leaf", func(m *Manifest) { m.Integrity.Leaves = m.Integrity.Leaves[1:] }, "integrity.leaves"},
+		{"bad leaf hex", func(m *Manifest) { m.Integrity.Leaves[0] = "zz" }, "integrity.leaves"},
+		{"root mismatch", func(m *Manifest) {
+			m.Integrity.Leaves[0], m.Integrity.Leaves[1] = m.Integrity.Leaves[1], m.Integrity.Leaves[0]
+		}, "integrity.root"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := base()
+			tt.modify(m)
+			err := m.Validate()
+			var verr *ManifestValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected ManifestValidationError, got %v", err)
+			}
+			if verr.Field != tt.field {
+				t.Errorf("field = %q, want %q", verr.Field, tt.field)
+			}
+		})
+	}
+}
+
+func TestDownloadFileVerified_RetriesCorruptRange(t *testing.T) {
+	data := testData(256 * 1024)
+	ix := buildTestIndex(t, data, 16*1024)
+	server := newCorruptingServer(data)
+	defer server.Close()
+
+	// Corrupt one byte in the third 64KB range once
+	server.corrupt[2*64*1024+100] = 1
+
+	d := NewDownloader(
+		WithConcurrency(4),
+		WithChunkBytes(64*1024),
+		WithRetryConfig(3, 10*time.Millisecond, 50*time.Millisecond),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	result, err := d.DownloadFileVerified(context.Background(), server.URL+"/model.tbenc", outputPath, int64(len(data)), ix)
+	if err != nil {
+		t.Fatalf("DownloadFileVerified failed: %v", err)
+	}
+	if result.BytesWritten != int64(len(data)) {
+		t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, len(data))
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded content mismatch")
+	}
+
+	// Only the corrupt range was fetched twice
+	if n := server.requestCount("bytes=131072-196607"); n != 2 {
+		t.Errorf("corrupt range requested %d times, want 2", n)
+	}
+	if n := server.requestCount("bytes=0-65535"); n != 1 {
+		t.Errorf("intact range requested %d times, want 1", n)
+	}
+}
+
+func TestDownloadFileVerified_RetryResumesAfterVerifiedBlocks(t *testing.T) {
+	data := testData(256 * 1024)
+	ix := buildTestIndex(t, data, 16*1024)
+	server := newCorruptingServer(data)
+	defer server.Close()
+
+	// Corrupt the third 16KB block of the third 64KB range once
+	server.corrupt[2*64*1024+40*1024] = 1
+
+	var mu sync.Mutex
+	var maxProgress int64
+	d := NewDownloader(
+		WithConcurrency(4),
+		WithChunkBytes(64*1024),
+		WithRetryConfig(3, 10*time.Millisecond, 50*time.Millisecond),
+		WithProgressCallback(func(downloaded, total int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			maxProgress = max(maxProgress, downloaded)
+		}),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	if _, err := d.DownloadFileVerified(context.Background(), server.URL+"/model.tbenc", outputPath, int64(len(data)), ix); err != nil {
+		t.Fatalf("DownloadFileVerified failed: %v", err)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data) {
+		t.Error("downloaded content mismatch")
+	}
+
+	// The retry starts at the failed block, and the blocks verified before
+	// it are not counted twice
+	if n := server.requestCount("bytes=163840-196607"); n != 1 {
+		t.Errorf("retry from the corrupt block requested %d times, want 1", n)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if maxProgress > int64(len(data)) {
+		t.Errorf("progress reached %d of %d bytes", maxProgress, len(data))
+	}
+}
+
+func TestDownloadFileVerified_PersistentCorruption(t *testing.T) {
+	data := testData(128 * 1024)
+	ix := buildTestIndex(t, data, 16*1024)
+	server := newCorruptingServer(data)
+	defer server.Close()
+	server.corrupt[70000] = 100
+
+	d := NewDownloader(
+		WithChunkBytes(64*1024),
+		WithRetryConfig(2, 10*time.Millisecond, 50*time.Millisecond),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	_, err := d.DownloadFileVerified(context.Background(), server.URL+"/model.tbenc", outputPath, int64(len(data)), ix)
+	if !errors.Is(err, ErrHashMismatch) {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+	if _, statErr := os.Stat(outputPath); !os.IsNotExist(statErr) {
+		t.Error("corrupt download should be removed")
+	}
+}
+
+func TestDownloadFileVerified_RepairsSingleThreadedDownload(t *testing.T) {
+	// Smaller than ChunkBytes, so fetched with one GET
+	data := testData(40 * 1024)
+	ix := buildTestIndex(t, data, 4*1024)
+	server := newCorruptingServer(data)
+	defer server.Close()
+	server.corrupt[5*4*1024+1] = 1
+
+	d := NewDownloader(
+		WithChunkBytes(64*1024),
+		WithRetryConfig(1, 10*time.Millisecond, 50*time.Millisecond),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	if _, err := d.DownloadFileVerified(context.Background(), server.URL+"/model.tbenc", outputPath, int64(len(data)), ix); err != nil {
+		t.Fatalf("DownloadFileVerified failed: %v", err)
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("repaired content mismatch")
+	}
+	if n := server.requestCount("bytes=20480-24575"); n != 1 {
+		t.Errorf("corrupt block re-fetched %d times, want 1", n)
+	}
+}
+
+func TestDownloadFiles_IntegrityIndex(t *testing.T) {
+	data := testData(200 * 1024)
+	ix := buildTestIndex(t, data, 32*1024)
+	server := newCorruptingServer(data)
+	defer server.Close()
+	server.corrupt[150000] = 1
+
+	d := NewDownloader(
+		WithChunkBytes(64*1024),
+		WithRetryConfig(2, 10*time.Millisecond, 50*time.Millisecond),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	// The whole-file hash is deliberately wrong: the index is used instead
+	targets := []FileTarget{{
+		URL:       server.URL + "/model.tbenc",
+		Path:      outputPath,
+		Size:      int64(len(data)),
+		SHA256:    hex.EncodeToString(make([]byte, 32)),
+		Integrity: ix,
+	}}
+
+	if _, err := d.DownloadFiles(context.Background(), targets); err != nil {
+		t.Fatalf("DownloadFiles failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded content mismatch")
+	}
+}
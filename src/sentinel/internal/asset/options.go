@@ -13,6 +13,7 @@ const (
 	DefaultMaxRetries      = 3
 	DefaultInitialBackoff  = 1 * time.Second
 	DefaultMaxBackoff      = 30 * time.Second
+	DefaultMaxURLRefreshes = 3
 
 	// Validation limits
 	MinConcurrency = 1
@@ -32,6 +33,14 @@ type DownloadConfig struct {
 	// Default: 4, Range: 1-32
 	Concurrency int
 
+	// MaxAdaptiveConcurrency enables adaptive concurrency for range
+	// downloads: starting at Concurrency, the number of range requests in
+	// flight across all downloads and streams of a Downloader grows while
+	// throughput improves, up to this value, and halves on throttling or
+	// latency spikes, down to MinConcurrency. Zero keeps Concurrency fixed.
+	// Default: 0, Range: 0 or 1-32
+	MaxAdaptiveConcurrency int
+
 	// ChunkBytes is the size of each download chunk for range requests.
 	// Default: 8MB, Range: 1KB-64MB
 	ChunkBytes int
@@ -55,6 +64,26 @@ type DownloadConfig struct {
 	// ProgressCallback is called periodically to report download progress.
 	// May be nil.
 	ProgressCallback ProgressFunc
+
+	// StatsCallback is called once per second during range downloads with
+	// the current concurrency and throughput. May be nil.
+	StatsCallback StatsFunc
+
+	// Resume keeps interrupted range downloads as a .part file with a
+	// journal of completed ranges, so a later download of the same blob
+	// fetches only the missing ranges.
+	// Default: false
+	Resume bool
+
+	// URLRefresh is called when a request fails because its SAS URL
+	// expired, to obtain a new URL for the same blob. May be nil, in which
+	// case an expired URL fails the download.
+	URLRefresh URLRefreshFunc
+
+	// MaxURLRefreshes is the number of times URLRefresh may be called for
+	// one file.
+	// Default: 3
+	MaxURLRefreshes int
 }
 
 // DefaultDownloadConfig returns a DownloadConfig with default values.
@@ -67,6 +96,7 @@ func DefaultDownloadConfig() *DownloadConfig {
 		InitialBackoff:   DefaultInitialBackoff,
 		MaxBackoff:       DefaultMaxBackoff,
 		ProgressCallback: nil,
+		MaxURLRefreshes:  DefaultMaxURLRefreshes,
 	}
 }
 
@@ -79,6 +109,13 @@ func (c *DownloadConfig) Validate() {
 		c.Concurrency = MaxConcurrency
 	}
 
+	if c.MaxAdaptiveConcurrency < 0 {
+		c.MaxAdaptiveConcurrency = 0
+	}
+	if c.MaxAdaptiveConcurrency > MaxConcurrency {
+		c.MaxAdaptiveConcurrency = MaxConcurrency
+	}
+
 	if c.ChunkBytes < MinChunkBytes {
 		c.ChunkBytes = MinChunkBytes
 	}
@@ -101,6 +138,10 @@ func (c *DownloadConfig) Validate() {
 	if c.MaxBackoff <= 0 {
 		c.MaxBackoff = DefaultMaxBackoff
 	}
+
+	if c.MaxURLRefreshes < 0 {
+		c.MaxURLRefreshes = 0
+	}
 }
 
 // DownloaderOption is a functional option for configuring a Downloader.
@@ -124,6 +165,16 @@ func WithConcurrency(n int) DownloaderOption {
 	}
 }
 
+// WithAdaptiveConcurrency lets the number of concurrent range requests
+// adapt between MinConcurrency and max, starting at the configured
+// concurrency. The value will be clamped to the range [0, 32]; 0 disables
+// adaptation.
+func WithAdaptiveConcurrency(max int) DownloaderOption {
+	return func(d *Downloader) {
+		d.config.MaxAdaptiveConcurrency = max
+	}
+}
+
 // WithChunkBytes sets the size of each download chunk for range requests.
 // The value will be clamped to the range [1KB, 64MB].
 func WithChunkBytes(size int) DownloaderOption {
@@ -149,6 +200,40 @@ func WithProgressCallback(fn ProgressFunc) DownloaderOption {
 	}
 }
 
+// WithStatsCallback sets the function that receives download statistics.
+func WithStatsCallback(fn StatsFunc) DownloaderOption {
+	return func(d *Downloader) {
+		d.config.StatsCallback = fn
+	}
+}
+
+// WithBandwidthLimiter limits the bandwidth of all downloads through l,
+// which may be shared with other downloaders.
+func WithBandwidthLimiter(l *BandwidthLimiter) DownloaderOption {
+	return func(d *Downloader) {
+		d.limiter = l
+	}
+}
+
+// WithResume enables resumable downloads. Servers must support range
+// requests and send an ETag; other downloads start from zero as before.
+func WithResume(enabled bool) DownloaderOption {
+	return func(d *Downloader) {
+		d.config.Resume = enabled
+	}
+}
+
+// WithURLRefresh sets the function that replaces an expired SAS URL and how
+// many times it may be called per file. While a new URL is fetched, all
+// requests for that file wait; failed and pending ranges then continue with
+// the new URL.
+func WithURLRefresh(fn URLRefreshFunc, maxRefreshes int) DownloaderOption {
+	return func(d *Downloader) {
+		d.config.URLRefresh = fn
+		d.config.MaxURLRefreshes = maxRefreshes
+	}
+}
+
 // WithRequestTimeout sets the timeout for each HTTP request.
 func WithRequestTimeout(timeout time.Duration) DownloaderOption {
 	return func(d *Downloader) {
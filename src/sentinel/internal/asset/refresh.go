@@ -0,0 +1,119 @@
+package asset
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// URLRefreshFunc obtains new authorization after the SAS token in expired
+// stopped working and returns a fresh URL for the same blob.
+type URLRefreshFunc func(ctx context.Context, expired string) (string, error)
+
+// blobURL is the current URL of one blob, shared by every request that
+// downloads it.
+//
+// When a request fails with an expired SAS, renew fetches a new URL once
+// (single-flight) through the downloader's URLRefreshFunc. Until it is
+// available, get blocks, so every worker pauses before its next request;
+// requests already in flight under the old URL fail, find the URL renewed
+// and continue with the new one.
+type blobURL struct {
+	refresh URLRefreshFunc
+	max     int // Refreshes allowed
+
+	mu      sync.Mutex
+	url     string
+	gen     int           // Incremented by every refresh
+	pending chan struct{} // Closed when the refresh in flight completes
+	count   int
+	err     error // Sticky failure of a refresh
+}
+
+// newBlobURL returns the shared URL for a download starting at url.
+func (d *Downloader) newBlobURL(url string) *blobURL {
+	return &blobURL{
+		refresh: d.config.URLRefresh,
+		max:     d.config.MaxURLRefreshes,
+		url:     url,
+	}
+}
+
+// get returns the current URL and its generation, waiting while a refresh
+// is in flight.
+func (b *blobURL) get(ctx context.Context) (string, int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.wait(ctx); err != nil {
+		return "", 0, err
+	}
+	return b.url, b.gen, b.err
+}
+
+// wait blocks until no refresh is in flight. b.mu must be held; it is
+// released while waiting.
+func (b *blobURL) wait(ctx context.Context) error {
+	for b.pending != nil {
+		pending := b.pending
+		b.mu.Unlock()
+		select {
+		case <-pending:
+		case <-ctx.Done():
+			b.mu.Lock()
+			return ctx.Err()
+		}
+		b.mu.Lock()
+	}
+	return nil
+}
+
+// renew replaces the URL of generation gen, which failed with cause. If the
+// URL was already renewed since gen, it returns at once. Without a refresh
+// function, or once MaxURLRefreshes is used up, cause is returned.
+func (b *blobURL) renew(ctx context.Context, gen int, cause error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	if b.err != nil {
+		return b.err
+	}
+	if gen != b.gen {
+		return nil
+	}
+	if b.refresh == nil {
+		return cause
+	}
+	if b.count >= b.max {
+		return fmt.Errorf("%w (after %d URL refreshes)", cause, b.count)
+	}
+
+	b.count++
+	b.pending = make(chan struct{})
+	expired := b.url
+	b.mu.Unlock()
+
+	log.Printf("SAS URL expired for %s, requesting a new one (%d/%d)", sanitizeURL(expired), b.count, b.max)
+	url, err := b.refresh(ctx, expired)
+
+	b.mu.Lock()
+	if err != nil {
+		b.err = fmt.Errorf("failed to refresh expired URL: %w", err)
+	} else {
+		b.url = url
+		b.gen++
+	}
+	close(b.pending)
+	b.pending = nil
+	return b.err
+}
+
+// String returns the current URL.
+func (b *blobURL) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.url
+}
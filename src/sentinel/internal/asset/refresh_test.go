@@ -0,0 +1,273 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expiringBlobServer serves a blob to URLs whose "sig" token has not
+// expired. Tokens expire ttl after they are issued.
+type expiringBlobServer struct {
+	*httptest.Server
+	data  []byte
+	ttl   time.Duration
+	delay time.Duration // Per range request
+
+	mu      sync.Mutex
+	expiry  map[string]time.Time
+	latest  string
+	issued  int
+	expired int64 // Requests rejected with 403
+}
+
+func newExpiringBlobServer(data []byte, ttl time.Duration) *expiringBlobServer {
+	s := &expiringBlobServer{data: data, ttl: ttl, expiry: make(map[string]time.Time)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// issue returns a URL with a new token.
+func (s *expiringBlobServer) issue() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issued++
+	s.latest = fmt.Sprintf("t%d", s.issued)
+	s.expiry[s.latest] = time.Now().Add(s.ttl)
+	return s.URL + "/model.tbenc?sig=" + s.latest
+}
+
+// refresh is a URLRefreshFunc that fails the test if it is asked to replace
+// a token that was already replaced.
+func (s *expiringBlobServer) refresh(t *testing.T, calls *int32) URLRefreshFunc {
+	return func(ctx context.Context, expired string) (string, error) {
+		atomic.AddInt32(calls, 1)
+		s.mu.Lock()
+		latest := s.latest
+		s.mu.Unlock()
+		if !strings.HasSuffix(expired, "sig="+latest) {
+			t.Errorf("refresh of %s, which was already replaced by %s", expired, latest)
+		}
+		return s.issue(), nil
+	}
+}
+
+func (s *expiringBlobServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	expiry, ok := s.expiry[r.URL.Query().Get("sig")]
+	s.mu.Unlock()
+	if !ok || time.Now().After(expiry) {
+		atomic.AddInt64(&s.expired, 1)
+		http.Error(w, "AuthenticationFailed", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", `"blob"`)
+	if r.Method == "HEAD" {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.data)))
+		return
+	}
+
+	var start, end int64
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.data)))
+		w.Write(s.data)
+		return
+	}
+	time.Sleep(s.delay)
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(s.data)))
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+	w.Write(s.data[start : end+1])
+}
+
+func TestDownloadFileConcurrent_RefreshesExpiredURL(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 128*1024) // 2MB
+	server := newExpiringBlobServer(data, 60*time.Millisecond)
+	server.delay = 10 * time.Millisecond
+	defer server.Close()
+
+	var calls int32
+	d := NewDownloader(
+		WithConcurrency(4),
+		WithChunkBytes(64*1024),
+		WithRetryConfig(0, time.Millisecond, time.Millisecond),
+		WithURLRefresh(server.refresh(t, &calls), 100),
+	)
+	outputPath := filepath.Join(t.TempDir(), "model.tbenc")
+
+	if _, err := d.DownloadFileConcurrent(context.Background(), server.issue(), outputPath, int64(len(data))); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+
+	got, _ := os.ReadFile(outputPath)
+	if !bytes.Equal(got, data) {
+		t.Error("content mismatch after URL refreshes")
+	}
+	if calls == 0 || atomic.LoadInt64(&server.expired) == 0 {
+		t.Fatalf("no token expired during the download (refreshes %d), test too fast", calls)
+	}
+}
+
+func TestDownloadFile_RefreshesExpiredURL(t *testing.T) {
+	data := []byte("small encrypted config")
+	server := newExpiringBlobServer(data, time.Minute)
+	defer server.Close()
+
+	var calls int32
+	d := NewDownloader(WithURLRefresh(server.refresh(t, &calls), 1))
+	outputPath := filepath.Join(t.TempDir(), "config.tbenc")
+
+	// A URL issued by a previous authorization
+	stale := server.URL + "/model.tbenc?sig=t0"
+	server.latest = "t0"
+	if _, err := d.DownloadFile(context.Background(), stale, outputPath); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("refresh called %d times, want 1", calls)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data) {
+		t.Error("content mismatch")
+	}
+}
+
+func TestDownloadFileConcurrent_RefreshLimit(t *testing.T) {
+	data := bytes.Repeat([]byte{0x42}, 256*1024)
+	server := newExpiringBlobServer(data, -time.Second) // Every token is born expired
+	defer server.Close()
+
+	var calls int32
+	d := NewDownloader(
+		WithChunkBytes(64*1024),
+		WithRetryConfig(0, time.Millisecond, time.Millisecond),
+		WithURLRefresh(server.refresh(t, &calls), 2),
+	)
+	_, err := d.DownloadFileConcurrent(context.Background(), server.issue(), filepath.Join(t.TempDir(), "model.tbenc"), int64(len(data)))
+	if !IsSASExpired(err) {
+		t.Fatalf("expected SAS expired error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("refresh called %d times, want the limit of 2", calls)
+	}
+}
+
+func TestDownloadFileConcurrent_RefreshFails(t *testing.T) {
+	data := bytes.Repeat([]byte{0x42}, 256*1024)
+	server := newExpiringBlobServer(data, -time.Second)
+	defer server.Close()
+
+	denied := errors.New("contract suspended")
+	d := NewDownloader(
+		WithChunkBytes(64*1024),
+		WithURLRefresh(func(ctx context.Context, expired string) (string, error) {
+			return "", denied
+		}, 3),
+	)
+	_, err := d.DownloadFileConcurrent(context.Background(), server.issue(), filepath.Join(t.TempDir(), "model.tbenc"), int64(len(data)))
+	if !errors.Is(err, denied) {
+		t.Errorf("expected the refresh error, got %v", err)
+	}
+}
+
+func TestBlobURL_SingleFlight(t *testing.T) {
+	release := make(chan struct{})
+	var calls int32
+	d := NewDownloader(WithURLRefresh(func(ctx context.Context, expired string) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "https://blob/new", nil
+	}, 3))
+	src := d.newBlobURL("https://blob/old")
+	expired := NewRangeError("https://blob/old", http.StatusForbidden, 0, 0, nil)
+
+	// Every worker fails under generation 0 at once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := src.renew(context.Background(), 0, expired); err != nil {
+				t.Errorf("renew() error = %v", err)
+			}
+		}()
+	}
+
+	// Workers asking for the URL pause until the refresh completes
+	got := make(chan string, 1)
+	go func() {
+		for atomic.LoadInt32(&calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		url, _, _ := src.get(context.Background())
+		got <- url
+	}()
+	select {
+	case url := <-got:
+		t.Fatalf("get() returned %q during the refresh", url)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	wg.Wait()
+	if url := <-got; url != "https://blob/new" {
+		t.Errorf("get() = %q after the refresh, want the new URL", url)
+	}
+	if calls != 1 {
+		t.Errorf("refresh called %d times, want 1", calls)
+	}
+	if _, gen, _ := src.get(context.Background()); gen != 1 {
+		t.Errorf("generation = %d, want 1", gen)
+	}
+}
+
+func TestDownloadRange_ContinuesFromLastByte(t *testing.T) {
+	data := bytes.Repeat([]byte("range data "), 10000)
+	var starts []int64
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		mu.Lock()
+		starts = append(starts, start)
+		first := len(starts) == 1
+		mu.Unlock()
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if first {
+			// Drop the connection halfway through the body
+			w.Write(data[start : start+(end-start+1)/2])
+			return
+		}
+		w.Write(data[start : end+1])
+	}))
+	defer server.Close()
+
+	d := NewDownloader(WithRetryConfig(1, time.Millisecond, time.Millisecond))
+	buf := make([]byte, len(data))
+	written, err := d.downloadRange(context.Background(), &bufferWriterAt{buf: buf}, d.newBlobURL(server.URL), "", 0, int64(len(data))-1, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("downloadRange() error = %v", err)
+	}
+	if written != int64(len(data)) || !bytes.Equal(buf, data) {
+		t.Errorf("downloadRange() wrote %d bytes, content match %v", written, bytes.Equal(buf, data))
+	}
+	if len(starts) != 2 || starts[1] != int64(len(data))/2 {
+		t.Errorf("requests started at %v, want the retry to start at %d", starts, len(data)/2)
+	}
+}
@@ -0,0 +1,137 @@
+package asset
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// s3Source reads an object of S3-compatible storage through a presigned GET
+// URL.
+//
+// A presigned URL is signed for one method, so the object is probed with a
+// one-byte range GET instead of HEAD. Errors carry an XML body whose code
+// tells an expired signature, which a new URL restores, from other access
+// denials, which it does not; both are 403.
+type s3Source struct {
+	client *http.Client
+	url    string
+}
+
+// Stat requests the first byte to learn size and ETag.
+func (s *s3Source) Stat(ctx context.Context) (SourceInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return SourceInfo{}, NewDownloadError(s.url, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return SourceInfo{}, NewNetworkError("download", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return SourceInfo{
+			Size:   parseContentRangeSize(resp.Header.Get("Content-Range")),
+			Ranges: true,
+			ETag:   resp.Header.Get("ETag"),
+		}, nil
+	case http.StatusOK:
+		return SourceInfo{Size: resp.ContentLength, ETag: resp.Header.Get("ETag")}, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		// S3 rejects any range of an empty object
+		return SourceInfo{ETag: resp.Header.Get("ETag")}, nil
+	}
+	return SourceInfo{}, s.statusError("download", resp, 0, 0)
+}
+
+// ReadRange sends a range request, with If-Match when etag is set. S3
+// answers a range it cannot honour with an error, never the whole object.
+func (s *s3Source) ReadRange(ctx context.Context, start, end int64, etag string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, NewRangeError(s.url, 0, start, end, err)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, NewNetworkError("range", s.url, err)
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		defer resp.Body.Close()
+		return nil, s.statusError("range", resp, start, end)
+	}
+	return resp.Body, nil
+}
+
+// Open sends a GET request for the whole object.
+func (s *s3Source) Open(ctx context.Context) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, 0, NewDownloadError(s.url, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, 0, NewNetworkError("download", s.url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, 0, s.statusError("download", resp, 0, 0)
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// s3Error is the XML body of an S3 error response.
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// statusError classifies an unsuccessful response by its S3 error code.
+func (s *s3Source) statusError(op string, resp *http.Response, start, end int64) *AssetError {
+	status := resp.StatusCode
+	var body s3Error
+	xml.Unmarshal([]byte(errorBody(resp)), &body)
+	detail := fmt.Errorf("%w: status %d: %s: %s", ErrDownloadFailed, status, body.Code, body.Message)
+
+	var err *AssetError
+	switch {
+	case s3Expired(body):
+		err = sourceError(op, s.url, status, start, end, true, ErrSASExpired)
+	case status == http.StatusPreconditionFailed:
+		err = sourceError(op, s.url, status, start, end, false, ErrSourceChanged)
+	case body.Code == "SlowDown" || body.Code == "RequestTimeout" || body.Code == "InternalError":
+		err = sourceError(op, s.url, status, start, end, true, detail)
+	default:
+		// Other denials (bad signature, missing permission) stay denied
+		err = sourceError(op, s.url, status, start, end, isRetryableStatusCode(status), detail)
+	}
+	err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	return err
+}
+
+// s3Expired reports whether an S3 error means the presigned URL or its
+// temporary credentials expired.
+func s3Expired(e s3Error) bool {
+	switch e.Code {
+	case "ExpiredToken", "TokenRefreshRequired":
+		return true
+	case "AccessDenied":
+		return strings.Contains(strings.ToLower(e.Message), "expired")
+	}
+	return false
+}
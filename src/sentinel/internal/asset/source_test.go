@@ -0,0 +1,247 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewSource_Schemes(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://account.blob.core.windows.net/c/model.tbenc?sig=abc", "http"},
+		{"http://edc.example.com/public/asset", "http"},
+		{"https://bucket.s3.amazonaws.com/model.tbenc?X-Amz-Signature=abc", "s3"},
+		{"s3+https://minio.example.com/bucket/model.tbenc?X-Amz-Expires=60", "s3"},
+		{"file:///var/lib/assets/model.tbenc", "file"},
+		{"file://localhost/var/lib/assets/model.tbenc", "file"},
+		{"/var/lib/assets/model.tbenc", "file"},
+	}
+	for _, tt := range tests {
+		source, err := NewSource(tt.url, nil)
+		if err != nil {
+			t.Errorf("NewSource(%q) error = %v", tt.url, err)
+			continue
+		}
+		var got string
+		switch source.(type) {
+		case *httpSource:
+			got = "http"
+		case *s3Source:
+			got = "s3"
+		case *fileSource:
+			got = "file"
+		}
+		if got != tt.want {
+			t.Errorf("NewSource(%q) = %T, want %s source", tt.url, source, tt.want)
+		}
+	}
+
+	if s, _ := NewSource("s3+https://minio.example.com/bucket/model.tbenc", nil); s.(*s3Source).url != "https://minio.example.com/bucket/model.tbenc" {
+		t.Errorf("s3+https URL not rewritten: %q", s.(*s3Source).url)
+	}
+
+	for _, bad := range []string{"ftp://host/model.tbenc", "file://remote/model.tbenc", "relative/model.tbenc"} {
+		if _, err := NewSource(bad, nil); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("NewSource(%q) error = %v, want ErrInvalidURL", bad, err)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.tbenc")
+	data := []byte("0123456789abcdef")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := NewSource("file://"+filepath.ToSlash(path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	info, err := source.Stat(ctx)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size != int64(len(data)) || !info.Ranges || info.ETag == "" {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	body, err := source.ReadRange(ctx, 4, 9, info.ETag)
+	if err != nil {
+		t.Fatalf("ReadRange() error = %v", err)
+	}
+	got, _ := io.ReadAll(body)
+	body.Close()
+	if string(got) != "456789" {
+		t.Errorf("ReadRange() = %q, want %q", got, "456789")
+	}
+
+	if _, err := source.ReadRange(ctx, 10, 16, ""); err == nil {
+		t.Error("ReadRange() past the end succeeded")
+	}
+
+	body, size, err := source.Open(ctx)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, _ = io.ReadAll(body)
+	body.Close()
+	if size != int64(len(data)) || !bytes.Equal(got, data) {
+		t.Errorf("Open() = %d bytes %q", size, got)
+	}
+
+	// A replaced file no longer matches the ETag
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	_, err = source.ReadRange(ctx, 0, 3, info.ETag)
+	if !errors.Is(err, ErrSourceChanged) {
+		t.Errorf("ReadRange() after change error = %v, want ErrSourceChanged", err)
+	}
+
+	// Local failures are never retried
+	missing, _ := NewSource(filepath.Join(t.TempDir(), "missing.tbenc"), nil)
+	_, _, err = missing.Open(ctx)
+	var assetErr *AssetError
+	if !errors.As(err, &assetErr) || assetErr.Retryable {
+		t.Errorf("Open() of a missing file error = %v, want a non-retryable AssetError", err)
+	}
+}
+
+func TestS3Source_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		expired   bool
+		retryable bool
+	}{
+		{
+			name:      "expired signature",
+			status:    http.StatusForbidden,
+			body:      `<Error><Code>AccessDenied</Code><Message>Request has expired</Message></Error>`,
+			expired:   true,
+			retryable: true,
+		},
+		{
+			name:      "expired token",
+			status:    http.StatusBadRequest,
+			body:      `<Error><Code>ExpiredToken</Code><Message>The provided token has expired.</Message></Error>`,
+			expired:   true,
+			retryable: true,
+		},
+		{
+			name:   "signature mismatch",
+			status: http.StatusForbidden,
+			body:   `<Error><Code>SignatureDoesNotMatch</Code><Message>The request signature we calculated does not match</Message></Error>`,
+		},
+		{
+			name:   "no such key",
+			status: http.StatusNotFound,
+			body:   `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`,
+		},
+		{
+			name:      "slow down",
+			status:    http.StatusServiceUnavailable,
+			body:      `<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>`,
+			retryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/xml")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			source, _ := NewSource(server.URL+"/bucket/model.tbenc?X-Amz-Signature=abc", nil)
+			_, err := source.ReadRange(context.Background(), 0, 99, "")
+
+			var assetErr *AssetError
+			if !errors.As(err, &assetErr) {
+				t.Fatalf("ReadRange() error = %v, want an AssetError", err)
+			}
+			if got := errors.Is(err, ErrSASExpired); got != tt.expired {
+				t.Errorf("expired = %v, want %v (%v)", got, tt.expired, err)
+			}
+			if assetErr.Retryable != tt.retryable {
+				t.Errorf("retryable = %v, want %v (%v)", assetErr.Retryable, tt.retryable, err)
+			}
+		})
+	}
+}
+
+func TestS3Source_StatWithRangeGet(t *testing.T) {
+	data := []byte("s3 object contents")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A presigned GET URL is not valid for HEAD
+		if r.Method != "GET" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "model.tbenc", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	source, _ := NewSource("s3+"+server.URL+"/bucket/model.tbenc", nil)
+	info, err := source.Stat(context.Background())
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size != int64(len(data)) || !info.Ranges {
+		t.Errorf("Stat() = %+v, want size %d with ranges", info, len(data))
+	}
+}
+
+func TestDownloadFileConcurrent_FileSource(t *testing.T) {
+	data := bytes.Repeat([]byte("offline "), 16*1024)
+	srcPath := filepath.Join(t.TempDir(), "model.tbenc")
+	if err := os.WriteFile(srcPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewDownloader(WithConcurrency(4), WithChunkBytes(16*1024))
+	outputPath := filepath.Join(t.TempDir(), "out.tbenc")
+	if _, err := d.DownloadFileConcurrent(context.Background(), "file://"+filepath.ToSlash(srcPath), outputPath, int64(len(data))); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if got, _ := os.ReadFile(outputPath); !bytes.Equal(got, data) {
+		t.Error("content mismatch")
+	}
+}
+
+func TestDownloadManifest_FileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(validManifestJSON()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := DownloadManifest(context.Background(), "file://"+filepath.ToSlash(path))
+	if err != nil {
+		t.Fatalf("DownloadManifest() error = %v", err)
+	}
+	if m.AssetID != "tb-asset-123" {
+		t.Errorf("AssetID = %q", m.AssetID)
+	}
+
+	_, err = DownloadManifest(context.Background(), filepath.Join(filepath.Dir(path), "missing.json"))
+	var assetErr *AssetError
+	if !errors.As(err, &assetErr) || assetErr.Retryable || !errors.Is(err, ErrManifestDownloadFailed) {
+		t.Errorf("missing manifest error = %v, want a non-retryable ErrManifestDownloadFailed", err)
+	}
+}
@@ -0,0 +1,357 @@
+package asset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+)
+
+// OpenStream returns a reader that yields the content at url strictly in order
+// without writing it to disk.
+//
+// When the server supports HTTP Range requests, up to Concurrency ranges of
+// ChunkBytes each are fetched ahead of the reader, so memory use is bounded by
+// Concurrency*ChunkBytes. Their requests count towards the concurrency limit
+// shared with the other downloads and streams of d. Otherwise a single
+// streaming GET is used.
+//
+// The totalSize parameter should be provided from the manifest; if the server
+// reports a different size the stream fails immediately with ErrFileSizeMismatch.
+// The caller must Close the returned reader, which cancels any in-flight requests.
+func (d *Downloader) OpenStream(ctx context.Context, url string, totalSize int64) (io.ReadCloser, error) {
+	return d.openStream(ctx, url, totalSize, nil)
+}
+
+// OpenStreamVerified opens a stream like OpenStream and checks it against an
+// integrity index.
+//
+// Ranges are aligned to index blocks and delivered only after all their
+// blocks verify; a corrupt block is re-fetched from the start of that block.
+// When the blob has to be streamed with a single GET, each block is checked
+// as it passes and a mismatch fails the stream.
+func (d *Downloader) OpenStreamVerified(ctx context.Context, url string, totalSize int64, ix *IntegrityIndex) (io.ReadCloser, error) {
+	if ix == nil {
+		return nil, errors.New("integrity index is required")
+	}
+	return d.openStream(ctx, url, totalSize, ix)
+}
+
+// openStream implements OpenStream, verifying blocks against ix when it is
+// not nil.
+func (d *Downloader) openStream(ctx context.Context, url string, totalSize int64, ix *IntegrityIndex) (io.ReadCloser, error) {
+	src := d.newBlobURL(url)
+	probe, err := d.probe(ctx, src)
+	supportsRange, serverSize := probe.Ranges, probe.Size
+	if err != nil {
+		log.Printf("Range check failed, falling back to single-stream download: %v", err)
+		return d.openGetStream(ctx, src, totalSize, ix)
+	}
+
+	if totalSize == 0 {
+		totalSize = serverSize
+	}
+	if serverSize > 0 && totalSize != serverSize {
+		return nil, NewDownloadError(url, 0, fmt.Errorf("%w: expected %d bytes, server reports %d", ErrFileSizeMismatch, totalSize, serverSize))
+	}
+
+	if !supportsRange || totalSize <= 0 {
+		log.Printf("Server doesn't support range requests or size unknown, falling back to single-stream download")
+		return d.openGetStream(ctx, src, totalSize, ix)
+	}
+
+	var verifier *blockVerifier
+	if ix != nil {
+		if verifier, err = newBlockVerifier(ix, totalSize); err != nil {
+			return nil, NewDownloadError(url, 0, err)
+		}
+	}
+
+	return d.openRangeStream(ctx, src, totalSize, verifier), nil
+}
+
+// openGetStream streams the whole blob from a single request.
+func (d *Downloader) openGetStream(ctx context.Context, src *blobURL, totalSize int64, ix *IntegrityIndex) (io.ReadCloser, error) {
+	body, size, err := d.getCurrent(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	if totalSize <= 0 {
+		totalSize = size
+	}
+
+	s := &getStream{
+		ctx:   ctx,
+		url:   src.String(),
+		body:  body,
+		total: totalSize,
+		d:     d,
+	}
+	if ix != nil {
+		verifier, err := newBlockVerifier(ix, totalSize)
+		if err != nil {
+			body.Close()
+			return nil, NewDownloadError(s.url, 0, err)
+		}
+		s.blocks = verifier.newRangeHasher(0)
+	}
+	return s, nil
+}
+
+// getStream wraps a GET response body with cancellation, progress reporting
+// and a final size check.
+type getStream struct {
+	ctx       context.Context
+	url       string
+	body      io.ReadCloser
+	total     int64
+	delivered int64
+	d         *Downloader
+	blocks    *rangeHasher // nil unless verifying
+}
+
+func (s *getStream) Read(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, NewNetworkError("stream", s.url, err)
+	}
+
+	n, err := s.body.Read(p)
+	if n > 0 {
+		if s.blocks != nil {
+			if _, err := s.blocks.write(p[:n]); err != nil {
+				return 0, NewDownloadError(s.url, 0, err)
+			}
+		}
+		if err := s.d.limiter.WaitN(s.ctx, n); err != nil {
+			return n, NewNetworkError("stream", s.url, err)
+		}
+		s.delivered += int64(n)
+		if s.d.config.ProgressCallback != nil {
+			s.d.config.ProgressCallback(s.delivered, s.total)
+		}
+	}
+
+	if err == io.EOF {
+		if s.total > 0 && s.delivered != s.total {
+			return n, NewDownloadError(s.url, 0, fmt.Errorf("%w: expected %d bytes, got %d", ErrFileSizeMismatch, s.total, s.delivered))
+		}
+		return n, io.EOF
+	}
+	if err != nil {
+		return n, NewNetworkError("stream", s.url, err)
+	}
+	return n, nil
+}
+
+func (s *getStream) Close() error {
+	return s.body.Close()
+}
+
+// rangeStream fetches ranges concurrently and delivers them in order.
+type rangeStream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	src    *blobURL
+	url    string
+	total  int64
+	d      *Downloader
+
+	verifier *blockVerifier // nil unless verifying
+
+	ranges  []rangeSpec
+	results []chan rangeData // one per range, buffered
+	slots   chan struct{}    // bounds ranges held in memory
+
+	schedulerDone chan struct{}
+	stopSampling  func() // Of the shared concurrency controller
+	wg            sync.WaitGroup
+	closeOnce     sync.Once
+
+	errMu    sync.Mutex
+	firstErr error
+
+	// Reader state (not safe for concurrent Read calls)
+	cur               []byte // unread part of the current range
+	holding           bool   // current range holds a slot
+	next              int
+	delivered         int64
+	err               error
+	lastLoggedPercent int
+}
+
+// rangeData is a downloaded range or the error that prevented it.
+type rangeData struct {
+	buf []byte
+	err error
+}
+
+// bufferWriterAt adapts a range buffer to the absolute offsets used by downloadRange.
+type bufferWriterAt struct {
+	buf  []byte
+	base int64
+}
+
+func (b *bufferWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	rel := off - b.base
+	if rel < 0 || rel+int64(len(p)) > int64(len(b.buf)) {
+		return 0, fmt.Errorf("write at %d outside range buffer", off)
+	}
+	return copy(b.buf[rel:], p), nil
+}
+
+// openRangeStream starts the range scheduler and returns the ordered reader.
+func (d *Downloader) openRangeStream(ctx context.Context, src *blobURL, totalSize int64, verifier *blockVerifier) *rangeStream {
+	streamCtx, cancel := context.WithCancel(ctx)
+
+	// Whole blocks per range when verifying
+	rangeBytes := int64(d.config.ChunkBytes)
+	if verifier != nil {
+		rangeBytes = verifier.rangeBytes(rangeBytes)
+	}
+	ranges := splitRanges(totalSize, rangeBytes)
+	s := &rangeStream{
+		ctx:               streamCtx,
+		cancel:            cancel,
+		src:               src,
+		url:               src.String(),
+		total:             totalSize,
+		d:                 d,
+		verifier:          verifier,
+		ranges:            ranges,
+		results:           make([]chan rangeData, len(ranges)),
+		slots:             make(chan struct{}, d.config.Concurrency),
+		schedulerDone:     make(chan struct{}),
+		stopSampling:      d.ctl.startSampling(d.sampleInterval),
+		lastLoggedPercent: -1,
+	}
+	for i := range s.results {
+		s.results[i] = make(chan rangeData, 1)
+	}
+
+	go s.schedule()
+
+	return s
+}
+
+// schedule launches range downloads in order, never holding more than
+// Concurrency ranges that the reader has not yet consumed. Each request also
+// waits for the shared concurrency controller.
+func (s *rangeStream) schedule() {
+	defer close(s.schedulerDone)
+
+	for i, r := range s.ranges {
+		select {
+		case s.slots <- struct{}{}:
+		case <-s.ctx.Done():
+			return
+		}
+
+		s.wg.Add(1)
+		go func(i int, r rangeSpec) {
+			defer s.wg.Done()
+
+			buf := make([]byte, r.end-r.start+1)
+			_, err := s.d.downloadRange(s.ctx, &bufferWriterAt{buf: buf, base: r.start}, s.src, "", r.start, r.end, nil, s.verifier, s.d.ctl)
+			if err != nil {
+				s.setErr(err)
+				s.cancel()
+			}
+			s.results[i] <- rangeData{buf: buf, err: err}
+		}(i, r)
+	}
+}
+
+// setErr records the first download error so it is reported instead of the
+// cancellation errors it causes in other ranges.
+func (s *rangeStream) setErr(err error) {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.firstErr == nil {
+		s.firstErr = err
+	}
+}
+
+func (s *rangeStream) downloadErr(fallback error) error {
+	s.errMu.Lock()
+	defer s.errMu.Unlock()
+	if s.firstErr != nil {
+		return s.firstErr
+	}
+	return fallback
+}
+
+// Read implements io.Reader, blocking until the next range in order is available.
+func (s *rangeStream) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// Stop delivering as soon as the stream is closed or a range fails
+	if s.err == nil && s.ctx.Err() != nil {
+		s.err = s.downloadErr(NewNetworkError("stream", s.url, s.ctx.Err()))
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	for len(s.cur) == 0 {
+		// Current range fully consumed: let the scheduler fetch another
+		if s.holding {
+			<-s.slots
+			s.holding = false
+		}
+
+		if s.next == len(s.ranges) {
+			s.err = io.EOF
+			return 0, io.EOF
+		}
+
+		select {
+		case res := <-s.results[s.next]:
+			if res.err != nil {
+				s.err = s.downloadErr(res.err)
+				return 0, s.err
+			}
+			s.cur = res.buf
+			s.holding = true
+			s.next++
+		case <-s.ctx.Done():
+			s.err = s.downloadErr(NewNetworkError("stream", s.url, s.ctx.Err()))
+			return 0, s.err
+		}
+	}
+
+	n := copy(p, s.cur)
+	s.cur = s.cur[n:]
+	s.delivered += int64(n)
+	s.reportProgress()
+
+	return n, nil
+}
+
+// reportProgress invokes the progress callback and logs at 10% intervals.
+func (s *rangeStream) reportProgress() {
+	if s.d.config.ProgressCallback != nil {
+		s.d.config.ProgressCallback(s.delivered, s.total)
+	}
+
+	percent := int(float64(s.delivered) / float64(s.total) * 100)
+	if percent/10 > s.lastLoggedPercent/10 {
+		log.Printf("Stream progress: %d%% (%d/%d bytes)", percent, s.delivered, s.total)
+		s.lastLoggedPercent = percent
+	}
+}
+
+// Close cancels outstanding range requests and waits for them to exit.
+func (s *rangeStream) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancel()
+		<-s.schedulerDone
+		s.wg.Wait()
+		s.stopSampling()
+	})
+	return nil
+}
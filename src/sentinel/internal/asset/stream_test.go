@@ -0,0 +1,227 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenStream_RangesInOrder(t *testing.T) {
+	size := 1024*1024 + 123
+	testData := make([]byte, size)
+	for i := range testData {
+		testData[i] = byte(i * 31)
+	}
+
+	server := newTestRangeServer(testData)
+	defer server.Close()
+
+	var lastProgress int64
+	d := NewDownloader(
+		WithConcurrency(4),
+		WithChunkBytes(64*1024),
+		WithProgressCallback(func(downloaded, total int64) {
+			atomic.StoreInt64(&lastProgress, downloaded)
+		}),
+	)
+
+	stream, err := d.OpenStream(context.Background(), server.URL+"/test.bin", int64(size))
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	// Read in odd-sized pieces to cross range boundaries
+	var got bytes.Buffer
+	if _, err := io.CopyBuffer(&got, stream, make([]byte, 7777)); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	if !bytes.Equal(got.Bytes(), testData) {
+		t.Error("streamed content does not match original")
+	}
+	if atomic.LoadInt64(&lastProgress) != int64(size) {
+		t.Errorf("final progress = %d, want %d", lastProgress, size)
+	}
+	// HEAD plus one request per range
+	if reqs := atomic.LoadInt64(&server.requestCount); reqs != 1+int64((size+64*1024-1)/(64*1024)) {
+		t.Errorf("unexpected request count: %d", reqs)
+	}
+}
+
+func TestOpenStream_BoundedReadAhead(t *testing.T) {
+	testData := make([]byte, 64*1024)
+	server := newTestRangeServer(testData)
+	defer server.Close()
+
+	d := NewDownloader(WithConcurrency(2), WithChunkBytes(1024))
+
+	stream, err := d.OpenStream(context.Background(), server.URL+"/test.bin", int64(len(testData)))
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	// Without a reader, only Concurrency ranges may be fetched
+	time.Sleep(100 * time.Millisecond)
+	if reqs := atomic.LoadInt64(&server.requestCount); reqs > 1+2 {
+		t.Errorf("fetched %d ranges ahead of the reader, want at most 2", reqs-1)
+	}
+}
+
+func TestOpenStream_FallbackToGet(t *testing.T) {
+	testData := bytes.Repeat([]byte("stream-fallback-"), 1000)
+	server := newTestRangeServer(testData)
+	server.rangeDisabled = true
+	defer server.Close()
+
+	d := NewDownloader(WithChunkBytes(1024))
+
+	stream, err := d.OpenStream(context.Background(), server.URL+"/test.bin", int64(len(testData)))
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, testData) {
+		t.Error("streamed content does not match original")
+	}
+}
+
+func TestOpenStream_SizeMismatch(t *testing.T) {
+	testData := make([]byte, 4096)
+	server := newTestRangeServer(testData)
+	defer server.Close()
+
+	d := NewDownloader(WithChunkBytes(1024))
+
+	_, err := d.OpenStream(context.Background(), server.URL+"/test.bin", 5000)
+	if !errors.Is(err, ErrFileSizeMismatch) {
+		t.Errorf("expected ErrFileSizeMismatch, got %v", err)
+	}
+}
+
+func TestOpenStream_RangeError(t *testing.T) {
+	testData := make([]byte, 256*1024)
+	server := newTestRangeServer(testData)
+	server.failAfter = 128 * 1024
+	server.failWithCode = 403
+	defer server.Close()
+
+	d := NewDownloader(
+		WithConcurrency(4),
+		WithChunkBytes(32*1024),
+		WithRetryConfig(0, time.Millisecond, time.Millisecond),
+	)
+
+	stream, err := d.OpenStream(context.Background(), server.URL+"/test.bin", int64(len(testData)))
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	defer stream.Close()
+
+	got, err := io.ReadAll(stream)
+	if !IsSASExpired(err) {
+		t.Errorf("expected SAS expired error, got: %v", err)
+	}
+	if len(got) > 128*1024 {
+		t.Errorf("read %d bytes past the failing range", len(got))
+	}
+}
+
+func TestOpenStream_CloseEarly(t *testing.T) {
+	testData := make([]byte, 512*1024)
+	server := newTestRangeServer(testData)
+	server.delayMs = 10
+	defer server.Close()
+
+	d := NewDownloader(WithConcurrency(4), WithChunkBytes(16*1024))
+
+	stream, err := d.OpenStream(context.Background(), server.URL+"/test.bin", int64(len(testData)))
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+
+	buf := make([]byte, 1000)
+	if _, err := io.ReadFull(stream, buf); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		stream.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not return")
+	}
+
+	if _, err := stream.Read(buf); err == nil {
+		t.Error("expected error reading after Close")
+	}
+}
+
+func TestOpenStreamVerified_RetriesCorruptRange(t *testing.T) {
+	data := testData(256 * 1024)
+	ix := buildTestIndex(t, data, 16*1024)
+	server := newCorruptingServer(data)
+	defer server.Close()
+
+	// Corrupt the second block of the second 64KB range once
+	server.corrupt[64*1024+20*1024] = 1
+
+	d := NewDownloader(
+		WithConcurrency(2),
+		WithChunkBytes(64*1024),
+		WithRetryConfig(3, 10*time.Millisecond, 50*time.Millisecond),
+	)
+	stream, err := d.OpenStreamVerified(context.Background(), server.URL+"/model.tbenc", int64(len(data)), ix)
+	if err != nil {
+		t.Fatalf("OpenStreamVerified failed: %v", err)
+	}
+	defer stream.Close()
+
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("streamed content does not match original")
+	}
+	if n := server.requestCount("bytes=81920-131071"); n != 1 {
+		t.Errorf("retry from the corrupt block requested %d times, want 1", n)
+	}
+}
+
+func TestOpenStreamVerified_FallbackToGetMismatch(t *testing.T) {
+	data := testData(64 * 1024)
+	tampered := append([]byte(nil), data...)
+	tampered[40*1024] ^= 0xFF
+	ix := buildTestIndex(t, data, 16*1024)
+
+	server := newTestRangeServer(tampered)
+	server.rangeDisabled = true
+	defer server.Close()
+
+	d := NewDownloader(WithChunkBytes(16 * 1024))
+	stream, err := d.OpenStreamVerified(context.Background(), server.URL+"/model.tbenc", int64(len(data)), ix)
+	if err != nil {
+		t.Fatalf("OpenStreamVerified failed: %v", err)
+	}
+	defer stream.Close()
+
+	if _, err := io.ReadAll(stream); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("read error = %v, want ErrHashMismatch", err)
+	}
+}
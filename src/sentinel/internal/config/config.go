@@ -12,25 +12,44 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"trustbridge/sentinel/internal/asset"
+	"trustbridge/sentinel/internal/rangeserver"
 )
 
 // Default values for optional configuration
 const (
 	DefaultTargetDir           = "/mnt/resource/trustbridge"
 	DefaultPipePath            = "/dev/shm/model-pipe"
+	DefaultModelDir            = "/dev/shm/model"
 	DefaultReadySignal         = "/dev/shm/weights/ready.signal"
 	DefaultRuntimeURL          = "http://127.0.0.1:8081"
 	DefaultPublicAddr          = "0.0.0.0:8000"
 	DefaultHealthAddr          = "0.0.0.0:8001"
 	DefaultDownloadConcurrency = 4
 	DefaultDownloadChunkBytes  = 8388608 // 8MB
+	DefaultDecryptWorkers      = 1
+	DefaultHydrateMode         = HydrateModeDisk
+	DefaultInMemoryMaxBytes    = 64 * 1024 * 1024 // 64MB
+	DefaultFIFOMaxSessions     = 5
+	DefaultMaxURLRefreshes     = 3
+	DefaultDeliveryMode        = DeliveryModeFIFO
+	DefaultHandoffSocket       = "/dev/shm/trustbridge-handoff.sock"
+	DefaultRangeAddr           = "127.0.0.1:8090"
 	DefaultLogLevel            = "info"
 
+	// Upper bound of adaptive download concurrency per file
+	DefaultDownloadMaxConcurrency = 16
+
 	// Validation limits
 	MinDownloadConcurrency = 1
 	MaxDownloadConcurrency = 32
 	MinDownloadChunkBytes  = 1024             // 1KB
 	MaxDownloadChunkBytes  = 64 * 1024 * 1024 // 64MB
+	MinDecryptWorkers      = 1
+	MaxDecryptWorkers      = 64
+	MaxInMemoryMaxBytes    = 1024 * 1024 * 1024 // 1GB
+	MaxFIFOMaxSessions     = 1000
 
 	// Billing defaults
 	DefaultBillingInterval  = 60 * time.Second
@@ -38,6 +57,46 @@ const (
 	DefaultMeteringEndpoint = "https://marketplaceapi.microsoft.com"
 )
 
+// Hydrate modes
+const (
+	// HydrateModeDisk downloads the full ciphertext to TargetDir, verifies its
+	// hash, then decrypts from disk.
+	HydrateModeDisk = "disk"
+
+	// HydrateModeStream downloads, hashes and decrypts in a single pass
+	// straight into the FIFO without writing ciphertext to disk.
+	HydrateModeStream = "stream"
+)
+
+// Delivery modes
+const (
+	// DeliveryModeFIFO hands decrypted files to the runtime through FIFOs and
+	// tmpfs files at well-known paths.
+	DeliveryModeFIFO = "fifo"
+
+	// DeliveryModeMemfd decrypts every file into a sealed memfd and passes
+	// read-only descriptors over the Unix socket at TB_HANDOFF_SOCKET.
+	DeliveryModeMemfd = "memfd"
+
+	// DeliveryModeHTTP serves decrypted files with Range support on the
+	// loopback or Unix socket endpoint at TB_RANGE_ADDR, decrypting chunks
+	// from the cached ciphertext on demand.
+	DeliveryModeHTTP = "http"
+)
+
+// Valid delivery modes
+var validDeliveryModes = map[string]bool{
+	DeliveryModeFIFO:  true,
+	DeliveryModeMemfd: true,
+	DeliveryModeHTTP:  true,
+}
+
+// Valid hydrate modes
+var validHydrateModes = map[string]bool{
+	HydrateModeDisk:   true,
+	HydrateModeStream: true,
+}
+
 // Valid log levels
 var validLogLevels = map[string]bool{
 	"debug": true,
@@ -56,6 +115,7 @@ type Config struct {
 	// Paths with defaults
 	TargetDir   string // TB_TARGET_DIR - Directory for encrypted downloads
 	PipePath    string // TB_PIPE_PATH - FIFO path for decrypted output
+	ModelDir    string // TB_MODEL_DIR - tmpfs directory for multi-file assets
 	ReadySignal string // TB_READY_SIGNAL - Signal file for runtime readiness
 
 	// URLs with defaults
@@ -64,8 +124,39 @@ type Config struct {
 	HealthAddr string // TB_HEALTH_ADDR - Health endpoint address
 
 	// Download configuration
-	DownloadConcurrency int // TB_DOWNLOAD_CONCURRENCY - Number of concurrent download workers
-	DownloadChunkBytes  int // TB_DOWNLOAD_CHUNK_BYTES - Size of download chunks
+	DownloadConcurrency     int  // TB_DOWNLOAD_CONCURRENCY - Number of concurrent download workers
+	DownloadMaxConcurrency  int  // TB_DOWNLOAD_MAX_CONCURRENCY - Upper bound of adaptive range request concurrency across all files, at least TB_DOWNLOAD_CONCURRENCY (0 = fixed)
+	DownloadChunkBytes      int  // TB_DOWNLOAD_CHUNK_BYTES - Size of download chunks
+	DownloadResume          bool // TB_DOWNLOAD_RESUME - Keep interrupted downloads in TargetDir and fetch only the missing ranges on restart
+	DownloadMaxURLRefreshes int  // TB_DOWNLOAD_MAX_URL_REFRESHES - Re-authorizations allowed per file when its SAS URL expires mid-download
+	CacheMaxBytes           int  // TB_CACHE_MAX_BYTES - Size limit of verified files kept in TargetDir, least recently used evicted first (0 = unbounded)
+
+	// Download bandwidth, shared by all files and range requests
+	DownloadMaxBytesPerSec    int    // TB_DOWNLOAD_MAX_BYTES_PER_SEC - Bandwidth limit outside scheduled windows (0 = unlimited)
+	DownloadBandwidthSchedule string // TB_DOWNLOAD_BANDWIDTH_SCHEDULE - Time-of-day limits overriding it, e.g. "08:00-18:00=10485760,18:00-08:00=0" (local time)
+
+	// Hydration configuration
+	HydrateMode      string // TB_HYDRATE_MODE - Hydration mode (disk, stream)
+	InMemoryMaxBytes int    // TB_INMEMORY_MAX_BYTES - Multi-file entries up to this size are written as regular tmpfs files instead of FIFOs
+	VerifyChunks     bool   // TB_VERIFY_CHUNKS - Authenticate every chunk of downloaded files before decryption starts (disk mode)
+	FIFOMaxSessions  int    // TB_FIFO_MAX_SESSIONS - Times each FIFO is served to a new reader (disk mode, 0 = unlimited)
+
+	// FIFO watchdog
+	FIFOOpenTimeout  time.Duration // TB_FIFO_OPEN_TIMEOUT - Suspend if no reader opens a FIFO within this time (0 = wait indefinitely)
+	FIFOStallTimeout time.Duration // TB_FIFO_STALL_TIMEOUT - Suspend if a FIFO reader accepts no data for this long (0 = disabled)
+
+	// Delivery configuration
+	DeliveryMode       string // TB_DELIVERY_MODE - How decrypted files reach the runtime (fifo, memfd, http)
+	HandoffSocket      string // TB_HANDOFF_SOCKET - Unix socket passing memfd descriptors (memfd mode)
+	HandoffAllowedUIDs []int  // TB_HANDOFF_ALLOWED_UIDS - Peer uids allowed to receive descriptors (default: sentinel's uid)
+	HandoffAllowedPIDs []int  // TB_HANDOFF_ALLOWED_PIDS - Peer pids allowed to receive descriptors (default: any)
+	RangeAddr          string // TB_RANGE_ADDR - Loopback host:port or unix:/path of the range server (http mode)
+
+	// Decryption configuration
+	DecryptWorkers int // TB_DECRYPT_WORKERS - Number of parallel decryption workers
+
+	// Key delivery
+	AllowPlainKey bool // TB_ALLOW_PLAIN_KEY - Legacy: accept an unwrapped decryption_key_hex from the Control Plane
 
 	// Logging
 	LogLevel string // TB_LOG_LEVEL - Logging level (debug, info, warn, error)
@@ -121,11 +212,17 @@ func Load() (*Config, error) {
 		// Optional fields with defaults
 		TargetDir:   getEnv("TB_TARGET_DIR", DefaultTargetDir),
 		PipePath:    getEnv("TB_PIPE_PATH", DefaultPipePath),
+		ModelDir:    getEnv("TB_MODEL_DIR", DefaultModelDir),
 		ReadySignal: getEnv("TB_READY_SIGNAL", DefaultReadySignal),
 		RuntimeURL:  getEnv("TB_RUNTIME_URL", DefaultRuntimeURL),
 		PublicAddr:  getEnv("TB_PUBLIC_ADDR", DefaultPublicAddr),
 		HealthAddr:  getEnv("TB_HEALTH_ADDR", DefaultHealthAddr),
+		HydrateMode: strings.ToLower(getEnv("TB_HYDRATE_MODE", DefaultHydrateMode)),
 		LogLevel:    strings.ToLower(getEnv("TB_LOG_LEVEL", DefaultLogLevel)),
+
+		DeliveryMode:  strings.ToLower(getEnv("TB_DELIVERY_MODE", DefaultDeliveryMode)),
+		HandoffSocket: getEnv("TB_HANDOFF_SOCKET", DefaultHandoffSocket),
+		RangeAddr:     getEnv("TB_RANGE_ADDR", DefaultRangeAddr),
 	}
 
 	// Parse integer fields
@@ -140,6 +237,15 @@ func Load() (*Config, error) {
 	}
 	cfg.DownloadConcurrency = concurrency
 
+	maxConcurrency, err := getEnvInt("TB_DOWNLOAD_MAX_CONCURRENCY", DefaultDownloadMaxConcurrency)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_DOWNLOAD_MAX_CONCURRENCY",
+			Message: err.Error(),
+		})
+	}
+	cfg.DownloadMaxConcurrency = maxConcurrency
+
 	chunkBytes, err := getEnvInt("TB_DOWNLOAD_CHUNK_BYTES", DefaultDownloadChunkBytes)
 	if err != nil {
 		parseErrs = append(parseErrs, &ValidationError{
@@ -149,6 +255,101 @@ func Load() (*Config, error) {
 	}
 	cfg.DownloadChunkBytes = chunkBytes
 
+	cfg.DownloadResume = getEnvBool("TB_DOWNLOAD_RESUME", true)
+
+	maxURLRefreshes, err := getEnvInt("TB_DOWNLOAD_MAX_URL_REFRESHES", DefaultMaxURLRefreshes)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_DOWNLOAD_MAX_URL_REFRESHES",
+			Message: err.Error(),
+		})
+	}
+	cfg.DownloadMaxURLRefreshes = maxURLRefreshes
+
+	cacheMaxBytes, err := getEnvInt("TB_CACHE_MAX_BYTES", 0)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_CACHE_MAX_BYTES",
+			Message: err.Error(),
+		})
+	}
+	cfg.CacheMaxBytes = cacheMaxBytes
+
+	maxBytesPerSec, err := getEnvInt("TB_DOWNLOAD_MAX_BYTES_PER_SEC", 0)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_DOWNLOAD_MAX_BYTES_PER_SEC",
+			Message: err.Error(),
+		})
+	}
+	cfg.DownloadMaxBytesPerSec = maxBytesPerSec
+	cfg.DownloadBandwidthSchedule = getEnv("TB_DOWNLOAD_BANDWIDTH_SCHEDULE", "")
+
+	decryptWorkers, err := getEnvInt("TB_DECRYPT_WORKERS", DefaultDecryptWorkers)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_DECRYPT_WORKERS",
+			Message: err.Error(),
+		})
+	}
+	cfg.DecryptWorkers = decryptWorkers
+
+	inMemoryMaxBytes, err := getEnvInt("TB_INMEMORY_MAX_BYTES", DefaultInMemoryMaxBytes)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_INMEMORY_MAX_BYTES",
+			Message: err.Error(),
+		})
+	}
+	cfg.InMemoryMaxBytes = inMemoryMaxBytes
+
+	cfg.VerifyChunks = getEnvBool("TB_VERIFY_CHUNKS", true)
+
+	fifoMaxSessions, err := getEnvInt("TB_FIFO_MAX_SESSIONS", DefaultFIFOMaxSessions)
+	if err != nil {
+		parseErrs = append(parseErrs, &ValidationError{
+			Field:   "TB_FIFO_MAX_SESSIONS",
+			Message: err.Error(),
+		})
+	}
+	cfg.FIFOMaxSessions = fifoMaxSessions
+
+	for _, d := range []struct {
+		key  string
+		dest *time.Duration
+	}{
+		{"TB_FIFO_OPEN_TIMEOUT", &cfg.FIFOOpenTimeout},
+		{"TB_FIFO_STALL_TIMEOUT", &cfg.FIFOStallTimeout},
+	} {
+		value, err := getEnvDuration(d.key, 0)
+		if err != nil {
+			parseErrs = append(parseErrs, &ValidationError{
+				Field:   d.key,
+				Message: err.Error(),
+			})
+		}
+		*d.dest = value
+	}
+
+	for _, list := range []struct {
+		key  string
+		dest *[]int
+	}{
+		{"TB_HANDOFF_ALLOWED_UIDS", &cfg.HandoffAllowedUIDs},
+		{"TB_HANDOFF_ALLOWED_PIDS", &cfg.HandoffAllowedPIDs},
+	} {
+		values, err := getEnvIntList(list.key)
+		if err != nil {
+			parseErrs = append(parseErrs, &ValidationError{
+				Field:   list.key,
+				Message: err.Error(),
+			})
+		}
+		*list.dest = values
+	}
+
+	cfg.AllowPlainKey = getEnvBool("TB_ALLOW_PLAIN_KEY", false)
+
 	// Parse billing configuration
 	cfg.BillingEnabled = getEnvBool("TB_BILLING_ENABLED", false)
 	cfg.BillingDimension = getEnv("TB_BILLING_DIMENSION", DefaultBillingDimension)
@@ -233,6 +434,20 @@ func (c *Config) Validate() []error {
 		})
 	}
 
+	if c.ModelDir != "" && !strings.HasPrefix(c.ModelDir, "/") {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_MODEL_DIR",
+			Message: "must be an absolute path",
+		})
+	}
+
+	if c.HandoffSocket != "" && !strings.HasPrefix(c.HandoffSocket, "/") {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_HANDOFF_SOCKET",
+			Message: "must be an absolute path",
+		})
+	}
+
 	if c.ReadySignal != "" && !strings.HasPrefix(c.ReadySignal, "/") {
 		errs = append(errs, &ValidationError{
 			Field:   "TB_READY_SIGNAL",
@@ -248,6 +463,13 @@ func (c *Config) Validate() []error {
 		})
 	}
 
+	if c.DownloadMaxConcurrency < 0 || c.DownloadMaxConcurrency > MaxDownloadConcurrency {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_DOWNLOAD_MAX_CONCURRENCY",
+			Message: fmt.Sprintf("must be between 0 and %d, got %d", MaxDownloadConcurrency, c.DownloadMaxConcurrency),
+		})
+	}
+
 	if c.DownloadChunkBytes < MinDownloadChunkBytes || c.DownloadChunkBytes > MaxDownloadChunkBytes {
 		errs = append(errs, &ValidationError{
 			Field:   "TB_DOWNLOAD_CHUNK_BYTES",
@@ -255,6 +477,115 @@ func (c *Config) Validate() []error {
 		})
 	}
 
+	if c.DecryptWorkers < MinDecryptWorkers || c.DecryptWorkers > MaxDecryptWorkers {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_DECRYPT_WORKERS",
+			Message: fmt.Sprintf("must be between %d and %d, got %d", MinDecryptWorkers, MaxDecryptWorkers, c.DecryptWorkers),
+		})
+	}
+
+	if c.DownloadMaxURLRefreshes < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_DOWNLOAD_MAX_URL_REFRESHES",
+			Message: fmt.Sprintf("must not be negative, got %d", c.DownloadMaxURLRefreshes),
+		})
+	}
+
+	if c.CacheMaxBytes < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_CACHE_MAX_BYTES",
+			Message: fmt.Sprintf("must not be negative, got %d", c.CacheMaxBytes),
+		})
+	}
+
+	if c.DownloadMaxBytesPerSec < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_DOWNLOAD_MAX_BYTES_PER_SEC",
+			Message: fmt.Sprintf("must not be negative, got %d", c.DownloadMaxBytesPerSec),
+		})
+	}
+
+	if _, err := asset.ParseBandwidthSchedule(c.DownloadBandwidthSchedule); err != nil {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_DOWNLOAD_BANDWIDTH_SCHEDULE",
+			Message: err.Error(),
+		})
+	}
+
+	if c.InMemoryMaxBytes < 0 || c.InMemoryMaxBytes > MaxInMemoryMaxBytes {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_INMEMORY_MAX_BYTES",
+			Message: fmt.Sprintf("must be between 0 and %d, got %d", MaxInMemoryMaxBytes, c.InMemoryMaxBytes),
+		})
+	}
+
+	if c.FIFOMaxSessions < 0 || c.FIFOMaxSessions > MaxFIFOMaxSessions {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_FIFO_MAX_SESSIONS",
+			Message: fmt.Sprintf("must be between 0 and %d, got %d", MaxFIFOMaxSessions, c.FIFOMaxSessions),
+		})
+	}
+
+	if c.FIFOOpenTimeout < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_FIFO_OPEN_TIMEOUT",
+			Message: fmt.Sprintf("must not be negative, got %v", c.FIFOOpenTimeout),
+		})
+	}
+	if c.FIFOStallTimeout < 0 {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_FIFO_STALL_TIMEOUT",
+			Message: fmt.Sprintf("must not be negative, got %v", c.FIFOStallTimeout),
+		})
+	}
+
+	// Hydrate mode validation
+	if !validHydrateModes[c.HydrateMode] {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_HYDRATE_MODE",
+			Message: fmt.Sprintf("must be one of: disk, stream; got %q", c.HydrateMode),
+		})
+	}
+
+	if !validDeliveryModes[c.DeliveryMode] {
+		errs = append(errs, &ValidationError{
+			Field:   "TB_DELIVERY_MODE",
+			Message: fmt.Sprintf("must be one of: fifo, memfd, http; got %q", c.DeliveryMode),
+		})
+	}
+
+	if c.DeliveryMode == DeliveryModeHTTP {
+		if c.HydrateMode == HydrateModeStream {
+			errs = append(errs, &ValidationError{
+				Field:   "TB_DELIVERY_MODE",
+				Message: "http requires TB_HYDRATE_MODE=disk; ranges are decrypted from the cached ciphertext",
+			})
+		}
+		if err := rangeserver.ValidateAddr(c.RangeAddr); err != nil {
+			errs = append(errs, &ValidationError{
+				Field:   "TB_RANGE_ADDR",
+				Message: err.Error(),
+			})
+		}
+	}
+
+	for _, list := range []struct {
+		key    string
+		values []int
+	}{
+		{"TB_HANDOFF_ALLOWED_UIDS", c.HandoffAllowedUIDs},
+		{"TB_HANDOFF_ALLOWED_PIDS", c.HandoffAllowedPIDs},
+	} {
+		for _, v := range list.values {
+			if v < 0 {
+				errs = append(errs, &ValidationError{
+					Field:   list.key,
+					Message: fmt.Sprintf("must not be negative, got %d", v),
+				})
+			}
+		}
+	}
+
 	// Log level validation
 	if !validLogLevels[c.LogLevel] {
 		errs = append(errs, &ValidationError{
@@ -300,18 +631,38 @@ func (c *Config) Validate() []error {
 // Sensitive values are redacted.
 func (c *Config) String() string {
 	return fmt.Sprintf(
-		"Config{ContractID=%q, AssetID=%q, EDCEndpoint=%q, TargetDir=%q, PipePath=%q, ReadySignal=%q, RuntimeURL=%q, PublicAddr=%q, HealthAddr=%q, DownloadConcurrency=%d, DownloadChunkBytes=%d, LogLevel=%q, BillingEnabled=%t, BillingInterval=%v, BillingDimension=%q}",
+		"Config{ContractID=%q, AssetID=%q, EDCEndpoint=%q, TargetDir=%q, PipePath=%q, ModelDir=%q, ReadySignal=%q, RuntimeURL=%q, PublicAddr=%q, HealthAddr=%q, DownloadConcurrency=%d, DownloadMaxConcurrency=%d, DownloadChunkBytes=%d, DownloadResume=%t, DownloadMaxURLRefreshes=%d, CacheMaxBytes=%d, DownloadMaxBytesPerSec=%d, DownloadBandwidthSchedule=%q, DecryptWorkers=%d, HydrateMode=%q, InMemoryMaxBytes=%d, VerifyChunks=%t, FIFOMaxSessions=%d, FIFOOpenTimeout=%v, FIFOStallTimeout=%v, DeliveryMode=%q, HandoffSocket=%q, HandoffAllowedUIDs=%v, HandoffAllowedPIDs=%v, RangeAddr=%q, AllowPlainKey=%t, LogLevel=%q, BillingEnabled=%t, BillingInterval=%v, BillingDimension=%q}",
 		c.ContractID,
 		c.AssetID,
 		c.EDCEndpoint,
 		c.TargetDir,
 		c.PipePath,
+		c.ModelDir,
 		c.ReadySignal,
 		c.RuntimeURL,
 		c.PublicAddr,
 		c.HealthAddr,
 		c.DownloadConcurrency,
+		c.DownloadMaxConcurrency,
 		c.DownloadChunkBytes,
+		c.DownloadResume,
+		c.DownloadMaxURLRefreshes,
+		c.CacheMaxBytes,
+		c.DownloadMaxBytesPerSec,
+		c.DownloadBandwidthSchedule,
+		c.DecryptWorkers,
+		c.HydrateMode,
+		c.InMemoryMaxBytes,
+		c.VerifyChunks,
+		c.FIFOMaxSessions,
+		c.FIFOOpenTimeout,
+		c.FIFOStallTimeout,
+		c.DeliveryMode,
+		c.HandoffSocket,
+		c.HandoffAllowedUIDs,
+		c.HandoffAllowedPIDs,
+		c.RangeAddr,
+		c.AllowPlainKey,
 		c.LogLevel,
 		c.BillingEnabled,
 		c.BillingInterval,
@@ -342,6 +693,26 @@ func getEnvInt(key string, defaultValue int) (int, error) {
 	return intValue, nil
 }
 
+// getEnvIntList returns the environment variable as a comma-separated list of
+// integers, or nil if not set.
+func getEnvIntList(key string) ([]int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	var values []int
+	for _, field := range strings.Split(value, ",") {
+		intValue, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer list: %q", value)
+		}
+		values = append(values, intValue)
+	}
+
+	return values, nil
+}
+
 // validateURL checks if a string is a valid URL with http or https scheme.
 func validateURL(rawURL string) error {
 	parsed, err := url.Parse(rawURL)
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 // setTestEnv sets environment variables for testing and returns a cleanup function.
@@ -23,11 +25,31 @@ func clearConfigEnv(t *testing.T) {
 		"TB_EDC_ENDPOINT",
 		"TB_TARGET_DIR",
 		"TB_PIPE_PATH",
+		"TB_MODEL_DIR",
 		"TB_READY_SIGNAL",
 		"TB_RUNTIME_URL",
 		"TB_PUBLIC_ADDR",
 		"TB_DOWNLOAD_CONCURRENCY",
+		"TB_DOWNLOAD_MAX_CONCURRENCY",
 		"TB_DOWNLOAD_CHUNK_BYTES",
+		"TB_DECRYPT_WORKERS",
+		"TB_HYDRATE_MODE",
+		"TB_INMEMORY_MAX_BYTES",
+		"TB_VERIFY_CHUNKS",
+		"TB_DOWNLOAD_RESUME",
+		"TB_CACHE_MAX_BYTES",
+		"TB_DOWNLOAD_MAX_URL_REFRESHES",
+		"TB_DOWNLOAD_MAX_BYTES_PER_SEC",
+		"TB_DOWNLOAD_BANDWIDTH_SCHEDULE",
+		"TB_FIFO_MAX_SESSIONS",
+		"TB_FIFO_OPEN_TIMEOUT",
+		"TB_FIFO_STALL_TIMEOUT",
+		"TB_DELIVERY_MODE",
+		"TB_HANDOFF_SOCKET",
+		"TB_HANDOFF_ALLOWED_UIDS",
+		"TB_HANDOFF_ALLOWED_PIDS",
+		"TB_RANGE_ADDR",
+		"TB_ALLOW_PLAIN_KEY",
 		"TB_LOG_LEVEL",
 	}
 	for _, key := range envVars {
@@ -142,9 +164,60 @@ func TestLoad_Defaults(t *testing.T) {
 	if cfg.DownloadConcurrency != DefaultDownloadConcurrency {
 		t.Errorf("DownloadConcurrency = %d, want default %d", cfg.DownloadConcurrency, DefaultDownloadConcurrency)
 	}
+	if cfg.DownloadMaxConcurrency != DefaultDownloadMaxConcurrency {
+		t.Errorf("DownloadMaxConcurrency = %d, want default %d", cfg.DownloadMaxConcurrency, DefaultDownloadMaxConcurrency)
+	}
 	if cfg.DownloadChunkBytes != DefaultDownloadChunkBytes {
 		t.Errorf("DownloadChunkBytes = %d, want default %d", cfg.DownloadChunkBytes, DefaultDownloadChunkBytes)
 	}
+	if cfg.DecryptWorkers != DefaultDecryptWorkers {
+		t.Errorf("DecryptWorkers = %d, want default %d", cfg.DecryptWorkers, DefaultDecryptWorkers)
+	}
+	if cfg.HydrateMode != DefaultHydrateMode {
+		t.Errorf("HydrateMode = %q, want default %q", cfg.HydrateMode, DefaultHydrateMode)
+	}
+	if cfg.ModelDir != DefaultModelDir {
+		t.Errorf("ModelDir = %q, want default %q", cfg.ModelDir, DefaultModelDir)
+	}
+	if cfg.InMemoryMaxBytes != DefaultInMemoryMaxBytes {
+		t.Errorf("InMemoryMaxBytes = %d, want default %d", cfg.InMemoryMaxBytes, DefaultInMemoryMaxBytes)
+	}
+	if !cfg.VerifyChunks {
+		t.Error("VerifyChunks = false, want default true")
+	}
+	if !cfg.DownloadResume {
+		t.Error("DownloadResume = false, want default true")
+	}
+	if cfg.CacheMaxBytes != 0 {
+		t.Errorf("CacheMaxBytes = %d, want default 0", cfg.CacheMaxBytes)
+	}
+	if cfg.DownloadMaxBytesPerSec != 0 || cfg.DownloadBandwidthSchedule != "" {
+		t.Errorf("DownloadMaxBytesPerSec, DownloadBandwidthSchedule = %d, %q, want unlimited", cfg.DownloadMaxBytesPerSec, cfg.DownloadBandwidthSchedule)
+	}
+	if cfg.DownloadMaxURLRefreshes != DefaultMaxURLRefreshes {
+		t.Errorf("DownloadMaxURLRefreshes = %d, want default %d", cfg.DownloadMaxURLRefreshes, DefaultMaxURLRefreshes)
+	}
+	if cfg.FIFOMaxSessions != DefaultFIFOMaxSessions {
+		t.Errorf("FIFOMaxSessions = %d, want default %d", cfg.FIFOMaxSessions, DefaultFIFOMaxSessions)
+	}
+	if cfg.FIFOOpenTimeout != 0 || cfg.FIFOStallTimeout != 0 {
+		t.Errorf("FIFOOpenTimeout, FIFOStallTimeout = %v, %v, want 0, 0", cfg.FIFOOpenTimeout, cfg.FIFOStallTimeout)
+	}
+	if cfg.DeliveryMode != DefaultDeliveryMode {
+		t.Errorf("DeliveryMode = %q, want default %q", cfg.DeliveryMode, DefaultDeliveryMode)
+	}
+	if cfg.HandoffSocket != DefaultHandoffSocket {
+		t.Errorf("HandoffSocket = %q, want default %q", cfg.HandoffSocket, DefaultHandoffSocket)
+	}
+	if cfg.HandoffAllowedUIDs != nil || cfg.HandoffAllowedPIDs != nil {
+		t.Errorf("HandoffAllowedUIDs/PIDs = %v/%v, want nil", cfg.HandoffAllowedUIDs, cfg.HandoffAllowedPIDs)
+	}
+	if cfg.RangeAddr != DefaultRangeAddr {
+		t.Errorf("RangeAddr = %q, want default %q", cfg.RangeAddr, DefaultRangeAddr)
+	}
+	if cfg.AllowPlainKey {
+		t.Error("AllowPlainKey = true, want default false")
+	}
 	if cfg.LogLevel != DefaultLogLevel {
 		t.Errorf("LogLevel = %q, want default %q", cfg.LogLevel, DefaultLogLevel)
 	}
@@ -153,17 +226,37 @@ func TestLoad_Defaults(t *testing.T) {
 func TestLoad_CustomValues(t *testing.T) {
 	clearConfigEnv(t)
 	setTestEnv(t, map[string]string{
-		"TB_CONTRACT_ID":          "contract-123",
-		"TB_ASSET_ID":             "asset-456",
-		"TB_EDC_ENDPOINT":         "https://edc.example.com",
-		"TB_TARGET_DIR":           "/custom/target",
-		"TB_PIPE_PATH":            "/custom/pipe",
-		"TB_READY_SIGNAL":         "/custom/signal",
-		"TB_RUNTIME_URL":          "http://localhost:9000",
-		"TB_PUBLIC_ADDR":          "0.0.0.0:9090",
-		"TB_DOWNLOAD_CONCURRENCY": "8",
-		"TB_DOWNLOAD_CHUNK_BYTES": "16777216",
-		"TB_LOG_LEVEL":            "DEBUG",
+		"TB_CONTRACT_ID":                 "contract-123",
+		"TB_ASSET_ID":                    "asset-456",
+		"TB_EDC_ENDPOINT":                "https://edc.example.com",
+		"TB_TARGET_DIR":                  "/custom/target",
+		"TB_PIPE_PATH":                   "/custom/pipe",
+		"TB_MODEL_DIR":                   "/custom/model",
+		"TB_READY_SIGNAL":                "/custom/signal",
+		"TB_RUNTIME_URL":                 "http://localhost:9000",
+		"TB_PUBLIC_ADDR":                 "0.0.0.0:9090",
+		"TB_DOWNLOAD_CONCURRENCY":        "8",
+		"TB_DOWNLOAD_MAX_CONCURRENCY":    "0",
+		"TB_DOWNLOAD_CHUNK_BYTES":        "16777216",
+		"TB_DECRYPT_WORKERS":             "8",
+		"TB_HYDRATE_MODE":                "Stream",
+		"TB_INMEMORY_MAX_BYTES":          "0",
+		"TB_VERIFY_CHUNKS":               "false",
+		"TB_DOWNLOAD_RESUME":             "false",
+		"TB_CACHE_MAX_BYTES":             "107374182400",
+		"TB_DOWNLOAD_MAX_URL_REFRESHES":  "0",
+		"TB_DOWNLOAD_MAX_BYTES_PER_SEC":  "52428800",
+		"TB_DOWNLOAD_BANDWIDTH_SCHEDULE": "08:00-18:00=10485760",
+		"TB_FIFO_MAX_SESSIONS":           "0",
+		"TB_FIFO_OPEN_TIMEOUT":           "2m",
+		"TB_FIFO_STALL_TIMEOUT":          "30s",
+		"TB_DELIVERY_MODE":               "MEMFD",
+		"TB_HANDOFF_SOCKET":              "/run/tb/handoff.sock",
+		"TB_HANDOFF_ALLOWED_UIDS":        "1000, 1001",
+		"TB_HANDOFF_ALLOWED_PIDS":        "4242",
+		"TB_RANGE_ADDR":                  "unix:/run/tb/range.sock",
+		"TB_ALLOW_PLAIN_KEY":             "true",
+		"TB_LOG_LEVEL":                   "DEBUG",
 	})
 
 	cfg, err := Load()
@@ -177,6 +270,9 @@ func TestLoad_CustomValues(t *testing.T) {
 	if cfg.PipePath != "/custom/pipe" {
 		t.Errorf("PipePath = %q, want %q", cfg.PipePath, "/custom/pipe")
 	}
+	if cfg.ModelDir != "/custom/model" {
+		t.Errorf("ModelDir = %q, want %q", cfg.ModelDir, "/custom/model")
+	}
 	if cfg.ReadySignal != "/custom/signal" {
 		t.Errorf("ReadySignal = %q, want %q", cfg.ReadySignal, "/custom/signal")
 	}
@@ -189,9 +285,68 @@ func TestLoad_CustomValues(t *testing.T) {
 	if cfg.DownloadConcurrency != 8 {
 		t.Errorf("DownloadConcurrency = %d, want %d", cfg.DownloadConcurrency, 8)
 	}
+	if cfg.DownloadMaxConcurrency != 0 {
+		t.Errorf("DownloadMaxConcurrency = %d, want 0", cfg.DownloadMaxConcurrency)
+	}
 	if cfg.DownloadChunkBytes != 16777216 {
 		t.Errorf("DownloadChunkBytes = %d, want %d", cfg.DownloadChunkBytes, 16777216)
 	}
+	if cfg.DecryptWorkers != 8 {
+		t.Errorf("DecryptWorkers = %d, want %d", cfg.DecryptWorkers, 8)
+	}
+	// HydrateMode should be lowercased
+	if cfg.HydrateMode != HydrateModeStream {
+		t.Errorf("HydrateMode = %q, want %q", cfg.HydrateMode, HydrateModeStream)
+	}
+	if cfg.InMemoryMaxBytes != 0 {
+		t.Errorf("InMemoryMaxBytes = %d, want 0", cfg.InMemoryMaxBytes)
+	}
+	if cfg.VerifyChunks {
+		t.Error("VerifyChunks = true, want false")
+	}
+	if cfg.DownloadResume {
+		t.Error("DownloadResume = true, want false")
+	}
+	if cfg.CacheMaxBytes != 107374182400 {
+		t.Errorf("CacheMaxBytes = %d, want 107374182400", cfg.CacheMaxBytes)
+	}
+	if cfg.DownloadMaxBytesPerSec != 52428800 {
+		t.Errorf("DownloadMaxBytesPerSec = %d, want 52428800", cfg.DownloadMaxBytesPerSec)
+	}
+	if cfg.DownloadBandwidthSchedule != "08:00-18:00=10485760" {
+		t.Errorf("DownloadBandwidthSchedule = %q, want %q", cfg.DownloadBandwidthSchedule, "08:00-18:00=10485760")
+	}
+	if cfg.DownloadMaxURLRefreshes != 0 {
+		t.Errorf("DownloadMaxURLRefreshes = %d, want 0", cfg.DownloadMaxURLRefreshes)
+	}
+	if cfg.FIFOMaxSessions != 0 {
+		t.Errorf("FIFOMaxSessions = %d, want 0", cfg.FIFOMaxSessions)
+	}
+	if cfg.FIFOOpenTimeout != 2*time.Minute {
+		t.Errorf("FIFOOpenTimeout = %v, want 2m", cfg.FIFOOpenTimeout)
+	}
+	if cfg.FIFOStallTimeout != 30*time.Second {
+		t.Errorf("FIFOStallTimeout = %v, want 30s", cfg.FIFOStallTimeout)
+	}
+	// DeliveryMode should be lowercased
+	if cfg.DeliveryMode != DeliveryModeMemfd {
+		t.Errorf("DeliveryMode = %q, want %q", cfg.DeliveryMode, DeliveryModeMemfd)
+	}
+	if cfg.HandoffSocket != "/run/tb/handoff.sock" {
+		t.Errorf("HandoffSocket = %q, want %q", cfg.HandoffSocket, "/run/tb/handoff.sock")
+	}
+	if fmt.Sprint(cfg.HandoffAllowedUIDs) != "[1000 1001]" {
+		t.Errorf("HandoffAllowedUIDs = %v, want [1000 1001]", cfg.HandoffAllowedUIDs)
+	}
+	if fmt.Sprint(cfg.HandoffAllowedPIDs) != "[4242]" {
+		t.Errorf("HandoffAllowedPIDs = %v, want [4242]", cfg.HandoffAllowedPIDs)
+	}
+	if cfg.RangeAddr != "unix:/run/tb/range.sock" {
+		t.Errorf("RangeAddr = %q, want %q", cfg.RangeAddr, "unix:/run/tb/range.sock")
+	}
+	if !cfg.AllowPlainKey {
+		t.Error("AllowPlainKey = false, want true")
+	}
 	// LogLevel should be lowercased
 	if cfg.LogLevel != "debug" {
 		t.Errorf("LogLevel = %q, want %q", cfg.LogLevel, "debug")
@@ -264,6 +419,38 @@ func TestLoad_InvalidChunkBytes(t *testing.T) {
 	}
 }
 
+func TestLoad_InvalidDecryptWorkers(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"zero", "0"},
+		{"too_high", "1000"},
+		{"not_integer", "many"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":     "contract-123",
+				"TB_ASSET_ID":        "asset-456",
+				"TB_EDC_ENDPOINT":    "https://edc.example.com",
+				"TB_DECRYPT_WORKERS": tt.value,
+			})
+
+			_, err := Load()
+			if err == nil {
+				t.Fatalf("Load() error = nil, want error for invalid decrypt workers %q", tt.value)
+			}
+
+			if !strings.Contains(err.Error(), "TB_DECRYPT_WORKERS") {
+				t.Errorf("error = %v, want error mentioning TB_DECRYPT_WORKERS", err)
+			}
+		})
+	}
+}
+
 func TestLoad_InvalidLogLevel(t *testing.T) {
 	clearConfigEnv(t)
 	setTestEnv(t, map[string]string{
@@ -283,6 +470,277 @@ func TestLoad_InvalidLogLevel(t *testing.T) {
 	}
 }
 
+func TestLoad_InvalidHydrateMode(t *testing.T) {
+	clearConfigEnv(t)
+	setTestEnv(t, map[string]string{
+		"TB_CONTRACT_ID":  "contract-123",
+		"TB_ASSET_ID":     "asset-456",
+		"TB_EDC_ENDPOINT": "https://edc.example.com",
+		"TB_HYDRATE_MODE": "memory",
+	})
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load() error = nil, want error for invalid hydrate mode")
+	}
+
+	if !strings.Contains(err.Error(), "TB_HYDRATE_MODE") {
+		t.Errorf("error = %v, want error mentioning TB_HYDRATE_MODE", err)
+	}
+}
+
+func TestLoad_InvalidInMemoryMaxBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"negative", "-1"},
+		{"too_large", "2147483648"},
+		{"not_a_number", "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":        "contract-123",
+				"TB_ASSET_ID":           "asset-456",
+				"TB_EDC_ENDPOINT":       "https://edc.example.com",
+				"TB_INMEMORY_MAX_BYTES": tt.value,
+			})
+
+			_, err := Load()
+			if err == nil {
+				t.Fatalf("Load() error = nil, want error for TB_INMEMORY_MAX_BYTES=%q", tt.value)
+			}
+
+			if !strings.Contains(err.Error(), "TB_INMEMORY_MAX_BYTES") {
+				t.Errorf("error = %v, want error mentioning TB_INMEMORY_MAX_BYTES", err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidCacheMaxBytes(t *testing.T) {
+	for _, value := range []string{"-1", "100GB"} {
+		t.Run(value, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":     "contract-123",
+				"TB_ASSET_ID":        "asset-456",
+				"TB_EDC_ENDPOINT":    "https://edc.example.com",
+				"TB_CACHE_MAX_BYTES": value,
+			})
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), "TB_CACHE_MAX_BYTES") {
+				t.Errorf("Load() error = %v, want error mentioning TB_CACHE_MAX_BYTES", err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidMaxConcurrency(t *testing.T) {
+	for _, value := range []string{"-1", "33", "auto"} {
+		t.Run(value, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":              "contract-123",
+				"TB_ASSET_ID":                 "asset-456",
+				"TB_EDC_ENDPOINT":             "https://edc.example.com",
+				"TB_DOWNLOAD_MAX_CONCURRENCY": value,
+			})
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), "TB_DOWNLOAD_MAX_CONCURRENCY") {
+				t.Errorf("Load() error = %v, want error mentioning TB_DOWNLOAD_MAX_CONCURRENCY", err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidBandwidth(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"TB_DOWNLOAD_MAX_BYTES_PER_SEC", "-1"},
+		{"TB_DOWNLOAD_MAX_BYTES_PER_SEC", "10MB"},
+		{"TB_DOWNLOAD_BANDWIDTH_SCHEDULE", "business hours=1000"},
+		{"TB_DOWNLOAD_BANDWIDTH_SCHEDULE", "08:00-18:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":  "contract-123",
+				"TB_ASSET_ID":     "asset-456",
+				"TB_EDC_ENDPOINT": "https://edc.example.com",
+				tt.key:            tt.value,
+			})
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("Load() error = %v, want error mentioning %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidMaxURLRefreshes(t *testing.T) {
+	for _, value := range []string{"-1", "many"} {
+		t.Run(value, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":                "contract-123",
+				"TB_ASSET_ID":                   "asset-456",
+				"TB_EDC_ENDPOINT":               "https://edc.example.com",
+				"TB_DOWNLOAD_MAX_URL_REFRESHES": value,
+			})
+
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), "TB_DOWNLOAD_MAX_URL_REFRESHES") {
+				t.Errorf("Load() error = %v, want error mentioning TB_DOWNLOAD_MAX_URL_REFRESHES", err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidFIFOMaxSessions(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"negative", "-1"},
+		{"too_large", "1001"},
+		{"not_a_number", "many"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":       "contract-123",
+				"TB_ASSET_ID":          "asset-456",
+				"TB_EDC_ENDPOINT":      "https://edc.example.com",
+				"TB_FIFO_MAX_SESSIONS": tt.value,
+			})
+
+			_, err := Load()
+			if err == nil {
+				t.Fatalf("Load() error = nil, want error for TB_FIFO_MAX_SESSIONS=%q", tt.value)
+			}
+
+			if !strings.Contains(err.Error(), "TB_FIFO_MAX_SESSIONS") {
+				t.Errorf("error = %v, want error mentioning TB_FIFO_MAX_SESSIONS", err)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidFIFOTimeouts(t *testing.T) {
+	for _, key := range []string{"TB_FIFO_OPEN_TIMEOUT", "TB_FIFO_STALL_TIMEOUT"} {
+		for _, value := range []string{"-1s", "soon"} {
+			t.Run(key+"="+value, func(t *testing.T) {
+				clearConfigEnv(t)
+				setTestEnv(t, map[string]string{
+					"TB_CONTRACT_ID":  "contract-123",
+					"TB_ASSET_ID":     "asset-456",
+					"TB_EDC_ENDPOINT": "https://edc.example.com",
+					key:               value,
+				})
+
+				_, err := Load()
+				if err == nil {
+					t.Fatalf("Load() error = nil, want error for %s=%q", key, value)
+				}
+				if !strings.Contains(err.Error(), key) {
+					t.Errorf("error = %v, want error mentioning %s", err, key)
+				}
+			})
+		}
+	}
+}
+
+func TestLoad_InvalidHandoff(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"unknown delivery mode", "TB_DELIVERY_MODE", "shm"},
+		{"relative socket", "TB_HANDOFF_SOCKET", "handoff.sock"},
+		{"uid not a number", "TB_HANDOFF_ALLOWED_UIDS", "1000,root"},
+		{"negative uid", "TB_HANDOFF_ALLOWED_UIDS", "-1"},
+		{"empty pid entry", "TB_HANDOFF_ALLOWED_PIDS", "42,"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			setTestEnv(t, map[string]string{
+				"TB_CONTRACT_ID":  "contract-123",
+				"TB_ASSET_ID":     "asset-456",
+				"TB_EDC_ENDPOINT": "https://edc.example.com",
+				tt.key:            tt.value,
+			})
+
+			_, err := Load()
+			if err == nil {
+				t.Fatalf("Load() error = nil, want error for %s=%q", tt.key, tt.value)
+			}
+
+			if !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("error = %v, want error mentioning %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoad_HTTPDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{"loopback", map[string]string{"TB_RANGE_ADDR": "127.0.0.1:9000"}, ""},
+		{"unix socket", map[string]string{"TB_RANGE_ADDR": "unix:/run/tb/range.sock"}, ""},
+		{"non-loopback addr", map[string]string{"TB_RANGE_ADDR": "0.0.0.0:9000"}, "TB_RANGE_ADDR"},
+		{"relative socket", map[string]string{"TB_RANGE_ADDR": "unix:range.sock"}, "TB_RANGE_ADDR"},
+		{"stream hydrate", map[string]string{"TB_HYDRATE_MODE": "stream"}, "TB_DELIVERY_MODE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearConfigEnv(t)
+			env := map[string]string{
+				"TB_CONTRACT_ID":   "contract-123",
+				"TB_ASSET_ID":      "asset-456",
+				"TB_EDC_ENDPOINT":  "https://edc.example.com",
+				"TB_DELIVERY_MODE": "HTTP",
+			}
+			for k, v := range tt.env {
+				env[k] = v
+			}
+			setTestEnv(t, env)
+
+			cfg, err := Load()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				if cfg.DeliveryMode != DeliveryModeHTTP {
+					t.Errorf("DeliveryMode = %q, want %q", cfg.DeliveryMode, DeliveryModeHTTP)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want error mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestLoad_InvalidURL(t *testing.T) {
 	tests := []struct {
 		name string
@@ -323,6 +781,7 @@ func TestLoad_InvalidPath(t *testing.T) {
 	}{
 		{"relative_target_dir", "TB_TARGET_DIR", "relative/path", "TB_TARGET_DIR"},
 		{"relative_pipe_path", "TB_PIPE_PATH", "relative/pipe", "TB_PIPE_PATH"},
+		{"relative_model_dir", "TB_MODEL_DIR", "relative/model", "TB_MODEL_DIR"},
 		{"relative_ready_signal", "TB_READY_SIGNAL", "relative/signal", "TB_READY_SIGNAL"},
 	}
 
@@ -381,11 +840,18 @@ func TestValidate_AllValid(t *testing.T) {
 		EDCEndpoint:         "https://edc.example.com",
 		TargetDir:           "/mnt/resource",
 		PipePath:            "/dev/shm/pipe",
+		ModelDir:            "/dev/shm/model",
 		ReadySignal:         "/dev/shm/ready",
 		RuntimeURL:          "http://localhost:8081",
 		PublicAddr:          "0.0.0.0:8000",
 		DownloadConcurrency: 4,
 		DownloadChunkBytes:  4194304,
+		DecryptWorkers:      4,
+		HydrateMode:         HydrateModeStream,
+		InMemoryMaxBytes:    DefaultInMemoryMaxBytes,
+		DeliveryMode:        DeliveryModeMemfd,
+		HandoffSocket:       "/dev/shm/handoff.sock",
+		HandoffAllowedUIDs:  []int{1000},
 		LogLevel:            "info",
 	}
 
@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// AlgoChaCha20Poly1305Chunked represents the ChaCha20-Poly1305 chunked
+// algorithm. It uses the same 12-byte nonce (prefix || chunk index), AAD and
+// 16-byte tag layout as AlgoAESGCMChunked, and is faster on CPUs without AES-NI.
+const AlgoChaCha20Poly1305Chunked uint8 = 2
+
+// Manifest names of the registered chunk algorithms.
+const (
+	AlgoNameAESGCMChunked           = "aes-256-gcm-chunked"
+	AlgoNameChaCha20Poly1305Chunked = "chacha20-poly1305-chunked"
+)
+
+// Algorithm describes a chunk AEAD usable in a tbenc header.
+//
+// Every algorithm must accept a 32-byte key and produce an AEAD with a
+// NonceSize-byte nonce and a TagSize-byte tag, so the record framing and
+// nonce derivation are shared by all of them.
+type Algorithm struct {
+	ID      uint8                                 // Header algo byte
+	Name    string                                // Manifest "algo" value
+	NewAEAD func(key []byte) (cipher.AEAD, error) // Constructs the AEAD for a 32-byte key
+}
+
+var (
+	algorithmsMu   sync.RWMutex
+	algorithmsByID = make(map[uint8]*Algorithm)
+	algorithmsName = make(map[string]*Algorithm)
+)
+
+func init() {
+	RegisterAlgorithm(Algorithm{
+		ID:      AlgoAESGCMChunked,
+		Name:    AlgoNameAESGCMChunked,
+		NewAEAD: newAESGCM,
+	})
+	RegisterAlgorithm(Algorithm{
+		ID:      AlgoChaCha20Poly1305Chunked,
+		Name:    AlgoNameChaCha20Poly1305Chunked,
+		NewAEAD: chacha20poly1305.New,
+	})
+}
+
+// RegisterAlgorithm adds a chunk algorithm to the registry.
+// It panics if the ID or name is already registered.
+func RegisterAlgorithm(a Algorithm) {
+	algorithmsMu.Lock()
+	defer algorithmsMu.Unlock()
+
+	if _, ok := algorithmsByID[a.ID]; ok {
+		panic(fmt.Sprintf("crypto: algorithm id %d registered twice", a.ID))
+	}
+	if _, ok := algorithmsName[a.Name]; ok {
+		panic(fmt.Sprintf("crypto: algorithm %q registered twice", a.Name))
+	}
+
+	algorithmsByID[a.ID] = &a
+	algorithmsName[a.Name] = &a
+}
+
+// LookupAlgorithm returns the registered algorithm for a header algo byte.
+func LookupAlgorithm(id uint8) (*Algorithm, error) {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+
+	a, ok := algorithmsByID[id]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm: %d", id)
+	}
+	return a, nil
+}
+
+// LookupAlgorithmName returns the registered algorithm for a manifest algo name.
+func LookupAlgorithmName(name string) (*Algorithm, error) {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+
+	a, ok := algorithmsName[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm: %q", name)
+	}
+	return a, nil
+}
+
+// AlgorithmNames returns the manifest names of all registered algorithms, sorted.
+func AlgorithmNames() []string {
+	algorithmsMu.RLock()
+	defer algorithmsMu.RUnlock()
+
+	names := make([]string, 0, len(algorithmsName))
+	for name := range algorithmsName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newAEAD creates the chunk AEAD for the header's algorithm and a 32-byte key.
+// The returned AEAD is safe for concurrent use and can be reused across chunks.
+func newAEAD(algo uint8, key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+
+	a, err := LookupAlgorithm(algo)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := a.NewAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s cipher: %w", a.Name, err)
+	}
+	if aead.NonceSize() != NonceSize || aead.Overhead() != TagSize {
+		return nil, fmt.Errorf("algorithm %s: nonce/tag size %d/%d, want %d/%d", a.Name, aead.NonceSize(), aead.Overhead(), NonceSize, TagSize)
+	}
+
+	return aead, nil
+}
+
+// newAESGCM creates the AES-256-GCM AEAD.
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	// Create AES cipher
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	// Create GCM
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	return gcm, nil
+}
@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAlgorithmRegistry_Builtins(t *testing.T) {
+	tests := []struct {
+		id   uint8
+		name string
+	}{
+		{AlgoAESGCMChunked, AlgoNameAESGCMChunked},
+		{AlgoChaCha20Poly1305Chunked, AlgoNameChaCha20Poly1305Chunked},
+	}
+
+	for _, tt := range tests {
+		byID, err := LookupAlgorithm(tt.id)
+		if err != nil {
+			t.Fatalf("LookupAlgorithm(%d) error = %v", tt.id, err)
+		}
+		byName, err := LookupAlgorithmName(tt.name)
+		if err != nil {
+			t.Fatalf("LookupAlgorithmName(%q) error = %v", tt.name, err)
+		}
+		if byID != byName {
+			t.Errorf("id %d and name %q resolve to different algorithms", tt.id, tt.name)
+		}
+	}
+
+	if _, err := LookupAlgorithm(0xff); err == nil {
+		t.Error("LookupAlgorithm(0xff) error = nil, want unsupported")
+	}
+	if _, err := LookupAlgorithmName("aes-128-gcm"); err == nil {
+		t.Error("LookupAlgorithmName(aes-128-gcm) error = nil, want unsupported")
+	}
+}
+
+func TestRegisterAlgorithm_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterAlgorithm with a duplicate id did not panic")
+		}
+	}()
+	RegisterAlgorithm(Algorithm{
+		ID:      AlgoAESGCMChunked,
+		Name:    "duplicate",
+		NewAEAD: func(key []byte) (cipher.AEAD, error) { return nil, nil },
+	})
+}
+
+func TestEncryptToWriter_ChaCha20Poly1305(t *testing.T) {
+	key := bytes.Repeat([]byte{0x09}, 32)
+	plaintext := testPlaintext(20*1024 + 5)
+
+	for _, version := range []uint16{Version, VersionV2} {
+		var out bytes.Buffer
+		result, err := EncryptToWriter(bytes.NewReader(plaintext), &out, key, 4096,
+			WithFormatVersion(version),
+			WithPlaintextSize(int64(len(plaintext))),
+			WithAlgorithm(AlgoChaCha20Poly1305Chunked),
+		)
+		if err != nil {
+			t.Fatalf("v%d: EncryptToWriter failed: %v", version, err)
+		}
+		if result.Header.Algo != AlgoChaCha20Poly1305Chunked {
+			t.Errorf("v%d: header algo = %d, want %d", version, result.Header.Algo, AlgoChaCha20Poly1305Chunked)
+		}
+
+		decrypted, err := DecryptToBytes(bytes.NewReader(out.Bytes()), key)
+		if err != nil {
+			t.Fatalf("v%d: DecryptToBytes failed: %v", version, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("v%d: sequential round trip mismatch", version)
+		}
+
+		var parallel bytes.Buffer
+		if _, err := DecryptToWriterParallel(bytes.NewReader(out.Bytes()), &parallel, key, 4); err != nil {
+			t.Fatalf("v%d: DecryptToWriterParallel failed: %v", version, err)
+		}
+		if !bytes.Equal(parallel.Bytes(), plaintext) {
+			t.Errorf("v%d: parallel round trip mismatch", version)
+		}
+
+		dr, err := NewDecryptingReaderAt(bytes.NewReader(out.Bytes()), key)
+		if err != nil {
+			t.Fatalf("v%d: NewDecryptingReaderAt failed: %v", version, err)
+		}
+		got, err := io.ReadAll(dr)
+		if err != nil {
+			t.Fatalf("v%d: DecryptingReader failed: %v", version, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("v%d: random-access round trip mismatch", version)
+		}
+	}
+}
+
+func TestDecrypt_AlgorithmIsAuthenticated(t *testing.T) {
+	key := bytes.Repeat([]byte{0x0a}, 32)
+	plaintext := testPlaintext(3000)
+
+	var out bytes.Buffer
+	_, err := EncryptToWriter(bytes.NewReader(plaintext), &out, key, 1024,
+		WithAlgorithm(AlgoChaCha20Poly1305Chunked),
+	)
+	if err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+
+	// Relabel the file as AES-GCM: decryption must fail, not misinterpret it
+	data := out.Bytes()
+	data[10] = AlgoAESGCMChunked
+
+	if _, err := DecryptToBytes(bytes.NewReader(data), key); err == nil {
+		t.Fatal("expected error after changing header algorithm, got nil")
+	}
+}
+
+func TestParseHeader_UnsupportedAlgorithm(t *testing.T) {
+	h, err := NewHeader(Version, 1024, 0)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	h.Algo = 0x7f
+	buf, _ := h.MarshalBinary()
+
+	_, err = ParseHeader(bytes.NewReader(buf))
+	if err == nil || !strings.Contains(err.Error(), "unsupported algorithm") {
+		t.Fatalf("ParseHeader error = %v, want unsupported algorithm", err)
+	}
+}
+
+func TestEncryptToWriter_UnsupportedAlgorithm(t *testing.T) {
+	key := bytes.Repeat([]byte{0x0b}, 32)
+	var out bytes.Buffer
+	_, err := EncryptToWriter(bytes.NewReader([]byte("x")), &out, key, 1024, WithAlgorithm(0x7f))
+	if err == nil {
+		t.Fatal("expected error for unregistered algorithm, got nil")
+	}
+}
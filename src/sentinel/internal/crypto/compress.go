@@ -0,0 +1,100 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides optional per-chunk zstd compression. A header with
+// FlagZstd frames every record as pt_len || stored_len || ciphertext || tag,
+// where the ciphertext encrypts stored_len bytes. A chunk whose stored_len
+// equals pt_len is stored raw (compression did not help); a smaller
+// stored_len holds a single zstd frame of the chunk. Decompression happens
+// only after the chunk has been authenticated.
+package crypto
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Header flags, stored in Reserved[flagsOffset] of v2 headers. The reserved
+// bytes of v1 headers were never specified and are ignored, so v1 streams
+// cannot be compressed.
+const (
+	// FlagZstd marks a stream whose records carry a stored_len and may hold
+	// zstd-compressed chunks.
+	FlagZstd uint8 = 0x01
+
+	// knownFlags is the set of flags this version understands.
+	knownFlags = FlagZstd
+
+	// flagsOffset is the index of the flags byte within Header.Reserved.
+	// Reserved[0:8] holds the tbenc/v2 plaintext length.
+	flagsOffset = 8
+
+	// StoredLenSize is the size of the stored_len field of compressed records.
+	StoredLenSize = 4
+)
+
+// CompressionZstd is the manifest "compression" value for FlagZstd streams.
+const CompressionZstd = "zstd"
+
+var (
+	// fp16/bf16 weights only shrink at the better-compression level; the
+	// faster levels store them at ~100%. Encryption is an offline step.
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression), zstd.WithEncoderConcurrency(1))
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(MaxChunkBytes))
+	})
+)
+
+// Compressed reports whether the stream's records may be compressed.
+func (h *Header) Compressed() bool {
+	return h.Flags&FlagZstd != 0
+}
+
+// RecordHeaderSize returns the size of the length fields before each
+// record's ciphertext.
+func (h *Header) RecordHeaderSize() int64 {
+	if h.Compressed() {
+		return RecordHeaderSize + StoredLenSize
+	}
+	return RecordHeaderSize
+}
+
+// compressChunk returns the zstd frame of plaintext appended to dst, or nil
+// if it would not be smaller than the plaintext.
+func compressChunk(dst, plaintext []byte) ([]byte, error) {
+	enc, err := zstdEncoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd encoder: %w", err)
+	}
+	compressed := enc.EncodeAll(plaintext, dst[:0])
+	if len(compressed) >= len(plaintext) {
+		SecureZeroBytes(compressed)
+		return nil, nil
+	}
+	return compressed, nil
+}
+
+// inflateChunk decompresses an authenticated chunk into dst, which must have
+// room for ptLen bytes, and checks that exactly ptLen bytes came out.
+//
+// The zstd decoder keeps its window in its own buffers; only the result
+// lands in dst.
+func inflateChunk(dst, stored []byte, chunkIndex uint64, ptLen uint32) ([]byte, error) {
+	dec, err := zstdDecoder()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zstd decoder: %w", err)
+	}
+
+	plaintext, err := dec.DecodeAll(stored, dst[:0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress chunk %d: %w", chunkIndex, err)
+	}
+	if uint32(len(plaintext)) != ptLen {
+		SecureZeroBytes(plaintext)
+		return nil, fmt.Errorf("decompressed length mismatch at chunk %d: got %d, expected %d", chunkIndex, len(plaintext), ptLen)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,260 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+// mixedPlaintext alternates compressible and random 1KB chunks, so a
+// compressed stream holds both zstd frames and raw chunks.
+func mixedPlaintext(t *testing.T, chunks int, tail int) []byte {
+	t.Helper()
+	var out []byte
+	for i := 0; i < chunks; i++ {
+		chunk := testPlaintext(1024)
+		if i%2 == 1 {
+			if _, err := rand.Read(chunk); err != nil {
+				t.Fatalf("rand.Read failed: %v", err)
+			}
+		}
+		out = append(out, chunk...)
+	}
+	return append(out, testPlaintext(tail)...)
+}
+
+func encryptZstd(t *testing.T, key, plaintext []byte, version uint16) (*EncryptResult, []byte) {
+	t.Helper()
+	var out bytes.Buffer
+	result, err := EncryptToWriter(bytes.NewReader(plaintext), &out, key, 1024,
+		WithFormatVersion(version),
+		WithPlaintextSize(int64(len(plaintext))),
+		WithZstd(),
+	)
+	if err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+	return result, out.Bytes()
+}
+
+func TestZstd_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"compressible", testPlaintext(10*1024 + 300)},
+		{"mixed", mixedPlaintext(t, 8, 17)},
+		{"exact_chunks", testPlaintext(4 * 1024)},
+		{"empty", []byte{}},
+	}
+
+	for _, tt := range tests {
+		for _, version := range []uint16{VersionV2} {
+			result, encrypted := encryptZstd(t, key, tt.plaintext, version)
+
+			header, err := ParseHeader(bytes.NewReader(encrypted))
+			if err != nil {
+				t.Fatalf("%s v%d: ParseHeader failed: %v", tt.name, version, err)
+			}
+			if !header.Compressed() {
+				t.Errorf("%s v%d: header not marked compressed", tt.name, version)
+			}
+			if result.CiphertextBytes != int64(len(encrypted)) {
+				t.Errorf("%s v%d: CiphertextBytes = %d, want %d", tt.name, version, result.CiphertextBytes, len(encrypted))
+			}
+
+			decrypted, err := DecryptToBytes(bytes.NewReader(encrypted), key)
+			if err != nil {
+				t.Fatalf("%s v%d: DecryptToBytes failed: %v", tt.name, version, err)
+			}
+			if !bytes.Equal(decrypted, tt.plaintext) {
+				t.Errorf("%s v%d: sequential round trip mismatch", tt.name, version)
+			}
+
+			var parallel bytes.Buffer
+			if _, err := DecryptToWriterParallel(bytes.NewReader(encrypted), &parallel, key, 4); err != nil {
+				t.Fatalf("%s v%d: DecryptToWriterParallel failed: %v", tt.name, version, err)
+			}
+			if !bytes.Equal(parallel.Bytes(), tt.plaintext) {
+				t.Errorf("%s v%d: parallel round trip mismatch", tt.name, version)
+			}
+
+			vr, err := VerifyAll(bytes.NewReader(encrypted), key, WithExpected(Expected{
+				ChunkBytes:      1024,
+				PlaintextBytes:  int64(len(tt.plaintext)),
+				CiphertextBytes: int64(len(encrypted)),
+			}))
+			if err != nil {
+				t.Fatalf("%s v%d: VerifyAll failed: %v", tt.name, version, err)
+			}
+			if vr.PlaintextBytes != int64(len(tt.plaintext)) {
+				t.Errorf("%s v%d: VerifyAll PlaintextBytes = %d, want %d", tt.name, version, vr.PlaintextBytes, len(tt.plaintext))
+			}
+		}
+	}
+}
+
+func TestZstd_ShrinksCompressibleData(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(64 * 1024)
+
+	result, _ := encryptZstd(t, key, plaintext, VersionV2)
+	if result.CiphertextBytes >= result.PlaintextBytes/2 {
+		t.Errorf("CiphertextBytes = %d for %d plaintext bytes, want under half", result.CiphertextBytes, result.PlaintextBytes)
+	}
+}
+
+func TestZstd_StoresIncompressibleChunksRaw(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := make([]byte, 3*1024+100)
+	if _, err := rand.Read(plaintext); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+
+	_, encrypted := encryptZstd(t, key, plaintext, VersionV2)
+
+	// Every record is pt_len || stored_len || ciphertext || tag with stored_len == pt_len
+	want := int64(HeaderSize) + 4*(RecordHeaderSize+StoredLenSize+TagSize) + int64(len(plaintext))
+	if int64(len(encrypted)) != want {
+		t.Errorf("ciphertext is %d bytes, want %d", len(encrypted), want)
+	}
+
+	off := HeaderSize
+	for i := 0; i < 4; i++ {
+		ptLen := binary.BigEndian.Uint32(encrypted[off:])
+		storedLen := binary.BigEndian.Uint32(encrypted[off+RecordHeaderSize:])
+		if ptLen != storedLen {
+			t.Errorf("chunk %d: stored_len %d, pt_len %d", i, storedLen, ptLen)
+		}
+		off += RecordHeaderSize + StoredLenSize + int(storedLen) + TagSize
+	}
+}
+
+func TestZstd_DecryptingReader(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := mixedPlaintext(t, 9, 300)
+
+	for _, version := range []uint16{VersionV2} {
+		_, encrypted := encryptZstd(t, key, plaintext, version)
+
+		r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key)
+		if err != nil {
+			t.Fatalf("v%d: NewDecryptingReaderAt failed: %v", version, err)
+		}
+		if r.Size() != int64(len(plaintext)) {
+			t.Fatalf("v%d: Size = %d, want %d", version, r.Size(), len(plaintext))
+		}
+
+		for _, rng := range [][2]int64{{0, 10}, {1000, 2100}, {5000, 9516}, {9215, 9516}} {
+			got := make([]byte, rng[1]-rng[0])
+			n, err := r.ReadAt(got, rng[0])
+			if err != nil && err != io.EOF {
+				t.Fatalf("v%d: ReadAt(%d) failed: %v", version, rng[0], err)
+			}
+			if !bytes.Equal(got[:n], plaintext[rng[0]:rng[1]]) {
+				t.Errorf("v%d: ReadAt(%d, %d) mismatch", version, rng[0], rng[1])
+			}
+		}
+		r.Close()
+	}
+}
+
+func TestZstd_DecryptingReader_SizeMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	_, encrypted := encryptZstd(t, key, testPlaintext(5*1024), VersionV2)
+
+	if _, err := NewDecryptingReaderAt(bytes.NewReader(encrypted[:len(encrypted)-5]), key); !errors.Is(err, ErrTruncated) {
+		t.Errorf("truncated: err = %v, want ErrTruncated", err)
+	}
+	extended := append(append([]byte{}, encrypted...), 0)
+	if _, err := NewDecryptingReaderAt(bytes.NewReader(extended), key); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("extended: err = %v, want ErrTrailingData", err)
+	}
+}
+
+func TestZstd_Tampering(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3 * 1024)
+	_, encrypted := encryptZstd(t, key, plaintext, VersionV2)
+
+	t.Run("flags cleared", func(t *testing.T) {
+		tampered := append([]byte{}, encrypted...)
+		tampered[HeaderSize-13+flagsOffset] = 0
+		if _, err := DecryptToBytes(bytes.NewReader(tampered), key); err == nil {
+			t.Error("decryption succeeded with the compression flag cleared")
+		}
+	})
+
+	t.Run("stored_len changed", func(t *testing.T) {
+		tampered := append([]byte{}, encrypted...)
+		off := HeaderSize + RecordHeaderSize
+		storedLen := binary.BigEndian.Uint32(tampered[off:])
+		binary.BigEndian.PutUint32(tampered[off:], storedLen-1)
+		if _, err := DecryptToBytes(bytes.NewReader(tampered), key); err == nil {
+			t.Error("decryption succeeded with a changed stored_len")
+		}
+	})
+
+	t.Run("stored_len exceeds pt_len", func(t *testing.T) {
+		tampered := append([]byte{}, encrypted...)
+		binary.BigEndian.PutUint32(tampered[HeaderSize+RecordHeaderSize:], 2000)
+		if _, err := DecryptToBytes(bytes.NewReader(tampered), key); err == nil {
+			t.Error("decryption accepted stored_len > pt_len")
+		}
+	})
+}
+
+func TestZstd_RequiresV2(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	var out bytes.Buffer
+	if _, err := EncryptToWriter(bytes.NewReader(testPlaintext(2048)), &out, key, 1024, WithZstd()); err == nil {
+		t.Error("EncryptToWriter compressed a tbenc/v1 stream")
+	}
+}
+
+func TestParseHeader_V1ReservedIgnored(t *testing.T) {
+	h, err := NewHeader(Version, 1024, -1)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	data, _ := h.MarshalBinary()
+	for i := HeaderSize - 13; i < HeaderSize; i++ {
+		data[i] = 0xff
+	}
+
+	parsed, err := ParseHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseHeader rejected v1 reserved bytes: %v", err)
+	}
+	if parsed.Flags != 0 || parsed.Compressed() {
+		t.Errorf("v1 Flags = %#x, want none", parsed.Flags)
+	}
+}
+
+func TestParseHeader_UnknownFlags(t *testing.T) {
+	h, err := NewHeader(VersionV2, 1024, 0)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	h.Flags = 0x80
+
+	data, _ := h.MarshalBinary()
+	if _, err := ParseHeader(bytes.NewReader(data)); err == nil {
+		t.Error("ParseHeader accepted unknown flags")
+	}
+
+	h.Flags = FlagZstd
+	data, _ = h.MarshalBinary()
+	parsed, err := ParseHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	if parsed.Flags != FlagZstd || parsed.RecordHeaderSize() != RecordHeaderSize+StoredLenSize {
+		t.Errorf("Flags = %#x, RecordHeaderSize = %d", parsed.Flags, parsed.RecordHeaderSize())
+	}
+}
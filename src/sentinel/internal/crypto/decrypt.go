@@ -1,14 +1,24 @@
 // Package crypto implements tbenc/v1 decryption for TrustBridge.
 //
-// The tbenc/v1 format is a chunked AES-256-GCM encryption format designed for
-// streaming decryption of large model weight files.
+// The tbenc/v1 format is a chunked AEAD encryption format designed for
+// streaming decryption of large model weight files. The chunk AEAD is chosen
+// by the header's algo byte from the registry in algorithm.go (AES-256-GCM or
+// ChaCha20-Poly1305).
+//
+// The tbenc/v2 format uses the same header layout and record framing, but
+// stores the total plaintext length in the header and binds it, together with
+// a final-chunk flag, into every chunk's AAD. This lets the decrypter detect
+// streams that were truncated or extended at a record boundary.
+//
+// A tbenc/v2 header may set FlagZstd in its flags byte, in which case chunks
+// may be zstd-compressed before encryption (see compress.go).
 package crypto
 
 import (
 	"bytes"
-	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -17,9 +27,12 @@ const (
 	// Magic header identifier
 	Magic = "TBENC001"
 
-	// Version is the format version
+	// Version is the tbenc/v1 format version
 	Version uint16 = 1
 
+	// VersionV2 is the tbenc/v2 format version (authenticated end-of-stream)
+	VersionV2 uint16 = 2
+
 	// AlgoAESGCMChunked represents the AES-256-GCM chunked algorithm
 	AlgoAESGCMChunked uint8 = 1
 
@@ -34,19 +47,83 @@ const (
 
 	// TagSize is the GCM authentication tag size
 	TagSize = 16
+
+	// RecordHeaderSize is the size of the pt_len prefix of each record
+	RecordHeaderSize = 4
 )
 
-// Header represents the parsed tbenc/v1 file header.
+// Errors returned when a tbenc/v2 stream does not end where its header says.
+var (
+	// ErrTruncated indicates the stream ended before the final chunk.
+	ErrTruncated = errors.New("tbenc stream truncated")
+
+	// ErrTrailingData indicates data was found after the final chunk.
+	ErrTrailingData = errors.New("tbenc stream has trailing data after final chunk")
+)
+
+// Header represents the parsed tbenc file header.
+//
+// For tbenc/v2, the first 8 reserved bytes hold the total plaintext length
+// (big-endian uint64), which is exposed as PlaintextBytes. The next reserved
+// byte holds the header flags, exposed as Flags, and the last four the key
+// reference, exposed as KeyID and KeyVersion. tbenc/v1 headers have neither:
+// their reserved bytes were never specified and are ignored.
 type Header struct {
-	Magic       [8]byte
-	Version     uint16
-	Algo        uint8
-	ChunkBytes  uint32
-	NoncePrefix [4]byte
-	Reserved    [13]byte
+	Magic          [8]byte
+	Version        uint16
+	Algo           uint8
+	ChunkBytes     uint32
+	NoncePrefix    [4]byte
+	Reserved       [13]byte
+	PlaintextBytes uint64 // tbenc/v2 only: total plaintext length
+	Flags          uint8  // tbenc/v2 only: FlagZstd or 0
+	KeyID          uint16 // tbenc/v2 only: provider key, 0 if not named
+	KeyVersion     uint16 // tbenc/v2 only: rotation of KeyID
+}
+
+// ChunkCount returns the number of records in a tbenc/v2 stream.
+// An empty v2 plaintext is encoded as a single empty final record.
+// Returns 0 for tbenc/v1 headers, where the count is not recorded.
+func (h *Header) ChunkCount() uint64 {
+	if h.Version != VersionV2 {
+		return 0
+	}
+	if h.PlaintextBytes == 0 {
+		return 1
+	}
+	chunk := uint64(h.ChunkBytes)
+	return (h.PlaintextBytes + chunk - 1) / chunk
+}
+
+// chunkPtLen returns the plaintext length of chunk chunkIndex in a v2 stream.
+func (h *Header) chunkPtLen(chunkIndex uint64) uint32 {
+	start := chunkIndex * uint64(h.ChunkBytes)
+	if start >= h.PlaintextBytes {
+		return 0
+	}
+	remaining := h.PlaintextBytes - start
+	if remaining > uint64(h.ChunkBytes) {
+		return h.ChunkBytes
+	}
+	return uint32(remaining)
+}
+
+// validatePtLen checks a record's pt_len against the header.
+func (h *Header) validatePtLen(chunkIndex uint64, ptLen uint32) error {
+	if h.Version == VersionV2 {
+		if expected := h.chunkPtLen(chunkIndex); ptLen != expected {
+			return fmt.Errorf("invalid pt_len %d at chunk %d (expected %d)", ptLen, chunkIndex, expected)
+		}
+		return nil
+	}
+
+	if ptLen == 0 || ptLen > h.ChunkBytes {
+		return fmt.Errorf("invalid pt_len %d at chunk %d (max %d)", ptLen, chunkIndex, h.ChunkBytes)
+	}
+	return nil
 }
 
-// ParseHeader reads and validates the 32-byte tbenc/v1 header.
+// ParseHeader reads and validates the 32-byte tbenc/v1 or tbenc/v2 header.
 func ParseHeader(r io.Reader) (*Header, error) {
 	buf := make([]byte, HeaderSize)
 	if _, err := io.ReadFull(r, buf); err != nil {
@@ -68,23 +145,23 @@ func ParseHeader(r io.Reader) (*Header, error) {
 	h.Version = binary.BigEndian.Uint16(buf[offset : offset+2])
 	offset += 2
 
-	if h.Version != Version {
-		return nil, fmt.Errorf("unsupported version: got %d, want %d", h.Version, Version)
+	if h.Version != Version && h.Version != VersionV2 {
+		return nil, fmt.Errorf("unsupported version: got %d, want %d or %d", h.Version, Version, VersionV2)
 	}
 
 	// Parse algo (uint8)
 	h.Algo = buf[offset]
 	offset++
 
-	if h.Algo != AlgoAESGCMChunked {
-		return nil, fmt.Errorf("unsupported algorithm: got %d, want %d", h.Algo, AlgoAESGCMChunked)
+	if _, err := LookupAlgorithm(h.Algo); err != nil {
+		return nil, err
 	}
 
 	// Parse chunk_bytes (big-endian uint32)
 	h.ChunkBytes = binary.BigEndian.Uint32(buf[offset : offset+4])
 	offset += 4
 
-	if h.ChunkBytes == 0 || h.ChunkBytes > 64*1024*1024 {
+	if h.ChunkBytes == 0 || h.ChunkBytes > MaxChunkBytes {
 		return nil, fmt.Errorf("invalid chunk_bytes: %d (must be 1 to 64MB)", h.ChunkBytes)
 	}
 
@@ -92,12 +169,98 @@ func ParseHeader(r io.Reader) (*Header, error) {
 	copy(h.NoncePrefix[:], buf[offset:offset+4])
 	offset += 4
 
-	// Parse reserved (should be zeros, but we don't enforce)
+	// Parse reserved (unused bytes should be zeros, but we don't enforce)
 	copy(h.Reserved[:], buf[offset:offset+13])
 
+	// tbenc/v2 stores the total plaintext length in the first reserved bytes,
+	// followed by the flags and the key reference
+	if h.Version == VersionV2 {
+		h.parseKeyRef()
+
+		h.PlaintextBytes = binary.BigEndian.Uint64(h.Reserved[0:8])
+		if h.PlaintextBytes > uint64(h.ChunkBytes)*(1<<32) {
+			return nil, fmt.Errorf("invalid plaintext_bytes: %d", h.PlaintextBytes)
+		}
+
+		// Unknown flags change the record framing, so they cannot be ignored
+		h.Flags = h.Reserved[flagsOffset]
+		if h.Flags&^knownFlags != 0 {
+			return nil, fmt.Errorf("unsupported header flags: 0x%02x", h.Flags)
+		}
+	}
+
 	return h, nil
 }
 
+// readRecordLen reads and validates the pt_len prefix of record chunkIndex.
+//
+// It returns io.EOF at a clean end of stream. For tbenc/v2 streams, running
+// out of records before the final chunk returns ErrTruncated, and any data
+// after the final chunk returns ErrTrailingData.
+func readRecordLen(r io.Reader, h *Header, chunkIndex uint64) (uint32, error) {
+	if h.Version == VersionV2 && chunkIndex == h.ChunkCount() {
+		var b [1]byte
+		n, err := io.ReadFull(r, b[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w: after chunk %d", ErrTrailingData, chunkIndex-1)
+		}
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		return 0, fmt.Errorf("failed to check end of stream after chunk %d: %w", chunkIndex-1, err)
+	}
+
+	var lenBuf [RecordHeaderSize]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		if err == io.EOF {
+			if h.Version == VersionV2 {
+				return 0, fmt.Errorf("%w: got %d of %d chunks", ErrTruncated, chunkIndex, h.ChunkCount())
+			}
+			// Normal end of file
+			return 0, io.EOF
+		}
+		return 0, fmt.Errorf("failed to read pt_len at chunk %d: %w", chunkIndex, err)
+	}
+
+	ptLen := binary.BigEndian.Uint32(lenBuf[:])
+	if err := h.validatePtLen(chunkIndex, ptLen); err != nil {
+		return 0, err
+	}
+
+	return ptLen, nil
+}
+
+// readStoredLen reads the stored_len field that follows pt_len in records of
+// compressed streams. For other streams the chunk is stored raw and ptLen is
+// returned without reading.
+func readStoredLen(r io.Reader, h *Header, chunkIndex uint64, ptLen uint32) (uint32, error) {
+	if !h.Compressed() {
+		return ptLen, nil
+	}
+
+	var lenBuf [StoredLenSize]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, fmt.Errorf("failed to read stored_len at chunk %d: %w", chunkIndex, err)
+	}
+
+	storedLen := binary.BigEndian.Uint32(lenBuf[:])
+	if err := validateStoredLen(chunkIndex, ptLen, storedLen); err != nil {
+		return 0, err
+	}
+	return storedLen, nil
+}
+
+// validateStoredLen checks a record's stored_len against its pt_len.
+func validateStoredLen(chunkIndex uint64, ptLen, storedLen uint32) error {
+	if storedLen > ptLen || (storedLen == 0 && ptLen > 0) {
+		return fmt.Errorf("invalid stored_len %d at chunk %d (pt_len %d)", storedLen, chunkIndex, ptLen)
+	}
+	return nil
+}
+
 // deriveNonce derives a 12-byte GCM nonce from the prefix and chunk index.
 // Format: nonce_prefix (4 bytes) || counter (8 bytes, big-endian)
 func deriveNonce(prefix [4]byte, chunkIndex uint64) []byte {
@@ -108,9 +271,11 @@ func deriveNonce(prefix [4]byte, chunkIndex uint64) []byte {
 }
 
 // buildAAD constructs the Associated Authenticated Data for GCM verification.
-// AAD = magic||version||algo||chunk_bytes||nonce_prefix||chunk_index||pt_len
-func buildAAD(h *Header, chunkIndex uint64, ptLen uint32) []byte {
-	aad := make([]byte, 0, 8+2+1+4+4+8+4)
+// v1: AAD = magic||version||algo||chunk_bytes||nonce_prefix||chunk_index||pt_len
+// v2: AAD = v1 AAD||plaintext_bytes||final_flag
+// Compressed streams append flags||stored_len to either.
+func buildAAD(h *Header, chunkIndex uint64, ptLen, storedLen uint32) []byte {
+	aad := make([]byte, 0, 8+2+1+4+4+8+4+8+1+1+4)
 	aad = append(aad, h.Magic[:]...)
 	aad = binary.BigEndian.AppendUint16(aad, h.Version)
 	aad = append(aad, h.Algo)
@@ -118,10 +283,23 @@ func buildAAD(h *Header, chunkIndex uint64, ptLen uint32) []byte {
 	aad = append(aad, h.NoncePrefix[:]...)
 	aad = binary.BigEndian.AppendUint64(aad, chunkIndex)
 	aad = binary.BigEndian.AppendUint32(aad, ptLen)
+	if h.Version == VersionV2 {
+		aad = binary.BigEndian.AppendUint64(aad, h.PlaintextBytes)
+		var final uint8
+		if chunkIndex == h.ChunkCount()-1 {
+			final = 1
+		}
+		aad = append(aad, final)
+	}
+	if h.Compressed() {
+		aad = append(aad, h.Flags)
+		aad = binary.BigEndian.AppendUint32(aad, storedLen)
+	}
 	return aad
 }
 
-// DecryptChunk decrypts a single chunk using AES-256-GCM.
+// DecryptChunk decrypts a single chunk using the header's algorithm.
+// In a compressed stream it handles chunks stored raw (stored_len == pt_len).
 //
 // Args:
 //   - key: 32-byte AES-256 key
@@ -132,46 +310,67 @@ func buildAAD(h *Header, chunkIndex uint64, ptLen uint32) []byte {
 //
 // Returns decrypted plaintext or error.
 func DecryptChunk(key []byte, header *Header, chunkIndex uint64, ptLen uint32, ciphertextWithTag []byte) ([]byte, error) {
-	if len(key) != 32 {
-		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
-	}
-
-	// Create AES cipher
-	block, err := aes.NewCipher(key)
+	aead, err := newAEAD(header.Algo, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		return nil, err
 	}
 
-	// Create GCM
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
-	}
+	return openChunk(aead, nil, header, chunkIndex, ptLen, ciphertextWithTag)
+}
+
+// openChunk authenticates and decrypts a single chunk stored raw with an
+// existing AEAD. The plaintext is appended to dst; passing
+// ciphertextWithTag[:0] decrypts in place.
+func openChunk(gcm cipher.AEAD, dst []byte, header *Header, chunkIndex uint64, ptLen uint32, ciphertextWithTag []byte) ([]byte, error) {
+	return openRecord(gcm, dst, header, chunkIndex, ptLen, ptLen, ciphertextWithTag)
+}
 
+// openRecord authenticates and decrypts the stored bytes of a record, which
+// are the chunk plaintext, or its zstd frame if storedLen < ptLen.
+func openRecord(gcm cipher.AEAD, dst []byte, header *Header, chunkIndex uint64, ptLen, storedLen uint32, ciphertextWithTag []byte) ([]byte, error) {
 	// Derive nonce
 	nonce := deriveNonce(header.NoncePrefix, chunkIndex)
 
 	// Build AAD
-	aad := buildAAD(header, chunkIndex, ptLen)
+	aad := buildAAD(header, chunkIndex, ptLen, storedLen)
 
 	// Decrypt (Open verifies the tag and decrypts)
-	plaintext, err := gcm.Open(nil, nonce, ciphertextWithTag, aad)
+	stored, err := gcm.Open(dst, nonce, ciphertextWithTag, aad)
 	if err != nil {
 		return nil, fmt.Errorf("decryption failed at chunk %d: %w", chunkIndex, err)
 	}
 
 	// Verify plaintext length matches expected
-	if uint32(len(plaintext)) != ptLen {
-		return nil, fmt.Errorf("plaintext length mismatch: got %d, expected %d", len(plaintext), ptLen)
+	if uint32(len(stored)) != storedLen {
+		return nil, fmt.Errorf("plaintext length mismatch: got %d, expected %d", len(stored), storedLen)
+	}
+
+	return stored, nil
+}
+
+// decryptRecord authenticates a record and returns its chunk plaintext.
+// Chunks stored raw are decrypted in place in ciphertextWithTag; compressed
+// chunks are inflated into out, which must have room for ptLen bytes, and
+// the intermediate frame is wiped.
+func decryptRecord(gcm cipher.AEAD, header *Header, chunkIndex uint64, ptLen, storedLen uint32, ciphertextWithTag, out []byte) ([]byte, error) {
+	stored, err := openRecord(gcm, ciphertextWithTag[:0], header, chunkIndex, ptLen, storedLen, ciphertextWithTag)
+	if err != nil {
+		return nil, err
+	}
+	if storedLen == ptLen {
+		return stored, nil
 	}
 
-	return plaintext, nil
+	defer SecureZeroBytes(stored)
+	return inflateChunk(out, stored, chunkIndex, ptLen)
 }
 
-// DecryptToWriter decrypts a tbenc/v1 file and writes plaintext to a writer.
+// DecryptToWriter decrypts a tbenc file and writes plaintext to a writer.
 //
 // This is the main decryption function used by the sentinel for streaming
-// decryption to a FIFO or other writer.
+// decryption to a FIFO or other writer. For tbenc/v2 input, a stream that ends
+// before the final chunk fails with ErrTruncated and a stream with data after
+// the final chunk fails with ErrTrailingData.
 //
 // Args:
 //   - r: reader for encrypted input (e.g., os.File)
@@ -186,33 +385,56 @@ func DecryptToWriter(r io.Reader, w io.Writer, key []byte) (int64, error) {
 		return 0, fmt.Errorf("failed to parse header: %w", err)
 	}
 
+	aead, err := newAEAD(header.Algo, key)
+	if err != nil {
+		return 0, err
+	}
+
+	// Decrypt in place in guarded memory so plaintext never lands on the Go heap
+	buf, err := NewSecureBuffer(int(header.ChunkBytes) + TagSize)
+	if err != nil {
+		return 0, err
+	}
+	defer buf.Destroy()
+
+	// Compressed chunks are inflated into a second guarded buffer
+	var out []byte
+	if header.Compressed() {
+		outBuf, err := NewSecureBuffer(int(header.ChunkBytes))
+		if err != nil {
+			return 0, err
+		}
+		defer outBuf.Destroy()
+		out = outBuf.Bytes()
+	}
+
 	var totalWritten int64
 	chunkIndex := uint64(0)
 
 	for {
-		// Read record header: pt_len (uint32, big-endian)
-		var ptLen uint32
-		if err := binary.Read(r, binary.BigEndian, &ptLen); err != nil {
+		// Read and validate record header: pt_len (uint32, big-endian)
+		ptLen, err := readRecordLen(r, header, chunkIndex)
+		if err != nil {
 			if err == io.EOF {
 				// Normal end of file
 				break
 			}
-			return totalWritten, fmt.Errorf("failed to read pt_len at chunk %d: %w", chunkIndex, err)
+			return totalWritten, err
 		}
 
-		// Validate pt_len
-		if ptLen == 0 || ptLen > header.ChunkBytes {
-			return totalWritten, fmt.Errorf("invalid pt_len %d at chunk %d (max %d)", ptLen, chunkIndex, header.ChunkBytes)
+		storedLen, err := readStoredLen(r, header, chunkIndex, ptLen)
+		if err != nil {
+			return totalWritten, err
 		}
 
 		// Read ciphertext + tag
-		ctWithTag := make([]byte, int(ptLen)+TagSize)
+		ctWithTag := buf.Bytes()[:int(storedLen)+TagSize]
 		if _, err := io.ReadFull(r, ctWithTag); err != nil {
 			return totalWritten, fmt.Errorf("failed to read ciphertext at chunk %d: %w", chunkIndex, err)
 		}
 
 		// Decrypt chunk
-		plaintext, err := DecryptChunk(key, header, chunkIndex, ptLen, ctWithTag)
+		plaintext, err := decryptRecord(aead, header, chunkIndex, ptLen, storedLen, ctWithTag, out)
 		if err != nil {
 			return totalWritten, err
 		}
@@ -234,15 +456,6 @@ func DecryptToWriter(r io.Reader, w io.Writer, key []byte) (int64, error) {
 	return totalWritten, nil
 }
 
-// SecureZeroBytes overwrites a byte slice with zeros.
-// Note: This is best-effort and may not prevent all memory attacks due to
-// Go's GC potentially copying data, but it reduces exposure.
-func SecureZeroBytes(b []byte) {
-	for i := range b {
-		b[i] = 0
-	}
-}
-
 // DecryptToBytes is a convenience function that decrypts an entire file to memory.
 // Use with caution for large files.
 func DecryptToBytes(r io.Reader, key []byte) ([]byte, error) {
@@ -0,0 +1,239 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides a parallel decryption pipeline that spreads chunk
+// authentication and decryption across multiple cores while writing
+// plaintext strictly in chunk-index order.
+package crypto
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// parallelWindowFactor bounds the number of chunks in flight per worker.
+// With N workers at most N*parallelWindowFactor chunk buffers are allocated
+// in guarded memory, which keeps memory bounded when the writer (e.g. a FIFO)
+// is slow.
+const parallelWindowFactor = 2
+
+// parallelChunk is a single record travelling through the pipeline.
+type parallelChunk struct {
+	index     uint64
+	ptLen     uint32
+	storedLen uint32
+	buf       *[]byte // pooled buffer holding ciphertext+tag, decrypted in place
+	plaintext []byte  // slice of buf after successful decryption
+	err       error
+}
+
+// DecryptToWriterParallel decrypts a tbenc stream using a pool of workers.
+//
+// The pipeline has three stages:
+//  1. A reader goroutine frames records from r ahead of decryption.
+//  2. Worker goroutines authenticate and decrypt chunks concurrently, using a
+//     single shared AEAD and pooled guarded buffers (decryption happens in
+//     place, so plaintext never lands on the Go heap). Compressed chunks are
+//     inflated into the second half of the same buffer.
+//  3. A reorder stage (the calling goroutine) writes plaintext to w strictly
+//     in chunk-index order.
+//
+// Error semantics match DecryptToWriter: the error for the lowest failing
+// chunk index is returned, and no plaintext past that chunk is written.
+//
+// If workers is 1 or less, this falls back to DecryptToWriter.
+func DecryptToWriterParallel(r io.Reader, w io.Writer, key []byte, workers int) (int64, error) {
+	if workers <= 1 {
+		return DecryptToWriter(r, w, key)
+	}
+
+	// Parse header
+	header, err := ParseHeader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	gcm, err := newAEAD(header.Algo, key)
+	if err != nil {
+		return 0, err
+	}
+
+	window := workers * parallelWindowFactor
+	bufSize := int(header.ChunkBytes) + TagSize
+	if header.Compressed() {
+		bufSize += int(header.ChunkBytes)
+	}
+	pool := newSecureBufferPool(window, bufSize)
+	defer pool.Destroy()
+	slots := make(chan struct{}, window)
+	jobs := make(chan *parallelChunk, window)
+	results := make(chan *parallelChunk, window)
+	done := make(chan struct{})
+
+	// Stage 1: frame records
+	var readerWg sync.WaitGroup
+	readerWg.Add(1)
+	go func() {
+		defer readerWg.Done()
+		defer close(jobs)
+		readRecords(r, header, pool, slots, jobs, done)
+	}()
+
+	// Stage 2: decrypt
+	var workerWg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		workerWg.Add(1)
+		go func() {
+			defer workerWg.Done()
+			decryptWorker(gcm, header, pool, jobs, results, done)
+		}()
+	}
+
+	go func() {
+		workerWg.Wait()
+		close(results)
+	}()
+
+	// Stage 3: reorder and write
+	var totalWritten int64
+	var firstErr error
+	pending := make(map[uint64]*parallelChunk, window)
+	next := uint64(0)
+
+	for c := range results {
+		pending[c.index] = c
+
+		for firstErr == nil {
+			p, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+
+			if p.err != nil {
+				firstErr = p.err
+				releaseChunk(pool, p)
+				break
+			}
+
+			n, err := w.Write(p.plaintext)
+			totalWritten += int64(n)
+
+			// Security: best-effort overwrite plaintext buffer
+			releaseChunk(pool, p)
+			<-slots
+
+			if err != nil {
+				firstErr = fmt.Errorf("failed to write plaintext at chunk %d: %w", next, err)
+				break
+			}
+
+			next++
+		}
+
+		if firstErr != nil {
+			break
+		}
+	}
+
+	// Stop the pipeline and release any chunks still in flight
+	close(done)
+	for c := range results {
+		releaseChunk(pool, c)
+	}
+	readerWg.Wait()
+	for _, p := range pending {
+		releaseChunk(pool, p)
+	}
+
+	return totalWritten, firstErr
+}
+
+// readRecords frames tbenc records from r and sends them to jobs.
+// A framing error is forwarded as a chunk carrying the error so that the
+// reorder stage reports it at the correct position.
+func readRecords(r io.Reader, header *Header, pool *secureBufferPool, slots chan struct{}, jobs chan<- *parallelChunk, done <-chan struct{}) {
+	for chunkIndex := uint64(0); ; chunkIndex++ {
+		// Wait for a free slot so the reader never runs too far ahead
+		select {
+		case slots <- struct{}{}:
+		case <-done:
+			return
+		}
+
+		c := &parallelChunk{index: chunkIndex}
+
+		// Read and validate record header: pt_len (uint32, big-endian)
+		ptLen, err := readRecordLen(r, header, chunkIndex)
+		if err == io.EOF {
+			// Normal end of file
+			return
+		}
+		if err != nil {
+			c.err = err
+		} else if c.storedLen, err = readStoredLen(r, header, chunkIndex, ptLen); err != nil {
+			c.err = err
+		} else {
+			c.ptLen = ptLen
+
+			// Read ciphertext + tag into a pooled buffer
+			c.buf, err = pool.Get()
+			if err != nil {
+				c.err = fmt.Errorf("failed to allocate buffer at chunk %d: %w", chunkIndex, err)
+			} else {
+				ct := (*c.buf)[:int(c.storedLen)+TagSize]
+				if _, err := io.ReadFull(r, ct); err != nil {
+					c.err = fmt.Errorf("failed to read ciphertext at chunk %d: %w", chunkIndex, err)
+				}
+			}
+		}
+
+		// Capture the error before handing the chunk to a worker
+		failed := c.err != nil
+
+		select {
+		case jobs <- c:
+		case <-done:
+			releaseChunk(pool, c)
+			return
+		}
+
+		if failed {
+			return
+		}
+	}
+}
+
+// decryptWorker authenticates and decrypts chunks from jobs in place.
+func decryptWorker(gcm cipher.AEAD, header *Header, pool *secureBufferPool, jobs <-chan *parallelChunk, results chan<- *parallelChunk, done <-chan struct{}) {
+	for c := range jobs {
+		if c.err == nil {
+			ct := (*c.buf)[:int(c.storedLen)+TagSize]
+			out := (*c.buf)[int(header.ChunkBytes)+TagSize:]
+			c.plaintext, c.err = decryptRecord(gcm, header, c.index, c.ptLen, c.storedLen, ct, out)
+		}
+
+		select {
+		case results <- c:
+		case <-done:
+			releaseChunk(pool, c)
+		}
+	}
+}
+
+// releaseChunk zeroes the used part of a chunk's buffer and returns it to the pool.
+func releaseChunk(pool *secureBufferPool, c *parallelChunk) {
+	if c.buf == nil {
+		return
+	}
+	if c.storedLen != c.ptLen {
+		// Inflated plaintext sits after the ciphertext
+		SecureZeroBytes(*c.buf)
+	} else {
+		SecureZeroBytes((*c.buf)[:int(c.storedLen)+TagSize])
+	}
+	pool.Put(c.buf)
+	c.buf = nil
+	c.plaintext = nil
+}
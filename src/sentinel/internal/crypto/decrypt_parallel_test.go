@@ -0,0 +1,228 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDecryptToWriterParallel_MatchesSequential(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := make([]byte, 100*1024+123)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+
+	encryptedFile := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	for _, workers := range []int{0, 1, 2, 4, 16} {
+		var output bytes.Buffer
+		n, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), &output, key, workers)
+		if err != nil {
+			t.Fatalf("workers=%d: DecryptToWriterParallel failed: %v", workers, err)
+		}
+		if n != int64(len(plaintext)) {
+			t.Errorf("workers=%d: bytes written mismatch: got %d, want %d", workers, n, len(plaintext))
+		}
+		if !bytes.Equal(output.Bytes(), plaintext) {
+			t.Errorf("workers=%d: plaintext mismatch", workers)
+		}
+	}
+}
+
+func TestDecryptToWriterParallel_EmptyFile(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	encryptedFile := createTestEncryptedFile(t, key, []byte{}, 1024)
+
+	var output bytes.Buffer
+	n, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), &output, key, 4)
+	if err != nil {
+		t.Fatalf("DecryptToWriterParallel failed: %v", err)
+	}
+	if n != 0 || output.Len() != 0 {
+		t.Errorf("expected no output, got %d bytes", output.Len())
+	}
+}
+
+func TestDecryptToWriterParallel_WrongKey(t *testing.T) {
+	correctKey := bytes.Repeat([]byte{0x42}, 32)
+	wrongKey := bytes.Repeat([]byte{0x99}, 32)
+	plaintext := bytes.Repeat([]byte("X"), 8*1024)
+
+	encryptedFile := createTestEncryptedFile(t, correctKey, plaintext, 1024)
+
+	var output bytes.Buffer
+	n, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), &output, wrongKey, 4)
+	if err == nil {
+		t.Fatal("expected decryption error with wrong key, got nil")
+	}
+	if !strings.Contains(err.Error(), "chunk 0") {
+		t.Errorf("expected error at chunk 0, got: %v", err)
+	}
+	if n != 0 || output.Len() != 0 {
+		t.Errorf("expected nothing written, got %d bytes", output.Len())
+	}
+}
+
+func TestDecryptToWriterParallel_FirstFailingChunk(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := make([]byte, 20*1024)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+
+	encryptedFile := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	// Corrupt chunks 5 and 9; chunk 5 must be reported.
+	recordSize := 4 + 1024 + TagSize
+	for _, idx := range []int{9, 5} {
+		encryptedFile[HeaderSize+idx*recordSize+10] ^= 0xFF
+	}
+
+	var output bytes.Buffer
+	n, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), &output, key, 8)
+	if err == nil {
+		t.Fatal("expected error for corrupted chunk, got nil")
+	}
+	if !strings.Contains(err.Error(), "chunk 5") {
+		t.Errorf("expected error at chunk 5, got: %v", err)
+	}
+
+	// Exactly the first five chunks must have been written.
+	if n != 5*1024 {
+		t.Errorf("bytes written = %d, want %d", n, 5*1024)
+	}
+	if !bytes.Equal(output.Bytes(), plaintext[:5*1024]) {
+		t.Error("written plaintext does not match the chunks before the failure")
+	}
+}
+
+func TestDecryptToWriterParallel_Truncated(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("T"), 4*1024)
+
+	encryptedFile := createTestEncryptedFile(t, key, plaintext, 1024)
+	truncated := encryptedFile[:len(encryptedFile)-10]
+
+	var output bytes.Buffer
+	n, err := DecryptToWriterParallel(bytes.NewReader(truncated), &output, key, 4)
+	if err == nil {
+		t.Fatal("expected error for truncated file, got nil")
+	}
+	if !strings.Contains(err.Error(), "chunk 3") {
+		t.Errorf("expected error at chunk 3, got: %v", err)
+	}
+	if n != 3*1024 {
+		t.Errorf("bytes written = %d, want %d", n, 3*1024)
+	}
+}
+
+// failingWriter fails after accepting a fixed number of writes.
+type failingWriter struct {
+	mu     sync.Mutex
+	writes int
+	limit  int
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.writes >= fw.limit {
+		return 0, errors.New("broken pipe")
+	}
+	fw.writes++
+	return len(p), nil
+}
+
+func TestDecryptToWriterParallel_WriteError(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("W"), 64*1024)
+
+	encryptedFile := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	fw := &failingWriter{limit: 3}
+	n, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), fw, key, 4)
+	if err == nil {
+		t.Fatal("expected write error, got nil")
+	}
+	if !strings.Contains(err.Error(), "failed to write plaintext at chunk 3") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n != 3*1024 {
+		t.Errorf("bytes written = %d, want %d", n, 3*1024)
+	}
+}
+
+func TestDecryptToFIFO_WithWorkers(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("TrustBridge-Parallel-"), 5000)
+
+	encryptedData := createTestEncryptedFile(t, key, plaintext, 4096)
+	encryptedPath := filepath.Join(tmpDir, "test.tbenc")
+	if err := os.WriteFile(encryptedPath, encryptedData, 0644); err != nil {
+		t.Fatalf("failed to write encrypted file: %v", err)
+	}
+
+	fifoPath := filepath.Join(tmpDir, "test-pipe")
+
+	resultCh := DecryptToFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithWorkers(4),
+	)
+
+	var decrypted bytes.Buffer
+	var readErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := waitForFIFO(fifoPath); err != nil {
+			readErr = err
+			return
+		}
+		fifo, err := os.Open(fifoPath)
+		if err != nil {
+			readErr = err
+			return
+		}
+		defer fifo.Close()
+		_, readErr = io.Copy(&decrypted, fifo)
+	}()
+
+	result := <-resultCh
+	wg.Wait()
+
+	if result.Err != nil {
+		t.Fatalf("decryption failed: %v", result.Err)
+	}
+	if readErr != nil {
+		t.Fatalf("read failed: %v", readErr)
+	}
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Error("decrypted data does not match plaintext")
+	}
+}
+
+// BenchmarkDecryptToWriterParallel benchmarks the parallel pipeline on 4MB chunks
+func BenchmarkDecryptToWriterParallel(b *testing.B) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("X"), 64*1024*1024) // 64MB
+
+	encryptedFile := createTestEncryptedFile(&testing.T{}, key, plaintext, 4*1024*1024)
+
+	b.ResetTimer()
+	b.SetBytes(int64(len(plaintext)))
+
+	for i := 0; i < b.N; i++ {
+		if _, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), io.Discard, key, 4); err != nil {
+			b.Fatalf("DecryptToWriterParallel failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides random-access decryption over tbenc files. Because every
+// record except the last holds exactly ChunkBytes of plaintext, any plaintext
+// offset maps to a computable ciphertext offset, so only the chunks covering a
+// requested range need to be read and authenticated. Records of compressed
+// streams vary in size, so their offsets are indexed once when the reader is
+// created.
+package crypto
+
+import (
+	"container/list"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// DefaultReaderCacheChunks is the default number of decrypted chunks kept in
+// memory by a DecryptingReader.
+const DefaultReaderCacheChunks = 4
+
+// ReaderAtOption configures a DecryptingReader.
+type ReaderAtOption func(*DecryptingReader)
+
+// WithCacheChunks sets how many decrypted chunks are cached.
+// Values below 1 are treated as 1.
+func WithCacheChunks(n int) ReaderAtOption {
+	return func(d *DecryptingReader) {
+		if n < 1 {
+			n = 1
+		}
+		d.cacheChunks = n
+	}
+}
+
+// WithCiphertextSize sets the total ciphertext size. This is required for
+// tbenc/v1 sources that do not expose their size through a Size() or Stat()
+// method, since the v1 header does not record the plaintext length.
+func WithCiphertextSize(size int64) ReaderAtOption {
+	return func(d *DecryptingReader) {
+		d.ctSize = size
+	}
+}
+
+// DecryptingReader provides random access to the plaintext of a tbenc file.
+//
+// It implements io.ReaderAt, io.Reader and io.Seeker. Each chunk touched by a
+// read is authenticated before any of its plaintext is returned. ReadAt is
+// safe for concurrent use; Read and Seek share a single offset and must not
+// be called concurrently.
+type DecryptingReader struct {
+	ra          io.ReaderAt
+	header      *Header
+	gcm         cipher.AEAD
+	size        int64  // total plaintext size
+	chunkCount  uint64 // number of records
+	ctSize      int64  // total ciphertext size (0 if unknown)
+	cacheChunks int
+	records     []recordInfo // record index of compressed streams
+
+	mu    sync.Mutex
+	cache map[uint64]*list.Element
+	lru   *list.List // front = most recently used
+
+	offset int64 // offset for Read/Seek
+}
+
+// recordInfo locates one record of a compressed stream.
+type recordInfo struct {
+	offset    int64 // file offset of the record's pt_len
+	storedLen uint32
+}
+
+// cachedChunk is a decrypted chunk held in the LRU cache.
+type cachedChunk struct {
+	index     uint64
+	plaintext []byte
+}
+
+// sizer is implemented by readers that know their total size (e.g. bytes.Reader,
+// io.SectionReader).
+type sizer interface {
+	Size() int64
+}
+
+// statter is implemented by readers backed by a file (e.g. os.File).
+type statter interface {
+	Stat() (os.FileInfo, error)
+}
+
+// NewDecryptingReaderAt creates a random-access plaintext reader over a tbenc file.
+//
+// The header is read and validated immediately. For tbenc/v1 files the plaintext
+// length is derived from the ciphertext size, which is taken from
+// WithCiphertextSize or discovered through a Size() or Stat() method on ra.
+func NewDecryptingReaderAt(ra io.ReaderAt, key []byte, opts ...ReaderAtOption) (*DecryptingReader, error) {
+	d := &DecryptingReader{
+		ra:          ra,
+		cacheChunks: DefaultReaderCacheChunks,
+		cache:       make(map[uint64]*list.Element),
+		lru:         list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	header, err := ParseHeader(io.NewSectionReader(ra, 0, HeaderSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse header: %w", err)
+	}
+	d.header = header
+
+	gcm, err := newAEAD(header.Algo, key)
+	if err != nil {
+		return nil, err
+	}
+	d.gcm = gcm
+
+	if d.ctSize == 0 {
+		d.ctSize = readerSize(ra)
+	}
+
+	if err := d.computeLayout(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// readerSize returns the size of ra if it can be discovered, or 0.
+func readerSize(ra io.ReaderAt) int64 {
+	switch r := ra.(type) {
+	case sizer:
+		return r.Size()
+	case statter:
+		if info, err := r.Stat(); err == nil {
+			return info.Size()
+		}
+	}
+	return 0
+}
+
+// computeLayout determines the plaintext size and record count.
+func (d *DecryptingReader) computeLayout() error {
+	h := d.header
+	if h.Compressed() {
+		return d.indexRecords()
+	}
+
+	recordSize := int64(RecordHeaderSize) + int64(h.ChunkBytes) + TagSize
+
+	if h.Version == VersionV2 {
+		d.size = int64(h.PlaintextBytes)
+		d.chunkCount = h.ChunkCount()
+
+		if d.ctSize > 0 {
+			expected := int64(HeaderSize) + int64(d.chunkCount)*(RecordHeaderSize+TagSize) + d.size
+			if d.ctSize < expected {
+				return fmt.Errorf("%w: ciphertext is %d bytes, expected %d", ErrTruncated, d.ctSize, expected)
+			}
+			if d.ctSize > expected {
+				return fmt.Errorf("%w: ciphertext is %d bytes, expected %d", ErrTrailingData, d.ctSize, expected)
+			}
+		}
+		return nil
+	}
+
+	if d.ctSize <= 0 {
+		return errors.New("cannot determine ciphertext size for tbenc/v1 source (use WithCiphertextSize)")
+	}
+
+	body := d.ctSize - HeaderSize
+	if body < 0 {
+		return fmt.Errorf("ciphertext too short: %d bytes", d.ctSize)
+	}
+
+	fullChunks := body / recordSize
+	remainder := body % recordSize
+	d.chunkCount = uint64(fullChunks)
+	d.size = fullChunks * int64(h.ChunkBytes)
+
+	if remainder > 0 {
+		lastPt := remainder - RecordHeaderSize - TagSize
+		if lastPt <= 0 {
+			return fmt.Errorf("invalid trailing record of %d bytes", remainder)
+		}
+		d.chunkCount++
+		d.size += lastPt
+	}
+
+	return nil
+}
+
+// indexRecords walks the framing of a compressed (always tbenc/v2) stream and
+// records where each record starts. Every record except the last must hold a
+// full chunk, so plaintext offsets still map directly to chunk indexes.
+func (d *DecryptingReader) indexRecords() error {
+	h := d.header
+
+	var lens [RecordHeaderSize + StoredLenSize]byte
+	off := int64(HeaderSize)
+	for chunkIndex := uint64(0); chunkIndex < h.ChunkCount(); chunkIndex++ {
+		if chunkIndex > 0 && d.size%int64(h.ChunkBytes) != 0 {
+			return fmt.Errorf("short chunk %d is not the last record", chunkIndex-1)
+		}
+
+		if err := readFullAt(d.ra, lens[:], off); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return fmt.Errorf("%w: failed to read record %d", ErrTruncated, chunkIndex)
+			}
+			return fmt.Errorf("failed to read record %d: %w", chunkIndex, err)
+		}
+		ptLen := binary.BigEndian.Uint32(lens[:RecordHeaderSize])
+		storedLen := binary.BigEndian.Uint32(lens[RecordHeaderSize:])
+		if err := h.validatePtLen(chunkIndex, ptLen); err != nil {
+			return err
+		}
+		if err := validateStoredLen(chunkIndex, ptLen, storedLen); err != nil {
+			return err
+		}
+
+		d.records = append(d.records, recordInfo{offset: off, storedLen: storedLen})
+		d.size += int64(ptLen)
+		off += h.RecordHeaderSize() + int64(storedLen) + TagSize
+
+		if d.ctSize > 0 && off > d.ctSize {
+			return fmt.Errorf("%w: record %d ends at %d, ciphertext is %d bytes", ErrTruncated, chunkIndex, off, d.ctSize)
+		}
+	}
+
+	d.chunkCount = uint64(len(d.records))
+	if d.ctSize > 0 && off < d.ctSize {
+		return fmt.Errorf("%w: records end at %d, ciphertext is %d bytes", ErrTrailingData, off, d.ctSize)
+	}
+	return nil
+}
+
+// Size returns the total plaintext size.
+func (d *DecryptingReader) Size() int64 {
+	return d.size
+}
+
+// Header returns the parsed file header.
+func (d *DecryptingReader) Header() *Header {
+	return d.header
+}
+
+// ReadAt implements io.ReaderAt, decrypting only the chunks that overlap
+// [off, off+len(p)).
+func (d *DecryptingReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("crypto: negative offset")
+	}
+	if off >= d.size {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+
+	chunkBytes := int64(d.header.ChunkBytes)
+	n := 0
+
+	for n < len(p) && off < d.size {
+		chunkIndex := uint64(off / chunkBytes)
+		inChunk := off - int64(chunkIndex)*chunkBytes
+
+		copied, err := d.readChunk(chunkIndex, p[n:], inChunk)
+		if err != nil {
+			return n, err
+		}
+		n += copied
+		off += int64(copied)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Read implements io.Reader.
+func (d *DecryptingReader) Read(p []byte) (int, error) {
+	n, err := d.ReadAt(p, d.offset)
+	d.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		// Report EOF on the next call, per io.Reader convention
+		err = nil
+	}
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (d *DecryptingReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = d.offset + offset
+	case io.SeekEnd:
+		abs = d.size + offset
+	default:
+		return 0, errors.New("crypto: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("crypto: negative position")
+	}
+	d.offset = abs
+	return abs, nil
+}
+
+// Close wipes all cached plaintext. The underlying io.ReaderAt is not closed.
+func (d *DecryptingReader) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for e := d.lru.Front(); e != nil; e = e.Next() {
+		SecureZeroBytes(e.Value.(*cachedChunk).plaintext)
+	}
+	d.lru.Init()
+	d.cache = make(map[uint64]*list.Element)
+	return nil
+}
+
+// readChunk copies plaintext from chunk chunkIndex, starting at inChunk, into p.
+// The copy happens under the cache lock so evicted chunks can be wiped safely.
+func (d *DecryptingReader) readChunk(chunkIndex uint64, p []byte, inChunk int64) (int, error) {
+	d.mu.Lock()
+	if e, ok := d.cache[chunkIndex]; ok {
+		d.lru.MoveToFront(e)
+		n := copy(p, e.Value.(*cachedChunk).plaintext[inChunk:])
+		d.mu.Unlock()
+		return n, nil
+	}
+	d.mu.Unlock()
+
+	// Decrypt outside the lock so concurrent ReadAt calls can proceed
+	plaintext, err := d.decryptChunk(chunkIndex)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Another reader may have decrypted the same chunk concurrently
+	if e, ok := d.cache[chunkIndex]; ok {
+		SecureZeroBytes(plaintext)
+		d.lru.MoveToFront(e)
+		return copy(p, e.Value.(*cachedChunk).plaintext[inChunk:]), nil
+	}
+
+	n := copy(p, plaintext[inChunk:])
+
+	d.cache[chunkIndex] = d.lru.PushFront(&cachedChunk{index: chunkIndex, plaintext: plaintext})
+	for d.lru.Len() > d.cacheChunks {
+		oldest := d.lru.Back()
+		c := oldest.Value.(*cachedChunk)
+		d.lru.Remove(oldest)
+		delete(d.cache, c.index)
+		SecureZeroBytes(c.plaintext)
+	}
+
+	return n, nil
+}
+
+// decryptChunk reads and authenticates a single record.
+func (d *DecryptingReader) decryptChunk(chunkIndex uint64) ([]byte, error) {
+	if chunkIndex >= d.chunkCount {
+		return nil, fmt.Errorf("chunk %d out of range (%d chunks)", chunkIndex, d.chunkCount)
+	}
+
+	h := d.header
+	chunkBytes := int64(h.ChunkBytes)
+	recordSize := int64(RecordHeaderSize) + chunkBytes + TagSize
+
+	expectedPt := chunkBytes
+	if remaining := d.size - int64(chunkIndex)*chunkBytes; remaining < expectedPt {
+		expectedPt = remaining
+	}
+
+	off := int64(HeaderSize) + int64(chunkIndex)*recordSize
+	storedLen := uint32(expectedPt)
+	if d.records != nil {
+		off = d.records[chunkIndex].offset
+		storedLen = d.records[chunkIndex].storedLen
+	}
+
+	record := make([]byte, h.RecordHeaderSize()+int64(storedLen)+TagSize)
+	if err := readFullAt(d.ra, record, off); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("%w: failed to read chunk %d", ErrTruncated, chunkIndex)
+		}
+		return nil, fmt.Errorf("failed to read chunk %d: %w", chunkIndex, err)
+	}
+
+	ptLen := binary.BigEndian.Uint32(record[:RecordHeaderSize])
+	if int64(ptLen) != expectedPt {
+		return nil, fmt.Errorf("invalid pt_len %d at chunk %d (expected %d)", ptLen, chunkIndex, expectedPt)
+	}
+	if h.Compressed() {
+		if got := binary.BigEndian.Uint32(record[RecordHeaderSize:]); got != storedLen {
+			return nil, fmt.Errorf("invalid stored_len %d at chunk %d (expected %d)", got, chunkIndex, storedLen)
+		}
+	}
+
+	ct := record[h.RecordHeaderSize():]
+	var out []byte
+	if storedLen != ptLen {
+		out = make([]byte, ptLen)
+	}
+	return decryptRecord(d.gcm, h, chunkIndex, ptLen, storedLen, ct, out)
+}
+
+// readFullAt fills p from ra at off, returning io.ErrUnexpectedEOF if the
+// input ends first. A reader may return io.EOF along with a full read that
+// ends exactly at the end of input; that read is complete.
+func readFullAt(ra io.ReaderAt, p []byte, off int64) error {
+	n, err := ra.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,344 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingReaderAt counts ReadAt calls on the underlying ciphertext.
+type countingReaderAt struct {
+	r     *bytes.Reader
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return c.r.ReadAt(p, off)
+}
+
+// eofReaderAt returns io.EOF with reads that end exactly at the end of the
+// input, as io.ReaderAt permits.
+type eofReaderAt struct {
+	data []byte
+}
+
+func (e *eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= int64(len(e.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, e.data[off:])
+	if off+int64(n) == int64(len(e.data)) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func testPlaintext(size int) []byte {
+	plaintext := make([]byte, size)
+	for i := range plaintext {
+		plaintext[i] = byte(i % 251)
+	}
+	return plaintext
+}
+
+func TestDecryptingReader_ReadAt(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(10*1024 + 300)
+
+	for _, version := range []uint16{Version, VersionV2} {
+		encrypted := createTestEncryptedFileVersion(t, key, plaintext, 1024, version)
+
+		r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key)
+		if err != nil {
+			t.Fatalf("v%d: NewDecryptingReaderAt failed: %v", version, err)
+		}
+
+		if r.Size() != int64(len(plaintext)) {
+			t.Fatalf("v%d: Size() = %d, want %d", version, r.Size(), len(plaintext))
+		}
+
+		tests := []struct {
+			name   string
+			off    int64
+			length int
+		}{
+			{"start", 0, 100},
+			{"within_chunk", 200, 500},
+			{"across_chunks", 1000, 100},
+			{"many_chunks", 500, 5000},
+			{"last_partial", 10*1024 + 100, 200},
+			{"whole_file", 0, len(plaintext)},
+		}
+
+		for _, tt := range tests {
+			buf := make([]byte, tt.length)
+			n, err := r.ReadAt(buf, tt.off)
+			if err != nil {
+				t.Fatalf("v%d %s: ReadAt failed: %v", version, tt.name, err)
+			}
+			if n != tt.length {
+				t.Errorf("v%d %s: n = %d, want %d", version, tt.name, n, tt.length)
+			}
+			if !bytes.Equal(buf, plaintext[tt.off:tt.off+int64(tt.length)]) {
+				t.Errorf("v%d %s: plaintext mismatch", version, tt.name)
+			}
+		}
+	}
+}
+
+func TestDecryptingReader_ReadAtEOF(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(2000)
+
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+	r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key)
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	buf := make([]byte, 100)
+	n, err := r.ReadAt(buf, 1950)
+	if err != io.EOF {
+		t.Errorf("expected io.EOF for read past end, got %v", err)
+	}
+	if n != 50 || !bytes.Equal(buf[:n], plaintext[1950:]) {
+		t.Errorf("short read mismatch: n=%d", n)
+	}
+
+	if _, err := r.ReadAt(buf, 5000); err != io.EOF {
+		t.Errorf("expected io.EOF for offset past end, got %v", err)
+	}
+}
+
+func TestDecryptingReader_EOFWithFullRead(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+
+	for _, version := range []uint16{Version, VersionV2} {
+		encrypted := createTestEncryptedFileVersion(t, key, plaintext, 1024, version)
+		r, err := NewDecryptingReaderAt(&eofReaderAt{data: encrypted}, key, WithCiphertextSize(int64(len(encrypted))))
+		if err != nil {
+			t.Fatalf("v%d: NewDecryptingReaderAt failed: %v", version, err)
+		}
+
+		got, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+		if err != nil {
+			t.Fatalf("v%d: reading the last chunk failed: %v", version, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("v%d: plaintext mismatch", version)
+		}
+	}
+}
+
+func TestDecryptingReader_ReadSeek(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(5000)
+
+	encrypted := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+	r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key)
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	all, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(all, plaintext) {
+		t.Error("sequential read mismatch")
+	}
+
+	pos, err := r.Seek(-100, io.SeekEnd)
+	if err != nil || pos != 4900 {
+		t.Fatalf("Seek(-100, End) = %d, %v", pos, err)
+	}
+	tail, _ := io.ReadAll(r)
+	if !bytes.Equal(tail, plaintext[4900:]) {
+		t.Error("tail read mismatch")
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Error("expected error for negative seek")
+	}
+}
+
+func TestDecryptingReader_OnlyTouchesNeededChunks(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(64 * 1024)
+
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+	src := &countingReaderAt{r: bytes.NewReader(encrypted)}
+
+	r, err := NewDecryptingReaderAt(src, key, WithCiphertextSize(int64(len(encrypted))))
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	headerCalls := src.calls
+
+	buf := make([]byte, 10)
+	for i := 0; i < 5; i++ {
+		if _, err := r.ReadAt(buf, 40*1024+int64(i)); err != nil {
+			t.Fatalf("ReadAt failed: %v", err)
+		}
+	}
+
+	if got := src.calls - headerCalls; got != 1 {
+		t.Errorf("expected 1 chunk read (cached afterwards), got %d", got)
+	}
+}
+
+func TestDecryptingReader_CacheEviction(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(8 * 1024)
+
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+	r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key, WithCacheChunks(2))
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	buf := make([]byte, 1)
+	for _, off := range []int64{0, 1024, 2048, 3072, 0} {
+		if _, err := r.ReadAt(buf, off); err != nil {
+			t.Fatalf("ReadAt(%d) failed: %v", off, err)
+		}
+		if buf[0] != plaintext[off] {
+			t.Errorf("ReadAt(%d) = %d, want %d", off, buf[0], plaintext[off])
+		}
+	}
+
+	if r.lru.Len() != 2 {
+		t.Errorf("cache size = %d, want 2", r.lru.Len())
+	}
+
+	r.Close()
+	if r.lru.Len() != 0 {
+		t.Errorf("cache not cleared on Close")
+	}
+}
+
+func TestDecryptingReader_ConcurrentReadAt(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(32 * 1024)
+
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+	r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key, WithCacheChunks(2))
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			buf := make([]byte, 777)
+			for i := 0; i < 50; i++ {
+				off := int64((g*4099 + i*1237) % (len(plaintext) - len(buf)))
+				if _, err := r.ReadAt(buf, off); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(buf, plaintext[off:off+int64(len(buf))]) {
+					errs <- errors.New("plaintext mismatch")
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestDecryptingReader_TamperedChunk(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(4096)
+
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+	encrypted[HeaderSize+2*(RecordHeaderSize+1024+TagSize)+50] ^= 0x01
+
+	r, err := NewDecryptingReaderAt(bytes.NewReader(encrypted), key)
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	buf := make([]byte, 100)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		t.Errorf("untouched chunk should read fine, got %v", err)
+	}
+	if _, err := r.ReadAt(buf, 2048); err == nil || !strings.Contains(err.Error(), "chunk 2") {
+		t.Errorf("expected authentication failure at chunk 2, got %v", err)
+	}
+}
+
+func TestDecryptingReader_V2SizeMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+
+	encrypted := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+
+	_, err := NewDecryptingReaderAt(bytes.NewReader(encrypted[:len(encrypted)-1]), key)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+
+	_, err = NewDecryptingReaderAt(bytes.NewReader(append(encrypted, 0x00)), key)
+	if !errors.Is(err, ErrTrailingData) {
+		t.Errorf("expected ErrTrailingData, got %v", err)
+	}
+}
+
+func TestDecryptingReader_V1UnknownSize(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	encrypted := createTestEncryptedFile(t, key, testPlaintext(100), 1024)
+
+	src := &countingReaderAt{r: bytes.NewReader(encrypted)}
+	if _, err := NewDecryptingReaderAt(src, key); err == nil {
+		t.Error("expected error for v1 source without a known size")
+	}
+}
+
+func TestDecryptingReader_File(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+
+	path := filepath.Join(t.TempDir(), "model.tbenc")
+	if err := os.WriteFile(path, createTestEncryptedFile(t, key, plaintext, 1024), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer f.Close()
+
+	r, err := NewDecryptingReaderAt(f, key)
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+
+	buf := make([]byte, 10)
+	if _, err := r.ReadAt(buf, 2990); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if !bytes.Equal(buf, plaintext[2990:]) {
+		t.Error("plaintext mismatch")
+	}
+}
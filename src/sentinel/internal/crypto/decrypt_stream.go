@@ -10,7 +10,9 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"sync/atomic"
+	"time"
 )
 
 // StreamResult contains the result of a streaming decryption operation.
@@ -27,6 +29,14 @@ type streamConfig struct {
 	progressCallback func(bytesWritten, totalBytes int64)
 	logger           *slog.Logger
 	totalBytes       int64 // Expected total plaintext bytes (for progress %)
+	workers          int   // Number of decryption workers (<= 1 means sequential)
+	maxSessions      int   // ServeFIFO deliveries (0 means unlimited)
+	tracker          *SessionTracker
+	onOpen           func()        // Called once a reader has opened the FIFO
+	key              KeyRef        // Key at hand, checked against the header
+	plaintextSHA256  string        // Expected plaintext hash, lowercase hex ("" skips the check)
+	openTimeout      time.Duration // How long to wait for a FIFO reader (0 = until cancelled)
+	stallTimeout     time.Duration // Longest FIFO write without reader progress (0 = no limit)
 }
 
 // WithProgressCallback sets a callback function that is called periodically
@@ -52,13 +62,29 @@ func WithTotalBytes(totalBytes int64) StreamOption {
 	}
 }
 
+// WithWorkers sets the number of parallel decryption workers.
+// Values of 1 or less select the sequential decrypter.
+func WithWorkers(n int) StreamOption {
+	return func(c *streamConfig) {
+		c.workers = n
+	}
+}
+
+// WithExpectedKey checks the header's key reference against key before
+// anything is decrypted, failing with ErrWrongKeyVersion on a mismatch.
+func WithExpectedKey(key KeyRef) StreamOption {
+	return func(c *streamConfig) {
+		c.key = key
+	}
+}
+
 // DecryptToFIFO decrypts an encrypted file to a FIFO asynchronously.
 //
 // This function:
 // 1. Creates the FIFO at fifoPath (if it doesn't exist)
 // 2. Opens the encrypted file
 // 3. Starts a goroutine that:
-//   - Opens the FIFO for writing (blocks until a reader opens it)
+//   - Opens the FIFO for writing once a reader has opened it
 //   - Decrypts the file chunk by chunk
 //   - Writes plaintext to the FIFO
 //
@@ -67,14 +93,17 @@ func WithTotalBytes(totalBytes int64) StreamOption {
 // The returned channel receives exactly one StreamResult when decryption
 // completes (either successfully or with an error).
 //
-// The context can be used to cancel the operation. If cancelled, the
-// result will contain a context.Canceled or context.DeadlineExceeded error.
+// The context can be used to cancel the operation, including a write blocked
+// on a reader that stopped reading. If cancelled, the result will contain a
+// context.Canceled or context.DeadlineExceeded error. WithOpenTimeout and
+// WithStallTimeout bound the wait for a reader and for reader progress.
 func DecryptToFIFO(ctx context.Context, encryptedPath, fifoPath string, key []byte, opts ...StreamOption) <-chan StreamResult {
 	result := make(chan StreamResult, 1)
 
 	// Apply options
 	cfg := &streamConfig{
-		logger: slog.Default(),
+		logger:  slog.Default(),
+		workers: 1,
 	}
 	for _, opt := range opts {
 		opt(cfg)
@@ -93,6 +122,37 @@ func DecryptToFIFO(ctx context.Context, encryptedPath, fifoPath string, key []by
 	return result
 }
 
+// DecryptStreamToFIFO decrypts a tbenc stream to a FIFO asynchronously.
+//
+// It behaves like DecryptToFIFO but reads ciphertext from r instead of a file,
+// so ciphertext can be decrypted while it is still being downloaded. Each chunk
+// is authenticated before its plaintext is written; verifying a whole-file hash
+// of the ciphertext is the caller's responsibility.
+func DecryptStreamToFIFO(ctx context.Context, r io.Reader, fifoPath string, key []byte, opts ...StreamOption) <-chan StreamResult {
+	result := make(chan StreamResult, 1)
+
+	// Apply options
+	cfg := &streamConfig{
+		logger:  slog.Default(),
+		workers: 1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	go func() {
+		defer close(result)
+
+		bytesWritten, err := decryptStreamToFIFOInternal(ctx, r, fifoPath, key, cfg)
+		result <- StreamResult{
+			BytesWritten: bytesWritten,
+			Err:          err,
+		}
+	}()
+
+	return result
+}
+
 // DecryptToFIFOBlocking is a blocking variant of DecryptToFIFO.
 // It blocks until decryption is complete and returns the result directly.
 func DecryptToFIFOBlocking(ctx context.Context, encryptedPath, fifoPath string, key []byte, opts ...StreamOption) (int64, error) {
@@ -111,6 +171,79 @@ func DecryptToFIFOBlocking(ctx context.Context, encryptedPath, fifoPath string,
 	}
 }
 
+// DecryptToFile decrypts a tbenc stream into a regular file at outputPath.
+//
+// Unlike a FIFO, the file can be opened and read any number of times, which
+// suits small files (configs, tokenizers) that runtimes read more than once.
+// It is intended for tmpfs paths so plaintext never reaches persistent disk.
+// The file is written atomically with FIFOMode permissions; nothing is left
+// at outputPath on failure.
+func DecryptToFile(ctx context.Context, r io.Reader, outputPath string, key []byte, opts ...StreamOption) (int64, error) {
+	cfg := &streamConfig{
+		logger:  slog.Default(),
+		workers: 1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if outputPath == "" {
+		return 0, fmt.Errorf("output path cannot be empty")
+	}
+	if len(key) != 32 {
+		return 0, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+
+	r, err := checkStreamKey(r, cfg.key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := EnsureParentDir(outputPath); err != nil {
+		return 0, fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(outputPath), ".decrypt-*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath) // No-op after a successful rename
+
+	if err := tmpFile.Chmod(FIFOMode); err != nil {
+		tmpFile.Close()
+		return 0, fmt.Errorf("failed to set file permissions: %w", err)
+	}
+
+	ctxReader := &contextReader{
+		ctx: ctx,
+		r:   r,
+	}
+
+	out, checkPlaintext := cfg.hashPlaintext(tmpFile)
+	bytesWritten, err := DecryptToWriterParallel(ctxReader, out, key, cfg.workers)
+	if closeErr := tmpFile.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close temp file: %w", closeErr)
+	}
+	if err != nil {
+		return bytesWritten, fmt.Errorf("decryption failed: %w", err)
+	}
+	if err := checkPlaintext(); err != nil {
+		return bytesWritten, err
+	}
+
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return bytesWritten, fmt.Errorf("failed to rename decrypted file: %w", err)
+	}
+
+	cfg.logger.Info("decrypted to file",
+		"path", outputPath,
+		"bytes_written", bytesWritten,
+	)
+
+	return bytesWritten, nil
+}
+
 // decryptToFIFOInternal contains the actual decryption logic.
 func decryptToFIFOInternal(ctx context.Context, encryptedPath, fifoPath string, key []byte, cfg *streamConfig) (int64, error) {
 	// Validate inputs
@@ -132,6 +265,7 @@ func decryptToFIFOInternal(ctx context.Context, encryptedPath, fifoPath string,
 	cfg.logger.Info("starting decryption to FIFO",
 		"encrypted_path", encryptedPath,
 		"fifo_path", fifoPath,
+		"workers", cfg.workers,
 	)
 
 	// Create FIFO
@@ -146,48 +280,79 @@ func decryptToFIFOInternal(ctx context.Context, encryptedPath, fifoPath string,
 	}
 	defer encFile.Close()
 
-	// Check for cancellation before blocking on FIFO open
+	return writeFIFO(ctx, encFile, fifoPath, key, cfg)
+}
+
+// decryptStreamToFIFOInternal contains the decryption logic for streamed ciphertext.
+func decryptStreamToFIFOInternal(ctx context.Context, r io.Reader, fifoPath string, key []byte, cfg *streamConfig) (int64, error) {
+	// Validate inputs
+	if r == nil {
+		return 0, fmt.Errorf("encrypted stream cannot be nil")
+	}
+	if fifoPath == "" {
+		return 0, fmt.Errorf("FIFO path cannot be empty")
+	}
+	if len(key) != 32 {
+		return 0, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+
+	// Check for cancellation
 	if err := ctx.Err(); err != nil {
 		return 0, err
 	}
 
-	cfg.logger.Info("opening FIFO for writing (will block until reader connects)")
+	cfg.logger.Info("starting streaming decryption to FIFO",
+		"fifo_path", fifoPath,
+		"workers", cfg.workers,
+	)
 
-	// Open FIFO for writing - this blocks until a reader opens it
-	// We use a goroutine to allow cancellation
-	type fifoResult struct {
-		file *os.File
-		err  error
+	// Create FIFO
+	if err := CreateFIFO(fifoPath); err != nil {
+		return 0, fmt.Errorf("failed to create FIFO: %w", err)
 	}
-	fifoOpenCh := make(chan fifoResult, 1)
 
-	go func() {
-		f, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
-		fifoOpenCh <- fifoResult{file: f, err: err}
-	}()
+	return writeFIFO(ctx, r, fifoPath, key, cfg)
+}
 
-	var fifoFile *os.File
-	select {
-	case <-ctx.Done():
-		// Context cancelled while waiting for reader
-		// Note: The goroutine will still be blocked on OpenFile until
-		// a reader connects or the FIFO is removed
-		return 0, ctx.Err()
-	case res := <-fifoOpenCh:
-		if res.err != nil {
-			return 0, fmt.Errorf("failed to open FIFO for writing: %w", res.err)
-		}
-		fifoFile = res.file
+// writeFIFO opens the FIFO for writing and decrypts r into it.
+func writeFIFO(ctx context.Context, r io.Reader, fifoPath string, key []byte, cfg *streamConfig) (int64, error) {
+	// Check for cancellation before waiting for a reader
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// A wrong key fails before the runtime is handed a FIFO
+	r, err := checkStreamKey(r, cfg.key)
+	if err != nil {
+		return 0, err
+	}
+
+	cfg.logger.Info("waiting for FIFO reader")
+
+	// Poll for a reader instead of blocking in open(2), so cancellation and
+	// the open timeout take effect at once and no goroutine is left behind
+	fifoFile, err := openFIFOWriter(ctx, fifoPath, cfg.openTimeout)
+	if err != nil {
+		return 0, err
 	}
 	defer fifoFile.Close()
 
+	fifoOut := newFIFOWriter(ctx, fifoFile, cfg.stallTimeout)
+	defer fifoOut.Close()
+
 	cfg.logger.Info("FIFO opened, starting decryption")
+	if cfg.onOpen != nil {
+		cfg.onOpen()
+	}
+
+	// Hash the plaintext as it streams out, if the manifest records it
+	out, checkPlaintext := cfg.hashPlaintext(fifoOut)
 
 	// Create progress tracking writer
-	var progressWriter io.Writer = fifoFile
+	progressWriter := out
 	if cfg.progressCallback != nil || cfg.totalBytes > 0 {
 		progressWriter = &progressTrackingWriter{
-			w:                fifoFile,
+			w:                out,
 			totalBytes:       cfg.totalBytes,
 			progressCallback: cfg.progressCallback,
 			logger:           cfg.logger,
@@ -198,14 +363,17 @@ func decryptToFIFOInternal(ctx context.Context, encryptedPath, fifoPath string,
 	// Create a context-aware reader that checks for cancellation
 	ctxReader := &contextReader{
 		ctx: ctx,
-		r:   encFile,
+		r:   r,
 	}
 
 	// Decrypt to the progress writer
-	bytesWritten, err := DecryptToWriter(ctxReader, progressWriter, key)
+	bytesWritten, err := DecryptToWriterParallel(ctxReader, progressWriter, key, cfg.workers)
 	if err != nil {
 		return bytesWritten, fmt.Errorf("decryption failed: %w", err)
 	}
+	if err := checkPlaintext(); err != nil {
+		return bytesWritten, err
+	}
 
 	cfg.logger.Info("decryption completed",
 		"bytes_written", bytesWritten,
@@ -515,3 +515,134 @@ func TestDecryptToFIFO_NonexistentEncryptedFile(t *testing.T) {
 		t.Error("expected error for nonexistent encrypted file")
 	}
 }
+
+func TestDecryptStreamToFIFO_Success(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "decrypt-stream-test-*")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("TrustBridge-Stream-Test-"), 1000)
+	encryptedData := createTestEncryptedFile(t, key, plaintext, 4096)
+
+	fifoPath := filepath.Join(tmpDir, "test-pipe")
+
+	// Feed the ciphertext through a pipe to mimic an in-flight download
+	pr, pw := io.Pipe()
+	go func() {
+		for off := 0; off < len(encryptedData); off += 1000 {
+			end := min(off+1000, len(encryptedData))
+			if _, err := pw.Write(encryptedData[off:end]); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+
+	resultCh := DecryptStreamToFIFO(context.Background(), pr, fifoPath, key,
+		WithWorkers(2),
+	)
+
+	var decrypted bytes.Buffer
+	var readErr error
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if err := waitForFIFO(fifoPath); err != nil {
+			readErr = err
+			return
+		}
+
+		fifo, err := os.Open(fifoPath)
+		if err != nil {
+			readErr = err
+			return
+		}
+		defer fifo.Close()
+
+		_, readErr = io.Copy(&decrypted, fifo)
+	}()
+
+	result := <-resultCh
+	wg.Wait()
+
+	if result.Err != nil {
+		t.Fatalf("DecryptStreamToFIFO failed: %v", result.Err)
+	}
+	if readErr != nil {
+		t.Fatalf("reading from FIFO failed: %v", readErr)
+	}
+	if result.BytesWritten != int64(len(plaintext)) {
+		t.Errorf("bytes written = %d, want %d", result.BytesWritten, len(plaintext))
+	}
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Errorf("plaintext mismatch: got %d bytes, want %d bytes", decrypted.Len(), len(plaintext))
+	}
+}
+
+func TestDecryptStreamToFIFO_NilReader(t *testing.T) {
+	tmpDir := t.TempDir()
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	result := <-DecryptStreamToFIFO(context.Background(), nil, filepath.Join(tmpDir, "pipe"), key)
+	if result.Err == nil {
+		t.Error("expected error for nil reader")
+	}
+}
+
+func TestDecryptToFile_Success(t *testing.T) {
+	tmpDir := t.TempDir()
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("{\"model_type\": \"llama\"}"), 100)
+	encryptedData := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	outputPath := filepath.Join(tmpDir, "nested", "config.json")
+	n, err := DecryptToFile(context.Background(), bytes.NewReader(encryptedData), outputPath, key)
+	if err != nil {
+		t.Fatalf("DecryptToFile failed: %v", err)
+	}
+	if n != int64(len(plaintext)) {
+		t.Errorf("bytes written = %d, want %d", n, len(plaintext))
+	}
+
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("failed to read output: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("plaintext mismatch")
+	}
+
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if info.Mode().Perm() != FIFOMode {
+		t.Errorf("file mode = %o, want %o", info.Mode().Perm(), FIFOMode)
+	}
+}
+
+func TestDecryptToFile_WrongKeyLeavesNothing(t *testing.T) {
+	tmpDir := t.TempDir()
+	key := bytes.Repeat([]byte{0x42}, 32)
+	wrongKey := bytes.Repeat([]byte{0x43}, 32)
+	encryptedData := createTestEncryptedFile(t, key, []byte("tokenizer data"), 1024)
+
+	outputPath := filepath.Join(tmpDir, "tokenizer.json")
+	if _, err := DecryptToFile(context.Background(), bytes.NewReader(encryptedData), outputPath, wrongKey); err == nil {
+		t.Fatal("expected error with wrong key")
+	}
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected empty directory after failure, found %d entries", len(entries))
+	}
+}
@@ -7,15 +7,23 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
 // Helper function to create a minimal valid tbenc/v1 encrypted test file
 func createTestEncryptedFile(t *testing.T, key []byte, plaintext []byte, chunkBytes uint32) []byte {
 	t.Helper()
+	return createTestEncryptedFileVersion(t, key, plaintext, chunkBytes, Version)
+}
+
+// Helper function to create a valid tbenc/v1 or tbenc/v2 encrypted test file
+func createTestEncryptedFileVersion(t *testing.T, key []byte, plaintext []byte, chunkBytes uint32, version uint16) []byte {
+	t.Helper()
 
 	if len(key) != 32 {
 		t.Fatalf("key must be 32 bytes, got %d", len(key))
@@ -42,7 +50,7 @@ func createTestEncryptedFile(t *testing.T, key []byte, plaintext []byte, chunkBy
 	buf.WriteString(Magic)
 
 	// Version (uint16, big-endian)
-	binary.Write(&buf, binary.BigEndian, Version)
+	binary.Write(&buf, binary.BigEndian, version)
 
 	// Algo (uint8)
 	buf.WriteByte(AlgoAESGCMChunked)
@@ -53,12 +61,25 @@ func createTestEncryptedFile(t *testing.T, key []byte, plaintext []byte, chunkBy
 	// Nonce prefix (4 bytes)
 	buf.Write(noncePrefix[:])
 
-	// Reserved (13 bytes of zeros)
-	buf.Write(make([]byte, 13))
+	// Reserved (13 bytes; v2 stores plaintext length in the first 8)
+	reserved := make([]byte, 13)
+	if version == VersionV2 {
+		binary.BigEndian.PutUint64(reserved[0:8], uint64(len(plaintext)))
+	}
+	buf.Write(reserved)
 
-	// Encrypt plaintext in chunks
+	header := &Header{
+		Version:        version,
+		Algo:           AlgoAESGCMChunked,
+		ChunkBytes:     chunkBytes,
+		NoncePrefix:    noncePrefix,
+		PlaintextBytes: uint64(len(plaintext)),
+	}
+	copy(header.Magic[:], Magic)
+
+	// Encrypt plaintext in chunks (v2 encodes empty plaintext as one empty record)
 	chunkIndex := uint64(0)
-	for offset := 0; offset < len(plaintext); offset += int(chunkBytes) {
+	for offset := 0; offset < len(plaintext) || (version == VersionV2 && chunkIndex == 0); offset += int(chunkBytes) {
 		end := offset + int(chunkBytes)
 		if end > len(plaintext) {
 			end = len(plaintext)
@@ -73,15 +94,7 @@ func createTestEncryptedFile(t *testing.T, key []byte, plaintext []byte, chunkBy
 		binary.BigEndian.PutUint64(nonce[4:12], chunkIndex)
 
 		// Build AAD
-		header := &Header{
-			Version:     Version,
-			Algo:        AlgoAESGCMChunked,
-			ChunkBytes:  chunkBytes,
-			NoncePrefix: noncePrefix,
-		}
-		copy(header.Magic[:], Magic)
-
-		aad := buildAAD(header, chunkIndex, ptLen)
+		aad := buildAAD(header, chunkIndex, ptLen, ptLen)
 
 		// Encrypt
 		ciphertextWithTag := gcm.Seal(nil, nonce, chunk, aad)
@@ -276,6 +289,148 @@ func TestDecryptToWriter_WrongKey(t *testing.T) {
 	}
 }
 
+func TestParseHeader_V2(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("V2"), 1500) // 3000 bytes
+
+	encryptedFile := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+
+	header, err := ParseHeader(bytes.NewReader(encryptedFile))
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+
+	if header.Version != VersionV2 {
+		t.Errorf("version mismatch: got %d, want %d", header.Version, VersionV2)
+	}
+	if header.PlaintextBytes != uint64(len(plaintext)) {
+		t.Errorf("plaintext_bytes mismatch: got %d, want %d", header.PlaintextBytes, len(plaintext))
+	}
+	if header.ChunkCount() != 3 {
+		t.Errorf("chunk count mismatch: got %d, want 3", header.ChunkCount())
+	}
+}
+
+func TestDecryptToWriter_V2(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+	}{
+		{"empty", []byte{}},
+		{"single_chunk", []byte("TrustBridge v2")},
+		{"exact_chunks", bytes.Repeat([]byte("A"), 2048)},
+		{"partial_last_chunk", bytes.Repeat([]byte("B"), 2500)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encryptedFile := createTestEncryptedFileVersion(t, key, tt.plaintext, 1024, VersionV2)
+
+			for _, workers := range []int{1, 4} {
+				var output bytes.Buffer
+				n, err := DecryptToWriterParallel(bytes.NewReader(encryptedFile), &output, key, workers)
+				if err != nil {
+					t.Fatalf("workers=%d: decrypt failed: %v", workers, err)
+				}
+				if n != int64(len(tt.plaintext)) {
+					t.Errorf("workers=%d: bytes written mismatch: got %d, want %d", workers, n, len(tt.plaintext))
+				}
+				if !bytes.Equal(output.Bytes(), tt.plaintext) {
+					t.Errorf("workers=%d: plaintext mismatch", workers)
+				}
+			}
+		})
+	}
+}
+
+func TestDecryptToWriter_V2_TruncatedAtRecordBoundary(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("C"), 3000)
+
+	recordSize := RecordHeaderSize + 1024 + TagSize
+
+	// v1: dropping the final record decrypts "successfully" to a shorter plaintext
+	v1 := createTestEncryptedFile(t, key, plaintext, 1024)
+	v1Truncated := v1[:HeaderSize+2*recordSize]
+	n, err := DecryptToWriter(bytes.NewReader(v1Truncated), io.Discard, key)
+	if err != nil || n != 2048 {
+		t.Fatalf("v1 truncation baseline: n=%d err=%v", n, err)
+	}
+
+	// v2: the same truncation is detected
+	v2 := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+	v2Truncated := v2[:HeaderSize+2*recordSize]
+
+	for _, workers := range []int{1, 4} {
+		_, err := DecryptToWriterParallel(bytes.NewReader(v2Truncated), io.Discard, key, workers)
+		if !errors.Is(err, ErrTruncated) {
+			t.Errorf("workers=%d: expected ErrTruncated, got %v", workers, err)
+		}
+	}
+}
+
+func TestDecryptToWriter_V2_Extended(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("D"), 2048)
+
+	v2 := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+
+	// Append a copy of the first record after the final chunk
+	recordSize := RecordHeaderSize + 1024 + TagSize
+	extended := append(append([]byte{}, v2...), v2[HeaderSize:HeaderSize+recordSize]...)
+
+	for _, workers := range []int{1, 4} {
+		var output bytes.Buffer
+		_, err := DecryptToWriterParallel(bytes.NewReader(extended), &output, key, workers)
+		if !errors.Is(err, ErrTrailingData) {
+			t.Errorf("workers=%d: expected ErrTrailingData, got %v", workers, err)
+		}
+	}
+}
+
+func TestDecryptToWriter_V2_TamperedLength(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := bytes.Repeat([]byte("E"), 3000)
+
+	v2 := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+
+	// Rewrite the header to claim only two chunks and drop the last record.
+	// The length is bound into every chunk's AAD, so chunk 0 must fail.
+	recordSize := RecordHeaderSize + 1024 + TagSize
+	tampered := append([]byte{}, v2[:HeaderSize+2*recordSize]...)
+	binary.BigEndian.PutUint64(tampered[19:27], 2048)
+
+	_, err := DecryptToWriter(bytes.NewReader(tampered), io.Discard, key)
+	if err == nil {
+		t.Fatal("expected authentication failure for tampered length, got nil")
+	}
+	if !strings.Contains(err.Error(), "decryption failed at chunk 0") {
+		t.Errorf("expected failure at chunk 0, got: %v", err)
+	}
+}
+
+func TestDecryptChunk_V1V2DomainSeparation(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := []byte("same plaintext")
+
+	v1 := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	// Reinterpret the v1 record under a v2 header with a matching length
+	header, err := ParseHeader(bytes.NewReader(v1))
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	header.Version = VersionV2
+	header.PlaintextBytes = uint64(len(plaintext))
+
+	record := v1[HeaderSize+RecordHeaderSize:]
+	if _, err := DecryptChunk(key, header, 0, uint32(len(plaintext)), record); err == nil {
+		t.Fatal("expected v1 record to fail under v2 AAD, got nil")
+	}
+}
+
 func TestSecureZeroBytes(t *testing.T) {
 	data := []byte("sensitive data")
 	SecureZeroBytes(data)
@@ -331,6 +486,30 @@ func TestPythonInterop(t *testing.T) {
 	t.Logf("  SHA256: %s", hashHex)
 }
 
+// TestDecrypt_V1ZeroPtLenVector checks that an authentic tbenc/v1 record
+// with pt_len 0 is rejected. The Python reader rejects the same vector
+// (test_crypto_tbenc.py::test_decrypt_v1_zero_pt_len_vector).
+func TestDecrypt_V1ZeroPtLenVector(t *testing.T) {
+	vector, _ := hex.DecodeString("5442454e43303031000101000004000102030400000000000000000000000000" +
+		"00000000c3d875132e772cfe85c231e9099b48b4")
+	key, _ := hex.DecodeString("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+	// The tag is valid, so only the pt_len check can reject the record
+	header, err := ParseHeader(bytes.NewReader(vector))
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	gcm, _ := newAEAD(header.Algo, key)
+	if _, err := gcm.Open(nil, deriveNonce(header.NoncePrefix, 0), vector[HeaderSize+4:], buildAAD(header, 0, 0, 0)); err != nil {
+		t.Fatalf("vector record does not authenticate: %v", err)
+	}
+
+	_, err = DecryptToBytes(bytes.NewReader(vector), key)
+	if err == nil || !strings.Contains(err.Error(), "invalid pt_len 0") {
+		t.Errorf("DecryptToBytes error = %v, want invalid pt_len 0", err)
+	}
+}
+
 // TestDecryptToBytes tests the convenience function
 func TestDecryptToBytes(t *testing.T) {
 	key := bytes.Repeat([]byte{0x55}, 32)
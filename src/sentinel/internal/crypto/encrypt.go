@@ -0,0 +1,301 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides tbenc encryption. The framing (header, nonce prefix,
+// AAD layout) is byte-identical to the Python CLI's crypto_tbenc module, so
+// assets produced by either tool decrypt with the other.
+package crypto
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+const (
+	// MinChunkBytes is the smallest chunk size accepted by the encryptor.
+	MinChunkBytes = 1024
+
+	// MaxChunkBytes is the largest chunk size accepted by ParseHeader and the encryptor.
+	MaxChunkBytes = 64 * 1024 * 1024
+
+	// DefaultChunkBytes matches the Python CLI default (4MB).
+	DefaultChunkBytes = 4 * 1024 * 1024
+)
+
+// EncryptResult contains the result of an encryption operation.
+type EncryptResult struct {
+	Header           *Header
+	PlaintextBytes   int64
+	CiphertextBytes  int64
+	SHA256Ciphertext string // lowercase hex
+	SHA256Plaintext  string // lowercase hex; set by EncryptToWriter only
+}
+
+// EncryptOption configures EncryptToWriter.
+type EncryptOption func(*encryptConfig)
+
+// encryptConfig holds the configuration for encryption.
+type encryptConfig struct {
+	version        uint16
+	algo           uint8
+	noncePrefix    *[4]byte
+	plaintextBytes int64 // required for tbenc/v2
+	flags          uint8
+	key            KeyRef
+}
+
+// WithFormatVersion selects the output format (Version or VersionV2).
+// tbenc/v2 also requires WithPlaintextSize.
+func WithFormatVersion(version uint16) EncryptOption {
+	return func(c *encryptConfig) {
+		c.version = version
+	}
+}
+
+// WithAlgorithm selects the chunk algorithm (AlgoAESGCMChunked by default).
+// The id must be registered with RegisterAlgorithm.
+func WithAlgorithm(algo uint8) EncryptOption {
+	return func(c *encryptConfig) {
+		c.algo = algo
+	}
+}
+
+// WithZstd compresses each chunk with zstd before encryption and sets
+// FlagZstd in the header. Chunks that do not shrink are stored raw. The
+// ciphertext size then depends on the data, so manifests must record it.
+// Only tbenc/v2 headers carry flags, so it requires WithFormatVersion(VersionV2).
+func WithZstd() EncryptOption {
+	return func(c *encryptConfig) {
+		c.flags |= FlagZstd
+	}
+}
+
+// WithKeyRef records the provider key reference in the header, so consumers
+// holding another version of the key fail before decrypting. Only tbenc/v2
+// headers carry it, so a non-zero reference requires
+// WithFormatVersion(VersionV2).
+func WithKeyRef(key KeyRef) EncryptOption {
+	return func(c *encryptConfig) {
+		c.key = key
+	}
+}
+
+// WithPlaintextSize declares the total plaintext length. It is required for
+// tbenc/v2, where the length is stored in the header before any chunk is
+// written; encryption fails if the input does not match.
+func WithPlaintextSize(n int64) EncryptOption {
+	return func(c *encryptConfig) {
+		c.plaintextBytes = n
+	}
+}
+
+// WithNoncePrefix sets a fixed nonce prefix instead of a random one.
+// This is intended for deterministic test vectors only: reusing a prefix
+// with the same key breaks GCM security.
+func WithNoncePrefix(prefix [4]byte) EncryptOption {
+	return func(c *encryptConfig) {
+		c.noncePrefix = &prefix
+	}
+}
+
+// MarshalBinary encodes the header into its 32-byte wire format.
+// For tbenc/v2 headers, PlaintextBytes, Flags and the key reference are
+// written into the reserved bytes; tbenc/v1 headers keep Reserved as is.
+func (h *Header) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, HeaderSize)
+	buf = append(buf, h.Magic[:]...)
+	buf = binary.BigEndian.AppendUint16(buf, h.Version)
+	buf = append(buf, h.Algo)
+	buf = binary.BigEndian.AppendUint32(buf, h.ChunkBytes)
+	buf = append(buf, h.NoncePrefix[:]...)
+
+	reserved := h.Reserved
+	if h.Version == VersionV2 {
+		binary.BigEndian.PutUint64(reserved[0:8], h.PlaintextBytes)
+		reserved[flagsOffset] = h.Flags
+		binary.BigEndian.PutUint16(reserved[keyIDOffset:], h.KeyID)
+		binary.BigEndian.PutUint16(reserved[keyVersionOffset:], h.KeyVersion)
+	}
+	buf = append(buf, reserved[:]...)
+
+	return buf, nil
+}
+
+// NewHeader builds a header for a new tbenc file with a random nonce prefix.
+func NewHeader(version uint16, chunkBytes uint32, plaintextBytes int64) (*Header, error) {
+	if version != Version && version != VersionV2 {
+		return nil, fmt.Errorf("unsupported version: %d", version)
+	}
+	if chunkBytes < MinChunkBytes || chunkBytes > MaxChunkBytes {
+		return nil, fmt.Errorf("chunk_bytes must be between %d and %d, got %d", MinChunkBytes, MaxChunkBytes, chunkBytes)
+	}
+	if version == VersionV2 && plaintextBytes < 0 {
+		return nil, fmt.Errorf("tbenc/v2 requires the plaintext size")
+	}
+
+	h := &Header{
+		Version:    version,
+		Algo:       AlgoAESGCMChunked,
+		ChunkBytes: chunkBytes,
+	}
+	copy(h.Magic[:], Magic)
+
+	if version == VersionV2 {
+		h.PlaintextBytes = uint64(plaintextBytes)
+	}
+
+	if _, err := io.ReadFull(rand.Reader, h.NoncePrefix[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+
+	return h, nil
+}
+
+// EncryptChunk encrypts a single chunk using the header's algorithm.
+//
+// Returns the ciphertext with the 16-byte tag appended. It is the
+// counterpart of DecryptChunk; in a compressed stream the chunk is stored raw.
+func EncryptChunk(key []byte, header *Header, chunkIndex uint64, plaintext []byte) ([]byte, error) {
+	gcm, err := newAEAD(header.Algo, key)
+	if err != nil {
+		return nil, err
+	}
+	return sealChunk(gcm, nil, header, chunkIndex, plaintext), nil
+}
+
+// sealChunk encrypts a single chunk stored raw with an existing AEAD,
+// appending to dst.
+func sealChunk(gcm cipher.AEAD, dst []byte, header *Header, chunkIndex uint64, plaintext []byte) []byte {
+	return sealRecord(gcm, dst, header, chunkIndex, uint32(len(plaintext)), plaintext)
+}
+
+// sealRecord encrypts the stored bytes of a record for a chunk of ptLen
+// plaintext bytes, appending to dst.
+func sealRecord(gcm cipher.AEAD, dst []byte, header *Header, chunkIndex uint64, ptLen uint32, stored []byte) []byte {
+	nonce := deriveNonce(header.NoncePrefix, chunkIndex)
+	aad := buildAAD(header, chunkIndex, ptLen, uint32(len(stored)))
+	return gcm.Seal(dst, nonce, stored, aad)
+}
+
+// EncryptToWriter encrypts plaintext from r into tbenc format on w.
+//
+// Args:
+//   - r: reader for plaintext input
+//   - w: writer for the encrypted output
+//   - key: 32-byte AES-256 key
+//   - chunkBytes: plaintext bytes per chunk (1KB to 64MB)
+//
+// Returns the header, sizes and the ciphertext and plaintext SHA256 needed for
+// the manifest.
+func EncryptToWriter(r io.Reader, w io.Writer, key []byte, chunkBytes uint32, opts ...EncryptOption) (*EncryptResult, error) {
+	cfg := &encryptConfig{
+		version:        Version,
+		algo:           AlgoAESGCMChunked,
+		plaintextBytes: -1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	gcm, err := newAEAD(cfg.algo, key)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := NewHeader(cfg.version, chunkBytes, cfg.plaintextBytes)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.flags != 0 && cfg.version != VersionV2 {
+		return nil, fmt.Errorf("zstd compression requires tbenc/v2")
+	}
+	if !cfg.key.IsZero() && cfg.version != VersionV2 {
+		return nil, fmt.Errorf("a key reference requires tbenc/v2")
+	}
+	header.Algo = cfg.algo
+	header.Flags = cfg.flags
+	header.KeyID = cfg.key.ID
+	header.KeyVersion = cfg.key.Version
+	if cfg.noncePrefix != nil {
+		header.NoncePrefix = *cfg.noncePrefix
+	}
+
+	hasher := sha256.New()
+	ptHasher := sha256.New()
+	out := io.MultiWriter(w, hasher)
+	result := &EncryptResult{Header: header}
+
+	// Write header
+	headerBytes, _ := header.MarshalBinary()
+	if _, err := out.Write(headerBytes); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	result.CiphertextBytes = HeaderSize
+
+	plaintext := make([]byte, chunkBytes)
+	defer SecureZeroBytes(plaintext)
+	var frame []byte
+	if header.Compressed() {
+		frame = make([]byte, 0, chunkBytes)
+		defer func() { SecureZeroBytes(frame[:cap(frame)]) }()
+	}
+	record := make([]byte, 0, int(header.RecordHeaderSize())+int(chunkBytes)+TagSize)
+
+	for chunkIndex := uint64(0); ; chunkIndex++ {
+		n, readErr := io.ReadFull(r, plaintext)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("failed to read plaintext at chunk %d: %w", chunkIndex, readErr)
+		}
+
+		// tbenc/v2 encodes an empty plaintext as a single empty final record
+		emptyV2 := header.Version == VersionV2 && chunkIndex == 0 && n == 0
+		if n == 0 && !emptyV2 {
+			break
+		}
+
+		if header.Version == VersionV2 {
+			if uint64(result.PlaintextBytes)+uint64(n) > header.PlaintextBytes {
+				return nil, fmt.Errorf("plaintext exceeds declared size of %d bytes", header.PlaintextBytes)
+			}
+		}
+
+		ptHasher.Write(plaintext[:n])
+
+		// Write record: pt_len (uint32) [+ stored_len (uint32)] + ciphertext_with_tag
+		stored := plaintext[:n]
+		record = binary.BigEndian.AppendUint32(record[:0], uint32(n))
+		if header.Compressed() {
+			compressed, err := compressChunk(frame, stored)
+			if err != nil {
+				return nil, err
+			}
+			if compressed != nil {
+				frame, stored = compressed, compressed
+			}
+			record = binary.BigEndian.AppendUint32(record, uint32(len(stored)))
+		}
+		record = sealRecord(gcm, record, header, chunkIndex, uint32(n), stored)
+		if _, err := out.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write chunk %d: %w", chunkIndex, err)
+		}
+
+		result.PlaintextBytes += int64(n)
+		result.CiphertextBytes += int64(len(record))
+
+		if readErr != nil {
+			break
+		}
+	}
+
+	if header.Version == VersionV2 && uint64(result.PlaintextBytes) != header.PlaintextBytes {
+		return nil, fmt.Errorf("plaintext is %d bytes, declared %d", result.PlaintextBytes, header.PlaintextBytes)
+	}
+
+	result.SHA256Ciphertext = hex.EncodeToString(hasher.Sum(nil))
+	result.SHA256Plaintext = hex.EncodeToString(ptHasher.Sum(nil))
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestEncryptToWriter_MatchesReferenceEncoding(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	prefix := [4]byte{0x01, 0x02, 0x03, 0x04}
+
+	tests := []struct {
+		name      string
+		version   uint16
+		plaintext []byte
+	}{
+		{"v1_multi_chunk", Version, testPlaintext(5*1024 + 17)},
+		{"v1_exact_chunks", Version, testPlaintext(3 * 1024)},
+		{"v1_empty", Version, []byte{}},
+		{"v2_multi_chunk", VersionV2, testPlaintext(5*1024 + 17)},
+		{"v2_exact_chunks", VersionV2, testPlaintext(3 * 1024)},
+		{"v2_empty", VersionV2, []byte{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			result, err := EncryptToWriter(bytes.NewReader(tt.plaintext), &out, key, 1024,
+				WithFormatVersion(tt.version),
+				WithPlaintextSize(int64(len(tt.plaintext))),
+				WithNoncePrefix(prefix),
+			)
+			if err != nil {
+				t.Fatalf("EncryptToWriter failed: %v", err)
+			}
+
+			expected := createTestEncryptedFileVersion(t, key, tt.plaintext, 1024, tt.version)
+			if !bytes.Equal(out.Bytes(), expected) {
+				t.Error("ciphertext does not match reference encoding")
+			}
+
+			sum := sha256.Sum256(expected)
+			if result.SHA256Ciphertext != hex.EncodeToString(sum[:]) {
+				t.Errorf("SHA256Ciphertext = %s, want %x", result.SHA256Ciphertext, sum)
+			}
+			if result.PlaintextBytes != int64(len(tt.plaintext)) {
+				t.Errorf("PlaintextBytes = %d, want %d", result.PlaintextBytes, len(tt.plaintext))
+			}
+			if result.CiphertextBytes != int64(len(expected)) {
+				t.Errorf("CiphertextBytes = %d, want %d", result.CiphertextBytes, len(expected))
+			}
+		})
+	}
+}
+
+func TestEncryptToWriter_RoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+	plaintext := testPlaintext(50*1024 + 3)
+
+	for _, version := range []uint16{Version, VersionV2} {
+		var out bytes.Buffer
+		_, err := EncryptToWriter(bytes.NewReader(plaintext), &out, key, 4096,
+			WithFormatVersion(version),
+			WithPlaintextSize(int64(len(plaintext))),
+		)
+		if err != nil {
+			t.Fatalf("v%d: EncryptToWriter failed: %v", version, err)
+		}
+
+		decrypted, err := DecryptToBytes(bytes.NewReader(out.Bytes()), key)
+		if err != nil {
+			t.Fatalf("v%d: DecryptToBytes failed: %v", version, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("v%d: round trip mismatch", version)
+		}
+	}
+}
+
+func TestEncryptToWriter_RandomNoncePrefix(t *testing.T) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+	plaintext := testPlaintext(2048)
+
+	var a, b bytes.Buffer
+	ra, err := EncryptToWriter(bytes.NewReader(plaintext), &a, key, 1024)
+	if err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+	rb, err := EncryptToWriter(bytes.NewReader(plaintext), &b, key, 1024)
+	if err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+
+	if ra.Header.NoncePrefix == rb.Header.NoncePrefix {
+		t.Error("expected distinct random nonce prefixes")
+	}
+	if bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("expected distinct ciphertexts")
+	}
+}
+
+func TestEncryptToWriter_V2SizeMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+	plaintext := testPlaintext(3000)
+
+	tests := []struct {
+		name     string
+		declared int64
+	}{
+		{"too_small", 2000},
+		{"too_large", 4000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			_, err := EncryptToWriter(bytes.NewReader(plaintext), &out, key, 1024,
+				WithFormatVersion(VersionV2),
+				WithPlaintextSize(tt.declared),
+			)
+			if err == nil {
+				t.Fatal("expected error for plaintext size mismatch")
+			}
+			if !strings.Contains(err.Error(), "declared") {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptToWriter_V2RequiresSize(t *testing.T) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+
+	var out bytes.Buffer
+	_, err := EncryptToWriter(bytes.NewReader([]byte("data")), &out, key, 1024, WithFormatVersion(VersionV2))
+	if err == nil {
+		t.Fatal("expected error when tbenc/v2 size is not declared")
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected nothing written, got %d bytes", out.Len())
+	}
+}
+
+func TestEncryptToWriter_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        []byte
+		chunkBytes uint32
+		opts       []EncryptOption
+	}{
+		{"short_key", make([]byte, 16), 1024, nil},
+		{"chunk_too_small", make([]byte, 32), 512, nil},
+		{"chunk_too_large", make([]byte, 32), MaxChunkBytes + 1, nil},
+		{"bad_version", make([]byte, 32), 1024, []EncryptOption{WithFormatVersion(3)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if _, err := EncryptToWriter(bytes.NewReader([]byte("x")), &out, tt.key, tt.chunkBytes, tt.opts...); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestEncryptToWriter_WriteError(t *testing.T) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+
+	fw := &failingWriter{limit: 2}
+	_, err := EncryptToWriter(bytes.NewReader(testPlaintext(4096)), fw, key, 1024)
+	if err == nil || !strings.Contains(err.Error(), "chunk 1") {
+		t.Errorf("expected write error at chunk 1, got %v", err)
+	}
+}
+
+func TestHeader_MarshalBinary_RoundTrip(t *testing.T) {
+	for _, version := range []uint16{Version, VersionV2} {
+		h, err := NewHeader(version, 4096, 123456)
+		if err != nil {
+			t.Fatalf("v%d: NewHeader failed: %v", version, err)
+		}
+
+		data, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatalf("v%d: MarshalBinary failed: %v", version, err)
+		}
+		if len(data) != HeaderSize {
+			t.Fatalf("v%d: header size = %d, want %d", version, len(data), HeaderSize)
+		}
+
+		parsed, err := ParseHeader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("v%d: ParseHeader failed: %v", version, err)
+		}
+		if parsed.NoncePrefix != h.NoncePrefix || parsed.ChunkBytes != h.ChunkBytes || parsed.PlaintextBytes != h.PlaintextBytes {
+			t.Errorf("v%d: parsed header mismatch: %+v vs %+v", version, parsed, h)
+		}
+	}
+}
+
+func TestEncryptChunk_DecryptChunk(t *testing.T) {
+	key := bytes.Repeat([]byte{0x07}, 32)
+	h, err := NewHeader(Version, 1024, 0)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+
+	plaintext := []byte("single chunk")
+	ct, err := EncryptChunk(key, h, 3, plaintext)
+	if err != nil {
+		t.Fatalf("EncryptChunk failed: %v", err)
+	}
+
+	got, err := DecryptChunk(key, h, 3, uint32(len(plaintext)), ct)
+	if err != nil {
+		t.Fatalf("DecryptChunk failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("plaintext mismatch")
+	}
+
+	// A chunk sealed at one index must not open at another
+	if _, err := DecryptChunk(key, h, 4, uint32(len(plaintext)), ct); err == nil {
+		t.Error("expected failure when chunk index differs")
+	}
+}
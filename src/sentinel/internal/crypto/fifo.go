@@ -97,3 +97,33 @@ func IsFIFO(path string) bool {
 	}
 	return info.Mode()&os.ModeNamedPipe != 0
 }
+
+// ReplaceFIFO atomically replaces the FIFO at path with a new one.
+//
+// Processes that still have the old FIFO open keep the old pipe, so a
+// lingering reader sees EOF rather than data meant for the next reader.
+// Readers blocked opening the old FIFO are released with EOF instead of
+// waiting forever for a writer.
+func ReplaceFIFO(path string) error {
+	if path == "" {
+		return fmt.Errorf("fifo path cannot be empty")
+	}
+
+	// Hold the old pipe open for both ends until it is unlinked
+	oldFd, err := syscall.Open(path, syscall.O_RDWR|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err == nil {
+		defer syscall.Close(oldFd)
+	}
+
+	tmp := path + ".new"
+	_ = os.Remove(tmp)
+	if err := syscall.Mkfifo(tmp, FIFOMode); err != nil {
+		return fmt.Errorf("failed to create FIFO at %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace FIFO at %s: %w", path, err)
+	}
+
+	return nil
+}
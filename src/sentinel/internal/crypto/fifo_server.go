@@ -0,0 +1,250 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides restartable FIFO delivery: the model is streamed again
+// to every new reader of the FIFO, so a runtime that crashes or restarts
+// mid-load can simply reopen the pipe.
+package crypto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Session states reported by SessionTracker.
+const (
+	SessionWaiting   = "waiting"   // Waiting for a reader to open the FIFO
+	SessionStreaming = "streaming" // Reader connected, plaintext being written
+	SessionCompleted = "completed" // Whole file delivered
+	SessionAborted   = "aborted"   // Reader disconnected before the end
+	SessionFailed    = "failed"    // Decryption or I/O error
+)
+
+// ErrSessionLimit indicates the last allowed delivery session was aborted by
+// the reader, so the runtime never received the whole file.
+var ErrSessionLimit = errors.New("FIFO session limit reached")
+
+// FIFOSession describes one delivery of a file through its FIFO.
+type FIFOSession struct {
+	Path         string    // FIFO path
+	Number       int       // 1-based session number
+	State        string    // One of the Session* states
+	BytesWritten int64     // Plaintext bytes written in this session
+	StartedAt    time.Time // When the reader connected (zero while waiting)
+	Completed    int       // Sessions of this FIFO that delivered the whole file
+	Aborted      int       // Sessions of this FIFO aborted by the reader
+}
+
+// SessionTracker records the current delivery session of every FIFO.
+// It is safe for concurrent use.
+type SessionTracker struct {
+	mu       sync.Mutex
+	sessions map[string]*FIFOSession
+	order    []string
+}
+
+// NewSessionTracker creates an empty SessionTracker.
+func NewSessionTracker() *SessionTracker {
+	return &SessionTracker{sessions: make(map[string]*FIFOSession)}
+}
+
+// Sessions returns the current session of every FIFO, in the order the
+// FIFOs were first served.
+func (t *SessionTracker) Sessions() []FIFOSession {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]FIFOSession, len(t.order))
+	for i, path := range t.order {
+		out[i] = *t.sessions[path]
+	}
+	return out
+}
+
+// update applies fn to the session of the FIFO at path.
+func (t *SessionTracker) update(path string, fn func(s *FIFOSession)) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[path]
+	if !ok {
+		s = &FIFOSession{Path: path}
+		t.sessions[path] = s
+		t.order = append(t.order, path)
+	}
+	fn(s)
+}
+
+// WithMaxSessions limits how many times ServeFIFO delivers the file.
+// Zero means no limit. The default is 1.
+func WithMaxSessions(n int) StreamOption {
+	return func(c *streamConfig) {
+		c.maxSessions = n
+	}
+}
+
+// WithSessionTracker records ServeFIFO sessions in t.
+func WithSessionTracker(t *SessionTracker) StreamOption {
+	return func(c *streamConfig) {
+		c.tracker = t
+	}
+}
+
+// ServeFIFO decrypts an encrypted file to a FIFO once for every reader.
+//
+// Each session waits for a reader, rewinds to the start of the encrypted
+// file and streams the whole plaintext. A reader that disconnects early
+// (EPIPE) aborts only its session; the FIFO is then served again, up to
+// WithMaxSessions sessions. Each new session replaces the FIFO with a fresh
+// pipe, so a reader still draining the previous delivery never sees the next.
+//
+// The returned channel receives one StreamResult when serving stops: after
+// the session limit, on a decryption error, when the reader stalls, or when
+// ctx is cancelled. A stalled reader is not served again.
+// BytesWritten is the total over all sessions. If the last allowed session
+// was aborted the error wraps ErrSessionLimit.
+func ServeFIFO(ctx context.Context, encryptedPath, fifoPath string, key []byte, opts ...StreamOption) <-chan StreamResult {
+	result := make(chan StreamResult, 1)
+
+	// Apply options
+	cfg := &streamConfig{
+		logger:      slog.Default(),
+		workers:     1,
+		maxSessions: 1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	go func() {
+		defer close(result)
+
+		bytesWritten, err := serveFIFO(ctx, encryptedPath, fifoPath, key, cfg)
+		result <- StreamResult{
+			BytesWritten: bytesWritten,
+			Err:          err,
+		}
+	}()
+
+	return result
+}
+
+// serveFIFO runs the ServeFIFO session loop.
+func serveFIFO(ctx context.Context, encryptedPath, fifoPath string, key []byte, cfg *streamConfig) (int64, error) {
+	if encryptedPath == "" {
+		return 0, fmt.Errorf("encrypted file path cannot be empty")
+	}
+	if fifoPath == "" {
+		return 0, fmt.Errorf("FIFO path cannot be empty")
+	}
+	if len(key) != 32 {
+		return 0, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+
+	if err := CreateFIFO(fifoPath); err != nil {
+		return 0, fmt.Errorf("failed to create FIFO: %w", err)
+	}
+
+	var total int64
+	var lastErr error
+	for n := 1; cfg.maxSessions <= 0 || n <= cfg.maxSessions; n++ {
+		// A fresh pipe per session: the previous reader keeps the old one
+		if n > 1 {
+			if err := ReplaceFIFO(fifoPath); err != nil {
+				return total, err
+			}
+		}
+
+		written, err := serveSession(ctx, encryptedPath, fifoPath, key, cfg, n)
+		total += written
+		lastErr = err
+
+		switch {
+		case err == nil:
+			continue
+		case ctx.Err() != nil:
+			return total, ctx.Err()
+		case isReaderGone(err):
+			cfg.logger.Warn("FIFO reader disconnected, serving again",
+				"session", n,
+				"bytes_written", written,
+			)
+			continue
+		default:
+			return total, err
+		}
+	}
+
+	if lastErr != nil {
+		return total, fmt.Errorf("%w after %d sessions: %w", ErrSessionLimit, cfg.maxSessions, lastErr)
+	}
+	return total, nil
+}
+
+// serveSession delivers the file once, recording the session in the tracker.
+func serveSession(ctx context.Context, encryptedPath, fifoPath string, key []byte, cfg *streamConfig, n int) (int64, error) {
+	cfg.tracker.update(fifoPath, func(s *FIFOSession) {
+		s.Number = n
+		s.State = SessionWaiting
+		s.BytesWritten = 0
+		s.StartedAt = time.Time{}
+	})
+
+	// Each session rewinds by reopening the encrypted file
+	encFile, err := os.Open(encryptedPath)
+	if err != nil {
+		cfg.tracker.update(fifoPath, func(s *FIFOSession) { s.State = SessionFailed })
+		return 0, fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer encFile.Close()
+
+	sessionCfg := *cfg
+	sessionCfg.logger = cfg.logger.With("session", n)
+	if n > 1 {
+		// The open timeout covers a runtime that never connects, not one
+		// that takes its time to restart
+		sessionCfg.openTimeout = 0
+	}
+	sessionCfg.onOpen = func() {
+		cfg.tracker.update(fifoPath, func(s *FIFOSession) {
+			s.State = SessionStreaming
+			s.StartedAt = time.Now()
+		})
+	}
+	sessionCfg.progressCallback = func(written, totalBytes int64) {
+		cfg.tracker.update(fifoPath, func(s *FIFOSession) { s.BytesWritten = written })
+		if cfg.progressCallback != nil {
+			cfg.progressCallback(written, totalBytes)
+		}
+	}
+
+	written, err := writeFIFO(ctx, encFile, fifoPath, key, &sessionCfg)
+	cfg.tracker.update(fifoPath, func(s *FIFOSession) {
+		s.BytesWritten = written
+		switch {
+		case err == nil:
+			s.State = SessionCompleted
+			s.Completed++
+		case isReaderGone(err):
+			s.State = SessionAborted
+			s.Aborted++
+		default:
+			s.State = SessionFailed
+		}
+	})
+
+	return written, err
+}
+
+// isReaderGone reports whether a write failed because the FIFO reader closed.
+func isReaderGone(err error) bool {
+	return errors.Is(err, syscall.EPIPE)
+}
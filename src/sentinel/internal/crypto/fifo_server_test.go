@@ -0,0 +1,216 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// setupServeFIFO writes an encrypted file larger than a pipe buffer and
+// returns its path, the FIFO path, the key and the plaintext.
+func setupServeFIFO(t *testing.T) (string, string, []byte, []byte) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	key := bytes.Repeat([]byte{0x5A}, 32)
+	plaintext := testPlaintext(512 * 1024)
+
+	encryptedPath := filepath.Join(tmpDir, "model.tbenc")
+	encrypted := createTestEncryptedFile(t, key, plaintext, 4096)
+	if err := os.WriteFile(encryptedPath, encrypted, 0644); err != nil {
+		t.Fatalf("failed to write encrypted file: %v", err)
+	}
+
+	return encryptedPath, filepath.Join(tmpDir, "model.pipe"), key, plaintext
+}
+
+// waitForSession waits until the tracker reports session n waiting for a reader.
+func waitForSession(tracker *SessionTracker, n int) error {
+	for i := 0; i < 200; i++ {
+		for _, s := range tracker.Sessions() {
+			if s.Number == n && s.State == SessionWaiting {
+				return nil
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("session %d not waiting within timeout", n)
+}
+
+// readFIFO opens the FIFO for session n and reads up to limit bytes
+// (all of it when limit is negative).
+func readFIFO(tracker *SessionTracker, path string, n int, limit int64) ([]byte, error) {
+	if err := waitForSession(tracker, n); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit >= 0 {
+		r = io.LimitReader(f, limit)
+	}
+	return io.ReadAll(r)
+}
+
+func TestServeFIFO_ReaderRestart(t *testing.T) {
+	encryptedPath, fifoPath, key, plaintext := setupServeFIFO(t)
+	tracker := NewSessionTracker()
+
+	resultCh := ServeFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithMaxSessions(2),
+		WithSessionTracker(tracker),
+	)
+
+	// First reader crashes after a few KB
+	partial, err := readFIFO(tracker, fifoPath, 1, 8192)
+	if err != nil {
+		t.Fatalf("first reader failed: %v", err)
+	}
+	if len(partial) != 8192 {
+		t.Fatalf("first reader got %d bytes, want 8192", len(partial))
+	}
+
+	// Restarted reader receives the whole file from the start
+	full, err := readFIFO(tracker, fifoPath, 2, -1)
+	if err != nil {
+		t.Fatalf("second reader failed: %v", err)
+	}
+	if !bytes.Equal(full, plaintext) {
+		t.Errorf("second reader got %d bytes, want full %d byte plaintext", len(full), len(plaintext))
+	}
+
+	result := <-resultCh
+	if result.Err != nil {
+		t.Fatalf("ServeFIFO failed: %v", result.Err)
+	}
+
+	sessions := tracker.Sessions()
+	if len(sessions) != 1 {
+		t.Fatalf("tracker has %d FIFOs, want 1", len(sessions))
+	}
+	s := sessions[0]
+	if s.Number != 2 || s.State != SessionCompleted {
+		t.Errorf("session = %d/%s, want 2/%s", s.Number, s.State, SessionCompleted)
+	}
+	if s.Completed != 1 || s.Aborted != 1 {
+		t.Errorf("completed/aborted = %d/%d, want 1/1", s.Completed, s.Aborted)
+	}
+	if s.BytesWritten != int64(len(plaintext)) {
+		t.Errorf("BytesWritten = %d, want %d", s.BytesWritten, len(plaintext))
+	}
+	if s.StartedAt.IsZero() {
+		t.Error("StartedAt not set")
+	}
+}
+
+func TestServeFIFO_RepeatedDeliveries(t *testing.T) {
+	encryptedPath, fifoPath, key, plaintext := setupServeFIFO(t)
+	tracker := NewSessionTracker()
+
+	resultCh := ServeFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithMaxSessions(3),
+		WithSessionTracker(tracker),
+	)
+
+	for n := 1; n <= 3; n++ {
+		got, err := readFIFO(tracker, fifoPath, n, -1)
+		if err != nil {
+			t.Fatalf("reader %d failed: %v", n, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("reader %d got %d bytes, want %d", n, len(got), len(plaintext))
+		}
+	}
+
+	result := <-resultCh
+	if result.Err != nil {
+		t.Fatalf("ServeFIFO failed: %v", result.Err)
+	}
+	if want := 3 * int64(len(plaintext)); result.BytesWritten != want {
+		t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, want)
+	}
+	if s := tracker.Sessions()[0]; s.Completed != 3 {
+		t.Errorf("Completed = %d, want 3", s.Completed)
+	}
+}
+
+func TestServeFIFO_SessionLimit(t *testing.T) {
+	encryptedPath, fifoPath, key, _ := setupServeFIFO(t)
+	tracker := NewSessionTracker()
+
+	resultCh := ServeFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithMaxSessions(2),
+		WithSessionTracker(tracker),
+	)
+
+	for n := 1; n <= 2; n++ {
+		if _, err := readFIFO(tracker, fifoPath, n, 1024); err != nil {
+			t.Fatalf("reader %d failed: %v", n, err)
+		}
+	}
+
+	result := <-resultCh
+	if !errors.Is(result.Err, ErrSessionLimit) {
+		t.Fatalf("expected ErrSessionLimit, got %v", result.Err)
+	}
+	if s := tracker.Sessions()[0]; s.State != SessionAborted || s.Aborted != 2 {
+		t.Errorf("session state/aborted = %s/%d, want %s/2", s.State, s.Aborted, SessionAborted)
+	}
+}
+
+func TestServeFIFO_WrongKeyStops(t *testing.T) {
+	encryptedPath, fifoPath, _, _ := setupServeFIFO(t)
+	tracker := NewSessionTracker()
+
+	resultCh := ServeFIFO(context.Background(), encryptedPath, fifoPath, bytes.Repeat([]byte{0x01}, 32),
+		WithMaxSessions(0),
+		WithSessionTracker(tracker),
+	)
+
+	if _, err := readFIFO(tracker, fifoPath, 1, -1); err != nil {
+		t.Fatalf("reader failed: %v", err)
+	}
+
+	result := <-resultCh
+	if result.Err == nil || errors.Is(result.Err, ErrSessionLimit) {
+		t.Fatalf("expected decryption error, got %v", result.Err)
+	}
+	if s := tracker.Sessions()[0]; s.State != SessionFailed {
+		t.Errorf("state = %s, want %s", s.State, SessionFailed)
+	}
+}
+
+func TestServeFIFO_CancelWhileWaiting(t *testing.T) {
+	encryptedPath, fifoPath, key, _ := setupServeFIFO(t)
+	tracker := NewSessionTracker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resultCh := ServeFIFO(ctx, encryptedPath, fifoPath, key,
+		WithMaxSessions(0),
+		WithSessionTracker(tracker),
+	)
+
+	if err := waitForSession(tracker, 1); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	select {
+	case result := <-resultCh:
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", result.Err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("ServeFIFO did not stop after cancellation")
+	}
+}
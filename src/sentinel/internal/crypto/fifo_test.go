@@ -1,9 +1,12 @@
 package crypto
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestCreateFIFO(t *testing.T) {
@@ -239,3 +242,76 @@ func TestIsFIFO(t *testing.T) {
 		}
 	})
 }
+
+func TestReplaceFIFO(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("replaces with a new pipe", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "replace-fifo")
+		if err := CreateFIFO(path); err != nil {
+			t.Fatalf("CreateFIFO failed: %v", err)
+		}
+		before, err := os.Lstat(path)
+		if err != nil {
+			t.Fatalf("failed to stat FIFO: %v", err)
+		}
+
+		if err := ReplaceFIFO(path); err != nil {
+			t.Fatalf("ReplaceFIFO failed: %v", err)
+		}
+
+		after, err := os.Lstat(path)
+		if err != nil {
+			t.Fatalf("failed to stat FIFO: %v", err)
+		}
+		if !IsFIFO(path) {
+			t.Error("path is not a FIFO after replacement")
+		}
+		if after.Mode().Perm() != FIFOMode {
+			t.Errorf("mode = %o, want %o", after.Mode().Perm(), FIFOMode)
+		}
+		if before.Sys().(*syscall.Stat_t).Ino == after.Sys().(*syscall.Stat_t).Ino {
+			t.Error("FIFO inode unchanged after replacement")
+		}
+	})
+
+	t.Run("releases readers of the old pipe", func(t *testing.T) {
+		path := filepath.Join(tmpDir, "replace-fifo-reader")
+		if err := CreateFIFO(path); err != nil {
+			t.Fatalf("CreateFIFO failed: %v", err)
+		}
+
+		// This open blocks until a writer appears on the old pipe
+		done := make(chan error, 1)
+		go func() {
+			f, err := os.Open(path)
+			if err != nil {
+				done <- err
+				return
+			}
+			defer f.Close()
+			_, err = io.ReadAll(f)
+			done <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		if err := ReplaceFIFO(path); err != nil {
+			t.Fatalf("ReplaceFIFO failed: %v", err)
+		}
+
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("old reader failed: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("reader of the old FIFO still blocked")
+		}
+	})
+
+	t.Run("fails with empty path", func(t *testing.T) {
+		if err := ReplaceFIFO(""); err == nil {
+			t.Error("expected error for empty path, got nil")
+		}
+	})
+}
@@ -0,0 +1,169 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides context-aware FIFO writing. The FIFO is opened with
+// O_NONBLOCK, polling until a reader connects, so waiting for a reader never
+// parks a goroutine in open(2). The resulting descriptor is serviced by the
+// runtime poller (epoll on Linux), which lets writes carry deadlines: ctx
+// cancellation expires the deadline immediately, and the optional stall
+// timeout fails a write once the reader has made no progress for that long.
+package crypto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// fifoOpenPollInterval is how often a FIFO is reopened while waiting
+	// for a reader.
+	fifoOpenPollInterval = 20 * time.Millisecond
+
+	// fifoWriteBytes bounds each write to the default pipe capacity, so the
+	// stall deadline measures reader progress rather than the write size.
+	fifoWriteBytes = 64 * 1024
+)
+
+var (
+	// ErrNoReader indicates that no reader opened the FIFO within the open
+	// timeout.
+	ErrNoReader = errors.New("no FIFO reader connected")
+
+	// ErrReaderStalled indicates that the FIFO reader stopped consuming data
+	// for longer than the stall timeout.
+	ErrReaderStalled = errors.New("FIFO reader stalled")
+)
+
+// WithOpenTimeout fails with ErrNoReader if no reader opens the FIFO within
+// d. Zero (the default) waits until ctx is cancelled. ServeFIFO applies it to
+// the first session only.
+func WithOpenTimeout(d time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.openTimeout = d
+	}
+}
+
+// WithStallTimeout fails with ErrReaderStalled once the FIFO reader has
+// accepted no data for d. Zero (the default) disables the watchdog.
+func WithStallTimeout(d time.Duration) StreamOption {
+	return func(c *streamConfig) {
+		c.stallTimeout = d
+	}
+}
+
+// openFIFOWriter opens fifoPath for writing once a reader has it open. It
+// returns ctx's error on cancellation and ErrNoReader once timeout (if
+// positive) elapses.
+func openFIFOWriter(ctx context.Context, fifoPath string, timeout time.Duration) (*os.File, error) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	ticker := time.NewTicker(fifoOpenPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Without a reader a non-blocking open fails with ENXIO instead of
+		// blocking
+		f, err := os.OpenFile(fifoPath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, syscall.ENXIO) {
+			return nil, fmt.Errorf("failed to open FIFO for writing: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline:
+			return nil, fmt.Errorf("%w within %s", ErrNoReader, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// fifoWriter writes to a FIFO opened by openFIFOWriter, giving up when ctx is
+// cancelled or the reader stalls.
+type fifoWriter struct {
+	f     *os.File
+	ctx   context.Context
+	stall time.Duration
+
+	mu   sync.Mutex // Orders deadline updates against cancellation
+	stop func() bool
+}
+
+// newFIFOWriter wraps f. Close must be called to release the cancellation
+// hook; it does not close f.
+func newFIFOWriter(ctx context.Context, f *os.File, stall time.Duration) *fifoWriter {
+	w := &fifoWriter{f: f, ctx: ctx, stall: stall}
+	w.stop = context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		// A deadline in the past wakes a blocked Write at once
+		w.f.SetWriteDeadline(time.Unix(1, 0))
+	})
+	return w
+}
+
+// Write implements io.Writer in pieces of at most fifoWriteBytes, renewing
+// the stall deadline before each piece.
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		if err := w.renewDeadline(); err != nil {
+			return written, err
+		}
+
+		piece := p[:min(len(p), fifoWriteBytes)]
+		n, err := w.f.Write(piece)
+		written += n
+		p = p[n:]
+		if err != nil {
+			return written, w.mapError(err)
+		}
+	}
+	return written, nil
+}
+
+// renewDeadline sets the deadline for the next piece, unless ctx is done.
+func (w *fifoWriter) renewDeadline() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	var deadline time.Time
+	if w.stall > 0 {
+		deadline = time.Now().Add(w.stall)
+	}
+	// Files the poller cannot serve (ErrNoDeadline) block as before
+	if err := w.f.SetWriteDeadline(deadline); err != nil && !errors.Is(err, os.ErrNoDeadline) {
+		return fmt.Errorf("failed to set FIFO write deadline: %w", err)
+	}
+	return nil
+}
+
+// mapError turns an expired deadline into the reason it expired.
+func (w *fifoWriter) mapError(err error) error {
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	if ctxErr := w.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return fmt.Errorf("%w: no progress for %s", ErrReaderStalled, w.stall)
+}
+
+// Close releases the cancellation hook.
+func (w *fifoWriter) Close() {
+	w.stop()
+}
@@ -0,0 +1,148 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// openStalledReader opens the FIFO for reading and never reads from it.
+func openStalledReader(t *testing.T, fifoPath string) {
+	t.Helper()
+	if err := waitForFIFO(fifoPath); err != nil {
+		t.Fatalf("FIFO not created: %v", err)
+	}
+	f, err := os.Open(fifoPath)
+	if err != nil {
+		t.Fatalf("failed to open FIFO: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+}
+
+// awaitResult returns the stream result or fails if it takes longer than d.
+func awaitResult(t *testing.T, ch <-chan StreamResult, d time.Duration) StreamResult {
+	t.Helper()
+	select {
+	case result := <-ch:
+		return result
+	case <-time.After(d):
+		t.Fatalf("no result within %s", d)
+		return StreamResult{}
+	}
+}
+
+func TestDecryptToFIFO_CancelWhileWriting(t *testing.T) {
+	encryptedPath, fifoPath, key, _ := setupServeFIFO(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resultCh := DecryptToFIFO(ctx, encryptedPath, fifoPath, key)
+	openStalledReader(t, fifoPath)
+
+	// Let the writer fill the pipe and block
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	result := awaitResult(t, resultCh, time.Second)
+	if !errors.Is(result.Err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", result.Err)
+	}
+	if result.BytesWritten == 0 {
+		t.Error("expected a partial write before cancellation")
+	}
+}
+
+func TestDecryptToFIFO_ReaderStalled(t *testing.T) {
+	encryptedPath, fifoPath, key, _ := setupServeFIFO(t)
+
+	resultCh := DecryptToFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithStallTimeout(200*time.Millisecond),
+	)
+	openStalledReader(t, fifoPath)
+
+	result := awaitResult(t, resultCh, 2*time.Second)
+	if !errors.Is(result.Err, ErrReaderStalled) {
+		t.Fatalf("expected ErrReaderStalled, got %v", result.Err)
+	}
+}
+
+func TestDecryptToFIFO_SlowReaderNotStalled(t *testing.T) {
+	encryptedPath, fifoPath, key, plaintext := setupServeFIFO(t)
+
+	resultCh := DecryptToFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithStallTimeout(300*time.Millisecond),
+	)
+	if err := waitForFIFO(fifoPath); err != nil {
+		t.Fatalf("FIFO not created: %v", err)
+	}
+	f, err := os.Open(fifoPath)
+	if err != nil {
+		t.Fatalf("failed to open FIFO: %v", err)
+	}
+	defer f.Close()
+
+	// Slower overall than the stall timeout, but never idle for that long
+	var got bytes.Buffer
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buf)
+		got.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	result := awaitResult(t, resultCh, time.Second)
+	if result.Err != nil {
+		t.Fatalf("DecryptToFIFO failed: %v", result.Err)
+	}
+	if !bytes.Equal(got.Bytes(), plaintext) {
+		t.Error("plaintext mismatch")
+	}
+}
+
+func TestDecryptToFIFO_NoReader(t *testing.T) {
+	encryptedPath, fifoPath, key, _ := setupServeFIFO(t)
+
+	start := time.Now()
+	result := awaitResult(t, DecryptToFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithOpenTimeout(100*time.Millisecond),
+	), 2*time.Second)
+	if !errors.Is(result.Err, ErrNoReader) {
+		t.Fatalf("expected ErrNoReader, got %v", result.Err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("gave up after %s, before the open timeout", elapsed)
+	}
+}
+
+func TestServeFIFO_StalledReaderStops(t *testing.T) {
+	encryptedPath, fifoPath, key, _ := setupServeFIFO(t)
+	tracker := NewSessionTracker()
+
+	resultCh := ServeFIFO(context.Background(), encryptedPath, fifoPath, key,
+		WithMaxSessions(0),
+		WithSessionTracker(tracker),
+		WithStallTimeout(200*time.Millisecond),
+	)
+	if err := waitForSession(tracker, 1); err != nil {
+		t.Fatal(err)
+	}
+	openStalledReader(t, fifoPath)
+
+	result := awaitResult(t, resultCh, 2*time.Second)
+	if !errors.Is(result.Err, ErrReaderStalled) {
+		t.Fatalf("expected ErrReaderStalled, got %v", result.Err)
+	}
+	if s := tracker.Sessions(); len(s) != 1 || s[0].State != SessionFailed || s[0].Number != 1 {
+		t.Errorf("sessions = %+v, want session 1 failed", s)
+	}
+}
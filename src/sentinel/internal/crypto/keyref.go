@@ -0,0 +1,92 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides key references. A tbenc/v2 header may name the provider
+// key it was encrypted under (key_id, big-endian uint16 in Reserved[9:11])
+// and that key's rotation (key_version, Reserved[11:13]), so a consumer
+// holding a different key version fails with ErrWrongKeyVersion before
+// decrypting instead of with a tag mismatch at chunk 0. Both zero means the
+// file does not name its key, as do all tbenc/v1 headers. The reference is a
+// hint, not part of the AAD: the key itself is what authenticates the chunks.
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// keyIDOffset is the index of key_id within Header.Reserved.
+	keyIDOffset = 9
+
+	// keyVersionOffset is the index of key_version within Header.Reserved.
+	keyVersionOffset = 11
+)
+
+// ErrWrongKeyVersion indicates that the key at hand is not the one the
+// file was encrypted with.
+var ErrWrongKeyVersion = errors.New("wrong key version")
+
+// KeyRef identifies a provider key and its rotation. The zero value means
+// the key is not identified.
+type KeyRef struct {
+	ID      uint16
+	Version uint16
+}
+
+// IsZero reports whether the reference is unset.
+func (k KeyRef) IsZero() bool {
+	return k == KeyRef{}
+}
+
+// String formats the reference for error messages.
+func (k KeyRef) String() string {
+	if k.IsZero() {
+		return "unidentified key"
+	}
+	return fmt.Sprintf("key %d version %d", k.ID, k.Version)
+}
+
+// Key returns the key reference stored in the header.
+func (h *Header) Key() KeyRef {
+	return KeyRef{ID: h.KeyID, Version: h.KeyVersion}
+}
+
+// CheckKey returns an error wrapping ErrWrongKeyVersion if the header names
+// a key other than have. A header that names no key, or an unidentified
+// have, is accepted; decryption then fails on the tag if the key is wrong.
+func (h *Header) CheckKey(have KeyRef) error {
+	if h.Key().IsZero() || have.IsZero() || h.Key() == have {
+		return nil
+	}
+	return fmt.Errorf("%w: file was encrypted with %s, have %s", ErrWrongKeyVersion, h.Key(), have)
+}
+
+// parseKeyRef sets KeyID and KeyVersion from the reserved bytes.
+func (h *Header) parseKeyRef() {
+	h.KeyID = binary.BigEndian.Uint16(h.Reserved[keyIDOffset:])
+	h.KeyVersion = binary.BigEndian.Uint16(h.Reserved[keyVersionOffset:])
+}
+
+// checkStreamKey reads the header from r and checks it against have. It
+// returns a reader that yields the whole stream, header included.
+func checkStreamKey(r io.Reader, have KeyRef) (io.Reader, error) {
+	if have.IsZero() {
+		return r, nil
+	}
+
+	buf := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	header, err := ParseHeader(bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	if err := header.CheckKey(have); err != nil {
+		return nil, err
+	}
+	return io.MultiReader(bytes.NewReader(buf), r), nil
+}
@@ -0,0 +1,175 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encryptWithKeyRef(t *testing.T, key, plaintext []byte, ref KeyRef) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	if _, err := EncryptToWriter(bytes.NewReader(plaintext), &out, key, 1024,
+		WithFormatVersion(VersionV2), WithPlaintextSize(int64(len(plaintext))), WithKeyRef(ref)); err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestHeader_KeyRefRoundTrip(t *testing.T) {
+	h, err := NewHeader(VersionV2, 1024, 5000)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	h.Flags = FlagZstd
+	h.KeyID = 0x1234
+	h.KeyVersion = 0xfffe
+
+	data, _ := h.MarshalBinary()
+	parsed, err := ParseHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	if parsed.Key() != (KeyRef{ID: 0x1234, Version: 0xfffe}) {
+		t.Errorf("Key() = %+v", parsed.Key())
+	}
+	if parsed.Flags != h.Flags {
+		t.Errorf("Flags = %#x, want %#x", parsed.Flags, h.Flags)
+	}
+	if parsed.PlaintextBytes != 5000 {
+		t.Errorf("PlaintextBytes = %d, want 5000", parsed.PlaintextBytes)
+	}
+}
+
+func TestHeader_V1HasNoKeyRef(t *testing.T) {
+	// Whatever a v1 writer left in the reserved bytes names no key
+	h, err := NewHeader(Version, 1024, -1)
+	if err != nil {
+		t.Fatalf("NewHeader failed: %v", err)
+	}
+	for i := range h.Reserved {
+		h.Reserved[i] = 0xa5
+	}
+	h.KeyID = 7
+	h.KeyVersion = 3
+
+	data, _ := h.MarshalBinary()
+	if !bytes.Equal(data[HeaderSize-len(h.Reserved):], h.Reserved[:]) {
+		t.Error("MarshalBinary changed the reserved bytes of a v1 header")
+	}
+	parsed, err := ParseHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseHeader failed: %v", err)
+	}
+	if !parsed.Key().IsZero() {
+		t.Errorf("Key() = %v, want an unidentified key", parsed.Key())
+	}
+	if err := parsed.CheckKey(KeyRef{ID: 9, Version: 1}); err != nil {
+		t.Errorf("CheckKey() = %v, want nil", err)
+	}
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	var out bytes.Buffer
+	if _, err := EncryptToWriter(bytes.NewReader(testPlaintext(100)), &out, key, 1024, WithKeyRef(KeyRef{ID: 7, Version: 3})); err == nil {
+		t.Error("EncryptToWriter recorded a key reference in a v1 header")
+	}
+}
+
+func TestHeader_CheckKey(t *testing.T) {
+	ref := KeyRef{ID: 7, Version: 3}
+
+	tests := []struct {
+		name   string
+		header KeyRef
+		have   KeyRef
+		wrong  bool
+	}{
+		{"match", ref, ref, false},
+		{"unnamed header", KeyRef{}, ref, false},
+		{"unidentified key", ref, KeyRef{}, false},
+		{"other version", ref, KeyRef{ID: 7, Version: 4}, true},
+		{"other key", ref, KeyRef{ID: 8, Version: 3}, true},
+	}
+
+	for _, tt := range tests {
+		h := &Header{KeyID: tt.header.ID, KeyVersion: tt.header.Version}
+		err := h.CheckKey(tt.have)
+		if tt.wrong && !errors.Is(err, ErrWrongKeyVersion) {
+			t.Errorf("%s: CheckKey() = %v, want ErrWrongKeyVersion", tt.name, err)
+		}
+		if !tt.wrong && err != nil {
+			t.Errorf("%s: CheckKey() = %v, want nil", tt.name, err)
+		}
+	}
+}
+
+func TestKeyRef_NotAuthenticated(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+	encrypted := encryptWithKeyRef(t, key, plaintext, KeyRef{ID: 7, Version: 3})
+
+	// Legacy decrypters ignore the reference entirely
+	decrypted, err := DecryptToBytes(bytes.NewReader(encrypted), key)
+	if err != nil {
+		t.Fatalf("DecryptToBytes failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Error("round trip mismatch")
+	}
+}
+
+func TestVerifyAll_WrongKeyVersion(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+	encrypted := encryptWithKeyRef(t, key, plaintext, KeyRef{ID: 7, Version: 3})
+
+	exp := Expected{
+		ChunkBytes:      1024,
+		PlaintextBytes:  int64(len(plaintext)),
+		CiphertextBytes: int64(len(encrypted)),
+		Key:             KeyRef{ID: 7, Version: 3},
+	}
+	if _, err := VerifyAll(bytes.NewReader(encrypted), key, WithExpected(exp)); err != nil {
+		t.Fatalf("VerifyAll failed for matching key: %v", err)
+	}
+
+	exp.Key.Version = 2
+	result, err := VerifyAll(bytes.NewReader(encrypted), key, WithExpected(exp))
+	if !errors.Is(err, ErrWrongKeyVersion) {
+		t.Fatalf("expected ErrWrongKeyVersion, got %v", err)
+	}
+	if result.Chunks != 0 {
+		t.Errorf("Chunks = %d, want 0: the header check must come first", result.Chunks)
+	}
+}
+
+func TestDecryptToFile_WrongKeyVersion(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+	encrypted := encryptWithKeyRef(t, key, plaintext, KeyRef{ID: 7, Version: 3})
+	out := filepath.Join(t.TempDir(), "config.json")
+
+	_, err := DecryptToFile(context.Background(), bytes.NewReader(encrypted), out, key,
+		WithExpectedKey(KeyRef{ID: 7, Version: 4}),
+	)
+	if !errors.Is(err, ErrWrongKeyVersion) {
+		t.Fatalf("expected ErrWrongKeyVersion, got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("output file exists after a wrong key version")
+	}
+
+	n, err := DecryptToFile(context.Background(), bytes.NewReader(encrypted), out, key,
+		WithExpectedKey(KeyRef{ID: 7, Version: 3}),
+	)
+	if err != nil {
+		t.Fatalf("DecryptToFile failed for matching key: %v", err)
+	}
+	got, _ := os.ReadFile(out)
+	if n != int64(len(plaintext)) || !bytes.Equal(got, plaintext) {
+		t.Error("decrypted file does not match plaintext")
+	}
+}
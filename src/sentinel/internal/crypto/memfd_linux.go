@@ -0,0 +1,102 @@
+package crypto
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// memfdSeals prevents any further change to a memfd's contents or size.
+const memfdSeals = unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_WRITE | unix.F_SEAL_SEAL
+
+// DecryptToMemfd decrypts a tbenc stream into an anonymous memory file.
+//
+// The memfd is never linked into a filesystem, so no other process can open
+// it by path. Once decryption succeeds the memfd is sealed against writes and
+// resizing, and a read-only, seekable file is returned. The caller owns the
+// file; the memory is released when the file and every descriptor passed to
+// other processes are closed. Nothing is kept on failure.
+func DecryptToMemfd(ctx context.Context, r io.Reader, name string, key []byte, opts ...StreamOption) (*os.File, int64, error) {
+	cfg := &streamConfig{
+		logger:  slog.Default(),
+		workers: 1,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if len(key) != 32 {
+		return nil, 0, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+
+	r, err := checkStreamKey(r, cfg.key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The name is only shown in /proc/<pid>/fd and is limited to 249 bytes
+	memfdName := "tb:" + name
+	if len(memfdName) > 249 {
+		memfdName = memfdName[:249]
+	}
+	fd, err := unix.MemfdCreate(memfdName, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create memfd: %w", err)
+	}
+	rw := os.NewFile(uintptr(fd), "memfd:"+name)
+	defer rw.Close()
+
+	w, checkPlaintext := cfg.hashPlaintext(rw)
+	if cfg.progressCallback != nil || cfg.totalBytes > 0 {
+		w = &progressTrackingWriter{
+			w:                w,
+			totalBytes:       cfg.totalBytes,
+			progressCallback: cfg.progressCallback,
+			logger:           cfg.logger,
+			lastLogPercent:   -10, // Will log at 0%
+		}
+	}
+
+	ctxReader := &contextReader{
+		ctx: ctx,
+		r:   r,
+	}
+
+	bytesWritten, err := DecryptToWriterParallel(ctxReader, w, key, cfg.workers)
+	if err != nil {
+		return nil, bytesWritten, fmt.Errorf("decryption failed: %w", err)
+	}
+	if err := checkPlaintext(); err != nil {
+		return nil, bytesWritten, err
+	}
+
+	if _, err := unix.FcntlInt(rw.Fd(), unix.F_ADD_SEALS, memfdSeals); err != nil {
+		return nil, bytesWritten, fmt.Errorf("failed to seal memfd: %w", err)
+	}
+
+	ro, err := ReopenReadOnly(rw)
+	if err != nil {
+		return nil, bytesWritten, err
+	}
+
+	cfg.logger.Info("decrypted to memfd",
+		"bytes_written", bytesWritten,
+	)
+
+	return ro, bytesWritten, nil
+}
+
+// ReopenReadOnly opens f again for reading only, with its own file offset.
+// It works for files that have no path, such as memfds.
+func ReopenReadOnly(f *os.File) (*os.File, error) {
+	path := fmt.Sprintf("/proc/self/fd/%d", f.Fd())
+	ro, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reopen %s read-only: %w", f.Name(), err)
+	}
+	return ro, nil
+}
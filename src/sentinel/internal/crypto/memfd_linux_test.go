@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDecryptToMemfd_Success(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(10*1024 + 3)
+	encryptedData := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	f, n, err := DecryptToMemfd(context.Background(), bytes.NewReader(encryptedData), "model.safetensors", key,
+		WithWorkers(4),
+	)
+	if err != nil {
+		t.Fatalf("DecryptToMemfd failed: %v", err)
+	}
+	defer f.Close()
+
+	if n != int64(len(plaintext)) {
+		t.Errorf("bytes written = %d, want %d", n, len(plaintext))
+	}
+
+	got, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("failed to read memfd: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("plaintext mismatch")
+	}
+
+	// Seekable: read a range from the middle
+	tail := make([]byte, 100)
+	if _, err := f.ReadAt(tail, 5000); err != nil {
+		t.Fatalf("ReadAt failed: %v", err)
+	}
+	if !bytes.Equal(tail, plaintext[5000:5100]) {
+		t.Error("ReadAt returned wrong data")
+	}
+
+	// Read-only and sealed
+	if _, err := f.Write([]byte("x")); err == nil {
+		t.Error("write to returned memfd succeeded")
+	}
+	seals, err := unix.FcntlInt(f.Fd(), unix.F_GET_SEALS, 0)
+	if err != nil {
+		t.Fatalf("F_GET_SEALS failed: %v", err)
+	}
+	if seals&memfdSeals != memfdSeals {
+		t.Errorf("seals = %#x, want %#x", seals, memfdSeals)
+	}
+	if _, err := unix.Mmap(int(f.Fd()), 0, len(plaintext), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED); err == nil {
+		t.Error("writable shared mapping of sealed memfd succeeded")
+	}
+}
+
+func TestDecryptToMemfd_WrongKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	encryptedData := createTestEncryptedFile(t, key, testPlaintext(4096), 1024)
+
+	f, _, err := DecryptToMemfd(context.Background(), bytes.NewReader(encryptedData), "model", bytes.Repeat([]byte{0x43}, 32))
+	if err == nil {
+		f.Close()
+		t.Fatal("expected error for wrong key, got nil")
+	}
+	if f != nil {
+		t.Error("file returned on failure")
+	}
+}
+
+func TestDecryptToMemfd_PlaintextHashMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	encryptedData := createTestEncryptedFile(t, key, testPlaintext(4096), 1024)
+
+	f, _, err := DecryptToMemfd(context.Background(), bytes.NewReader(encryptedData), "model", key,
+		WithPlaintextSHA256(plaintextSum([]byte("another model"))),
+	)
+	if !errors.Is(err, ErrPlaintextHashMismatch) {
+		t.Fatalf("expected ErrPlaintextHashMismatch, got %v", err)
+	}
+	if f != nil {
+		f.Close()
+		t.Error("file returned on failure")
+	}
+}
+
+func TestReopenReadOnly_OwnOffset(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(2048)
+	encryptedData := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	f, _, err := DecryptToMemfd(context.Background(), bytes.NewReader(encryptedData), "model", key)
+	if err != nil {
+		t.Fatalf("DecryptToMemfd failed: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(1000, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+
+	g, err := ReopenReadOnly(f)
+	if err != nil {
+		t.Fatalf("ReopenReadOnly failed: %v", err)
+	}
+	defer g.Close()
+
+	got, err := io.ReadAll(g)
+	if err != nil {
+		t.Fatalf("failed to read reopened memfd: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("reopened file does not start at offset 0")
+	}
+}
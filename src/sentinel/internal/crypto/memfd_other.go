@@ -0,0 +1,21 @@
+//go:build !linux
+
+package crypto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// errMemfdUnsupported is returned on platforms without memfd_create.
+var errMemfdUnsupported = errors.New("memfd delivery is only supported on Linux")
+
+func DecryptToMemfd(ctx context.Context, r io.Reader, name string, key []byte, opts ...StreamOption) (*os.File, int64, error) {
+	return nil, 0, errMemfdUnsupported
+}
+
+func ReopenReadOnly(f *os.File) (*os.File, error) {
+	return nil, errMemfdUnsupported
+}
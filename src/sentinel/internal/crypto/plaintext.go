@@ -0,0 +1,86 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides the plaintext hash check. Every chunk is authenticated,
+// so decryption proves the ciphertext was produced with the key; it does not
+// prove the plaintext is the model the provider meant to ship (for example a
+// wrong checkpoint encrypted under the right key). With WithPlaintextSHA256
+// the plaintext is hashed as it is written out and compared with the
+// manifest's sha256_plaintext once the stream ends.
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// ErrPlaintextHashMismatch indicates that the decrypted plaintext does not
+// match the expected SHA256.
+var ErrPlaintextHashMismatch = errors.New("plaintext hash mismatch")
+
+// PlaintextHashError reports a plaintext hash mismatch. It matches
+// ErrPlaintextHashMismatch with errors.Is.
+type PlaintextHashError struct {
+	Expected string // lowercase hex
+	Actual   string // lowercase hex
+}
+
+// Error implements the error interface.
+func (e *PlaintextHashError) Error() string {
+	return fmt.Sprintf("%v: expected %s, got %s", ErrPlaintextHashMismatch, e.Expected, e.Actual)
+}
+
+// Unwrap returns ErrPlaintextHashMismatch.
+func (e *PlaintextHashError) Unwrap() error {
+	return ErrPlaintextHashMismatch
+}
+
+// WithPlaintextSHA256 hashes the plaintext as it is written and fails with a
+// PlaintextHashError at the end of the stream if it does not match expected,
+// a 64-character hex string. An empty expected disables the check.
+//
+// For a FIFO the plaintext has already been handed to the reader when the
+// mismatch is detected; regular files and memfds are discarded instead.
+func WithPlaintextSHA256(expected string) StreamOption {
+	return func(c *streamConfig) {
+		c.plaintextSHA256 = strings.ToLower(expected)
+	}
+}
+
+// plaintextHasher hashes everything written through it.
+type plaintextHasher struct {
+	w        io.Writer
+	hash     hash.Hash
+	expected string
+}
+
+// hashPlaintext wraps w in a plaintextHasher if a plaintext hash is expected.
+// The returned check compares the hash once everything has been written.
+func (c *streamConfig) hashPlaintext(w io.Writer) (io.Writer, func() error) {
+	if c.plaintextSHA256 == "" {
+		return w, func() error { return nil }
+	}
+	h := &plaintextHasher{w: w, hash: sha256.New(), expected: c.plaintextSHA256}
+	return h, h.check
+}
+
+// Write implements io.Writer, updating the hash with each write.
+func (h *plaintextHasher) Write(p []byte) (int, error) {
+	n, err := h.w.Write(p)
+	if n > 0 {
+		h.hash.Write(p[:n])
+	}
+	return n, err
+}
+
+// check compares the plaintext hash with the expected one.
+func (h *plaintextHasher) check() error {
+	if actual := hex.EncodeToString(h.hash.Sum(nil)); actual != h.expected {
+		return &PlaintextHashError{Expected: h.expected, Actual: actual}
+	}
+	return nil
+}
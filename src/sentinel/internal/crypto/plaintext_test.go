@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func plaintextSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// decryptThroughFIFO decrypts encrypted into a FIFO with opts and returns the
+// result and everything the reader received.
+func decryptThroughFIFO(t *testing.T, encrypted, key []byte, opts ...StreamOption) (StreamResult, []byte) {
+	t.Helper()
+	fifoPath := filepath.Join(t.TempDir(), "pipe")
+
+	resultCh := DecryptStreamToFIFO(context.Background(), bytes.NewReader(encrypted), fifoPath, key, opts...)
+	if err := waitForFIFO(fifoPath); err != nil {
+		t.Fatalf("FIFO not created: %v", err)
+	}
+	fifo, err := os.Open(fifoPath)
+	if err != nil {
+		t.Fatalf("failed to open FIFO: %v", err)
+	}
+	received, _ := io.ReadAll(fifo)
+	fifo.Close()
+
+	return <-resultCh, received
+}
+
+func TestEncryptToWriter_SHA256Plaintext(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+
+	result, err := EncryptToWriter(bytes.NewReader(plaintext), io.Discard, key, 1024)
+	if err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+	if result.SHA256Plaintext != plaintextSum(plaintext) {
+		t.Errorf("SHA256Plaintext = %s, want %s", result.SHA256Plaintext, plaintextSum(plaintext))
+	}
+}
+
+func TestDecryptStreamToFIFO_PlaintextHash(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(10*1024 + 7)
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+
+	result, received := decryptThroughFIFO(t, encrypted, key,
+		WithWorkers(2),
+		WithPlaintextSHA256(strings.ToUpper(plaintextSum(plaintext))),
+	)
+	if result.Err != nil {
+		t.Fatalf("DecryptStreamToFIFO failed: %v", result.Err)
+	}
+	if !bytes.Equal(received, plaintext) {
+		t.Error("plaintext mismatch")
+	}
+
+	// The wrong checkpoint under the right key: every chunk authenticates
+	wrong := plaintextSum([]byte("another model"))
+	result, _ = decryptThroughFIFO(t, encrypted, key, WithPlaintextSHA256(wrong))
+	if !errors.Is(result.Err, ErrPlaintextHashMismatch) {
+		t.Fatalf("expected ErrPlaintextHashMismatch, got %v", result.Err)
+	}
+	var hashErr *PlaintextHashError
+	if !errors.As(result.Err, &hashErr) || hashErr.Expected != wrong || hashErr.Actual != plaintextSum(plaintext) {
+		t.Errorf("error = %#v, want expected %s, actual %s", result.Err, wrong, plaintextSum(plaintext))
+	}
+	if result.BytesWritten != int64(len(plaintext)) {
+		t.Errorf("bytes written = %d, want %d", result.BytesWritten, len(plaintext))
+	}
+}
+
+func TestDecryptToFile_PlaintextHashMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x42}, 32)
+	plaintext := testPlaintext(3000)
+	encrypted := createTestEncryptedFile(t, key, plaintext, 1024)
+	out := filepath.Join(t.TempDir(), "config.json")
+
+	_, err := DecryptToFile(context.Background(), bytes.NewReader(encrypted), out, key,
+		WithPlaintextSHA256(plaintextSum([]byte("another config"))),
+	)
+	if !errors.Is(err, ErrPlaintextHashMismatch) {
+		t.Fatalf("expected ErrPlaintextHashMismatch, got %v", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("output file exists after a plaintext hash mismatch")
+	}
+
+	if _, err := DecryptToFile(context.Background(), bytes.NewReader(encrypted), out, key,
+		WithPlaintextSHA256(plaintextSum(plaintext)),
+	); err != nil {
+		t.Fatalf("DecryptToFile failed for matching hash: %v", err)
+	}
+}
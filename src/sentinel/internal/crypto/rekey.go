@@ -0,0 +1,131 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides re-keying: rotating the key of an existing tbenc file
+// without materialising its plaintext. Each record is authenticated with the
+// old key, decrypted in place in guarded memory and immediately sealed with
+// the new key under a fresh nonce prefix, so at most one chunk of plaintext
+// exists at a time.
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Rekey re-encrypts the tbenc stream r under newKey and writes it to w.
+//
+// The format, chunk size, plaintext length and compression are kept, and
+// compressed records are re-sealed without being inflated, so the output
+// has exactly the size of the input. The nonce prefix is always fresh
+// (WithNoncePrefix overrides it for test vectors), the algorithm is kept
+// unless WithAlgorithm selects another, and the header names the key given
+// by WithKeyRef, or no key; tbenc/v1 files cannot name one. Other options are
+// ignored.
+//
+// Every chunk is authenticated with oldKey before anything derived from it
+// is written; on error, w holds a partial stream that must be discarded.
+func Rekey(r io.Reader, w io.Writer, oldKey, newKey []byte, opts ...EncryptOption) (*EncryptResult, error) {
+	if subtle.ConstantTimeCompare(oldKey, newKey) == 1 {
+		return nil, errors.New("new key must differ from the old key")
+	}
+
+	oldHeader, err := ParseHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse header: %w", err)
+	}
+
+	cfg := &encryptConfig{algo: oldHeader.Algo}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if !cfg.key.IsZero() && oldHeader.Version != VersionV2 {
+		return nil, fmt.Errorf("a key reference requires tbenc/v2")
+	}
+
+	oldGCM, err := newAEAD(oldHeader.Algo, oldKey)
+	if err != nil {
+		return nil, err
+	}
+	newGCM, err := newAEAD(cfg.algo, newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := *oldHeader
+	header.Algo = cfg.algo
+	header.KeyID = cfg.key.ID
+	header.KeyVersion = cfg.key.Version
+	header.Reserved = [13]byte{}
+	if cfg.noncePrefix != nil {
+		header.NoncePrefix = *cfg.noncePrefix
+	} else if _, err := io.ReadFull(rand.Reader, header.NoncePrefix[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+
+	hasher := sha256.New()
+	out := io.MultiWriter(w, hasher)
+	result := &EncryptResult{Header: &header}
+
+	headerBytes, _ := header.MarshalBinary()
+	if _, err := out.Write(headerBytes); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+	result.CiphertextBytes = HeaderSize
+
+	// The old record is decrypted in place; only its stored bytes (the chunk
+	// or its zstd frame) are ever in plaintext
+	buf, err := NewSecureBuffer(int(header.ChunkBytes) + TagSize)
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Destroy()
+
+	record := make([]byte, 0, int(header.RecordHeaderSize())+int(header.ChunkBytes)+TagSize)
+
+	for chunkIndex := uint64(0); ; chunkIndex++ {
+		ptLen, err := readRecordLen(r, oldHeader, chunkIndex)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		storedLen, err := readStoredLen(r, oldHeader, chunkIndex, ptLen)
+		if err != nil {
+			return nil, err
+		}
+
+		ctWithTag := buf.Bytes()[:int(storedLen)+TagSize]
+		if _, err := io.ReadFull(r, ctWithTag); err != nil {
+			return nil, fmt.Errorf("failed to read ciphertext at chunk %d: %w", chunkIndex, err)
+		}
+
+		stored, err := openRecord(oldGCM, ctWithTag[:0], oldHeader, chunkIndex, ptLen, storedLen, ctWithTag)
+		if err != nil {
+			return nil, err
+		}
+
+		record = binary.BigEndian.AppendUint32(record[:0], ptLen)
+		if header.Compressed() {
+			record = binary.BigEndian.AppendUint32(record, storedLen)
+		}
+		record = sealRecord(newGCM, record, &header, chunkIndex, ptLen, stored)
+		SecureZeroBytes(ctWithTag)
+
+		if _, err := out.Write(record); err != nil {
+			return nil, fmt.Errorf("failed to write chunk %d: %w", chunkIndex, err)
+		}
+
+		result.PlaintextBytes += int64(ptLen)
+		result.CiphertextBytes += int64(len(record))
+	}
+
+	result.SHA256Ciphertext = hex.EncodeToString(hasher.Sum(nil))
+	return result, nil
+}
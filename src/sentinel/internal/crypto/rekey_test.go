@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRekey_RoundTrip(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x42}, 32)
+	newKey := bytes.Repeat([]byte{0x24}, 32)
+	plaintext := mixedPlaintext(t, 6, 300)
+
+	tests := []struct {
+		name string
+		opts []EncryptOption
+		key  KeyRef
+	}{
+		{"v1", nil, KeyRef{}},
+		{"v2", []EncryptOption{WithFormatVersion(VersionV2), WithPlaintextSize(int64(len(plaintext)))}, KeyRef{ID: 7, Version: 4}},
+		{"v2 zstd", []EncryptOption{WithFormatVersion(VersionV2), WithPlaintextSize(int64(len(plaintext))), WithZstd()}, KeyRef{ID: 7, Version: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var encrypted bytes.Buffer
+			if _, err := EncryptToWriter(bytes.NewReader(plaintext), &encrypted, oldKey, 1024, tt.opts...); err != nil {
+				t.Fatalf("EncryptToWriter failed: %v", err)
+			}
+			oldHeader, _ := ParseHeader(bytes.NewReader(encrypted.Bytes()))
+
+			var rekeyed bytes.Buffer
+			result, err := Rekey(bytes.NewReader(encrypted.Bytes()), &rekeyed, oldKey, newKey, WithKeyRef(tt.key))
+			if err != nil {
+				t.Fatalf("Rekey failed: %v", err)
+			}
+
+			if rekeyed.Len() != encrypted.Len() || result.CiphertextBytes != int64(encrypted.Len()) {
+				t.Errorf("rekeyed size = %d (result %d), want %d", rekeyed.Len(), result.CiphertextBytes, encrypted.Len())
+			}
+			if result.PlaintextBytes != int64(len(plaintext)) {
+				t.Errorf("PlaintextBytes = %d, want %d", result.PlaintextBytes, len(plaintext))
+			}
+
+			header, err := ParseHeader(bytes.NewReader(rekeyed.Bytes()))
+			if err != nil {
+				t.Fatalf("ParseHeader failed: %v", err)
+			}
+			if header.NoncePrefix == oldHeader.NoncePrefix {
+				t.Error("nonce prefix was reused")
+			}
+			if header.Key() != tt.key {
+				t.Errorf("Key() = %v, want %v", header.Key(), tt.key)
+			}
+			if header.Version != oldHeader.Version || header.Flags != oldHeader.Flags || header.PlaintextBytes != oldHeader.PlaintextBytes {
+				t.Errorf("header = %+v, want layout of %+v", header, oldHeader)
+			}
+
+			decrypted, err := DecryptToBytes(bytes.NewReader(rekeyed.Bytes()), newKey)
+			if err != nil {
+				t.Fatalf("DecryptToBytes with new key failed: %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("rekeyed plaintext mismatch")
+			}
+			if _, err := DecryptToBytes(bytes.NewReader(rekeyed.Bytes()), oldKey); err == nil {
+				t.Error("old key still decrypts the rekeyed file")
+			}
+		})
+	}
+}
+
+func TestRekey_Algorithm(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x42}, 32)
+	newKey := bytes.Repeat([]byte{0x24}, 32)
+	plaintext := testPlaintext(3000)
+	encrypted := createTestEncryptedFile(t, oldKey, plaintext, 1024)
+
+	var rekeyed bytes.Buffer
+	if _, err := Rekey(bytes.NewReader(encrypted), &rekeyed, oldKey, newKey, WithAlgorithm(AlgoChaCha20Poly1305Chunked)); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+
+	header, _ := ParseHeader(bytes.NewReader(rekeyed.Bytes()))
+	if header.Algo != AlgoChaCha20Poly1305Chunked {
+		t.Errorf("Algo = %d, want %d", header.Algo, AlgoChaCha20Poly1305Chunked)
+	}
+	decrypted, err := DecryptToBytes(bytes.NewReader(rekeyed.Bytes()), newKey)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("DecryptToBytes = %v, plaintext matches = %t", err, bytes.Equal(decrypted, plaintext))
+	}
+}
+
+func TestRekey_Errors(t *testing.T) {
+	oldKey := bytes.Repeat([]byte{0x42}, 32)
+	newKey := bytes.Repeat([]byte{0x24}, 32)
+	encrypted := createTestEncryptedFileVersion(t, oldKey, testPlaintext(3000), 1024, VersionV2)
+
+	if _, err := Rekey(bytes.NewReader(encrypted), &bytes.Buffer{}, oldKey, oldKey); err == nil {
+		t.Error("Rekey accepted the old key as the new key")
+	}
+	if _, err := Rekey(bytes.NewReader(encrypted), &bytes.Buffer{}, newKey, oldKey); err == nil {
+		t.Error("Rekey succeeded with the wrong old key")
+	}
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := Rekey(bytes.NewReader(tampered), &bytes.Buffer{}, oldKey, newKey); err == nil {
+		t.Error("Rekey succeeded on a tampered file")
+	}
+
+	if _, err := Rekey(bytes.NewReader(encrypted[:len(encrypted)-100]), &bytes.Buffer{}, oldKey, newKey); err == nil {
+		t.Error("Rekey succeeded on a truncated file")
+	}
+	extended := append(append([]byte{}, encrypted...), 0)
+	if _, err := Rekey(bytes.NewReader(extended), &bytes.Buffer{}, oldKey, newKey); !errors.Is(err, ErrTrailingData) {
+		t.Errorf("extended: err = %v, want ErrTrailingData", err)
+	}
+
+	v1 := createTestEncryptedFile(t, oldKey, testPlaintext(3000), 1024)
+	if _, err := Rekey(bytes.NewReader(v1), &bytes.Buffer{}, oldKey, newKey, WithKeyRef(KeyRef{ID: 7, Version: 4})); err == nil {
+		t.Error("Rekey recorded a key reference in a v1 header")
+	}
+}
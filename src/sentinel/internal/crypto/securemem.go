@@ -0,0 +1,237 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides guarded memory for keys and plaintext. Buffers are
+// allocated outside the Go heap, so the garbage collector never copies them,
+// locked into RAM so they are not swapped, and excluded from core dumps.
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// KeySize is the size of a tbenc data key.
+const KeySize = 32
+
+// fingerprintLabel is the message MACed by Key.Fingerprint.
+const fingerprintLabel = "tbenc key fingerprint v1"
+
+// mlockWarnOnce limits the RLIMIT_MEMLOCK warning to one log line.
+var mlockWarnOnce sync.Once
+
+// SecureBuffer is a fixed-size buffer in guarded memory.
+//
+// Locking is best-effort: when RLIMIT_MEMLOCK is too small the buffer is still
+// allocated outside the Go heap and excluded from core dumps, and a warning is
+// logged once. A SecureBuffer must be released with Destroy.
+type SecureBuffer struct {
+	mu     sync.Mutex
+	mem    []byte // whole allocation
+	data   []byte // caller-visible part of mem
+	locked bool
+}
+
+// NewSecureBuffer allocates a zeroed guarded buffer of size bytes.
+func NewSecureBuffer(size int) (*SecureBuffer, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("secure buffer size must be positive, got %d", size)
+	}
+
+	mem, err := secureAlloc(size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate secure buffer: %w", err)
+	}
+
+	b := &SecureBuffer{mem: mem, data: mem[:size]}
+	if err := secureLock(mem); err != nil {
+		mlockWarnOnce.Do(func() {
+			slog.Warn("secure memory could not be locked, it may be swapped to disk; raise RLIMIT_MEMLOCK to prevent this",
+				"error", err.Error(),
+			)
+		})
+	} else {
+		b.locked = true
+	}
+
+	return b, nil
+}
+
+// Bytes returns the buffer contents. The slice is invalid after Destroy.
+func (b *SecureBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data
+}
+
+// Locked reports whether the buffer is locked into RAM.
+func (b *SecureBuffer) Locked() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.locked
+}
+
+// Destroy zeroes and releases the buffer. It is safe to call more than once.
+func (b *SecureBuffer) Destroy() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.mem == nil {
+		return
+	}
+
+	SecureZeroBytes(b.mem)
+	if b.locked {
+		secureUnlock(b.mem)
+	}
+	secureFree(b.mem)
+
+	b.mem = nil
+	b.data = nil
+	b.locked = false
+}
+
+// Key is a 32-byte data key held in guarded memory.
+//
+// The key is decoded or unwrapped directly into its buffer, so it never
+// exists as a Go string or in a heap slice the caller cannot wipe. Call
+// Destroy as soon as the key is no longer needed.
+type Key struct {
+	buf *SecureBuffer
+}
+
+// AllocKey returns a zeroed Key whose Bytes can be filled in place, for
+// example as the destination of an AEAD Open.
+func AllocKey() (*Key, error) {
+	buf, err := NewSecureBuffer(KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{buf: buf}, nil
+}
+
+// NewKey copies raw into a new Key and zeroes raw.
+func NewKey(raw []byte) (*Key, error) {
+	defer SecureZeroBytes(raw)
+
+	if len(raw) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(raw))
+	}
+
+	k, err := AllocKey()
+	if err != nil {
+		return nil, err
+	}
+	copy(k.Bytes(), raw)
+	return k, nil
+}
+
+// DecodeHexKey decodes a 64-character hex key from src into a new Key.
+// src is a byte slice rather than a string so the caller can wipe it.
+func DecodeHexKey(src []byte) (*Key, error) {
+	if len(src) != hex.EncodedLen(KeySize) {
+		return nil, fmt.Errorf("hex key must be %d characters, got %d", hex.EncodedLen(KeySize), len(src))
+	}
+
+	k, err := AllocKey()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := hex.Decode(k.Bytes(), src); err != nil {
+		k.Destroy()
+		return nil, fmt.Errorf("invalid hex key: %w", err)
+	}
+	return k, nil
+}
+
+// Bytes returns the key material, or nil once the key is destroyed.
+// The slice must not be retained past Destroy.
+func (k *Key) Bytes() []byte {
+	return k.buf.Bytes()
+}
+
+// Fingerprint identifies the key without revealing it: the hex HMAC-SHA256
+// of a fixed label under the key. Two fingerprints are equal only for the
+// same key.
+func (k *Key) Fingerprint() string {
+	mac := hmac.New(sha256.New, k.Bytes())
+	mac.Write([]byte(fingerprintLabel))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Destroy wipes and releases the key. It is safe to call more than once.
+func (k *Key) Destroy() {
+	k.buf.Destroy()
+}
+
+// SecureZeroBytes overwrites a byte slice with zeros.
+// Note: This is best-effort and may not prevent all memory attacks due to
+// Go's GC potentially copying data, but it reduces exposure. Use
+// SecureBuffer for memory the GC must never copy.
+func SecureZeroBytes(b []byte) {
+	clear(b)
+	// Keep the store from being optimised away as dead
+	runtime.KeepAlive(b)
+}
+
+// secureBufferPool hands out equally sized buffers in guarded memory.
+// Buffers are allocated on first use and reused afterwards, so a small file
+// never pays for the full pipeline window.
+type secureBufferPool struct {
+	size int
+	free chan *[]byte
+
+	mu   sync.Mutex
+	bufs []*SecureBuffer
+}
+
+// newSecureBufferPool creates a pool of at most count buffers of size bytes.
+func newSecureBufferPool(count, size int) *secureBufferPool {
+	return &secureBufferPool{
+		size: size,
+		free: make(chan *[]byte, count),
+	}
+}
+
+// Get returns a free buffer, allocating one if none is available. Callers
+// must not hold more than count buffers at once.
+func (p *secureBufferPool) Get() (*[]byte, error) {
+	select {
+	case b := <-p.free:
+		return b, nil
+	default:
+	}
+
+	buf, err := NewSecureBuffer(p.size)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.bufs = append(p.bufs, buf)
+	p.mu.Unlock()
+
+	b := buf.Bytes()
+	return &b, nil
+}
+
+// Put returns a buffer to the pool.
+func (p *secureBufferPool) Put(b *[]byte) {
+	p.free <- b
+}
+
+// Destroy zeroes and releases all buffers.
+// No buffer may be in use when it is called.
+func (p *secureBufferPool) Destroy() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, buf := range p.bufs {
+		buf.Destroy()
+	}
+	p.bufs = nil
+}
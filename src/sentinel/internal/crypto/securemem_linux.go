@@ -0,0 +1,39 @@
+package crypto
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// secureAlloc maps anonymous private memory for size bytes, rounded up to
+// whole pages, and excludes it from core dumps.
+func secureAlloc(size int) ([]byte, error) {
+	pageSize := unix.Getpagesize()
+	length := (size + pageSize - 1) / pageSize * pageSize
+
+	mem, err := unix.Mmap(-1, 0, length, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := unix.Madvise(mem, unix.MADV_DONTDUMP); err != nil {
+		unix.Munmap(mem)
+		return nil, err
+	}
+
+	return mem, nil
+}
+
+// secureLock locks mem into RAM so it is never swapped.
+func secureLock(mem []byte) error {
+	return unix.Mlock(mem)
+}
+
+// secureUnlock undoes secureLock.
+func secureUnlock(mem []byte) {
+	unix.Munlock(mem)
+}
+
+// secureFree unmaps memory returned by secureAlloc.
+func secureFree(mem []byte) {
+	unix.Munmap(mem)
+}
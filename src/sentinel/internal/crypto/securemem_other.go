@@ -0,0 +1,20 @@
+//go:build !linux
+
+package crypto
+
+import "errors"
+
+// On platforms without mmap/madvise support, guarded buffers fall back to
+// the Go heap. They are still zeroed on Destroy but may be swapped or dumped.
+
+func secureAlloc(size int) ([]byte, error) {
+	return make([]byte, size), nil
+}
+
+func secureLock(mem []byte) error {
+	return errors.New("memory locking not supported on this platform")
+}
+
+func secureUnlock(mem []byte) {}
+
+func secureFree(mem []byte) {}
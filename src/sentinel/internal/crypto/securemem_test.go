@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSecureBuffer_Lifecycle(t *testing.T) {
+	buf, err := NewSecureBuffer(100)
+	if err != nil {
+		t.Fatalf("NewSecureBuffer failed: %v", err)
+	}
+
+	data := buf.Bytes()
+	if len(data) != 100 {
+		t.Fatalf("len(Bytes()) = %d, want 100", len(data))
+	}
+	if !bytes.Equal(data, make([]byte, 100)) {
+		t.Error("new buffer is not zeroed")
+	}
+	copy(data, "secret")
+
+	buf.Destroy()
+	if buf.Bytes() != nil {
+		t.Error("Bytes() after Destroy should be nil")
+	}
+	if buf.Locked() {
+		t.Error("Locked() after Destroy should be false")
+	}
+
+	// Destroy is idempotent
+	buf.Destroy()
+}
+
+func TestNewSecureBuffer_InvalidSize(t *testing.T) {
+	if _, err := NewSecureBuffer(0); err == nil {
+		t.Error("expected error for zero size, got nil")
+	}
+}
+
+func TestNewKey_ZeroesSource(t *testing.T) {
+	raw := bytes.Repeat([]byte{0x5c}, KeySize)
+	want := bytes.Clone(raw)
+
+	key, err := NewKey(raw)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	defer key.Destroy()
+
+	if !bytes.Equal(key.Bytes(), want) {
+		t.Errorf("key = %x, want %x", key.Bytes(), want)
+	}
+	if !bytes.Equal(raw, make([]byte, KeySize)) {
+		t.Error("NewKey did not zero its input")
+	}
+}
+
+func TestNewKey_InvalidLength(t *testing.T) {
+	if _, err := NewKey(make([]byte, 16)); err == nil {
+		t.Error("expected error for 16-byte key, got nil")
+	}
+}
+
+func TestDecodeHexKey(t *testing.T) {
+	src := []byte(strings.Repeat("ab", KeySize))
+
+	key, err := DecodeHexKey(src)
+	if err != nil {
+		t.Fatalf("DecodeHexKey failed: %v", err)
+	}
+	if !bytes.Equal(key.Bytes(), bytes.Repeat([]byte{0xab}, KeySize)) {
+		t.Errorf("key = %x, want all 0xab", key.Bytes())
+	}
+
+	key.Destroy()
+	if key.Bytes() != nil {
+		t.Error("Bytes() after Destroy should be nil")
+	}
+}
+
+func TestKey_Fingerprint(t *testing.T) {
+	a, _ := NewKey(bytes.Repeat([]byte{0x11}, KeySize))
+	defer a.Destroy()
+	same, _ := NewKey(bytes.Repeat([]byte{0x11}, KeySize))
+	defer same.Destroy()
+	other, _ := NewKey(bytes.Repeat([]byte{0x22}, KeySize))
+	defer other.Destroy()
+
+	fp := a.Fingerprint()
+	if len(fp) != 64 {
+		t.Errorf("Fingerprint() = %q, want 64 hex characters", fp)
+	}
+	if same.Fingerprint() != fp {
+		t.Error("fingerprints of equal keys differ")
+	}
+	if other.Fingerprint() == fp {
+		t.Error("fingerprints of different keys are equal")
+	}
+	if strings.Contains(fp, hex.EncodeToString(a.Bytes())) {
+		t.Error("fingerprint contains the key")
+	}
+}
+
+func TestDecodeHexKey_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{"too_short", "abcd"},
+		{"not_hex", strings.Repeat("zz", KeySize)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeHexKey([]byte(tt.src)); err == nil {
+				t.Error("expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestSecureBufferPool_Reuse(t *testing.T) {
+	pool := newSecureBufferPool(2, 64)
+	defer pool.Destroy()
+
+	a, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	b, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(*a) != 64 || len(*b) != 64 {
+		t.Fatalf("buffer sizes = %d, %d, want 64", len(*a), len(*b))
+	}
+
+	pool.Put(a)
+	c, err := pool.Get()
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if c != a {
+		t.Error("Get after Put should reuse the returned buffer")
+	}
+	if len(pool.bufs) != 2 {
+		t.Errorf("allocated %d buffers, want 2", len(pool.bufs))
+	}
+}
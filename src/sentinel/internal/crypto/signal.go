@@ -15,14 +15,39 @@ import (
 // SignalFileMode is the permission mode for the ready signal file.
 const SignalFileMode = 0644
 
+// TokenFileMode is the permission mode for the bearer token file.
+const TokenFileMode = 0600
+
 // SentinelVersion is the version string included in the signal file.
 const SentinelVersion = "0.1.0"
 
+// Ready file types.
+const (
+	ReadyFileFIFO    = "fifo"  // Named pipe, readable once from start to end
+	ReadyFileRegular = "file"  // Regular file in tmpfs
+	ReadyFileMemfd   = "memfd" // Sealed memfd, received from the handoff socket at Path
+	ReadyFileHTTP    = "http"  // URL on the local range server, fetched with the bearer token
+)
+
 // ReadySignal represents the JSON structure of the ready signal file.
 type ReadySignal struct {
-	Ready           bool   `json:"ready"`
-	Timestamp       string `json:"timestamp"`
-	SentinelVersion string `json:"sentinel_version"`
+	Ready           bool        `json:"ready"`
+	Timestamp       string      `json:"timestamp"`
+	SentinelVersion string      `json:"sentinel_version"`
+	ModelDir        string      `json:"model_dir,omitempty"` // Directory holding a multi-file model
+	Files           []ReadyFile `json:"files,omitempty"`     // Decrypted outputs available to the runtime
+
+	// Set when files are served by the local range server
+	HTTPSocket string `json:"http_socket,omitempty"` // Unix socket to dial for the file URLs, if not TCP
+	TokenFile  string `json:"token_file,omitempty"`  // File holding the bearer token for the file URLs
+}
+
+// ReadyFile describes one decrypted output listed in the ready signal.
+type ReadyFile struct {
+	Name           string `json:"name"`            // Name from the manifest
+	Path           string `json:"path"`            // Absolute path of the FIFO, file or handoff socket, or the file URL
+	Type           string `json:"type"`            // ReadyFileFIFO, ReadyFileRegular, ReadyFileMemfd or ReadyFileHTTP
+	PlaintextBytes int64  `json:"plaintext_bytes"` // Size of the decrypted content
 }
 
 // WriteReadySignal creates an atomic ready signal file at the specified path.
@@ -33,6 +58,19 @@ type ReadySignal struct {
 //
 // The parent directory is created if it doesn't exist.
 func WriteReadySignal(path string) error {
+	return WriteReadySignalLayout(path, "", nil)
+}
+
+// WriteReadySignalLayout is like WriteReadySignal but also lists the
+// decrypted files, and the model directory for multi-file assets, so the
+// runtime can discover the layout.
+func WriteReadySignalLayout(path, modelDir string, files []ReadyFile) error {
+	return WriteReadySignalData(path, ReadySignal{ModelDir: modelDir, Files: files})
+}
+
+// WriteReadySignalData writes signal as the ready signal, filling in Ready,
+// Timestamp and SentinelVersion.
+func WriteReadySignalData(path string, signal ReadySignal) error {
 	if path == "" {
 		return fmt.Errorf("signal path cannot be empty")
 	}
@@ -43,11 +81,9 @@ func WriteReadySignal(path string) error {
 	}
 
 	// Create the signal content
-	signal := ReadySignal{
-		Ready:           true,
-		Timestamp:       time.Now().UTC().Format(time.RFC3339),
-		SentinelVersion: SentinelVersion,
-	}
+	signal.Ready = true
+	signal.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	signal.SentinelVersion = SentinelVersion
 
 	data, err := json.MarshalIndent(signal, "", "  ")
 	if err != nil {
@@ -55,7 +91,32 @@ func WriteReadySignal(path string) error {
 	}
 	data = append(data, '\n')
 
-	// Write atomically: create temp file, write, rename
+	if err := writeFileAtomic(path, data, SignalFileMode); err != nil {
+		return fmt.Errorf("failed to write signal file: %w", err)
+	}
+	return nil
+}
+
+// WriteTokenFile atomically writes a bearer token for the runtime next to the
+// ready signal. The file is readable by the owner only.
+func WriteTokenFile(path, token string) error {
+	if path == "" {
+		return fmt.Errorf("token path cannot be empty")
+	}
+
+	if err := EnsureParentDir(path); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	if err := writeFileAtomic(path, []byte(token+"\n"), TokenFileMode); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to path by writing a temporary file in the
+// same directory and renaming it, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
 	dir := filepath.Dir(path)
 	tmpFile, err := os.CreateTemp(dir, ".signal-*.tmp")
 	if err != nil {
@@ -71,16 +132,16 @@ func WriteReadySignal(path string) error {
 		}
 	}()
 
-	// Write content
-	if _, err := tmpFile.Write(data); err != nil {
+	// Set permissions before writing
+	if err := tmpFile.Chmod(mode); err != nil {
 		tmpFile.Close()
-		return fmt.Errorf("failed to write signal data: %w", err)
+		return fmt.Errorf("failed to set file permissions: %w", err)
 	}
 
-	// Set permissions before rename
-	if err := tmpFile.Chmod(SignalFileMode); err != nil {
+	// Write content
+	if _, err := tmpFile.Write(data); err != nil {
 		tmpFile.Close()
-		return fmt.Errorf("failed to set file permissions: %w", err)
+		return fmt.Errorf("failed to write data: %w", err)
 	}
 
 	if err := tmpFile.Close(); err != nil {
@@ -89,7 +150,7 @@ func WriteReadySignal(path string) error {
 
 	// Atomic rename
 	if err := os.Rename(tmpPath, path); err != nil {
-		return fmt.Errorf("failed to rename signal file: %w", err)
+		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
 	cleanup = false // Successful - don't remove
@@ -190,6 +190,100 @@ func TestWriteReadySignal(t *testing.T) {
 	})
 }
 
+func TestWriteReadySignalLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "ready.signal")
+
+	files := []ReadyFile{
+		{Name: "model-00001-of-00002.safetensors", Path: "/dev/shm/model/model-00001-of-00002.safetensors", Type: ReadyFileFIFO, PlaintextBytes: 1 << 30},
+		{Name: "config.json", Path: "/dev/shm/model/config.json", Type: ReadyFileRegular, PlaintextBytes: 700},
+	}
+	if err := WriteReadySignalLayout(path, "/dev/shm/model", files); err != nil {
+		t.Fatalf("WriteReadySignalLayout failed: %v", err)
+	}
+
+	signal, err := ReadReadySignal(path)
+	if err != nil {
+		t.Fatalf("failed to read signal: %v", err)
+	}
+	if !signal.Ready {
+		t.Error("signal.Ready = false, want true")
+	}
+	if signal.ModelDir != "/dev/shm/model" {
+		t.Errorf("signal.ModelDir = %q, want %q", signal.ModelDir, "/dev/shm/model")
+	}
+	if len(signal.Files) != len(files) {
+		t.Fatalf("len(signal.Files) = %d, want %d", len(signal.Files), len(files))
+	}
+	for i := range files {
+		if signal.Files[i] != files[i] {
+			t.Errorf("signal.Files[%d] = %+v, want %+v", i, signal.Files[i], files[i])
+		}
+	}
+
+	// The plain signal must not carry layout fields
+	plainPath := filepath.Join(tmpDir, "plain.signal")
+	if err := WriteReadySignal(plainPath); err != nil {
+		t.Fatalf("WriteReadySignal failed: %v", err)
+	}
+	data, err := os.ReadFile(plainPath)
+	if err != nil {
+		t.Fatalf("failed to read signal file: %v", err)
+	}
+	if strings.Contains(string(data), "files") || strings.Contains(string(data), "model_dir") || strings.Contains(string(data), "token_file") {
+		t.Errorf("plain signal should not include layout, got %s", data)
+	}
+}
+
+func TestWriteReadySignalData_HTTP(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "ready.signal")
+	tokenPath := filepath.Join(tmpDir, "range.token")
+
+	if err := WriteTokenFile(tokenPath, "secret-token"); err != nil {
+		t.Fatalf("WriteTokenFile failed: %v", err)
+	}
+	info, err := os.Stat(tokenPath)
+	if err != nil {
+		t.Fatalf("failed to stat token file: %v", err)
+	}
+	if info.Mode().Perm() != TokenFileMode {
+		t.Errorf("token file mode = %o, want %o", info.Mode().Perm(), TokenFileMode)
+	}
+	data, err := os.ReadFile(tokenPath)
+	if err != nil {
+		t.Fatalf("failed to read token file: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != "secret-token" {
+		t.Errorf("token file = %q, want %q", data, "secret-token")
+	}
+
+	err = WriteReadySignalData(path, ReadySignal{
+		Files: []ReadyFile{
+			{Name: "model.safetensors", Path: "http://localhost/files/model.safetensors", Type: ReadyFileHTTP, PlaintextBytes: 1 << 20},
+		},
+		HTTPSocket: "/dev/shm/range.sock",
+		TokenFile:  tokenPath,
+	})
+	if err != nil {
+		t.Fatalf("WriteReadySignalData failed: %v", err)
+	}
+
+	signal, err := ReadReadySignal(path)
+	if err != nil {
+		t.Fatalf("failed to read signal: %v", err)
+	}
+	if !signal.Ready || signal.SentinelVersion != SentinelVersion || signal.Timestamp == "" {
+		t.Errorf("signal metadata not filled in: %+v", signal)
+	}
+	if signal.HTTPSocket != "/dev/shm/range.sock" || signal.TokenFile != tokenPath {
+		t.Errorf("signal HTTP fields = %q, %q", signal.HTTPSocket, signal.TokenFile)
+	}
+	if len(signal.Files) != 1 || signal.Files[0].Type != ReadyFileHTTP {
+		t.Errorf("signal.Files = %+v", signal.Files)
+	}
+}
+
 func TestRemoveReadySignal(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "signal-remove-test-*")
 	if err != nil {
@@ -0,0 +1,164 @@
+// Package crypto implements tbenc/v1 decryption for TrustBridge.
+//
+// This file provides authenticate-only verification: every chunk tag is
+// checked without writing plaintext anywhere.
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxReportedFailures caps the chunk failures kept in a VerifyResult.
+const maxReportedFailures = 64
+
+var (
+	// ErrChunkAuthFailed indicates one or more chunks failed authentication.
+	ErrChunkAuthFailed = errors.New("tbenc chunk authentication failed")
+
+	// ErrExpectationMismatch indicates the file does not match the expected
+	// layout, typically taken from the manifest.
+	ErrExpectationMismatch = errors.New("tbenc file does not match manifest")
+)
+
+// Expected describes the layout a file must have, typically from its manifest.
+type Expected struct {
+	ChunkBytes      int64  // Plaintext bytes per chunk
+	PlaintextBytes  int64  // Total plaintext length
+	CiphertextBytes int64  // Total file size, header included
+	Key             KeyRef // Key at hand; checked against the header when set
+}
+
+// ChunkFailure is a chunk whose tag did not authenticate.
+type ChunkFailure struct {
+	Index  uint64 // Zero-based chunk index
+	Offset int64  // File offset of the chunk's record
+	Err    error
+}
+
+// VerifyResult summarises an authenticate-only pass over a tbenc file.
+type VerifyResult struct {
+	Header          *Header
+	Chunks          uint64         // Records read
+	FailedChunks    uint64         // Records that failed authentication
+	Failures        []ChunkFailure // First failures, at most maxReportedFailures
+	PlaintextBytes  int64          // Plaintext length declared by the records
+	CiphertextBytes int64          // Bytes read, header included
+}
+
+// VerifyOption configures VerifyAll.
+type VerifyOption func(*verifyConfig)
+
+// verifyConfig holds the configuration for verification.
+type verifyConfig struct {
+	expected *Expected
+}
+
+// WithExpected checks the header, plaintext length and ciphertext size
+// against e. A header that disagrees fails before any chunk is read.
+func WithExpected(e Expected) VerifyOption {
+	return func(c *verifyConfig) {
+		c.expected = &e
+	}
+}
+
+// VerifyAll authenticates every chunk of a tbenc file without emitting plaintext.
+//
+// Chunks are decrypted in place in guarded memory and wiped immediately.
+// Authentication failures do not stop the pass: every failing chunk is
+// counted, and the first ones are reported in the result, with an error
+// wrapping ErrChunkAuthFailed. Framing errors (bad pt_len, truncation,
+// trailing data) stop the pass, since later records cannot be located.
+// With WithExpected, disagreement with the manifest returns an error
+// wrapping ErrExpectationMismatch, and a header naming a key other than
+// Expected.Key one wrapping ErrWrongKeyVersion.
+//
+// The result is returned whenever the header could be parsed, even on error.
+func VerifyAll(r io.Reader, key []byte, opts ...VerifyOption) (*VerifyResult, error) {
+	cfg := &verifyConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	header, err := ParseHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse header: %w", err)
+	}
+	result := &VerifyResult{Header: header, CiphertextBytes: HeaderSize}
+
+	if exp := cfg.expected; exp != nil {
+		if int64(header.ChunkBytes) != exp.ChunkBytes {
+			return result, fmt.Errorf("%w: chunk_bytes %d, header has %d", ErrExpectationMismatch, exp.ChunkBytes, header.ChunkBytes)
+		}
+		if header.Version == VersionV2 && int64(header.PlaintextBytes) != exp.PlaintextBytes {
+			return result, fmt.Errorf("%w: plaintext_bytes %d, header has %d", ErrExpectationMismatch, exp.PlaintextBytes, header.PlaintextBytes)
+		}
+		if err := header.CheckKey(exp.Key); err != nil {
+			return result, err
+		}
+	}
+
+	aead, err := newAEAD(header.Algo, key)
+	if err != nil {
+		return result, err
+	}
+
+	buf, err := NewSecureBuffer(int(header.ChunkBytes) + TagSize)
+	if err != nil {
+		return result, err
+	}
+	defer buf.Destroy()
+
+	for chunkIndex := uint64(0); ; chunkIndex++ {
+		offset := result.CiphertextBytes
+
+		ptLen, err := readRecordLen(r, header, chunkIndex)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, err
+		}
+		storedLen, err := readStoredLen(r, header, chunkIndex, ptLen)
+		if err != nil {
+			return result, err
+		}
+
+		ctWithTag := buf.Bytes()[:int(storedLen)+TagSize]
+		if _, err := io.ReadFull(r, ctWithTag); err != nil {
+			return result, fmt.Errorf("failed to read ciphertext at chunk %d: %w", chunkIndex, err)
+		}
+
+		result.Chunks++
+		result.PlaintextBytes += int64(ptLen)
+		result.CiphertextBytes += header.RecordHeaderSize() + int64(storedLen) + TagSize
+
+		// Decrypt in place and wipe: only the tag check matters, so
+		// compressed chunks are not inflated
+		_, err = openRecord(aead, ctWithTag[:0], header, chunkIndex, ptLen, storedLen, ctWithTag)
+		SecureZeroBytes(ctWithTag)
+		if err != nil {
+			result.FailedChunks++
+			if len(result.Failures) < maxReportedFailures {
+				result.Failures = append(result.Failures, ChunkFailure{Index: chunkIndex, Offset: offset, Err: err})
+			}
+		}
+	}
+
+	if result.FailedChunks > 0 {
+		return result, fmt.Errorf("%w: %d of %d chunks, first at chunk %d (offset %d)",
+			ErrChunkAuthFailed, result.FailedChunks, result.Chunks, result.Failures[0].Index, result.Failures[0].Offset)
+	}
+
+	if exp := cfg.expected; exp != nil {
+		if result.PlaintextBytes != exp.PlaintextBytes {
+			return result, fmt.Errorf("%w: plaintext_bytes %d, file has %d", ErrExpectationMismatch, exp.PlaintextBytes, result.PlaintextBytes)
+		}
+		if result.CiphertextBytes != exp.CiphertextBytes {
+			return result, fmt.Errorf("%w: ciphertext size %d, file has %d", ErrExpectationMismatch, exp.CiphertextBytes, result.CiphertextBytes)
+		}
+	}
+
+	return result, nil
+}
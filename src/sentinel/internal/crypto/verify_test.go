@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestVerifyAll_Valid(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+
+	for _, version := range []uint16{Version, VersionV2} {
+		plaintext := testPlaintext(5*1024 + 17)
+		encrypted := createTestEncryptedFileVersion(t, key, plaintext, 1024, version)
+
+		result, err := VerifyAll(bytes.NewReader(encrypted), key, WithExpected(Expected{
+			ChunkBytes:      1024,
+			PlaintextBytes:  int64(len(plaintext)),
+			CiphertextBytes: int64(len(encrypted)),
+		}))
+		if err != nil {
+			t.Fatalf("v%d: VerifyAll failed: %v", version, err)
+		}
+		if result.Chunks != 6 {
+			t.Errorf("v%d: Chunks = %d, want 6", version, result.Chunks)
+		}
+		if result.PlaintextBytes != int64(len(plaintext)) {
+			t.Errorf("v%d: PlaintextBytes = %d, want %d", version, result.PlaintextBytes, len(plaintext))
+		}
+		if result.CiphertextBytes != int64(len(encrypted)) {
+			t.Errorf("v%d: CiphertextBytes = %d, want %d", version, result.CiphertextBytes, len(encrypted))
+		}
+	}
+}
+
+func TestVerifyAll_ReportsEveryFailedChunk(t *testing.T) {
+	key := bytes.Repeat([]byte{0x22}, 32)
+	encrypted := createTestEncryptedFileVersion(t, key, testPlaintext(4*1024), 1024, VersionV2)
+
+	// Flip one ciphertext byte in chunks 1 and 3
+	record := RecordHeaderSize + 1024 + TagSize
+	encrypted[HeaderSize+1*record+RecordHeaderSize+10] ^= 0xFF
+	encrypted[HeaderSize+3*record+RecordHeaderSize+10] ^= 0xFF
+
+	result, err := VerifyAll(bytes.NewReader(encrypted), key)
+	if !errors.Is(err, ErrChunkAuthFailed) {
+		t.Fatalf("expected ErrChunkAuthFailed, got %v", err)
+	}
+	if result.Chunks != 4 || result.FailedChunks != 2 {
+		t.Fatalf("Chunks/FailedChunks = %d/%d, want 4/2", result.Chunks, result.FailedChunks)
+	}
+	for i, want := range []uint64{1, 3} {
+		f := result.Failures[i]
+		if f.Index != want {
+			t.Errorf("failure %d at chunk %d, want %d", i, f.Index, want)
+		}
+		if wantOffset := int64(HeaderSize + int(want)*record); f.Offset != wantOffset {
+			t.Errorf("failure %d offset = %d, want %d", i, f.Offset, wantOffset)
+		}
+	}
+}
+
+func TestVerifyAll_WrongKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x33}, 32)
+	encrypted := createTestEncryptedFile(t, key, testPlaintext(3*1024), 1024)
+
+	result, err := VerifyAll(bytes.NewReader(encrypted), bytes.Repeat([]byte{0x34}, 32))
+	if !errors.Is(err, ErrChunkAuthFailed) {
+		t.Fatalf("expected ErrChunkAuthFailed, got %v", err)
+	}
+	if result.FailedChunks != result.Chunks {
+		t.Errorf("FailedChunks = %d, want all %d", result.FailedChunks, result.Chunks)
+	}
+}
+
+func TestVerifyAll_ExpectationMismatch(t *testing.T) {
+	key := bytes.Repeat([]byte{0x44}, 32)
+	plaintext := testPlaintext(2*1024 + 5)
+
+	v1 := createTestEncryptedFile(t, key, plaintext, 1024)
+	v2 := createTestEncryptedFileVersion(t, key, plaintext, 1024, VersionV2)
+	exact := func(encrypted []byte) Expected {
+		return Expected{ChunkBytes: 1024, PlaintextBytes: int64(len(plaintext)), CiphertextBytes: int64(len(encrypted))}
+	}
+
+	tests := []struct {
+		name      string
+		encrypted []byte
+		modify    func(e *Expected)
+	}{
+		{"chunk_bytes", v1, func(e *Expected) { e.ChunkBytes = 2048 }},
+		{"v2 header plaintext_bytes", v2, func(e *Expected) { e.PlaintextBytes++ }},
+		{"v1 plaintext_bytes", v1, func(e *Expected) { e.PlaintextBytes-- }},
+		{"ciphertext size", v1, func(e *Expected) { e.CiphertextBytes += 100 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exp := exact(tt.encrypted)
+			tt.modify(&exp)
+			_, err := VerifyAll(bytes.NewReader(tt.encrypted), key, WithExpected(exp))
+			if !errors.Is(err, ErrExpectationMismatch) {
+				t.Errorf("expected ErrExpectationMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAll_Truncated(t *testing.T) {
+	key := bytes.Repeat([]byte{0x55}, 32)
+	encrypted := createTestEncryptedFileVersion(t, key, testPlaintext(3*1024), 1024, VersionV2)
+	truncated := encrypted[:HeaderSize+2*(RecordHeaderSize+1024+TagSize)]
+
+	_, err := VerifyAll(bytes.NewReader(truncated), key)
+	if !errors.Is(err, ErrTruncated) {
+		t.Errorf("expected ErrTruncated, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Receive connects to the handoff socket and returns the files it sends.
+// It is the runtime side of the protocol; the caller owns the returned files.
+func Receive(ctx context.Context, socketPath string) ([]File, error) {
+	var dialer net.Dialer
+	c, err := dialer.DialContext(ctx, "unixpacket", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", socketPath, err)
+	}
+	conn := c.(*net.UnixConn)
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+
+	var files []File
+	closeAll := func() {
+		for _, f := range files {
+			f.File.Close()
+		}
+	}
+
+	buf := make([]byte, maxMessageBytes)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	for {
+		n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+		if err != nil && !errors.Is(err, io.EOF) {
+			closeAll()
+			return nil, fmt.Errorf("failed to receive message: %w", err)
+		}
+		if n == 0 {
+			// Server closed the connection: nothing (more) to hand off
+			if len(files) > 0 {
+				closeAll()
+				return nil, errors.New("handoff ended after a partial file list")
+			}
+			return nil, nil
+		}
+
+		file, err := fileFromControl(oob[:oobn])
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		var msg Message
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			if file != nil {
+				file.Close()
+			}
+			closeAll()
+			return nil, fmt.Errorf("failed to parse message: %w", err)
+		}
+		if msg.Error != "" {
+			if file != nil {
+				file.Close()
+			}
+			closeAll()
+			if msg.Error == ErrPeerDenied.Error() {
+				return nil, ErrPeerDenied
+			}
+			return nil, fmt.Errorf("handoff refused: %s", msg.Error)
+		}
+		if file == nil {
+			closeAll()
+			return nil, fmt.Errorf("message for %s has no descriptor", msg.Name)
+		}
+		if msg.Index != len(files) {
+			file.Close()
+			closeAll()
+			return nil, fmt.Errorf("message for %s has index %d, want %d", msg.Name, msg.Index, len(files))
+		}
+
+		files = append(files, File{Name: msg.Name, PlaintextBytes: msg.PlaintextBytes, File: file})
+		if len(files) == msg.Count {
+			return files, nil
+		}
+	}
+}
+
+// fileFromControl extracts the descriptor passed with a message, if any.
+func fileFromControl(oob []byte) (*os.File, error) {
+	if len(oob) == 0 {
+		return nil, nil
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse control message: %w", err)
+	}
+
+	var fds []int
+	for _, m := range msgs {
+		rights, err := syscall.ParseUnixRights(&m)
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return nil, fmt.Errorf("expected 1 descriptor, got %d", len(fds))
+	}
+
+	syscall.CloseOnExec(fds[0])
+	return os.NewFile(uintptr(fds[0]), "handoff"), nil
+}
@@ -0,0 +1,69 @@
+// Package handoff passes decrypted model files to the runtime as file
+// descriptors over a Unix domain socket.
+//
+// The sentinel decrypts every file into a sealed memfd and listens on a
+// SOCK_SEQPACKET socket. A connecting runtime is checked against a PeerPolicy
+// using the kernel-reported SO_PEERCRED credentials, then receives one
+// message per file: a JSON Message with a read-only descriptor attached via
+// SCM_RIGHTS. Each connection gets descriptors with their own file offset,
+// so a restarted runtime can simply connect again.
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// ErrPeerDenied indicates the connecting process is not allowed by the policy.
+var ErrPeerDenied = errors.New("handoff peer not permitted")
+
+// maxMessageBytes bounds the JSON part of a message.
+const maxMessageBytes = 64 * 1024
+
+// File is a decrypted file handed to the runtime.
+type File struct {
+	Name           string   // Name from the manifest
+	PlaintextBytes int64    // Size of the decrypted content
+	File           *os.File // Read-only descriptor
+}
+
+// Message is the JSON payload of each message sent to the runtime.
+// File messages carry exactly one descriptor; an error message carries none
+// and ends the connection.
+type Message struct {
+	Name           string `json:"name,omitempty"`
+	PlaintextBytes int64  `json:"plaintext_bytes"`
+	Index          int    `json:"index"` // Zero-based position of this file
+	Count          int    `json:"count"` // Number of files in the handoff
+	Error          string `json:"error,omitempty"`
+}
+
+// PeerCred is the identity of a connected process as reported by the kernel.
+type PeerCred struct {
+	PID int
+	UID int
+	GID int
+}
+
+// PeerPolicy decides which processes may receive the model.
+type PeerPolicy struct {
+	UIDs []int // Allowed uids; empty allows only the sentinel's own uid
+	PIDs []int // Allowed pids; empty allows any pid
+}
+
+// Allow returns an error wrapping ErrPeerDenied if cred is not permitted.
+func (p PeerPolicy) Allow(cred PeerCred) error {
+	uids := p.UIDs
+	if len(uids) == 0 {
+		uids = []int{os.Getuid()}
+	}
+	if !slices.Contains(uids, cred.UID) {
+		return fmt.Errorf("%w: uid %d", ErrPeerDenied, cred.UID)
+	}
+	if len(p.PIDs) > 0 && !slices.Contains(p.PIDs, cred.PID) {
+		return fmt.Errorf("%w: pid %d", ErrPeerDenied, cred.PID)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package handoff
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentials returns the SO_PEERCRED credentials of the connected peer.
+// They are recorded by the kernel when the peer connects and cannot be forged.
+func peerCredentials(conn *net.UnixConn) (PeerCred, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCred{}, err
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return PeerCred{}, err
+	}
+	if credErr != nil {
+		return PeerCred{}, fmt.Errorf("SO_PEERCRED failed: %w", credErr)
+	}
+
+	return PeerCred{PID: int(ucred.Pid), UID: int(ucred.Uid), GID: int(ucred.Gid)}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package handoff
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentials is not implemented without SO_PEERCRED, so every peer is
+// refused.
+func peerCredentials(conn *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, errors.New("peer credentials are only supported on Linux")
+}
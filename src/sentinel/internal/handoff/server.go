@@ -0,0 +1,215 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"trustbridge/sentinel/internal/crypto"
+)
+
+// SocketMode is the permission mode of the handoff socket. Any local process
+// may connect; access is decided by the PeerPolicy.
+const SocketMode = 0666
+
+// writeTimeout bounds the time spent sending files to one connection.
+const writeTimeout = 10 * time.Second
+
+// Server hands decrypted files to every permitted process that connects.
+type Server struct {
+	path     string
+	files    []File
+	policy   PeerPolicy
+	logger   *slog.Logger
+	listener *net.UnixListener
+	conns    sync.WaitGroup
+	mu       sync.Mutex
+	started  bool
+}
+
+// ServerOption is a functional option for configuring the Server.
+type ServerOption func(*Server)
+
+// WithPolicy sets the peer policy. The default allows the sentinel's own uid.
+func WithPolicy(policy PeerPolicy) ServerOption {
+	return func(s *Server) {
+		s.policy = policy
+	}
+}
+
+// WithLogger sets the logger for the handoff server.
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer creates a handoff server for files listening at path.
+// The server does not take ownership of the files.
+func NewServer(path string, files []File, opts ...ServerOption) *Server {
+	s := &Server{
+		path:   path,
+		files:  files,
+		logger: slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Path returns the socket path.
+func (s *Server) Path() string {
+	return s.path
+}
+
+// Start listens on the socket and serves connections in a goroutine.
+// A stale socket left at the path is replaced.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("handoff server already started")
+	}
+
+	if err := crypto.EnsureParentDir(s.path); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+	if info, err := os.Lstat(s.path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("refusing to replace non-socket file at %s", s.path)
+		}
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("failed to remove stale socket at %s: %w", s.path, err)
+		}
+	}
+
+	listener, err := net.ListenUnix("unixpacket", &net.UnixAddr{Name: s.path, Net: "unixpacket"})
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.path, err)
+	}
+	if err := os.Chmod(s.path, SocketMode); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	s.listener = listener
+	s.started = true
+
+	go s.serve(listener)
+
+	return nil
+}
+
+// Stop closes the socket and waits for in-flight handoffs to finish.
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return nil
+	}
+	s.started = false
+
+	err := s.listener.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// serve accepts connections until the listener is closed.
+func (s *Server) serve(listener *net.UnixListener) {
+	for {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.logger.Error("Handoff accept failed", "error", err.Error())
+			}
+			return
+		}
+
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle checks the peer and sends it every file.
+func (s *Server) handle(conn *net.UnixConn) {
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+
+	cred, err := peerCredentials(conn)
+	if err != nil {
+		s.logger.Error("Handoff peer credentials unavailable", "error", err.Error())
+		return
+	}
+	logger := s.logger.With("peer_pid", cred.PID, "peer_uid", cred.UID, "peer_gid", cred.GID)
+
+	if err := s.policy.Allow(cred); err != nil {
+		logger.Warn("Handoff denied", "error", err.Error())
+		s.send(conn, Message{Error: ErrPeerDenied.Error()}, nil)
+		return
+	}
+
+	for i, f := range s.files {
+		msg := Message{
+			Name:           f.Name,
+			PlaintextBytes: f.PlaintextBytes,
+			Index:          i,
+			Count:          len(s.files),
+		}
+		if err := s.send(conn, msg, f.File); err != nil {
+			logger.Warn("Handoff aborted", "file", f.Name, "error", err.Error())
+			return
+		}
+	}
+
+	logger.Info("Handoff delivered", "files", len(s.files))
+}
+
+// send writes one message, attaching a fresh read-only descriptor for f.
+func (s *Server) send(conn *net.UnixConn, msg Message, f *os.File) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	var oob []byte
+	if f != nil {
+		// Each receiver gets its own open file and offset
+		ro, err := crypto.ReopenReadOnly(f)
+		if err != nil {
+			return err
+		}
+		defer ro.Close()
+		oob = syscall.UnixRights(int(ro.Fd()))
+	}
+
+	if _, _, err := conn.WriteMsgUnix(data, oob, nil); err != nil {
+		return fmt.Errorf("failed to send %s: %w", msg.Name, err)
+	}
+	return nil
+}
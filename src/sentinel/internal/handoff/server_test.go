@@ -0,0 +1,225 @@
+//go:build linux
+
+package handoff
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startServer starts a handoff server for the given contents and returns
+// its socket path.
+func startServer(t *testing.T, contents map[string]string, order []string, opts ...ServerOption) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	var files []File
+	for _, name := range order {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents[name]), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", name, err)
+		}
+		t.Cleanup(func() { f.Close() })
+		files = append(files, File{Name: name, PlaintextBytes: int64(len(contents[name])), File: f})
+	}
+
+	socketPath := filepath.Join(dir, "handoff.sock")
+	s := NewServer(socketPath, files, opts...)
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(func() { s.Stop(context.Background()) })
+
+	return socketPath
+}
+
+func receive(t *testing.T, socketPath string) ([]File, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return Receive(ctx, socketPath)
+}
+
+func TestServer_DeliversFiles(t *testing.T) {
+	contents := map[string]string{
+		"config.json":       `{"model_type": "llama"}`,
+		"model.safetensors": "weights-weights-weights",
+	}
+	order := []string{"config.json", "model.safetensors"}
+	socketPath := startServer(t, contents, order)
+
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("stat socket failed: %v", err)
+	}
+	if info.Mode().Perm() != SocketMode {
+		t.Errorf("socket mode = %o, want %o", info.Mode().Perm(), SocketMode)
+	}
+
+	// Every connection gets its own descriptors, starting at offset 0
+	for attempt := 0; attempt < 2; attempt++ {
+		files, err := receive(t, socketPath)
+		if err != nil {
+			t.Fatalf("attempt %d: Receive failed: %v", attempt, err)
+		}
+		if len(files) != len(order) {
+			t.Fatalf("attempt %d: got %d files, want %d", attempt, len(files), len(order))
+		}
+
+		for i, f := range files {
+			if f.Name != order[i] {
+				t.Errorf("file %d name = %q, want %q", i, f.Name, order[i])
+			}
+			if f.PlaintextBytes != int64(len(contents[f.Name])) {
+				t.Errorf("%s: PlaintextBytes = %d, want %d", f.Name, f.PlaintextBytes, len(contents[f.Name]))
+			}
+			got, err := io.ReadAll(f.File)
+			if err != nil {
+				t.Fatalf("%s: read failed: %v", f.Name, err)
+			}
+			if string(got) != contents[f.Name] {
+				t.Errorf("%s: content = %q, want %q", f.Name, got, contents[f.Name])
+			}
+			if _, err := f.File.Write([]byte("x")); err == nil {
+				t.Errorf("%s: descriptor is writable", f.Name)
+			}
+			f.File.Close()
+		}
+	}
+}
+
+func TestServer_DeniesUID(t *testing.T) {
+	socketPath := startServer(t, map[string]string{"model": "secret"}, []string{"model"},
+		WithPolicy(PeerPolicy{UIDs: []int{os.Getuid() + 1}}),
+	)
+
+	files, err := receive(t, socketPath)
+	if !errors.Is(err, ErrPeerDenied) {
+		t.Fatalf("expected ErrPeerDenied, got %v", err)
+	}
+	if files != nil {
+		t.Error("files returned to denied peer")
+	}
+}
+
+func TestServer_PIDPolicy(t *testing.T) {
+	allowed := startServer(t, map[string]string{"model": "secret"}, []string{"model"},
+		WithPolicy(PeerPolicy{PIDs: []int{os.Getpid()}}),
+	)
+	files, err := receive(t, allowed)
+	if err != nil {
+		t.Fatalf("Receive failed for allowed pid: %v", err)
+	}
+	files[0].File.Close()
+
+	denied := startServer(t, map[string]string{"model": "secret"}, []string{"model"},
+		WithPolicy(PeerPolicy{PIDs: []int{-1}}),
+	)
+	if _, err := receive(t, denied); !errors.Is(err, ErrPeerDenied) {
+		t.Fatalf("expected ErrPeerDenied, got %v", err)
+	}
+}
+
+func TestServer_NoFiles(t *testing.T) {
+	socketPath := startServer(t, nil, nil)
+
+	files, err := receive(t, socketPath)
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("got %d files, want 0", len(files))
+	}
+}
+
+func TestServer_Start(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("replaces stale socket", func(t *testing.T) {
+		path := filepath.Join(dir, "stale.sock")
+		l, err := net.ListenUnix("unixpacket", &net.UnixAddr{Name: path, Net: "unixpacket"})
+		if err != nil {
+			t.Fatalf("failed to create stale socket: %v", err)
+		}
+		l.SetUnlinkOnClose(false)
+		l.Close()
+
+		s := NewServer(path, nil)
+		if err := s.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		defer s.Stop(context.Background())
+
+		if err := s.Start(); err == nil {
+			t.Error("second Start succeeded")
+		}
+	})
+
+	t.Run("refuses regular file", func(t *testing.T) {
+		path := filepath.Join(dir, "regular")
+		if err := os.WriteFile(path, []byte("data"), 0600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+
+		if err := NewServer(path, nil).Start(); err == nil {
+			t.Fatal("Start replaced a regular file")
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("regular file removed: %v", err)
+		}
+	})
+
+	t.Run("stop removes socket", func(t *testing.T) {
+		path := filepath.Join(dir, "stop.sock")
+		s := NewServer(path, nil)
+		if err := s.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatalf("Stop failed: %v", err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Error("socket still exists after Stop")
+		}
+	})
+}
+
+func TestPeerPolicy_Allow(t *testing.T) {
+	uid := os.Getuid()
+
+	tests := []struct {
+		name   string
+		policy PeerPolicy
+		cred   PeerCred
+		allow  bool
+	}{
+		{"default allows own uid", PeerPolicy{}, PeerCred{PID: 10, UID: uid}, true},
+		{"default denies other uid", PeerPolicy{}, PeerCred{PID: 10, UID: uid + 1}, false},
+		{"listed uid", PeerPolicy{UIDs: []int{1000, 1001}}, PeerCred{PID: 10, UID: 1001}, true},
+		{"unlisted uid", PeerPolicy{UIDs: []int{1000}}, PeerCred{PID: 10, UID: 2000}, false},
+		{"listed pid", PeerPolicy{UIDs: []int{1000}, PIDs: []int{42}}, PeerCred{PID: 42, UID: 1000}, true},
+		{"unlisted pid", PeerPolicy{UIDs: []int{1000}, PIDs: []int{42}}, PeerCred{PID: 43, UID: 1000}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Allow(tt.cred)
+			if tt.allow && err != nil {
+				t.Errorf("Allow() = %v, want nil", err)
+			}
+			if !tt.allow && !errors.Is(err, ErrPeerDenied) {
+				t.Errorf("Allow() = %v, want ErrPeerDenied", err)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@
 // The health server exposes endpoints for Kubernetes probes and status monitoring:
 //   - GET /health    - Liveness probe (200 if Ready, 503 otherwise)
 //   - GET /readiness - Readiness probe (200 if state >= Decrypt)
-//   - GET /status    - JSON status with state, asset_id, and uptime
+//   - GET /status    - JSON status with state, asset_id, uptime and FIFO sessions
 package health
 
 import (
@@ -21,6 +21,7 @@ import (
 type Server struct {
 	machine    *state.Machine
 	addr       string
+	sessions   func() []SessionStatus
 	httpServer *http.Server
 	mu         sync.Mutex
 	started    bool
@@ -36,6 +37,14 @@ func WithAddr(addr string) ServerOption {
 	}
 }
 
+// WithSessionProvider reports FIFO delivery sessions in /status.
+// fn is called on every request and must be safe for concurrent use.
+func WithSessionProvider(fn func() []SessionStatus) ServerOption {
+	return func(s *Server) {
+		s.sessions = fn
+	}
+}
+
 // NewServer creates a new health check server.
 func NewServer(machine *state.Machine, opts ...ServerOption) *Server {
 	s := &Server{
@@ -151,10 +160,23 @@ type StatusResponse struct {
 	StartTime string `json:"start_time"`
 	Ready     bool   `json:"ready"`
 	Suspended bool   `json:"suspended"`
+
+	Sessions []SessionStatus `json:"sessions,omitempty"`
+}
+
+// SessionStatus describes the current delivery session of one FIFO.
+type SessionStatus struct {
+	File         string `json:"file"`
+	Session      int    `json:"session"`
+	State        string `json:"state"`
+	BytesWritten int64  `json:"bytes_written"`
+	StartedAt    string `json:"started_at,omitempty"`
+	Completed    int    `json:"completed"`
+	Aborted      int    `json:"aborted"`
 }
 
 // handleStatus handles the /status endpoint.
-// Returns JSON with current state, asset_id, and uptime.
+// Returns JSON with current state, asset_id, uptime and FIFO sessions.
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -171,6 +193,9 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 		Ready:     s.machine.IsReady(),
 		Suspended: s.machine.IsSuspended(),
 	}
+	if s.sessions != nil {
+		response.Sessions = s.sessions()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -323,6 +324,38 @@ func TestStatusEndpoint_Suspended(t *testing.T) {
 	}
 }
 
+func TestStatusEndpoint_Sessions(t *testing.T) {
+	m := state.New()
+
+	// Without a provider, sessions are omitted
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	rec := httptest.NewRecorder()
+	NewServer(m).Handler().ServeHTTP(rec, req)
+	if strings.Contains(rec.Body.String(), "sessions") {
+		t.Errorf("unexpected sessions in %s", rec.Body.String())
+	}
+
+	s := NewServer(m, WithSessionProvider(func() []SessionStatus {
+		return []SessionStatus{{File: "model.safetensors", Session: 2, State: "streaming", BytesWritten: 4096, Aborted: 1}}
+	}))
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	var response StatusResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode JSON: %v", err)
+	}
+
+	if len(response.Sessions) != 1 {
+		t.Fatalf("len(Response.Sessions) = %d, want 1", len(response.Sessions))
+	}
+	got := response.Sessions[0]
+	if got.File != "model.safetensors" || got.Session != 2 || got.State != "streaming" || got.BytesWritten != 4096 || got.Aborted != 1 {
+		t.Errorf("Response.Sessions[0] = %+v", got)
+	}
+}
+
 func TestStatusEndpoint_MethodNotAllowed(t *testing.T) {
 	m := state.New()
 	s := NewServer(m)
@@ -9,6 +9,8 @@ import (
 	"math/rand"
 	"net/http"
 	"time"
+
+	"trustbridge/sentinel/internal/crypto"
 )
 
 // Default client configuration values.
@@ -28,26 +30,70 @@ type AuthRequest struct {
 	HardwareID    string `json:"hw_id"`
 	Attestation   string `json:"attestation,omitempty"`
 	ClientVersion string `json:"client_version"`
+	PublicKey     string `json:"public_key,omitempty"`   // Ephemeral X25519 public key (base64)
+	KeyWrapAlg    string `json:"key_wrap_alg,omitempty"` // Envelope scheme for the data key
+	KeyID         uint16 `json:"key_id,omitempty"`       // Provider key the asset is encrypted under
+	KeyVersion    uint16 `json:"key_version,omitempty"`  // Rotation of KeyID
 }
 
 // AuthResponse represents the authorization response from the Control Plane.
 type AuthResponse struct {
-	Status           string    `json:"status"`                       // "authorized" or "denied"
-	SASUrl           string    `json:"sas_url,omitempty"`            // SAS URL for model.tbenc
-	ManifestUrl      string    `json:"manifest_url,omitempty"`       // SAS URL for manifest
-	DecryptionKeyHex string    `json:"decryption_key_hex,omitempty"` // 64 hex chars (32 bytes)
-	ExpiresAt        time.Time `json:"expires_at,omitempty"`         // When authorization expires
-	Reason           string    `json:"reason,omitempty"`             // Reason for denial
+	Status           string      `json:"status"`                       // "authorized" or "denied"
+	SASUrl           string      `json:"sas_url,omitempty"`            // SAS URL for model.tbenc
+	ManifestUrl      string      `json:"manifest_url,omitempty"`       // SAS URL for manifest
+	WrappedKey       *WrappedKey `json:"wrapped_key,omitempty"`        // Data key sealed to PublicKey
+	DecryptionKeyHex HexKey      `json:"decryption_key_hex,omitempty"` // Legacy: 64 hex chars (32 bytes)
+	ExpiresAt        time.Time   `json:"expires_at,omitempty"`         // When authorization expires
+	Reason           string      `json:"reason,omitempty"`             // Reason for denial
+	KeyID            uint16      `json:"key_id,omitempty"`             // Provider key of the data key, if reported
+	KeyVersion       uint16      `json:"key_version,omitempty"`        // Rotation of KeyID
+
+	// DecryptionKey is the 32-byte data key in guarded memory, unwrapped from
+	// WrappedKey or, in legacy mode, decoded from DecryptionKeyHex. The caller
+	// owns it and must Destroy it once decryption completes.
+	DecryptionKey *crypto.Key `json:"-"`
+}
+
+// KeyRef returns the reference of the returned key, zero if the Control
+// Plane did not report one.
+func (r *AuthResponse) KeyRef() crypto.KeyRef {
+	return crypto.KeyRef{ID: r.KeyID, Version: r.KeyVersion}
+}
+
+// HexKey is a hex-encoded key in a JSON string. It is kept as bytes rather
+// than a Go string so it can be wiped after decoding.
+type HexKey []byte
+
+// MarshalJSON encodes the key as a JSON string.
+func (h HexKey) MarshalJSON() ([]byte, error) {
+	out := make([]byte, 0, len(h)+2)
+	out = append(out, '"')
+	out = append(out, h...)
+	return append(out, '"'), nil
+}
+
+// UnmarshalJSON copies the hex digits of a JSON string without creating a Go string.
+func (h *HexKey) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*h = nil
+		return nil
+	}
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("decryption_key_hex must be a JSON string")
+	}
+	*h = append((*h)[:0], data[1:len(data)-1]...)
+	return nil
 }
 
 // LicenseClient handles communication with the Control Plane for authorization.
 type LicenseClient struct {
-	endpoint       string
-	httpClient     *http.Client
-	clientVersion  string
-	maxRetries     int
-	initialDelay   time.Duration
-	maxDelay       time.Duration
+	endpoint      string
+	httpClient    *http.Client
+	clientVersion string
+	maxRetries    int
+	initialDelay  time.Duration
+	maxDelay      time.Duration
+	allowPlainKey bool
 }
 
 // LicenseClientOption is a functional option for configuring LicenseClient.
@@ -76,6 +122,15 @@ func WithRetryConfig(maxRetries int, initialDelay, maxDelay time.Duration) Licen
 	}
 }
 
+// WithPlainKeyAllowed enables the legacy mode in which the Control Plane may
+// return the data key unwrapped in decryption_key_hex. When disabled (the
+// default) only a wrapped key is accepted.
+func WithPlainKeyAllowed(allowed bool) LicenseClientOption {
+	return func(c *LicenseClient) {
+		c.allowPlainKey = allowed
+	}
+}
+
 // NewLicenseClient creates a new authorization client.
 func NewLicenseClient(endpoint string, opts ...LicenseClientOption) *LicenseClient {
 	c := &LicenseClient{
@@ -103,16 +158,91 @@ func (c *LicenseClient) Authorize(ctx context.Context, contractID, assetID, hwID
 }
 
 // AuthorizeWithAttestation calls the Control Plane with an optional attestation token.
+//
+// A fresh X25519 key pair is generated for each call and its public key sent
+// in the request; the data key in the response must be wrapped to it unless
+// plain keys are allowed. On success DecryptionKey holds the data key.
 func (c *LicenseClient) AuthorizeWithAttestation(ctx context.Context, contractID, assetID, hwID, attestation string) (*AuthResponse, error) {
-	req := &AuthRequest{
-		ContractID:    contractID,
-		AssetID:       assetID,
-		HardwareID:    hwID,
-		Attestation:   attestation,
-		ClientVersion: c.clientVersion,
+	return c.authorize(ctx, &AuthRequest{
+		ContractID:  contractID,
+		AssetID:     assetID,
+		HardwareID:  hwID,
+		Attestation: attestation,
+	})
+}
+
+// AuthorizeForKey requests the data key named by key, as recorded in the
+// asset's manifest and tbenc headers. If the Control Plane reports a
+// different key, the returned error wraps crypto.ErrWrongKeyVersion.
+func (c *LicenseClient) AuthorizeForKey(ctx context.Context, contractID, assetID, hwID string, key crypto.KeyRef) (*AuthResponse, error) {
+	return c.authorize(ctx, &AuthRequest{
+		ContractID: contractID,
+		AssetID:    assetID,
+		HardwareID: hwID,
+		KeyID:      key.ID,
+		KeyVersion: key.Version,
+	})
+}
+
+// authorize completes req with the client version and a fresh key pair,
+// sends it and resolves the data key.
+func (c *LicenseClient) authorize(ctx context.Context, req *AuthRequest) (*AuthResponse, error) {
+	keyPair, err := GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("authorize: %w", err)
+	}
+
+	req.ClientVersion = c.clientVersion
+	req.PublicKey = keyPair.PublicKey()
+	req.KeyWrapAlg = KeyWrapAlgorithm
+
+	resp, err := c.doWithRetry(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.resolveKey(resp, keyPair, req); err != nil {
+		return nil, err
+	}
+
+	requested := crypto.KeyRef{ID: req.KeyID, Version: req.KeyVersion}
+	if got := resp.KeyRef(); !requested.IsZero() && !got.IsZero() && got != requested {
+		resp.DecryptionKey.Destroy()
+		resp.DecryptionKey = nil
+		return nil, fmt.Errorf("authorize: %w: requested %s, control plane returned %s", crypto.ErrWrongKeyVersion, requested, got)
 	}
 
-	return c.doWithRetry(ctx, req)
+	return resp, nil
+}
+
+// resolveKey sets resp.DecryptionKey from the wrapped key, or from the plain
+// key when legacy mode is enabled.
+func (c *LicenseClient) resolveKey(resp *AuthResponse, keyPair *KeyPair, req *AuthRequest) error {
+	// The hex key is never needed again once decoded
+	defer func() {
+		crypto.SecureZeroBytes(resp.DecryptionKeyHex)
+		resp.DecryptionKeyHex = nil
+	}()
+
+	if resp.WrappedKey != nil {
+		key, err := keyPair.Unwrap(resp.WrappedKey, req.ContractID, req.AssetID)
+		if err != nil {
+			return fmt.Errorf("authorize: %w", err)
+		}
+		resp.DecryptionKey = key
+		return nil
+	}
+
+	if !c.allowPlainKey {
+		return fmt.Errorf("authorize: %w: control plane returned decryption_key_hex but plain keys are not allowed", ErrPlainKeyRejected)
+	}
+
+	key, err := crypto.DecodeHexKey(resp.DecryptionKeyHex)
+	if err != nil {
+		return fmt.Errorf("authorize: %w: decryption_key_hex: %v", ErrInvalidResponse, err)
+	}
+	resp.DecryptionKey = key
+	return nil
 }
 
 // doWithRetry executes the request with exponential backoff retry logic.
@@ -192,7 +322,8 @@ func (c *LicenseClient) doRequest(ctx context.Context, req *AuthRequest) (*AuthR
 	// Handle HTTP status codes
 	switch httpResp.StatusCode {
 	case http.StatusOK:
-		// Success - parse response
+		// Success - parse response, then wipe the body since it may hold a key
+		defer crypto.SecureZeroBytes(respBody)
 		return c.parseSuccessResponse(respBody)
 
 	case http.StatusUnauthorized, http.StatusForbidden:
@@ -252,8 +383,8 @@ func (c *LicenseClient) parseSuccessResponse(body []byte) (*AuthResponse, error)
 	if resp.SASUrl == "" {
 		return nil, fmt.Errorf("authorize: %w: sas_url", ErrMissingRequiredField)
 	}
-	if resp.DecryptionKeyHex == "" {
-		return nil, fmt.Errorf("authorize: %w: decryption_key_hex", ErrMissingRequiredField)
+	if resp.WrappedKey == nil && len(resp.DecryptionKeyHex) == 0 {
+		return nil, fmt.Errorf("authorize: %w: wrapped_key", ErrMissingRequiredField)
 	}
 
 	return &resp, nil
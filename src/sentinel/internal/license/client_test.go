@@ -1,7 +1,9 @@
 package license
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -10,8 +12,13 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"trustbridge/sentinel/internal/crypto"
 )
 
+// testDataKey is the data key the mock control planes wrap for the client.
+var testDataKey = bytes.Repeat([]byte{0x42}, 32)
+
 func TestAuthorize_Success(t *testing.T) {
 	// Set up mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -37,14 +44,22 @@ func TestAuthorize_Success(t *testing.T) {
 		if req.AssetID != "asset-456" {
 			t.Errorf("AssetID = %q, want asset-456", req.AssetID)
 		}
+		if req.KeyWrapAlg != KeyWrapAlgorithm {
+			t.Errorf("KeyWrapAlg = %q, want %q", req.KeyWrapAlg, KeyWrapAlgorithm)
+		}
+
+		wrapped, err := WrapKey(req.PublicKey, testDataKey, req.ContractID, req.AssetID)
+		if err != nil {
+			t.Errorf("WrapKey() error = %v", err)
+		}
 
 		// Return success response
 		resp := AuthResponse{
-			Status:           "authorized",
-			SASUrl:           "https://storage.example.com/model.tbenc?sv=sig",
-			ManifestUrl:      "https://storage.example.com/manifest.json?sv=sig",
-			DecryptionKeyHex: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
-			ExpiresAt:        time.Now().Add(time.Hour),
+			Status:      "authorized",
+			SASUrl:      "https://storage.example.com/model.tbenc?sv=sig",
+			ManifestUrl: "https://storage.example.com/manifest.json?sv=sig",
+			WrappedKey:  wrapped,
+			ExpiresAt:   time.Now().Add(time.Hour),
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -67,8 +82,82 @@ func TestAuthorize_Success(t *testing.T) {
 	if resp.ManifestUrl == "" {
 		t.Error("ManifestUrl is empty")
 	}
-	if resp.DecryptionKeyHex == "" {
-		t.Error("DecryptionKeyHex is empty")
+	if !bytes.Equal(resp.DecryptionKey.Bytes(), testDataKey) {
+		t.Errorf("DecryptionKey = %x, want %x", resp.DecryptionKey.Bytes(), testDataKey)
+	}
+}
+
+func TestAuthorize_PlainKeyRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := AuthResponse{
+			Status:           "authorized",
+			SASUrl:           "https://storage.example.com/model.tbenc",
+			DecryptionKeyHex: HexKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewLicenseClient(server.URL)
+	_, err := client.Authorize(context.Background(), "contract-123", "asset-456", "hw-789")
+
+	if !errors.Is(err, ErrPlainKeyRejected) {
+		t.Fatalf("Authorize() error = %v, want ErrPlainKeyRejected", err)
+	}
+}
+
+func TestAuthorize_PlainKeyAllowed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := AuthResponse{
+			Status:           "authorized",
+			SASUrl:           "https://storage.example.com/model.tbenc",
+			DecryptionKeyHex: HexKey(hex.EncodeToString(testDataKey)),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewLicenseClient(server.URL, WithPlainKeyAllowed(true))
+	resp, err := client.Authorize(context.Background(), "contract-123", "asset-456", "hw-789")
+
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if !bytes.Equal(resp.DecryptionKey.Bytes(), testDataKey) {
+		t.Errorf("DecryptionKey = %x, want %x", resp.DecryptionKey.Bytes(), testDataKey)
+	}
+	if resp.DecryptionKeyHex != nil {
+		t.Error("DecryptionKeyHex should be wiped after decoding")
+	}
+}
+
+func TestAuthorize_WrappedKeyForOtherAsset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req AuthRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		wrapped, err := WrapKey(req.PublicKey, testDataKey, req.ContractID, "other-asset")
+		if err != nil {
+			t.Errorf("WrapKey() error = %v", err)
+		}
+
+		resp := AuthResponse{
+			Status:     "authorized",
+			SASUrl:     "https://storage.example.com/model.tbenc",
+			WrappedKey: wrapped,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewLicenseClient(server.URL)
+	_, err := client.Authorize(context.Background(), "contract-123", "asset-456", "hw-789")
+
+	if !errors.Is(err, ErrKeyUnwrapFailed) {
+		t.Fatalf("Authorize() error = %v, want ErrKeyUnwrapFailed", err)
 	}
 }
 
@@ -168,7 +257,7 @@ func TestAuthorize_Retry_500(t *testing.T) {
 		resp := AuthResponse{
 			Status:           "authorized",
 			SASUrl:           "https://storage.example.com/model.tbenc",
-			DecryptionKeyHex: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			DecryptionKeyHex: HexKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -176,7 +265,7 @@ func TestAuthorize_Retry_500(t *testing.T) {
 	defer server.Close()
 
 	// Use short delays for testing
-	client := NewLicenseClient(server.URL, WithRetryConfig(3, 10*time.Millisecond, 100*time.Millisecond))
+	client := NewLicenseClient(server.URL, WithRetryConfig(3, 10*time.Millisecond, 100*time.Millisecond), WithPlainKeyAllowed(true))
 	resp, err := client.Authorize(context.Background(), "contract-123", "asset-456", "hw-789")
 
 	if err != nil {
@@ -279,7 +368,7 @@ func TestAuthorize_MissingFields(t *testing.T) {
 			name: "missing_sas_url",
 			response: AuthResponse{
 				Status:           "authorized",
-				DecryptionKeyHex: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+				DecryptionKeyHex: HexKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
 			},
 			wantErr: "sas_url",
 		},
@@ -289,7 +378,7 @@ func TestAuthorize_MissingFields(t *testing.T) {
 				Status: "authorized",
 				SASUrl: "https://storage.example.com/model.tbenc",
 			},
-			wantErr: "decryption_key_hex",
+			wantErr: "wrapped_key",
 		},
 	}
 
@@ -330,7 +419,7 @@ func TestAuthorize_ExpiresAtParsing(t *testing.T) {
 	}))
 	defer server.Close()
 
-	client := NewLicenseClient(server.URL)
+	client := NewLicenseClient(server.URL, WithPlainKeyAllowed(true))
 	resp, err := client.Authorize(context.Background(), "contract-123", "asset-456", "hw-789")
 
 	if err != nil {
@@ -380,14 +469,14 @@ func TestAuthorize_RateLimited(t *testing.T) {
 		resp := AuthResponse{
 			Status:           "authorized",
 			SASUrl:           "https://storage.example.com/model.tbenc",
-			DecryptionKeyHex: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			DecryptionKeyHex: HexKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 	}))
 	defer server.Close()
 
-	client := NewLicenseClient(server.URL, WithRetryConfig(3, 10*time.Millisecond, 100*time.Millisecond))
+	client := NewLicenseClient(server.URL, WithRetryConfig(3, 10*time.Millisecond, 100*time.Millisecond), WithPlainKeyAllowed(true))
 	resp, err := client.Authorize(context.Background(), "contract-123", "asset-456", "hw-789")
 
 	if err != nil {
@@ -436,14 +525,14 @@ func TestAuthorizeWithAttestation(t *testing.T) {
 		resp := AuthResponse{
 			Status:           "authorized",
 			SASUrl:           "https://storage.example.com/model.tbenc",
-			DecryptionKeyHex: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+			DecryptionKeyHex: HexKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"),
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
 	}))
 	defer server.Close()
 
-	client := NewLicenseClient(server.URL)
+	client := NewLicenseClient(server.URL, WithPlainKeyAllowed(true))
 	_, err := client.AuthorizeWithAttestation(
 		context.Background(),
 		"contract-123",
@@ -457,6 +546,51 @@ func TestAuthorizeWithAttestation(t *testing.T) {
 	}
 }
 
+func TestAuthorizeForKey(t *testing.T) {
+	// The mock holds key 7 version 3 and reports it in every response
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req AuthRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+			return
+		}
+		if req.KeyID != 7 {
+			t.Errorf("KeyID = %d, want 7", req.KeyID)
+		}
+
+		wrapped, _ := WrapKey(req.PublicKey, testDataKey, req.ContractID, req.AssetID)
+		resp := AuthResponse{
+			Status:     "authorized",
+			SASUrl:     "https://storage.example.com/model.tbenc",
+			WrappedKey: wrapped,
+			KeyID:      7,
+			KeyVersion: 3,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewLicenseClient(server.URL)
+
+	resp, err := client.AuthorizeForKey(context.Background(), "contract-123", "asset-456", "hw-789", crypto.KeyRef{ID: 7, Version: 3})
+	if err != nil {
+		t.Fatalf("AuthorizeForKey() error = %v", err)
+	}
+	if resp.KeyRef() != (crypto.KeyRef{ID: 7, Version: 3}) {
+		t.Errorf("KeyRef() = %+v, want key 7 version 3", resp.KeyRef())
+	}
+	resp.DecryptionKey.Destroy()
+
+	_, err = client.AuthorizeForKey(context.Background(), "contract-123", "asset-456", "hw-789", crypto.KeyRef{ID: 7, Version: 2})
+	if !errors.Is(err, crypto.ErrWrongKeyVersion) {
+		t.Fatalf("AuthorizeForKey() error = %v, want ErrWrongKeyVersion", err)
+	}
+	if !strings.Contains(err.Error(), "key 7 version 2") || !strings.Contains(err.Error(), "key 7 version 3") {
+		t.Errorf("error should name both key versions, got: %v", err)
+	}
+}
+
 func TestNewLicenseClient_Options(t *testing.T) {
 	customClient := &http.Client{Timeout: 60 * time.Second}
 
@@ -22,6 +22,16 @@ var (
 
 	// ErrMissingRequiredField indicates a required field was missing from the response.
 	ErrMissingRequiredField = errors.New("missing required field in response")
+
+	// ErrPlainKeyRejected indicates the Control Plane sent an unwrapped data key
+	// while legacy plain-key delivery is disabled.
+	ErrPlainKeyRejected = errors.New("plain decryption key rejected")
+
+	// ErrUnsupportedKeyWrap indicates the wrapped key uses an unknown algorithm.
+	ErrUnsupportedKeyWrap = errors.New("unsupported key wrap algorithm")
+
+	// ErrKeyUnwrapFailed indicates the wrapped key could not be decrypted.
+	ErrKeyUnwrapFailed = errors.New("failed to unwrap decryption key")
 )
 
 // AuthError represents an authorization-specific error with additional context.
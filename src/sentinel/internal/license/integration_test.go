@@ -1,6 +1,7 @@
 package license_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -20,6 +21,8 @@ import (
 // 3. Call authorization endpoint
 // 4. Parse and validate response
 func TestFullAuthorizationFlow(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{0x5a}, 32)
+
 	// Set up mock control plane
 	mockControlPlane := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Verify request path and method
@@ -57,13 +60,22 @@ func TestFullAuthorizationFlow(t *testing.T) {
 			t.Error("ClientVersion is empty")
 		}
 
+		if req.PublicKey == "" {
+			t.Error("PublicKey is empty")
+		}
+
+		wrapped, err := license.WrapKey(req.PublicKey, dataKey, req.ContractID, req.AssetID)
+		if err != nil {
+			t.Errorf("WrapKey() error = %v", err)
+		}
+
 		// Return authorized response
 		resp := map[string]interface{}{
-			"status":             "authorized",
-			"sas_url":            "https://storage.example.com/model.tbenc?sv=sig",
-			"manifest_url":       "https://storage.example.com/manifest.json?sv=sig",
-			"decryption_key_hex": "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
-			"expires_at":         time.Now().Add(time.Hour).Format(time.RFC3339),
+			"status":       "authorized",
+			"sas_url":      "https://storage.example.com/model.tbenc?sv=sig",
+			"manifest_url": "https://storage.example.com/manifest.json?sv=sig",
+			"wrapped_key":  wrapped,
+			"expires_at":   time.Now().Add(time.Hour).Format(time.RFC3339),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -121,11 +133,8 @@ func TestFullAuthorizationFlow(t *testing.T) {
 	if resp.ManifestUrl == "" {
 		t.Error("ManifestUrl is empty")
 	}
-	if resp.DecryptionKeyHex == "" {
-		t.Error("DecryptionKeyHex is empty")
-	}
-	if len(resp.DecryptionKeyHex) != 64 {
-		t.Errorf("DecryptionKeyHex length = %d, want 64", len(resp.DecryptionKeyHex))
+	if !bytes.Equal(resp.DecryptionKey.Bytes(), dataKey) {
+		t.Errorf("DecryptionKey = %x, want %x", resp.DecryptionKey.Bytes(), dataKey)
 	}
 	if resp.ExpiresAt.IsZero() {
 		t.Error("ExpiresAt is zero")
@@ -311,7 +320,7 @@ func TestE2EWithMockedDMIAndControlPlane(t *testing.T) {
 	}
 
 	// Authorize
-	client := license.NewLicenseClient(cfg.EDCEndpoint)
+	client := license.NewLicenseClient(cfg.EDCEndpoint, license.WithPlainKeyAllowed(true))
 	resp, err := client.Authorize(context.Background(), cfg.ContractID, cfg.AssetID, fp.ID)
 	if err != nil {
 		t.Fatalf("Authorize() error = %v", err)
@@ -322,7 +331,7 @@ func TestE2EWithMockedDMIAndControlPlane(t *testing.T) {
 		t.Errorf("Status = %q, want authorized", resp.Status)
 	}
 
-	t.Logf("E2E test passed: authorized with key %s...", resp.DecryptionKeyHex[:16])
+	t.Logf("E2E test passed: authorized with key %x...", resp.DecryptionKey.Bytes()[:8])
 }
 
 // clearConfigEnv removes all TB_ environment variables.
@@ -0,0 +1,170 @@
+package license
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"trustbridge/sentinel/internal/crypto"
+)
+
+// KeyWrapAlgorithm identifies the envelope scheme used to deliver data keys.
+//
+// The Control Plane generates its own ephemeral X25519 key pair, computes the
+// shared secret with the sentinel's public key, derives a 32-byte wrapping key
+// with HKDF-SHA256 (salt = control plane public key || sentinel public key,
+// info = keyWrapInfo) and seals the data key with AES-256-GCM. The AAD binds
+// the wrapped key to the contract and asset it was issued for.
+const KeyWrapAlgorithm = "X25519-HKDF-SHA256-A256GCM"
+
+// keyWrapInfo is the HKDF info string for KeyWrapAlgorithm.
+const keyWrapInfo = "trustbridge/key-wrap/v1"
+
+// WrappedKey is a data key sealed to the sentinel's ephemeral public key.
+// Binary fields are standard base64.
+type WrappedKey struct {
+	Algorithm          string `json:"alg"`        // Must be KeyWrapAlgorithm
+	EphemeralPublicKey string `json:"epk"`        // Control plane X25519 public key (32 bytes)
+	Nonce              string `json:"nonce"`      // AES-GCM nonce (12 bytes)
+	Ciphertext         string `json:"ciphertext"` // Sealed data key with GCM tag
+}
+
+// KeyPair is an ephemeral X25519 key pair generated for one authorization.
+// The private key never leaves the process.
+type KeyPair struct {
+	private *ecdh.PrivateKey
+}
+
+// GenerateKeyPair creates a new ephemeral X25519 key pair.
+func GenerateKeyPair() (*KeyPair, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	return &KeyPair{private: private}, nil
+}
+
+// PublicKey returns the base64-encoded public key sent in AuthRequest.
+func (k *KeyPair) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.private.PublicKey().Bytes())
+}
+
+// Unwrap recovers the data key from a WrappedKey issued for contractID and assetID.
+// The key is decrypted directly into guarded memory; the caller must Destroy it.
+func (k *KeyPair) Unwrap(w *WrappedKey, contractID, assetID string) (*crypto.Key, error) {
+	if w.Algorithm != KeyWrapAlgorithm {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedKeyWrap, w.Algorithm)
+	}
+
+	epkBytes, err := base64.StdEncoding.DecodeString(w.EphemeralPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid epk: %v", ErrKeyUnwrapFailed, err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(w.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid nonce: %v", ErrKeyUnwrapFailed, err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(w.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ciphertext: %v", ErrKeyUnwrapFailed, err)
+	}
+
+	epk, err := ecdh.X25519().NewPublicKey(epkBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid epk: %v", ErrKeyUnwrapFailed, err)
+	}
+
+	gcm, err := newWrapAEAD(k.private, epk, epk, k.private.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: nonce must be %d bytes, got %d", ErrKeyUnwrapFailed, gcm.NonceSize(), len(nonce))
+	}
+
+	if len(ciphertext) != crypto.KeySize+gcm.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext must be %d bytes, got %d", ErrKeyUnwrapFailed, crypto.KeySize+gcm.Overhead(), len(ciphertext))
+	}
+
+	dataKey, err := crypto.AllocKey()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gcm.Open(dataKey.Bytes()[:0], nonce, ciphertext, keyWrapAAD(contractID, assetID)); err != nil {
+		dataKey.Destroy()
+		return nil, fmt.Errorf("%w: authentication failed", ErrKeyUnwrapFailed)
+	}
+
+	return dataKey, nil
+}
+
+// WrapKey seals dataKey to a sentinel public key. It is the Control Plane
+// side of KeyWrapAlgorithm, used by tests and mock servers.
+func WrapKey(sentinelPublicKey string, dataKey []byte, contractID, assetID string) (*WrappedKey, error) {
+	pubBytes, err := base64.StdEncoding.DecodeString(sentinelPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(pubBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	gcm, err := newWrapAEAD(ephemeral, recipient, ephemeral.PublicKey(), recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return &WrappedKey{
+		Algorithm:          KeyWrapAlgorithm,
+		EphemeralPublicKey: base64.StdEncoding.EncodeToString(ephemeral.PublicKey().Bytes()),
+		Nonce:              base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext:         base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, dataKey, keyWrapAAD(contractID, assetID))),
+	}, nil
+}
+
+// newWrapAEAD derives the wrapping key from the X25519 shared secret between
+// private and peer. Both sides salt HKDF with the control plane's ephemeral
+// public key followed by the sentinel's public key.
+func newWrapAEAD(private *ecdh.PrivateKey, peer, cpPublic, sentinelPublic *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key agreement failed: %v", ErrKeyUnwrapFailed, err)
+	}
+	defer crypto.SecureZeroBytes(shared)
+
+	salt := append(cpPublic.Bytes(), sentinelPublic.Bytes()...)
+
+	wrapKey, err := hkdf.Key(sha256.New, shared, salt, keyWrapInfo, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wrapping key: %w", err)
+	}
+	defer crypto.SecureZeroBytes(wrapKey)
+
+	block, err := aes.NewCipher(wrapKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyWrapAAD binds a wrapped key to its contract and asset.
+func keyWrapAAD(contractID, assetID string) []byte {
+	return []byte(keyWrapInfo + "\x00" + contractID + "\x00" + assetID)
+}
@@ -0,0 +1,93 @@
+package license
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWrapKey_RoundTrip(t *testing.T) {
+	keyPair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+
+	dataKey := bytes.Repeat([]byte{0x11}, 32)
+	wrapped, err := WrapKey(keyPair.PublicKey(), dataKey, "contract-1", "asset-1")
+	if err != nil {
+		t.Fatalf("WrapKey() error = %v", err)
+	}
+	if wrapped.Algorithm != KeyWrapAlgorithm {
+		t.Errorf("Algorithm = %q, want %q", wrapped.Algorithm, KeyWrapAlgorithm)
+	}
+
+	got, err := keyPair.Unwrap(wrapped, "contract-1", "asset-1")
+	if err != nil {
+		t.Fatalf("Unwrap() error = %v", err)
+	}
+	defer got.Destroy()
+	if !bytes.Equal(got.Bytes(), dataKey) {
+		t.Errorf("Unwrap() = %x, want %x", got.Bytes(), dataKey)
+	}
+}
+
+func TestUnwrap_Failures(t *testing.T) {
+	keyPair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	otherPair, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+
+	dataKey := bytes.Repeat([]byte{0x22}, 32)
+	wrapped, err := WrapKey(keyPair.PublicKey(), dataKey, "contract-1", "asset-1")
+	if err != nil {
+		t.Fatalf("WrapKey() error = %v", err)
+	}
+
+	badAlg := *wrapped
+	badAlg.Algorithm = "RSA-OAEP"
+
+	badNonce := *wrapped
+	badNonce.Nonce = "AAAA"
+
+	tests := []struct {
+		name       string
+		keyPair    *KeyPair
+		wrapped    *WrappedKey
+		contractID string
+		assetID    string
+		wantErr    error
+	}{
+		{"wrong_key_pair", otherPair, wrapped, "contract-1", "asset-1", ErrKeyUnwrapFailed},
+		{"wrong_contract", keyPair, wrapped, "contract-2", "asset-1", ErrKeyUnwrapFailed},
+		{"wrong_asset", keyPair, wrapped, "contract-1", "asset-2", ErrKeyUnwrapFailed},
+		{"bad_nonce", keyPair, &badNonce, "contract-1", "asset-1", ErrKeyUnwrapFailed},
+		{"unsupported_alg", keyPair, &badAlg, "contract-1", "asset-1", ErrUnsupportedKeyWrap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.keyPair.Unwrap(tt.wrapped, tt.contractID, tt.assetID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Unwrap() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateKeyPair_Unique(t *testing.T) {
+	a, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	b, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() error = %v", err)
+	}
+	if a.PublicKey() == b.PublicKey() {
+		t.Error("GenerateKeyPair() returned the same public key twice")
+	}
+}
@@ -0,0 +1,104 @@
+package license
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Integrity report values.
+const (
+	reportPath = "/api/v1/license/report"
+
+	// ReportPlaintextHashMismatch is the kind of report sent when the
+	// decrypted plaintext does not match the manifest's sha256_plaintext.
+	ReportPlaintextHashMismatch = "plaintext_hash_mismatch"
+)
+
+// IntegrityReport tells the Control Plane that a delivered asset failed an
+// integrity check, so the provider can investigate the published asset.
+type IntegrityReport struct {
+	ContractID    string    `json:"contract_id"`
+	AssetID       string    `json:"asset_id"`
+	HardwareID    string    `json:"hw_id"`
+	ClientVersion string    `json:"client_version"`
+	Kind          string    `json:"kind"`                  // e.g. ReportPlaintextHashMismatch
+	Expected      string    `json:"expected,omitempty"`    // Hash recorded in the manifest
+	Actual        string    `json:"actual,omitempty"`      // Hash of the delivered data
+	KeyID         uint16    `json:"key_id,omitempty"`      // Key the asset was decrypted with
+	KeyVersion    uint16    `json:"key_version,omitempty"` // Rotation of KeyID
+	Detail        string    `json:"detail,omitempty"`      // Error message
+	DetectedAt    time.Time `json:"detected_at"`
+}
+
+// ReportIntegrityFailure sends report to the Control Plane. ClientVersion is
+// filled in, and DetectedAt if unset. Network and server errors are retried
+// like authorization; any other non-2xx status fails immediately.
+func (c *LicenseClient) ReportIntegrityFailure(ctx context.Context, report *IntegrityReport) error {
+	report.ClientVersion = c.clientVersion
+	if report.DetectedAt.IsZero() {
+		report.DetectedAt = time.Now().UTC()
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("report: failed to marshal request: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("report: context cancelled: %w", ctx.Err())
+			case <-time.After(c.calculateBackoff(attempt)):
+			}
+		}
+
+		lastErr = c.sendReport(ctx, body)
+		if lastErr == nil {
+			return nil
+		}
+		var authErr *AuthError
+		if isAuthError(lastErr, &authErr) && !authErr.Retryable {
+			return lastErr
+		}
+	}
+
+	return fmt.Errorf("report: %w: %v", ErrMaxRetriesExceeded, lastErr)
+}
+
+// sendReport posts one integrity report.
+func (c *LicenseClient) sendReport(ctx context.Context, body []byte) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint+reportPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("report: failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return NewAuthNetworkError(fmt.Errorf("request failed: %w", err))
+	}
+	defer httpResp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+
+	switch {
+	case httpResp.StatusCode >= 200 && httpResp.StatusCode < 300:
+		return nil
+	case httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode >= 500:
+		return NewAuthServerError(httpResp.StatusCode, fmt.Errorf("server error: %s", string(respBody)))
+	default:
+		return &AuthError{
+			StatusCode: httpResp.StatusCode,
+			Status:     "error",
+			Reason:     string(respBody),
+			Retryable:  false,
+			Err:        ErrInvalidResponse,
+		}
+	}
+}
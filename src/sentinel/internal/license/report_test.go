@@ -0,0 +1,79 @@
+package license
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReportIntegrityFailure(t *testing.T) {
+	var attempts int32
+	var got IntegrityReport
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != reportPath || r.Method != "POST" {
+			t.Errorf("request = %s %s, want POST %s", r.Method, r.URL.Path, reportPath)
+		}
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode report: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	client := NewLicenseClient(server.URL,
+		WithClientVersion("sentinel/test"),
+		WithRetryConfig(3, 10*time.Millisecond, 100*time.Millisecond),
+	)
+	err := client.ReportIntegrityFailure(context.Background(), &IntegrityReport{
+		ContractID: "contract-123",
+		AssetID:    "asset-456",
+		HardwareID: "hw-789",
+		Kind:       ReportPlaintextHashMismatch,
+		Expected:   "aa",
+		Actual:     "bb",
+	})
+	if err != nil {
+		t.Fatalf("ReportIntegrityFailure() error = %v", err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("attempts = %d, want 2 (retry after 503)", attempts)
+	}
+	if got.Kind != ReportPlaintextHashMismatch || got.Expected != "aa" || got.Actual != "bb" {
+		t.Errorf("report = %+v", got)
+	}
+	if got.ClientVersion != "sentinel/test" {
+		t.Errorf("ClientVersion = %q, want sentinel/test", got.ClientVersion)
+	}
+	if got.DetectedAt.IsZero() {
+		t.Error("DetectedAt not set")
+	}
+}
+
+func TestReportIntegrityFailure_NotRetried(t *testing.T) {
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewLicenseClient(server.URL, WithRetryConfig(3, 10*time.Millisecond, 100*time.Millisecond))
+	err := client.ReportIntegrityFailure(context.Background(), &IntegrityReport{Kind: ReportPlaintextHashMismatch})
+	if err == nil {
+		t.Fatal("ReportIntegrityFailure() succeeded on 404")
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1", attempts)
+	}
+}
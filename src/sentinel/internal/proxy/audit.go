@@ -27,6 +27,10 @@ type AuditEntry struct {
 	ReqSHA256  string `json:"req_sha256"`
 	Status     int    `json:"status"`
 	LatencyMS  int64  `json:"latency_ms"`
+
+	// Set for reads of decrypted model files
+	Range string `json:"range,omitempty"` // Byte range served, as in Content-Range
+	Bytes int64  `json:"bytes,omitempty"` // Response body bytes written
 }
 
 // AuditLogger defines the interface for audit log writers.
@@ -182,7 +186,7 @@ func NewSlogAuditLogger(logger *slog.Logger) *SlogAuditLogger {
 
 // Log writes the audit entry to slog.
 func (l *SlogAuditLogger) Log(entry *AuditEntry) error {
-	l.logger.Info("audit",
+	args := []any{
 		"ts", entry.Timestamp,
 		"contract_id", entry.ContractID,
 		"asset_id", entry.AssetID,
@@ -191,7 +195,11 @@ func (l *SlogAuditLogger) Log(entry *AuditEntry) error {
 		"req_sha256", entry.ReqSHA256,
 		"status", entry.Status,
 		"latency_ms", entry.LatencyMS,
-	)
+	}
+	if entry.Range != "" || entry.Bytes != 0 {
+		args = append(args, "range", entry.Range, "bytes", entry.Bytes)
+	}
+	l.logger.Info("audit", args...)
 	return nil
 }
 
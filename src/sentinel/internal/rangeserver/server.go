@@ -0,0 +1,353 @@
+// Package rangeserver serves decrypted model files to the runtime over a
+// local HTTP endpoint.
+//
+// Files are exposed at /files/<name> and support Range requests, so runtimes
+// that load weights from an HTTP or S3-style URL can fetch just the byte
+// ranges they need. Plaintext is produced on demand from the cached
+// ciphertext, one authenticated chunk at a time. The endpoint listens on
+// loopback or a Unix socket only, every request must carry the per-boot
+// bearer token, and every read is recorded through a proxy.AuditLogger.
+package rangeserver
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"trustbridge/sentinel/internal/proxy"
+)
+
+// UnixPrefix marks a listen address as a Unix socket path.
+const UnixPrefix = "unix:"
+
+// FilesPath is the URL path under which files are served.
+const FilesPath = "/files/"
+
+// tokenBytes is the length of a generated bearer token before hex encoding.
+const tokenBytes = 32
+
+// emptyBodySHA256 is recorded as the request hash; reads have no body.
+var emptyBodySHA256 = func() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}()
+
+// File is a decrypted file exposed by the server.
+type File struct {
+	Name   string      // Name from the manifest, used in the URL
+	Reader io.ReaderAt // Plaintext, typically a crypto.DecryptingReader
+	Size   int64       // Plaintext size
+}
+
+// Server is the local HTTP range server.
+type Server struct {
+	addr        string
+	files       map[string]File
+	token       string
+	auditLogger proxy.AuditLogger
+	contractID  string
+	assetID     string
+	logger      *slog.Logger
+	httpServer  *http.Server
+	listener    net.Listener
+	mu          sync.Mutex
+	started     bool
+}
+
+// ServerOption is a functional option for configuring the Server.
+type ServerOption func(*Server)
+
+// WithAddr sets the listen address: a loopback host:port, or UnixPrefix
+// followed by an absolute socket path.
+func WithAddr(addr string) ServerOption {
+	return func(s *Server) {
+		s.addr = addr
+	}
+}
+
+// WithAuditLogger sets the audit logger for file reads.
+func WithAuditLogger(logger proxy.AuditLogger) ServerOption {
+	return func(s *Server) {
+		s.auditLogger = logger
+	}
+}
+
+// WithAuditIdentity sets the contract and asset recorded in audit entries.
+func WithAuditIdentity(contractID, assetID string) ServerOption {
+	return func(s *Server) {
+		s.contractID = contractID
+		s.assetID = assetID
+	}
+}
+
+// WithLogger sets the logger for the range server.
+func WithLogger(logger *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// NewServer creates a range server for files, accepting requests that carry
+// token as a bearer token.
+func NewServer(files []File, token string, opts ...ServerOption) *Server {
+	s := &Server{
+		addr:        "127.0.0.1:8090",
+		files:       make(map[string]File, len(files)),
+		token:       token,
+		auditLogger: proxy.NewNopAuditLogger(),
+		logger:      slog.Default(),
+	}
+	for _, f := range files {
+		s.files[f.Name] = f
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(FilesPath, s.handleFile)
+
+	s.httpServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	return s
+}
+
+// NewToken generates a random bearer token.
+func NewToken() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidateAddr checks that addr is a loopback host:port with a fixed port,
+// or UnixPrefix followed by an absolute path.
+func ValidateAddr(addr string) error {
+	if path, ok := strings.CutPrefix(addr, UnixPrefix); ok {
+		if !strings.HasPrefix(path, "/") {
+			return errors.New("unix socket path must be absolute")
+		}
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address: %w", err)
+	}
+	if port == "" || port == "0" {
+		return errors.New("port must be set")
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("host must be a loopback address, got %q", host)
+	}
+	return nil
+}
+
+// BaseURL returns the URL prefix of the files served at addr. For a Unix
+// socket the host is a placeholder; clients must dial the socket.
+func BaseURL(addr string) string {
+	if strings.HasPrefix(addr, UnixPrefix) {
+		return "http://localhost"
+	}
+	return "http://" + addr
+}
+
+// FileURL returns the URL of the named file served at addr.
+func FileURL(addr, name string) string {
+	segments := strings.Split(name, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return BaseURL(addr) + FilesPath + strings.Join(segments, "/")
+}
+
+// Start listens on the configured address and serves in a goroutine.
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return fmt.Errorf("range server already started")
+	}
+
+	if err := ValidateAddr(s.addr); err != nil {
+		return err
+	}
+
+	var listener net.Listener
+	var err error
+	if path, ok := strings.CutPrefix(s.addr, UnixPrefix); ok {
+		if info, statErr := os.Lstat(path); statErr == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(path) // Stale socket from a previous boot
+		}
+		listener, err = net.Listen("unix", path)
+		if err == nil {
+			err = os.Chmod(path, 0600)
+		}
+	} else {
+		listener, err = net.Listen("tcp", s.addr)
+	}
+	if err != nil {
+		if listener != nil {
+			listener.Close()
+		}
+		return fmt.Errorf("failed to listen on %s: %w", s.addr, err)
+	}
+
+	s.listener = listener
+	s.started = true
+
+	go func() {
+		if err := s.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("Range server error", "error", err.Error())
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the listening address once started, or the configured one.
+func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener != nil && s.listener.Addr().Network() == "tcp" {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Stop gracefully shuts down the range server.
+func (s *Server) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		return nil
+	}
+
+	s.started = false
+	return s.httpServer.Shutdown(ctx)
+}
+
+// Handler returns the HTTP handler for use in testing or embedding.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// handleFile serves GET and HEAD requests for /files/<name>.
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &countingWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+	var served *File
+	defer func() {
+		s.audit(r, rec, served, start)
+	}()
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(rec, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !s.authorized(r) {
+		rec.Header().Set("WWW-Authenticate", `Bearer realm="trustbridge"`)
+		http.Error(rec, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f, ok := s.files[strings.TrimPrefix(r.URL.Path, FilesPath)]
+	if !ok {
+		http.NotFound(rec, r)
+		return
+	}
+	served = &f
+
+	// Set explicitly so ServeContent does not decrypt the start to sniff it
+	rec.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(rec, r, f.Name, time.Time{}, io.NewSectionReader(f.Reader, 0, f.Size))
+}
+
+// authorized reports whether r carries the bearer token.
+func (s *Server) authorized(r *http.Request) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || s.token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+// audit records one request with the byte range that was served.
+func (s *Server) audit(r *http.Request, rec *countingWriter, f *File, start time.Time) {
+	entry := &proxy.AuditEntry{
+		Timestamp:  start.UTC().Format(time.RFC3339),
+		ContractID: s.contractID,
+		AssetID:    s.assetID,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		ReqSHA256:  emptyBodySHA256,
+		Status:     rec.statusCode,
+		LatencyMS:  time.Since(start).Milliseconds(),
+		Bytes:      rec.bytes,
+	}
+
+	if f != nil {
+		switch {
+		case rec.Header().Get("Content-Range") != "":
+			entry.Range = rec.Header().Get("Content-Range")
+		case rec.statusCode == http.StatusPartialContent:
+			// Multiple ranges: each part carries its own Content-Range
+			entry.Range = r.Header.Get("Range") + fmt.Sprintf("/%d", f.Size)
+		case rec.statusCode == http.StatusOK && f.Size > 0:
+			entry.Range = fmt.Sprintf("bytes 0-%d/%d", f.Size-1, f.Size)
+		}
+	}
+
+	// Errors are intentionally ignored to not affect request handling
+	s.auditLogger.Log(entry)
+}
+
+// countingWriter records the status code and body bytes of a response.
+type countingWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	bytes       int64
+}
+
+func (w *countingWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.statusCode = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
@@ -0,0 +1,286 @@
+package rangeserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"trustbridge/sentinel/internal/crypto"
+	"trustbridge/sentinel/internal/proxy"
+)
+
+const testToken = "test-token"
+
+// testFile encrypts plaintext and returns a File backed by a DecryptingReader.
+func testFile(t *testing.T, name string, plaintext []byte) File {
+	t.Helper()
+	key := bytes.Repeat([]byte{0x42}, 32)
+
+	var encrypted bytes.Buffer
+	if _, err := crypto.EncryptToWriter(bytes.NewReader(plaintext), &encrypted, key, 1024); err != nil {
+		t.Fatalf("EncryptToWriter failed: %v", err)
+	}
+
+	reader, err := crypto.NewDecryptingReaderAt(bytes.NewReader(encrypted.Bytes()), key)
+	if err != nil {
+		t.Fatalf("NewDecryptingReaderAt failed: %v", err)
+	}
+	t.Cleanup(func() { reader.Close() })
+
+	return File{Name: name, Reader: reader, Size: reader.Size()}
+}
+
+func testPlaintext(n int) []byte {
+	p := make([]byte, n)
+	for i := range p {
+		p[i] = byte(i * 7)
+	}
+	return p
+}
+
+func get(s *Server, path, token string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_FullAndRangeReads(t *testing.T) {
+	plaintext := testPlaintext(5000)
+	audit := proxy.NewMemoryAuditLogger(10)
+	s := NewServer([]File{testFile(t, "model.safetensors", plaintext)}, testToken,
+		WithAuditLogger(audit),
+		WithAuditIdentity("contract-1", "asset-1"),
+	)
+
+	rec := get(s, "/files/model.safetensors", testToken, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), plaintext) {
+		t.Error("full read returned wrong plaintext")
+	}
+	if rec.Header().Get("Accept-Ranges") != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", rec.Header().Get("Accept-Ranges"))
+	}
+
+	entry := audit.LastEntry()
+	if entry.Range != "bytes 0-4999/5000" || entry.Bytes != 5000 || entry.Status != http.StatusOK {
+		t.Errorf("audit entry = %+v", entry)
+	}
+	if entry.ContractID != "contract-1" || entry.AssetID != "asset-1" || entry.Path != "/files/model.safetensors" {
+		t.Errorf("audit identity = %+v", entry)
+	}
+
+	// Range spanning a chunk boundary
+	rec = get(s, "/files/model.safetensors", testToken, map[string]string{"Range": "bytes=1000-2099"})
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("range status = %d, want 206", rec.Code)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), plaintext[1000:2100]) {
+		t.Error("range read returned wrong plaintext")
+	}
+	entry = audit.LastEntry()
+	if entry.Range != "bytes 1000-2099/5000" || entry.Bytes != 1100 {
+		t.Errorf("audit range = %q bytes = %d", entry.Range, entry.Bytes)
+	}
+
+	// Suffix range
+	rec = get(s, "/files/model.safetensors", testToken, map[string]string{"Range": "bytes=-10"})
+	if !bytes.Equal(rec.Body.Bytes(), plaintext[4990:]) {
+		t.Error("suffix range returned wrong plaintext")
+	}
+
+	// Unsatisfiable range
+	rec = get(s, "/files/model.safetensors", testToken, map[string]string{"Range": "bytes=6000-"})
+	if rec.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable range status = %d, want 416", rec.Code)
+	}
+}
+
+func TestServer_Head(t *testing.T) {
+	s := NewServer([]File{testFile(t, "model.bin", testPlaintext(3000))}, testToken)
+
+	req := httptest.NewRequest(http.MethodHead, "/files/model.bin", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HEAD status = %d, want 200", rec.Code)
+	}
+	if rec.Header().Get("Content-Length") != "3000" {
+		t.Errorf("Content-Length = %q, want 3000", rec.Header().Get("Content-Length"))
+	}
+	if rec.Body.Len() != 0 {
+		t.Error("HEAD returned a body")
+	}
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	audit := proxy.NewMemoryAuditLogger(10)
+	s := NewServer([]File{testFile(t, "model.bin", testPlaintext(100))}, testToken, WithAuditLogger(audit))
+
+	for _, token := range []string{"", "wrong-token"} {
+		rec := get(s, "/files/model.bin", token, nil)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, rec.Code)
+		}
+		if rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("token %q: missing WWW-Authenticate", token)
+		}
+		if entry := audit.LastEntry(); entry.Status != http.StatusUnauthorized || entry.Range != "" {
+			t.Errorf("token %q: audit entry = %+v", token, entry)
+		}
+	}
+}
+
+func TestServer_NotFoundAndMethod(t *testing.T) {
+	s := NewServer([]File{testFile(t, "dir/model.bin", testPlaintext(100))}, testToken)
+
+	if rec := get(s, "/files/dir/model.bin", testToken, nil); rec.Code != http.StatusOK {
+		t.Errorf("nested name status = %d, want 200", rec.Code)
+	}
+	if rec := get(s, "/files/missing.bin", testToken, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing file status = %d, want 404", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/files/dir/model.bin", nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT status = %d, want 405", rec.Code)
+	}
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	plaintext := testPlaintext(2048)
+	socketPath := filepath.Join(t.TempDir(), "range.sock")
+	s := NewServer([]File{testFile(t, "model.bin", plaintext)}, testToken, WithAddr(UnixPrefix+socketPath))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}}
+
+	req, _ := http.NewRequest(http.MethodGet, FileURL(UnixPrefix+socketPath, "model.bin"), nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("Range", "bytes=100-199")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || !bytes.Equal(body, plaintext[100:200]) {
+		t.Errorf("status = %d, body matches = %t", resp.StatusCode, bytes.Equal(body, plaintext[100:200]))
+	}
+}
+
+func TestServer_Loopback(t *testing.T) {
+	plaintext := testPlaintext(100)
+
+	// Reserve a free port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	s := NewServer([]File{testFile(t, "model.bin", plaintext)}, testToken, WithAddr(addr))
+	if err := s.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer s.Stop(context.Background())
+
+	req, _ := http.NewRequest(http.MethodGet, FileURL(s.Addr(), "model.bin"), nil)
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(body, plaintext) {
+		t.Error("loopback read returned wrong plaintext")
+	}
+}
+
+func TestValidateAddr(t *testing.T) {
+	tests := []struct {
+		addr  string
+		valid bool
+	}{
+		{"127.0.0.1:8090", true},
+		{"localhost:8090", true},
+		{"[::1]:8090", true},
+		{"unix:/dev/shm/range.sock", true},
+		{"0.0.0.0:8090", false},
+		{"10.0.0.5:8090", false},
+		{"127.0.0.1:0", false},
+		{"127.0.0.1", false},
+		{"unix:range.sock", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateAddr(tt.addr)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateAddr(%q) = %v, want nil", tt.addr, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("ValidateAddr(%q) = nil, want error", tt.addr)
+		}
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	tests := []struct {
+		addr, name, want string
+	}{
+		{"127.0.0.1:8090", "model.safetensors", "http://127.0.0.1:8090/files/model.safetensors"},
+		{"127.0.0.1:8090", "sub dir/model #1.bin", "http://127.0.0.1:8090/files/sub%20dir/model%20%231.bin"},
+		{"unix:/dev/shm/range.sock", "config.json", "http://localhost/files/config.json"},
+	}
+
+	for _, tt := range tests {
+		if got := FileURL(tt.addr, tt.name); got != tt.want {
+			t.Errorf("FileURL(%q, %q) = %q, want %q", tt.addr, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken failed: %v", err)
+	}
+	b, _ := NewToken()
+	if len(a) != 2*tokenBytes || a == b {
+		t.Errorf("tokens %q, %q: want distinct %d-char values", a, b, 2*tokenBytes)
+	}
+	if _, err := fmt.Sscanf(a, "%x", new([]byte)); err != nil {
+		t.Errorf("token %q is not hex: %v", a, err)
+	}
+}